@@ -20,7 +20,10 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"time"
 	"tryffel.net/go/jellycli/config"
 	"tryffel.net/go/jellycli/interfaces"
 	"tryffel.net/go/jellycli/models"
@@ -35,57 +38,63 @@ type MediaServer interface {
 }
 
 // Streamer contains methods for streaming audio from remote location.
+// Transfer is cancelled once context is done.
 type Streamer interface {
 
 	// Stream streams song. If server does not implement separate streaming endpoint,
 	// implementcation can wrap Download.
-	Stream(Song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error)
+	Stream(ctx context.Context, Song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error)
+
+	// StreamFile streams song like Stream, but nothing is buffered in background and reading blocks
+	// until data is available, so that caller decides how much of the song is held in memory.
+	StreamFile(ctx context.Context, Song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error)
 
 	// Download downloads original audio file.
-	Download(Song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error)
+	Download(ctx context.Context, Song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error)
 }
 
 // Browser implements item-based viewing for music artists,albums,playlists etc.
+// Requests are cancelled once context is done.
 type Browser interface {
 
 	// GetArtists returns all artists
-	GetArtists(query *interfaces.QueryOpts) ([]*models.Artist, int, error)
+	GetArtists(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Artist, int, error)
 
 	// GetAlbumArtists returns artists that are marked as album artists. See GetArtists.
-	GetAlbumArtists(query *interfaces.QueryOpts) ([]*models.Artist, int, error)
+	GetAlbumArtists(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Artist, int, error)
 	// GetAlbums gets albums with given paging. Only PageSize and CurrentPage are used. Total count is returned
-	GetAlbums(query *interfaces.QueryOpts) ([]*models.Album, int, error)
+	GetAlbums(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Album, int, error)
 
 	// GetArtistAlbums returns albums that artist takes part in.
-	GetArtistAlbums(artist models.Id) ([]*models.Album, error)
+	GetArtistAlbums(ctx context.Context, artist models.Id) ([]*models.Album, error)
 
 	// GetAlbumSongs returns songs for given album id.
-	GetAlbumSongs(album models.Id) ([]*models.Song, error)
+	GetAlbumSongs(ctx context.Context, album models.Id) ([]*models.Song, error)
 	// GetPlaylists returns all playlists.
-	GetPlaylists() ([]*models.Playlist, error)
+	GetPlaylists(ctx context.Context) ([]*models.Playlist, error)
 	// GetPlaylistSongs fills songs array for playlist. If there's error, songs will not be filled
-	GetPlaylistSongs(playlist models.Id) ([]*models.Song, error)
+	GetPlaylistSongs(ctx context.Context, playlist models.Id) ([]*models.Song, error)
 
 	// GetSimilarArtists returns similar artists for artist id
-	GetSimilarArtists(artist models.Id) ([]*models.Artist, error)
+	GetSimilarArtists(ctx context.Context, artist models.Id) ([]*models.Artist, error)
 
 	// GetsimilarAlbums returns list of similar albums.
-	GetSimilarAlbums(album models.Id) ([]*models.Album, error)
+	GetSimilarAlbums(ctx context.Context, album models.Id) ([]*models.Album, error)
 
 	// GetRecentlyPlayed returns songs that have been played last.
-	GetRecentlyPlayed(paging interfaces.Paging) ([]*models.Song, int, error)
+	GetRecentlyPlayed(ctx context.Context, paging interfaces.Paging) ([]*models.Song, int, error)
 
 	// GetSongs returns songs by paging. It also returns total number of songs.
-	GetSongs(query *interfaces.QueryOpts) ([]*models.Song, int, error)
+	GetSongs(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Song, int, error)
 
 	// GetGenres returns music genres with paging. Return genres, total genres and possible error
-	GetGenres(paging interfaces.Paging) ([]*models.IdName, int, error)
+	GetGenres(ctx context.Context, paging interfaces.Paging) ([]*models.IdName, int, error)
 
 	// GetAlbumArtist returns main artist for album.
-	GetAlbumArtist(album *models.Album) (*models.Artist, error)
+	GetAlbumArtist(ctx context.Context, album *models.Album) (*models.Artist, error)
 
 	// GetInstantMix returns instant mix based on given item.
-	GetInstantMix(item models.Item) ([]*models.Song, error)
+	GetInstantMix(ctx context.Context, item models.Item) ([]*models.Song, error)
 
 	// GetLink returns a link to item that can be opened with browser.
 	// If there is no link or item is invalid, empty link is returned.
@@ -93,11 +102,11 @@ type Browser interface {
 
 	// Search returns values matching query and itemType, limited by number of maxResults,
 	// Only items of itemType should ne returned.
-	Search(query string, itemType models.ItemType, maxResults int) ([]models.Item, error)
+	Search(ctx context.Context, query string, itemType models.ItemType, maxResults int) ([]models.Item, error)
 
-	GetAlbum(id models.Id) (*models.Album, error)
+	GetAlbum(ctx context.Context, id models.Id) (*models.Album, error)
 
-	GetArtist(id models.Id) (*models.Artist, error)
+	GetArtist(ctx context.Context, id models.Id) (*models.Artist, error)
 
 	GetImageUrl(item models.Id, itemType models.ItemType) string
 }
@@ -114,6 +123,77 @@ type RemoteController interface {
 	RemoteControlEnabled() error
 }
 
+// LibraryNotifier is implemented by servers that push changes in library.
+type LibraryNotifier interface {
+	// AddLibraryChangedCallback adds a function that is called every time server reports changes in library.
+	AddLibraryChangedCallback(func(changes *models.LibraryChanges))
+}
+
+// RemoteControlNotifier is implemented by servers that keep a persistent connection for remote control.
+type RemoteControlNotifier interface {
+	// GetRemoteControlStatus returns current state of remote control connection.
+	GetRemoteControlStatus() models.RemoteControlStatus
+
+	// AddRemoteControlCallback adds a function that is called every time remote control connection state changes.
+	AddRemoteControlCallback(func(status models.RemoteControlStatus))
+}
+
+// MessageNotifier is implemented by servers that can send messages to be displayed to user.
+type MessageNotifier interface {
+	// AddMessageCallback adds a function that is called every time server sends a message.
+	AddMessageCallback(func(msg models.ServerMessage))
+}
+
+// RequestMetrics is implemented by servers that collect metrics of their requests.
+type RequestMetrics interface {
+	// GetRequestStats returns metrics of requests made so far.
+	GetRequestStats() models.RequestStats
+}
+
+// CacheMetrics is implemented by servers that cache metadata.
+type CacheMetrics interface {
+	// GetCacheStats returns metrics of metadata cache.
+	GetCacheStats() models.CacheStats
+}
+
+// AlbumBatcher is implemented by servers that can fetch several albums with a single request.
+type AlbumBatcher interface {
+	// GetAlbumsById returns albums for ids. Albums do not contain songs.
+	GetAlbumsById(ctx context.Context, ids []models.Id) ([]*models.Album, error)
+}
+
+// SyncPlay is implemented by servers that allow listening together in groups.
+type SyncPlay interface {
+	// GetSyncPlayGroups returns groups that user can join.
+	GetSyncPlayGroups() ([]*models.SyncPlayGroup, error)
+
+	// CreateSyncPlayGroup creates new group and joins it.
+	CreateSyncPlayGroup(name string) error
+
+	// JoinSyncPlayGroup joins existing group.
+	JoinSyncPlayGroup(group models.Id) error
+
+	// LeaveSyncPlayGroup leaves current group.
+	LeaveSyncPlayGroup() error
+
+	// SyncPlayRequest sends request to group. Server responds to all group members with
+	// a command or queue update.
+	SyncPlayRequest(request *models.SyncPlayRequest) error
+
+	// GetServerTime returns server time when it received request and when it sent response.
+	GetServerTime() (received, sent time.Time, err error)
+
+	// SetSyncPlayHandler sets handler that receives group updates and commands.
+	SetSyncPlayHandler(handler SyncPlayHandler)
+}
+
+// SyncPlayHandler receives updates and commands from SyncPlay group.
+type SyncPlayHandler interface {
+	OnSyncPlayGroup(update *models.SyncPlayGroupUpdate)
+	OnSyncPlayQueue(queue *models.SyncPlayQueue)
+	OnSyncPlayCommand(command *models.SyncPlayCommand)
+}
+
 // RemoteServer contains general methods for getting server connection status
 type RemoteServer interface {
 	// GetInfo returns general info
@@ -139,6 +219,27 @@ type RemoteServer interface {
 	GetId() string
 }
 
+// Replayer is implemented by servers that can send operations from outbox.
+type Replayer interface {
+	// Replay sends operation that previously failed to server.
+	Replay(op *models.Operation) error
+}
+
+// OperationError is returned when write operation to server failed and operation
+// should be stored to outbox and replayed later with Replayer.
+type OperationError struct {
+	Operation *models.Operation
+	Err       error
+}
+
+func (o *OperationError) Error() string {
+	return fmt.Sprintf("%s %s: %v", o.Operation.Type, o.Operation.Item, o.Err)
+}
+
+func (o *OperationError) Unwrap() error {
+	return o.Err
+}
+
 // Cacher describes how data may be pulled from remote server
 // and might override some Browser methods.
 type Cacher interface {
@@ -147,4 +248,8 @@ type Cacher interface {
 	// Else, player tries to pull songs directly using Browser.GetAlbumSongs.
 	// GetAlbumSongs is slower method (when album contains less songs then paged song list).
 	CanCacheSongs() bool
+
+	// CanFilterUserData returns true if Filter.UserDataChangedSince is implemented.
+	// Else, changed favorites and play counts are only pulled with full refresh.
+	CanFilterUserData() bool
 }
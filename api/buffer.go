@@ -20,6 +20,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
@@ -37,11 +38,11 @@ func MimeToAudioFormat(mimeType string) (format interfaces.AudioFormat, err erro
 	switch mimeType {
 	case "audio/mpeg":
 		format = interfaces.AudioFormatMp3
-	case "audio/flac":
+	case "audio/flac", "audio/x-flac":
 		format = interfaces.AudioFormatFlac
 	case "audio/ogg":
 		format = interfaces.AudioFormatOgg
-	case "audio/wav":
+	case "audio/wav", "audio/x-wav", "audio/wave":
 		format = interfaces.AudioFormatWav
 
 	default:
@@ -82,6 +83,14 @@ func (s *StreamBuffer) Len() int {
 	return s.buff.Len()
 }
 
+// Size returns stream size in bytes, or -1 if server did not tell size.
+func (s *StreamBuffer) Size() int64 {
+	if s.resp == nil {
+		return -1
+	}
+	return s.resp.ContentLength
+}
+
 func (s *StreamBuffer) SecondsBuffered() int {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -96,7 +105,7 @@ func (s *StreamBuffer) AudioFormat() (format interfaces.AudioFormat, err error)
 	return interfaces.AudioFormatNil, errors.New("no http response")
 }
 
-func NewStreamDownload(url string, headers map[string]string, params map[string]string,
+func NewStreamDownload(ctx context.Context, url string, headers map[string]string, params map[string]string,
 	client *http.Client, duration int) (*StreamBuffer, error) {
 	stream := &StreamBuffer{
 		lock:           &sync.Mutex{},
@@ -113,7 +122,7 @@ func NewStreamDownload(url string, headers map[string]string, params map[string]
 	stream.client = client
 
 	var err error
-	stream.req, err = http.NewRequest(http.MethodGet, url, nil)
+	stream.req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return stream, fmt.Errorf("init http request: %v", err)
 	}
@@ -0,0 +1,84 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"tryffel.net/go/jellycli/interfaces"
+)
+
+// FileDownload is a plain http download of a whole file. Unlike StreamBuffer, it does not buffer
+// anything in the background and reading blocks until there is data available or the body has been read.
+type FileDownload struct {
+	resp *http.Response
+}
+
+func (f *FileDownload) Read(p []byte) (n int, err error) {
+	return f.resp.Body.Read(p)
+}
+
+func (f *FileDownload) Close() error {
+	return f.resp.Body.Close()
+}
+
+// Size returns file size in bytes or -1, if server did not tell size.
+func (f *FileDownload) Size() int64 {
+	return f.resp.ContentLength
+}
+
+func (f *FileDownload) AudioFormat() (interfaces.AudioFormat, error) {
+	return MimeToAudioFormat(f.resp.Header.Get("Content-Type"))
+}
+
+// NewFileDownload starts a new download. Download is cancelled once ctx is done.
+func NewFileDownload(ctx context.Context, url string, headers map[string]string, params map[string]string,
+	client *http.Client) (*FileDownload, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("init http request: %v", err)
+	}
+
+	for k, v := range headers {
+		req.Header.Add(k, v)
+	}
+
+	if params != nil {
+		q := req.URL.Query()
+		for i, v := range params {
+			q.Add(i, v)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("make http request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("http request error, statuscode: %d", resp.StatusCode)
+	}
+	return &FileDownload{resp: resp}, nil
+}
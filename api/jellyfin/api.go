@@ -21,6 +21,7 @@
 package jellyfin
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -29,17 +30,18 @@ import (
 	"github.com/sirupsen/logrus"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"tryffel.net/go/jellycli/api"
 	"tryffel.net/go/jellycli/config"
 	"tryffel.net/go/jellycli/interfaces"
 	"tryffel.net/go/jellycli/models"
 	"tryffel.net/go/jellycli/task"
-	"tryffel.net/go/jellycli/util"
 )
 
 type socketState int
@@ -59,7 +61,6 @@ type Jellyfin struct {
 	userId    string
 	serverId  string
 	DeviceId  string
-	SessionId string
 	client    *http.Client
 	loggedIn  bool
 	musicView string
@@ -67,9 +68,32 @@ type Jellyfin struct {
 	player interfaces.Player
 	queue  interfaces.QueueController
 
+	statusLock   sync.RWMutex
+	playerStatus interfaces.AudioStatus
+
+	playbackLock        sync.Mutex
+	playback            *playback
+	streams             []stream
+	playlistItems       []queueItem
+	playlistItemCounter int
+
 	socketLock  sync.RWMutex
 	socket      *websocket.Conn
 	socketState socketState
+	// keepAlive is timeout that server expects keepalive messages within, 0 if not requested
+	keepAlive     time.Duration
+	lastMessage   time.Time
+	lastKeepAlive time.Time
+
+	requests requestLimiter
+	flights  flightGroup
+
+	callbackLock     sync.RWMutex
+	libraryChangedCb []func(changes *models.LibraryChanges)
+	syncPlayHandler  api.SyncPlayHandler
+	remoteControlCb  []func(status models.RemoteControlStatus)
+	remoteStatus     models.RemoteControlStatus
+	messageCb        []func(msg models.ServerMessage)
 
 	remoteControlEnabled bool
 }
@@ -155,7 +179,6 @@ func NewJellyfin(conf *config.Jellyfin, provider config.KeyValueProvider) (*Jell
 		return jf, fmt.Errorf("failed to get unique host id: %v", err)
 	}
 	jf.DeviceId = id
-	jf.SessionId = util.RandomKey(15)
 	jf.Name = "api"
 	jf.SetLoop(jf.loop)
 
@@ -223,18 +246,48 @@ func NewJellyfin(conf *config.Jellyfin, provider config.KeyValueProvider) (*Jell
 		return jf, err
 	}
 
-	return jf, err
+	if file := jf.cacheFile(); file != "" {
+		err = jf.cache.Load(file)
+		if err != nil {
+			logrus.Warningf("load metadata cache: %v", err)
+		} else {
+			logrus.Debugf("Loaded %d items to metadata cache", jf.cache.Count())
+		}
+	}
+	return jf, nil
 }
 
 func (jf *Jellyfin) SetPlayer(p interfaces.Player) {
 	jf.remoteControlEnabled = true
+	if jf.player != p && p != nil {
+		p.AddStatusCallback(jf.playerStatusChanged)
+	}
 	jf.player = p
 }
 
+// keep track of player status to handle relative commands, e.g. volume up.
+func (jf *Jellyfin) playerStatusChanged(status interfaces.AudioStatus) {
+	jf.statusLock.Lock()
+	jf.playerStatus = status
+	jf.statusLock.Unlock()
+}
+
+func (jf *Jellyfin) getPlayerStatus() interfaces.AudioStatus {
+	jf.statusLock.RLock()
+	defer jf.statusLock.RUnlock()
+	return jf.playerStatus
+}
+
 func (jf *Jellyfin) SetQueue(q interfaces.QueueController) {
 	jf.queue = q
 }
 
+func (jf *Jellyfin) AddLibraryChangedCallback(cb func(changes *models.LibraryChanges)) {
+	jf.callbackLock.Lock()
+	defer jf.callbackLock.Unlock()
+	jf.libraryChangedCb = append(jf.libraryChangedCb, cb)
+}
+
 func (jf *Jellyfin) ConnectionOk() error {
 	info, err := jf.getserverInfo()
 	if err != nil {
@@ -260,7 +313,7 @@ func (jf *Jellyfin) TokenOk() error {
 	}
 
 	// check token validity
-	body, err := jf.get("/System/Info", nil)
+	body, err := jf.get(context.Background(), "/System/Info", nil)
 	if body != nil {
 		defer body.Close()
 	}
@@ -294,7 +347,7 @@ func (jf *Jellyfin) selectDefaultMusicView(provider config.KeyValueProvider) err
 	if jf.musicView != "" {
 		return nil
 	}
-	views, err := jf.GetViews()
+	views, err := jf.GetViews(context.Background())
 	if err != nil {
 		return fmt.Errorf("get user views: %v", err)
 	}
@@ -334,7 +387,7 @@ func (jf *Jellyfin) selectDefaultMusicView(provider config.KeyValueProvider) err
 }
 
 func (jf *Jellyfin) ping() error {
-	body, err := jf.get("/System/Info/Public", nil)
+	body, err := jf.get(context.Background(), "/System/Info/Public", nil)
 	if err != nil {
 		return err
 	}
@@ -389,77 +442,77 @@ func (jf *Jellyfin) Start() error {
 	return jf.Task.Start()
 }
 
-func (jf *Jellyfin) loop() {
-	if jf.socket == nil {
-		return
+// Stop stops background tasks and saves metadata cache to disk.
+func (jf *Jellyfin) Stop() error {
+	if file := jf.cacheFile(); file != "" {
+		err := jf.cache.Save(file)
+		if err != nil {
+			logrus.Errorf("save metadata cache: %v", err)
+		}
 	}
+	return jf.Task.Stop()
+}
 
+func (jf *Jellyfin) loop() {
 	pingTicker := time.NewTicker(pingPeriod)
+	defer pingTicker.Stop()
+	cacheTicker := time.NewTicker(cacheRevalidateInterval)
+	defer cacheTicker.Stop()
 
 	// how often to check socket state
-	socketTimer := time.NewTimer(time.Second * 2)
-	// backoff for reconnecting socket
-	socketBackOff := time.Second
+	socketTimer := time.NewTimer(socketCheckInterval)
+	defer socketTimer.Stop()
+	// failed reconnection attempts
+	attempts := 0
 
-	go jf.readMessage()
-	for true {
+	if jf.WebsocketOk() {
+		jf.setRemoteControlStatus(models.RemoteControlStatus{State: models.RemoteControlConnected})
+		go jf.readMessage()
+	}
+	for {
 		select {
 		case <-jf.StopChan():
-			break
+			jf.closeSocket()
+			return
 		case <-pingTicker.C:
-			logrus.Tracef("Websocket send ping")
-			timeout := time.Now().Add(time.Second * 15)
-			jf.socketLock.Lock()
-			if jf.socketState == socketConnected {
-				err := jf.socket.SetWriteDeadline(timeout)
-				if err != nil {
-					logrus.Errorf("set socket write deadline: %v", err)
-					jf.handleSocketError(err)
-				}
-				err = jf.socket.WriteControl(websocket.PingMessage, []byte{}, timeout)
-				if err != nil {
-					logrus.Errorf("send ping to socket: %v", err)
-					jf.handleSocketError(err)
-				}
-			}
-			jf.socketLock.Unlock()
+			jf.sendPing()
+		case <-cacheTicker.C:
+			go jf.revalidateCache()
 		// keep websocket connected if possible
 		case <-socketTimer.C:
 			jf.socketLock.RLock()
 			state := jf.socketState
 			jf.socketLock.RUnlock()
-			if state == socketConnected {
-				// no worries
-				socketTimer.Reset(time.Second * 2)
-				socketBackOff = time.Second * 2
-			} else if state == socketReConnecting {
-				// await
+			switch state {
+			case socketConnected:
+				jf.keepAliveSocket()
+				socketTimer.Reset(socketCheckInterval)
+			case socketReConnecting:
 				socketTimer.Reset(time.Second)
 				logrus.Debug("websocket reconnect ongoing")
-			} else if state == socketAwaitsReconnecting || state == socketDisconnected {
-				// start reconnection
-
-				ok := jf.reconnectSocket()
-				if ok {
-					socketTimer.Reset(time.Second)
-					socketBackOff = 0
+			case socketAwaitsReconnecting, socketDisconnected:
+				err := jf.reconnectSocket()
+				if err == nil {
+					attempts = 0
+					jf.setRemoteControlStatus(models.RemoteControlStatus{State: models.RemoteControlConnected})
 					go jf.readMessage()
+					go jf.socketReconnected()
+					socketTimer.Reset(socketCheckInterval)
 				} else {
-					socketBackOff *= 2
-					if socketBackOff > time.Second*30 {
-						socketBackOff = time.Second * 30
-					}
-					socketTimer.Reset(socketBackOff)
-					logrus.Debugf("websocket reconnection failed, retry after %s", socketBackOff.String())
+					delay := reconnectDelay(attempts, rand.Float64())
+					attempts += 1
+					jf.setRemoteControlStatus(models.RemoteControlStatus{
+						State:   models.RemoteControlReconnecting,
+						Attempt: attempts,
+						RetryAt: time.Now().Add(delay),
+						Error:   err.Error(),
+					})
+					socketTimer.Reset(delay)
+					logrus.Debugf("websocket reconnection failed, retry after %s", delay.String())
 				}
 			}
 		}
 	}
-
-	err := jf.socket.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-	if err != nil {
-		logrus.Errorf("close websocket: %v", err)
-	}
 }
 
 func getBodyMsg(body io.ReadCloser) string {
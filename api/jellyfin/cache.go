@@ -19,59 +19,170 @@
 package jellyfin
 
 import (
+	"container/list"
+	"context"
+	"encoding/json"
 	"fmt"
-	"github.com/patrickmn/go-cache"
 	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
 	"time"
 	"tryffel.net/go/jellycli/config"
 	"tryffel.net/go/jellycli/models"
 )
 
+const (
+	// default maximum number of items in cache
+	defaultCacheItems = 5000
+	// items older than this are not loaded from disk
+	cacheMaxAge = time.Hour * 24 * 30
+	cacheFileVersion = 1
+)
+
+// cacheTTL is how long items of each type are fresh. Other items and lists use config.CacheTimeout.
+var cacheTTL = map[models.ItemType]time.Duration{
+	models.TypeArtist:   time.Hour * 6,
+	models.TypeAlbum:    time.Hour * 6,
+	models.TypeSong:     time.Hour * 6,
+	models.TypePlaylist: time.Minute * 10,
+}
+
+func ttlFor(itemType models.ItemType) time.Duration {
+	if ttl, ok := cacheTTL[itemType]; ok {
+		return ttl
+	}
+	return config.CacheTimeout
+}
+
+// cacheEntry is either an item or a list of ids.
+type cacheEntry struct {
+	key  string
+	item models.Item
+	list []models.Id
+	// when item was fetched or last revalidated
+	stored time.Time
+	// zero if item never expires
+	expires time.Time
+}
+
+// Cache is a size-bounded in-memory cache of items. When cache is full, least recently used items are removed.
+// Expired items are still returned, but they are queued for revalidation, see Jellyfin.revalidateCache.
+// Items can be saved to disk and loaded on next start, in which case all of them need revalidation.
 type Cache struct {
-	cache *cache.Cache
+	lock     sync.Mutex
+	maxItems int
+	entries  map[string]*list.Element
+	lru      *list.List
+	// stale items waiting for revalidation and time they were stored
+	stale map[models.Id]time.Time
+	stats models.CacheStats
 }
 
 //NewCache creates new cache that's ready to use.
 func NewCache() (*Cache, error) {
-	c := &Cache{}
-	c.cache = cache.New(config.CacheTimeout, config.CacheTimeout*2)
+	c := &Cache{
+		maxItems: defaultCacheItems,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		stale:    map[models.Id]time.Time{},
+	}
+	if config.AppConfig != nil && config.AppConfig.Player.MetadataCacheItems > 0 {
+		c.maxItems = config.AppConfig.Player.MetadataCacheItems
+	}
 	return c, nil
 }
 
 //Count returns total count of stored items
 func (c *Cache) Count() int {
-	return c.cache.ItemCount()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
 }
 
-//Put puts single item. If expire is true, item expires after default expiration
+//Put puts single item. If expire is true, item expires after ttl of its type.
 func (c *Cache) Put(id models.Id, item models.Item, expire bool) {
-	var timeout time.Duration
+	var expires time.Time
+	now := time.Now()
 	if expire {
-		timeout = config.CacheTimeout
+		expires = now.Add(ttlFor(item.GetType()))
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.set(&cacheEntry{key: string(id), item: item, stored: now, expires: expires})
+	delete(c.stale, id)
+}
+
+// set stores entry as most recently used and evicts least recently used entries if cache is full.
+// Caller must hold lock.
+func (c *Cache) set(entry *cacheEntry) {
+	if element, ok := c.entries[entry.key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
 	} else {
-		timeout = cache.NoExpiration
+		c.entries[entry.key] = c.lru.PushFront(entry)
+	}
+	for c.lru.Len() > c.maxItems {
+		oldest := c.lru.Back()
+		c.remove(oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
 	}
-	c.cache.Set(string(id), item, timeout)
 }
 
-//Get gets single item fro cache. Returns item and flag whether item is found
-func (c *Cache) Get(id models.Id) (models.Item, bool) {
-	data, found := c.cache.Get(string(id))
-	if !found {
-		return nil, false
+// remove entry. Caller must hold lock.
+func (c *Cache) remove(key string) {
+	if element, ok := c.entries[key]; ok {
+		c.lru.Remove(element)
+		delete(c.entries, key)
 	}
-	item, ok := data.(models.Item)
+	delete(c.stale, models.Id(key))
+}
+
+// get returns entry and marks it as most recently used. Caller must hold lock.
+func (c *Cache) get(key string) *cacheEntry {
+	element, ok := c.entries[key]
 	if !ok {
-		logrus.Errorf("Cached item not models.Item: %v", data)
-		c.Delete(id)
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	return element.Value.(*cacheEntry)
+}
+
+//Get gets single item from cache. Returns item and flag whether item is found.
+//Expired item is returned and queued for revalidation.
+func (c *Cache) Get(id models.Id) (models.Item, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry := c.get(string(id))
+	if entry == nil || entry.item == nil {
+		c.stats.Misses++
 		return nil, false
 	}
-	return item, true
+	c.stats.Hits++
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		if _, ok := c.stale[id]; !ok {
+			c.stale[id] = entry.stored
+			c.stats.StaleHits++
+		}
+	}
+	return entry.item, true
 }
 
-//Delete deletes item with given id. If item is not found, do nothing.
+//Delete deletes item or list.
 func (c *Cache) Delete(id models.Id) {
-	c.cache.Delete(string(id))
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.remove(string(id))
+}
+
+//Flush deletes all items and lists.
+func (c *Cache) Flush() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+	c.stale = map[models.Id]time.Time{}
 }
 
 //PutBatch put's multiple items with expiration. Each item must have a valid id
@@ -113,21 +224,28 @@ func (c *Cache) GetBatch(ids []models.Id) ([]models.Item, bool) {
 
 //PutList puts a list of ids under key
 func (c *Cache) PutList(id string, data []models.Id) {
-	c.cache.Set(id, data, config.CacheTimeout)
+	now := time.Now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.set(&cacheEntry{key: id, list: data, stored: now, expires: now.Add(config.CacheTimeout)})
 }
 
-//GetList gets list of Ids with given id
+//GetList gets list of Ids with given id. Lists cannot be revalidated, so expired lists are not returned.
 func (c *Cache) GetList(id string) ([]models.Id, bool) {
-	items, found := c.cache.Get(id)
-	if !found {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry := c.get(id)
+	if entry == nil || entry.list == nil {
+		c.stats.Misses++
 		return nil, false
 	}
-	ids, ok := items.([]models.Id)
-	if !ok {
-		c.Delete(models.Id(id))
+	if time.Now().After(entry.expires) {
+		c.remove(id)
+		c.stats.Misses++
 		return nil, false
 	}
-	return ids, true
+	c.stats.Hits++
+	return entry.list, true
 }
 
 func (c *Cache) GetArtist(id models.Id) *models.Artist {
@@ -173,3 +291,241 @@ func (c *Cache) GetPlaylist(id models.Id) *models.Playlist {
 	}
 	return nil
 }
+
+// takeStale returns at most max stale items waiting for revalidation and the time they were stored.
+// Returned items are no longer waiting. If they are not revalidated, they are queued again on next Get.
+func (c *Cache) takeStale(max int) map[models.Id]time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stale := map[models.Id]time.Time{}
+	for id, stored := range c.stale {
+		if len(stale) >= max {
+			break
+		}
+		stale[id] = stored
+		delete(c.stale, id)
+	}
+	return stale
+}
+
+// revalidated compares items to their current state on server. Items that have been saved after they were
+// stored, have different user data or are missing from server are removed and the rest are renewed.
+func (c *Cache) revalidated(ids []models.Id, current map[models.Id]savedItem) {
+	now := time.Now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, id := range ids {
+		element, ok := c.entries[string(id)]
+		if !ok {
+			continue
+		}
+		entry := element.Value.(*cacheEntry)
+		saved, found := current[id]
+		if !found || saved.DateLastSaved.After(entry.stored) || userDataModified(entry.item, saved.UserData) {
+			c.remove(string(id))
+			c.stats.Invalidated++
+			continue
+		}
+		entry.stored = now
+		entry.expires = now.Add(ttlFor(entry.item.GetType()))
+		c.stats.Revalidated++
+	}
+}
+
+// GetStats returns cache metrics.
+func (c *Cache) GetStats() models.CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.Items = c.lru.Len()
+	stats.MaxItems = c.maxItems
+	return stats
+}
+
+// cacheFile is the format of cache on disk.
+type cacheFile struct {
+	Version int
+	// entries from most recently used to least recently used
+	Entries []cacheFileEntry
+}
+
+type cacheFileEntry struct {
+	Type     models.ItemType
+	Stored   time.Time
+	Artist   *models.Artist   `json:",omitempty"`
+	Album    *models.Album    `json:",omitempty"`
+	Song     *models.Song     `json:",omitempty"`
+	Playlist *models.Playlist `json:",omitempty"`
+}
+
+// Save writes cached items to file. Lists are not saved.
+func (c *Cache) Save(file string) error {
+	data := cacheFile{Version: cacheFileVersion}
+	c.lock.Lock()
+	for element := c.lru.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*cacheEntry)
+		fileEntry := cacheFileEntry{Stored: entry.stored}
+		switch item := entry.item.(type) {
+		case *models.Artist:
+			fileEntry.Artist = item
+		case *models.Album:
+			fileEntry.Album = item
+		case *models.Song:
+			fileEntry.Song = item
+		case *models.Playlist:
+			fileEntry.Playlist = item
+		default:
+			continue
+		}
+		fileEntry.Type = entry.item.GetType()
+		data.Entries = append(data.Entries, fileEntry)
+	}
+	c.lock.Unlock()
+
+	err := os.MkdirAll(path.Dir(file), 0760)
+	if err != nil {
+		return fmt.Errorf("create cache directory: %v", err)
+	}
+	bytes, err := json.Marshal(&data)
+	if err != nil {
+		return fmt.Errorf("encode cache: %v", err)
+	}
+	tmpFile := file + ".tmp"
+	err = ioutil.WriteFile(tmpFile, bytes, 0640)
+	if err != nil {
+		return fmt.Errorf("write cache: %v", err)
+	}
+	return os.Rename(tmpFile, file)
+}
+
+// Load reads items from file written with Save. Loaded items are stale, so they are revalidated
+// once they are used. Items older than cacheMaxAge are ignored. If file does not exist, cache is left empty.
+func (c *Cache) Load(file string) error {
+	bytes, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read cache: %v", err)
+	}
+	data := cacheFile{}
+	err = json.Unmarshal(bytes, &data)
+	if err != nil {
+		return fmt.Errorf("decode cache: %v", err)
+	}
+	if data.Version != cacheFileVersion {
+		logrus.Infof("Ignore metadata cache of version %d", data.Version)
+		return nil
+	}
+
+	now := time.Now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// insert least recently used first to keep order
+	for i := len(data.Entries) - 1; i >= 0; i-- {
+		v := data.Entries[i]
+		if now.Sub(v.Stored) > cacheMaxAge {
+			continue
+		}
+		var item models.Item
+		switch {
+		case v.Artist != nil:
+			item = v.Artist
+		case v.Album != nil:
+			item = v.Album
+		case v.Song != nil:
+			item = v.Song
+		case v.Playlist != nil:
+			item = v.Playlist
+		default:
+			continue
+		}
+		if _, ok := c.entries[string(item.GetId())]; ok {
+			continue
+		}
+		c.set(&cacheEntry{key: string(item.GetId()), item: item, stored: v.Stored, expires: now})
+	}
+	return nil
+}
+
+const (
+	// how often to revalidate stale items
+	cacheRevalidateInterval = time.Second * 5
+	// max items to revalidate with single request
+	maxRevalidateItems = maxBatchIds
+)
+
+// savedItem is the state of item on server, used for revalidating cached items.
+type savedItem struct {
+	Id            string    `json:"Id"`
+	DateLastSaved time.Time `json:"DateLastSaved"`
+	UserData      userData  `json:"UserData"`
+}
+
+// userDataModified returns true if user data of cached item differs from server.
+func userDataModified(item models.Item, data userData) bool {
+	switch v := item.(type) {
+	case *models.Song:
+		return v.Favorite != data.IsFavorite || v.PlayCount != data.PlayCount
+	case *models.Album:
+		return v.Favorite != data.IsFavorite
+	case *models.Artist:
+		return v.Favorite != data.IsFavorite
+	}
+	return false
+}
+
+// revalidateCache fetches current state of stale items and compares it to cached items.
+// Modified and removed items are removed from cache and the rest are renewed.
+func (jf *Jellyfin) revalidateCache() {
+	stale := jf.cache.takeStale(maxRevalidateItems)
+	if len(stale) == 0 {
+		return
+	}
+	ids := make([]models.Id, 0, len(stale))
+	for id := range stale {
+		ids = append(ids, id)
+	}
+
+	params := *jf.defaultParams()
+	params.enableRecursive()
+	params.setIds(ids)
+	params["Fields"] = "DateLastSaved"
+	params["EnableUserData"] = "true"
+
+	resp, err := jf.get(context.Background(), fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+	if resp != nil {
+		defer resp.Close()
+	}
+	if err != nil {
+		logrus.Warningf("revalidate cache: %v", err)
+		return
+	}
+
+	dto := struct {
+		Items []savedItem `json:"Items"`
+	}{}
+	err = json.NewDecoder(resp).Decode(&dto)
+	if err != nil {
+		logrus.Warningf("revalidate cache: decode json: %v", err)
+		return
+	}
+	current := make(map[models.Id]savedItem, len(dto.Items))
+	for _, v := range dto.Items {
+		current[models.Id(v.Id)] = v
+	}
+	jf.cache.revalidated(ids, current)
+}
+
+// cacheFile returns path to metadata cache file of current server.
+func (jf *Jellyfin) cacheFile() string {
+	if jf.serverId == "" || config.AppConfig == nil {
+		return ""
+	}
+	return path.Join(config.AppConfig.Player.LocalCacheDir, "metadata", jf.serverId+".json")
+}
+
+// GetCacheStats returns metrics of metadata cache.
+func (jf *Jellyfin) GetCacheStats() models.CacheStats {
+	return jf.cache.GetStats()
+}
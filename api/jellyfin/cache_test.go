@@ -19,8 +19,17 @@
 package jellyfin
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 	"tryffel.net/go/jellycli/models"
 )
 
@@ -180,7 +189,7 @@ func TestCache_PutBatch(t *testing.T) {
 			t.Error("Failed to initialize cache: ", cache)
 			return
 		}
-		cache.cache.Flush()
+		cache.Flush()
 		t.Run(tt.name, func(t *testing.T) {
 			if err = cache.PutBatch(tt.items, true); (err != nil) != tt.wantErr {
 				t.Errorf("PutBatch() error = %v, wantErr %v", err, tt.wantErr)
@@ -249,7 +258,7 @@ func TestCache_GetBatch(t *testing.T) {
 			return
 		}
 		t.Run(tt.name, func(t *testing.T) {
-			cache.cache.Flush()
+			cache.Flush()
 
 			err = cache.PutBatch(tt.items, true)
 			if err != nil {
@@ -267,3 +276,177 @@ func TestCache_GetBatch(t *testing.T) {
 		})
 	}
 }
+
+// expireCacheItem marks item expired as if it was stored at given time.
+func expireCacheItem(c *Cache, id models.Id, stored time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry := c.entries[string(id)].Value.(*cacheEntry)
+	entry.stored = stored
+	entry.expires = stored
+}
+
+func TestCache_eviction(t *testing.T) {
+	cache, err := NewCache()
+	if err != nil {
+		t.Fatalf("new cache: %v", err)
+	}
+	cache.maxItems = 3
+	for i := 1; i <= 3; i++ {
+		id := models.Id(fmt.Sprintf("song-%d", i))
+		cache.Put(id, &models.Song{Id: id}, true)
+	}
+	// song-1 is now most recently used
+	cache.GetSong("song-1")
+	cache.Put("song-4", &models.Song{Id: "song-4"}, true)
+
+	if cache.Count() != 3 {
+		t.Errorf("expected 3 items, got %d", cache.Count())
+	}
+	if cache.GetSong("song-2") != nil {
+		t.Errorf("least recently used item not evicted")
+	}
+	if cache.GetSong("song-1") == nil || cache.GetSong("song-4") == nil {
+		t.Errorf("recently used item evicted")
+	}
+	stats := cache.GetStats()
+	if stats.Evictions != 1 || stats.Misses != 1 || stats.Hits != 3 {
+		t.Errorf("invalid stats: %+v", stats)
+	}
+}
+
+func TestCache_stale(t *testing.T) {
+	cache, err := NewCache()
+	if err != nil {
+		t.Fatalf("new cache: %v", err)
+	}
+	stored := time.Now().Add(-time.Hour * 24)
+	cache.Put("album-1", &models.Album{Id: "album-1"}, true)
+	cache.Put("album-2", &models.Album{Id: "album-2"}, true)
+	expireCacheItem(cache, "album-1", stored)
+
+	if cache.GetAlbum("album-1") == nil {
+		t.Fatalf("stale item not returned")
+	}
+	cache.GetAlbum("album-2")
+	stale := cache.takeStale(maxRevalidateItems)
+	if len(stale) != 1 || !stale["album-1"].Equal(stored) {
+		t.Errorf("invalid stale items: %v", stale)
+	}
+	if len(cache.takeStale(maxRevalidateItems)) != 0 {
+		t.Errorf("stale items returned twice")
+	}
+
+	cache.PutList("albums", []models.Id{"album-1"})
+	cache.lock.Lock()
+	cache.entries["albums"].Value.(*cacheEntry).expires = stored
+	cache.lock.Unlock()
+	if _, found := cache.GetList("albums"); found {
+		t.Errorf("expired list returned")
+	}
+}
+
+func TestCache_SaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jellycli-cache")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "metadata", "server.json")
+
+	cache, err := NewCache()
+	if err != nil {
+		t.Fatalf("new cache: %v", err)
+	}
+	err = cache.PutBatch(cacheTestData(), true)
+	if err != nil {
+		t.Fatalf("put items: %v", err)
+	}
+	cache.Put("old", &models.Song{Id: "old"}, true)
+	expireCacheItem(cache, "old", time.Now().Add(-cacheMaxAge*2))
+	cache.PutList("list", []models.Id{"s1"})
+	err = cache.Save(file)
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	loaded, err := NewCache()
+	if err != nil {
+		t.Fatalf("new cache: %v", err)
+	}
+	err = loaded.Load(file)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	items := cacheTestData()
+	if loaded.Count() != len(items) {
+		t.Errorf("expected %d items, got %d", len(items), loaded.Count())
+	}
+	for _, v := range items {
+		item, found := loaded.Get(v.GetId())
+		if !found || !reflect.DeepEqual(item, v) {
+			t.Errorf("item %s: got %v, want %v", v.GetId(), item, v)
+		}
+	}
+	if len(loaded.takeStale(maxRevalidateItems)) != len(items) {
+		t.Errorf("loaded items are not stale")
+	}
+
+	err = loaded.Load(path.Join(dir, "missing.json"))
+	if err != nil {
+		t.Errorf("load missing file: %v", err)
+	}
+}
+
+func TestJellyfin_revalidateCache(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		fmt.Fprint(w, `{"Items": [
+{"Id": "song-1", "DateLastSaved": "2020-10-01T10:00:00.0000000Z", "UserData": {"IsFavorite": true, "PlayCount": 2}},
+{"Id": "song-2", "DateLastSaved": "2020-10-01T13:00:00.0000000Z", "UserData": {}},
+{"Id": "song-3", "DateLastSaved": "2020-10-01T10:00:00.0000000Z", "UserData": {"IsFavorite": true}}],
+"TotalRecordCount": 3}`)
+	}))
+	defer server.Close()
+
+	cache, err := NewCache()
+	if err != nil {
+		t.Fatalf("new cache: %v", err)
+	}
+	stored := time.Date(2020, 10, 1, 11, 30, 0, 0, time.UTC)
+	// song-2 is modified, favorite of song-3 is changed and song-4 is removed from server
+	for _, id := range []models.Id{"song-1", "song-2", "song-3", "song-4", "song-5"} {
+		cache.Put(id, &models.Song{Id: id, Favorite: true, PlayCount: 2}, true)
+	}
+	cache.Put("song-3", &models.Song{Id: "song-3"}, true)
+	for _, id := range []models.Id{"song-1", "song-2", "song-3", "song-4"} {
+		expireCacheItem(cache, id, stored)
+		cache.GetSong(id)
+	}
+
+	jf := &Jellyfin{host: server.URL, client: server.Client(), cache: cache, userId: "user"}
+	jf.revalidateCache()
+
+	if query.Get("MinDateLastSaved") != "" || query.Get("Fields") != "DateLastSaved" {
+		t.Errorf("invalid query: %v", query)
+	}
+	if ids := strings.Split(query.Get("Ids"), ","); len(ids) != 4 {
+		t.Errorf("invalid ids: %v", ids)
+	}
+	for _, id := range []models.Id{"song-2", "song-3", "song-4"} {
+		if cache.GetSong(id) != nil {
+			t.Errorf("modified item %s not removed", id)
+		}
+	}
+	if cache.GetSong("song-1") == nil || cache.GetSong("song-5") == nil {
+		t.Errorf("unmodified item removed")
+	}
+	if len(cache.takeStale(maxRevalidateItems)) != 0 {
+		t.Errorf("unmodified item not renewed")
+	}
+	stats := cache.GetStats()
+	if stats.Revalidated != 1 || stats.Invalidated != 3 {
+		t.Errorf("invalid stats: %+v", stats)
+	}
+}
@@ -19,7 +19,9 @@
 package jellyfin
 
 import (
+	"context"
 	"fmt"
+	"github.com/sirupsen/logrus"
 	"io"
 	"tryffel.net/go/jellycli/api"
 	"tryffel.net/go/jellycli/config"
@@ -28,12 +30,68 @@ import (
 	"tryffel.net/go/jellycli/util"
 )
 
-func (jf *Jellyfin) Download(song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
-	return jf.Stream(song)
+// Download downloads original audio file. If original format is not supported, transcoded audio is downloaded.
+func (jf *Jellyfin) Download(ctx context.Context, song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	url := jf.host + "/Items/" + song.Id.String() + "/Download"
+	download, err := api.NewFileDownload(ctx, url, map[string]string{"X-Emby-Token": jf.token}, *jf.defaultParams(), jf.client)
+	if err != nil {
+		return nil, interfaces.AudioFormatNil, err
+	}
+	format, err := download.AudioFormat()
+	if err == nil {
+		return download, format, nil
+	}
+	download.Close()
+	logrus.Debugf("download song %s: %v, downloading transcoded audio instead", song.Id, err)
+
+	// original format is not playable, let server transcode it. This is not a playback stream.
+	params, _ := jf.streamParams()
+	url = jf.host + "/Audio/" + song.Id.String() + "/universal"
+	download, err = api.NewFileDownload(ctx, url, map[string]string{"X-Emby-Token": jf.token}, *params, jf.client)
+	if err != nil {
+		return nil, interfaces.AudioFormatNil, err
+	}
+	format, err = download.AudioFormat()
+	if err != nil {
+		download.Close()
+		return nil, interfaces.AudioFormatNil, err
+	}
+	return download, format, nil
 }
 
-func (jf *Jellyfin) Stream(song *models.Song) (rc io.ReadCloser, format interfaces.AudioFormat, err error) {
+func (jf *Jellyfin) Stream(ctx context.Context, song *models.Song) (rc io.ReadCloser, format interfaces.AudioFormat, err error) {
 	format = interfaces.AudioFormatNil
+	params, playSession := jf.streamParams()
+	url := jf.host + "/Audio/" + song.Id.String() + "/universal"
+	var stream *api.StreamBuffer
+	stream, err = api.NewStreamDownload(ctx, url, map[string]string{"X-Emby-Token": jf.token}, *params, jf.client, song.Duration)
+	rc = stream
+	format, err = stream.AudioFormat()
+	if err == nil {
+		jf.addStream(song.Id, playSession, stream.Size())
+	}
+	return
+}
+
+// StreamFile streams song without buffering it in background.
+func (jf *Jellyfin) StreamFile(ctx context.Context, song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	params, playSession := jf.streamParams()
+	url := jf.host + "/Audio/" + song.Id.String() + "/universal"
+	download, err := api.NewFileDownload(ctx, url, map[string]string{"X-Emby-Token": jf.token}, *params, jf.client)
+	if err != nil {
+		return nil, interfaces.AudioFormatNil, err
+	}
+	format, err := download.AudioFormat()
+	if err != nil {
+		download.Close()
+		return nil, interfaces.AudioFormatNil, err
+	}
+	jf.addStream(song.Id, playSession, download.Size())
+	return download, format, nil
+}
+
+// params for universal audio endpoint and play session of the request
+func (jf *Jellyfin) streamParams() (*params, string) {
 	params := jf.defaultParams()
 	ptr := params.ptr()
 	ptr["MaxStreamingBitrate"] = "140000000"
@@ -47,12 +105,7 @@ func (jf *Jellyfin) Stream(song *models.Song) (rc io.ReadCloser, format interfac
 	}
 	ptr["Container"] = formats
 	// Every new request requires new playsession
-	jf.SessionId = util.RandomKey(20)
-	ptr["PlaySessionId"] = jf.SessionId
-	url := jf.host + "/Audio/" + song.Id.String() + "/universal"
-	var stream *api.StreamBuffer
-	stream, err = api.NewStreamDownload(url, map[string]string{"X-Emby-Token": jf.token}, *params, jf.client, song.Duration)
-	rc = stream
-	format, err = stream.AudioFormat()
-	return
+	playSession := util.RandomKey(20)
+	ptr["PlaySessionId"] = playSession
+	return params, playSession
 }
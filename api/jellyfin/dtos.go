@@ -267,6 +267,7 @@ func (s *song) toSong() *models.Song {
 		DiscNumber: s.DiscNumber,
 		Artists:    artists,
 		Favorite:   s.UserData.IsFavorite,
+		PlayCount:  s.UserData.PlayCount,
 	}
 }
 
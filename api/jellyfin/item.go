@@ -19,6 +19,7 @@
 package jellyfin
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -31,6 +32,8 @@ import (
 
 const (
 	defaultLimit = "100"
+	// maximum number of ids in a single request
+	maxBatchIds = 50
 )
 
 func getItemType(dto *map[string]interface{}) (models.ItemType, error) {
@@ -53,14 +56,25 @@ func getItemType(dto *map[string]interface{}) (models.ItemType, error) {
 
 func (jf *Jellyfin) CanCacheSongs() bool { return true }
 
-func (jf *Jellyfin) GetItem(id models.Id) (models.Item, error) {
+func (jf *Jellyfin) CanFilterUserData() bool { return true }
+
+// GetItem returns item of any type. Concurrent requests for same item are coalesced.
+func (jf *Jellyfin) GetItem(ctx context.Context, id models.Id) (models.Item, error) {
+	val, err := jf.coalesce(ctx, "item-"+id.String(), func(ctx context.Context) (interface{}, error) {
+		return jf.getItem(ctx, id)
+	})
+	item, _ := val.(models.Item)
+	return item, err
+}
+
+func (jf *Jellyfin) getItem(ctx context.Context, id models.Id) (models.Item, error) {
 	item, found := jf.cache.Get(id)
 	if found && item != nil {
 		return item, nil
 	}
 	params := jf.defaultParams()
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items/%s", jf.userId, id), params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items/%s", jf.userId, id), params)
 	if err != nil {
 		return nil, fmt.Errorf("get item by id: %v", err)
 	}
@@ -122,49 +136,60 @@ func (jf *Jellyfin) GetItem(id models.Id) (models.Item, error) {
 	return item, nil
 }
 
-func (jf *Jellyfin) GetChildItems(id models.Id) ([]models.Item, error) {
+func (jf *Jellyfin) GetChildItems(ctx context.Context, id models.Id) ([]models.Item, error) {
 	// get users/<uid>/items/<id>?parentid=<pid>
 	return nil, nil
 }
 
-func (jf *Jellyfin) GetParentItem(id models.Id) (models.Item, error) {
+func (jf *Jellyfin) GetParentItem(ctx context.Context, id models.Id) (models.Item, error) {
 	return nil, nil
 }
 
-func (jf *Jellyfin) GetArtist(id models.Id) (*models.Artist, error) {
+// GetArtist returns artist with its albums. Concurrent requests for same artist are coalesced.
+func (jf *Jellyfin) GetArtist(ctx context.Context, id models.Id) (*models.Artist, error) {
+	val, err := jf.coalesce(ctx, "artist-"+id.String(), func(ctx context.Context) (interface{}, error) {
+		return jf.getArtist(ctx, id)
+	})
+	artist, _ := val.(*models.Artist)
+	return artist, err
+}
+
+func (jf *Jellyfin) getArtist(ctx context.Context, id models.Id) (*models.Artist, error) {
 	item, found := jf.cache.Get(id)
+	var ar *models.Artist
 	// Return cached value if both artist and albums exist
 	if found && item != nil {
 		artist, ok := item.(*models.Artist)
 		if !ok {
 			jf.cache.Delete(id)
 			logrus.Warningf("Found artist %s from cache with invalid type: %s", id, item.GetType())
-		} else if artist.Albums != nil {
-			if len(artist.Albums) == artist.AlbumCount {
-				return artist, nil
-			} else {
-				jf.cache.Delete(id)
-			}
+		} else if artist.Albums != nil && len(artist.Albums) == artist.AlbumCount {
+			return artist, nil
+		} else {
+			// artist is cached without albums, e.g. from artist list, only albums need to be fetched
+			copied := *artist
+			ar = &copied
+			ar.Albums = nil
 		}
 	}
 
-	ar := &models.Artist{}
-
-	params := jf.defaultParams()
-
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items/%s", jf.userId, id), params)
-	if err != nil {
-		return ar, fmt.Errorf("get artist: %v", err)
-	}
-	dto := artist{}
-	err = json.NewDecoder(resp).Decode(&dto)
-	if err != nil {
-		return ar, fmt.Errorf("parse artist: %v", err)
+	if ar == nil {
+		resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items/%s", jf.userId, id), jf.defaultParams())
+		if resp != nil {
+			defer resp.Close()
+		}
+		if err != nil {
+			return &models.Artist{}, fmt.Errorf("get artist: %v", err)
+		}
+		dto := artist{}
+		err = json.NewDecoder(resp).Decode(&dto)
+		if err != nil {
+			return &models.Artist{}, fmt.Errorf("parse artist: %v", err)
+		}
+		ar = dto.toArtist()
 	}
 
-	ar = dto.toArtist()
-
-	albums, err := jf.GetArtistAlbums(id)
+	albums, err := jf.GetArtistAlbums(ctx, id)
 	if err != nil {
 		return ar, fmt.Errorf("get artist albums: %v", err)
 	}
@@ -182,13 +207,27 @@ func (jf *Jellyfin) GetArtist(id models.Id) (*models.Artist, error) {
 	}
 
 	ar.Albums = ids
+	ar.AlbumCount = len(ids)
 	jf.cache.Put(id, ar, true)
 
 	return ar, nil
 }
 
-//GetArtistAlbums retrieves albums for given artist.
-func (jf *Jellyfin) GetArtistAlbums(id models.Id) ([]*models.Album, error) {
+//GetArtistAlbums retrieves albums for given artist. Concurrent requests for same artist are coalesced.
+func (jf *Jellyfin) GetArtistAlbums(ctx context.Context, id models.Id) ([]*models.Album, error) {
+	val, err := jf.coalesce(ctx, "artist-albums-"+id.String(), func(ctx context.Context) (interface{}, error) {
+		return jf.getArtistAlbums(ctx, id)
+	})
+	albums, _ := val.([]*models.Album)
+	return albums, err
+}
+
+func (jf *Jellyfin) getArtistAlbums(ctx context.Context, id models.Id) ([]*models.Album, error) {
+	// if artist albums are known, only albums missing from cache need to be fetched
+	if artist := jf.cache.GetArtist(id); artist != nil && artist.Albums != nil && len(artist.Albums) == artist.AlbumCount {
+		return jf.GetAlbumsById(ctx, artist.Albums)
+	}
+
 	params := *jf.defaultParams()
 	params.setIncludeTypes(mediaTypeAlbum)
 	params.enableRecursive()
@@ -197,16 +236,31 @@ func (jf *Jellyfin) GetArtistAlbums(id models.Id) ([]*models.Album, error) {
 	params["Limit"] = defaultLimit
 	params.setSorting("ProductionYear", "Ascending")
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+	if resp != nil {
+		defer resp.Close()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("get artist albums: %v", err)
 	}
 
 	albums, _, err := jf.parseAlbums(resp)
+	if err != nil {
+		return nil, fmt.Errorf("get artist albums: %v", err)
+	}
 	return albums, nil
 }
 
-func (jf *Jellyfin) GetAlbum(id models.Id) (*models.Album, error) {
+// GetAlbum returns album with its songs. Concurrent requests for same album are coalesced.
+func (jf *Jellyfin) GetAlbum(ctx context.Context, id models.Id) (*models.Album, error) {
+	val, err := jf.coalesce(ctx, "album-"+id.String(), func(ctx context.Context) (interface{}, error) {
+		return jf.getAlbum(ctx, id)
+	})
+	album, _ := val.(*models.Album)
+	return album, err
+}
+
+func (jf *Jellyfin) getAlbum(ctx context.Context, id models.Id) (*models.Album, error) {
 	item, found := jf.cache.Get(id)
 	// Return cached value if both artist and albums exist
 	if found && item != nil {
@@ -226,7 +280,7 @@ func (jf *Jellyfin) GetAlbum(id models.Id) (*models.Album, error) {
 	al := &models.Album{}
 	params := *jf.defaultParams()
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items/%s", jf.userId, id), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items/%s", jf.userId, id), &params)
 	if err != nil {
 		return al, fmt.Errorf("get album: %v", err)
 	}
@@ -238,7 +292,7 @@ func (jf *Jellyfin) GetAlbum(id models.Id) (*models.Album, error) {
 
 	al = dto.toAlbum()
 
-	songs, err := jf.GetAlbumSongs(id)
+	songs, err := jf.GetAlbumSongs(ctx, id)
 	if err != nil {
 		return al, fmt.Errorf("get albums songs: %v", err)
 	}
@@ -265,8 +319,16 @@ func (jf *Jellyfin) GetAlbum(id models.Id) (*models.Album, error) {
 	return al, nil
 }
 
-//GetAlbumSongs gets songs for given album.
-func (jf *Jellyfin) GetAlbumSongs(album models.Id) ([]*models.Song, error) {
+//GetAlbumSongs gets songs for given album. Concurrent requests for same album are coalesced.
+func (jf *Jellyfin) GetAlbumSongs(ctx context.Context, album models.Id) ([]*models.Song, error) {
+	val, err := jf.coalesce(ctx, "album-songs-"+album.String(), func(ctx context.Context) (interface{}, error) {
+		return jf.getAlbumSongs(ctx, album)
+	})
+	songs, _ := val.([]*models.Song)
+	return songs, err
+}
+
+func (jf *Jellyfin) getAlbumSongs(ctx context.Context, album models.Id) ([]*models.Song, error) {
 	params := *jf.defaultParams()
 	params.enableRecursive()
 	params.setParentId(album.String())
@@ -274,7 +336,7 @@ func (jf *Jellyfin) GetAlbumSongs(album models.Id) ([]*models.Song, error) {
 
 	params["Limit"] = defaultLimit
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
 	if err != nil {
 		return nil, fmt.Errorf("get album Songs; %v", err)
 	}
@@ -295,11 +357,11 @@ func (jf *Jellyfin) GetAlbumSongs(album models.Id) ([]*models.Song, error) {
 	return songs, nil
 }
 
-func (jf *Jellyfin) GetFavoriteArtists() ([]*models.Artist, error) {
+func (jf *Jellyfin) GetFavoriteArtists(ctx context.Context) ([]*models.Artist, error) {
 	params := *jf.defaultParams()
 	params["IsFavorite"] = "true"
 
-	resp, err := jf.get("/Artists", &params)
+	resp, err := jf.get(ctx, "/Artists", &params)
 	if err != nil {
 		return nil, fmt.Errorf("get favorite artists: %v", err)
 	}
@@ -322,7 +384,7 @@ func (jf *Jellyfin) GetFavoriteArtists() ([]*models.Artist, error) {
 	return artists, nil
 }
 
-func (jf *Jellyfin) GetFavoriteAlbums(paging interfaces.Paging) ([]*models.Album, int, error) {
+func (jf *Jellyfin) GetFavoriteAlbums(ctx context.Context, paging interfaces.Paging) ([]*models.Album, int, error) {
 	params := jf.defaultParams()
 	params.enableRecursive()
 	params.setParentId(jf.musicView)
@@ -331,7 +393,7 @@ func (jf *Jellyfin) GetFavoriteAlbums(paging interfaces.Paging) ([]*models.Album
 	ptr := params.ptr()
 	ptr["Filters"] = "IsFavorite"
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items", jf.userId), params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -344,7 +406,7 @@ func (jf *Jellyfin) GetFavoriteAlbums(paging interfaces.Paging) ([]*models.Album
 
 // GetPlaylists retrieves all playlists. Each playlists song count is known, but songs must be
 // retrieved separately
-func (jf *Jellyfin) GetPlaylists() ([]*models.Playlist, error) {
+func (jf *Jellyfin) GetPlaylists(ctx context.Context) ([]*models.Playlist, error) {
 	params := *jf.defaultParams()
 	params.setParentId(jf.musicView)
 	params.setIncludeTypes(mediaTypePlaylist)
@@ -353,7 +415,7 @@ func (jf *Jellyfin) GetPlaylists() ([]*models.Playlist, error) {
 
 	data := make([]*models.Playlist, 0)
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -377,11 +439,11 @@ func (jf *Jellyfin) GetPlaylists() ([]*models.Playlist, error) {
 }
 
 // GetPlaylistSongs returns songs for playlist id
-func (jf *Jellyfin) GetPlaylistSongs(playlist models.Id) ([]*models.Song, error) {
+func (jf *Jellyfin) GetPlaylistSongs(ctx context.Context, playlist models.Id) ([]*models.Song, error) {
 	params := *jf.defaultParams()
 	params.setParentId(playlist.String())
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -405,14 +467,14 @@ func (jf *Jellyfin) GetPlaylistSongs(playlist models.Id) ([]*models.Song, error)
 }
 
 // GetSongs returns songs by paging, and returns total number of songs
-func (jf *Jellyfin) GetSongs(query *interfaces.QueryOpts) ([]*models.Song, int, error) {
+func (jf *Jellyfin) GetSongs(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Song, int, error) {
 	params := *jf.defaultParams()
 	params.setIncludeTypes(mediaTypeSong)
 	params.enableRecursive()
 	params.setPaging(query.Paging)
 	params.setFilter(models.TypeSong, query.Filter)
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -437,7 +499,7 @@ func (jf *Jellyfin) GetSongs(query *interfaces.QueryOpts) ([]*models.Song, int,
 	return songs, dto.TotalSongs, nil
 }
 
-func (jf *Jellyfin) GetSongsById(ids []models.Id) ([]*models.Song, error) {
+func (jf *Jellyfin) GetSongsById(ctx context.Context, ids []models.Id) ([]*models.Song, error) {
 	params := *jf.defaultParams()
 	params.setIncludeTypes(mediaTypeSong)
 	params.enableRecursive()
@@ -446,17 +508,9 @@ func (jf *Jellyfin) GetSongsById(ids []models.Id) ([]*models.Song, error) {
 		return []*models.Song{}, fmt.Errorf("ids cannot be empty")
 	}
 
-	idList := ""
-	for i, v := range ids {
-		if i > 0 {
-			idList += ","
-		}
-		idList += v.String()
-	}
+	params.setIds(ids)
 
-	params["Ids"] = idList
-
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -482,14 +536,65 @@ func (jf *Jellyfin) GetSongsById(ids []models.Id) ([]*models.Song, error) {
 	return songs, nil
 }
 
+// GetAlbumsById returns albums for ids in the same order. Cached albums are returned as is and the rest are
+// fetched with as few requests as possible. Albums that server did not return are skipped.
+// Albums do not contain songs.
+func (jf *Jellyfin) GetAlbumsById(ctx context.Context, ids []models.Id) ([]*models.Album, error) {
+	found := make(map[models.Id]*models.Album, len(ids))
+	missing := make([]models.Id, 0, len(ids))
+	for _, id := range ids {
+		if album := jf.cache.GetAlbum(id); album != nil {
+			found[id] = album
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	ordered := func() []*models.Album {
+		albums := make([]*models.Album, 0, len(ids))
+		for _, id := range ids {
+			if album, ok := found[id]; ok {
+				albums = append(albums, album)
+			}
+		}
+		return albums
+	}
+
+	for len(missing) > 0 {
+		batch := missing
+		if len(batch) > maxBatchIds {
+			batch = batch[:maxBatchIds]
+		}
+		missing = missing[len(batch):]
+
+		params := *jf.defaultParams()
+		params.setIncludeTypes(mediaTypeAlbum)
+		params.enableRecursive()
+		params.setIds(batch)
+		resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+		if err != nil {
+			return ordered(), fmt.Errorf("get albums: %v", err)
+		}
+		fetched, _, err := jf.parseAlbums(resp)
+		resp.Close()
+		if err != nil {
+			return ordered(), fmt.Errorf("get albums: %v", err)
+		}
+		for _, v := range fetched {
+			jf.cache.Put(v.Id, v, true)
+			found[v.Id] = v
+		}
+	}
+	return ordered(), nil
+}
+
 // getArtists return artists defined by paging and total number of artists
-func (jf *Jellyfin) GetArtists(query *interfaces.QueryOpts) (artistList []*models.Artist, numRecords int, err error) {
+func (jf *Jellyfin) GetArtists(ctx context.Context, query *interfaces.QueryOpts) (artistList []*models.Artist, numRecords int, err error) {
 	params := *jf.defaultParams()
 	params.enableRecursive()
 	params.setPaging(query.Paging)
 	params.setSortingByType(models.TypeArtist, query.Sort)
 	params.setFilter(models.TypeArtist, query.Filter)
-	resp, err := jf.get("/Artists", &params)
+	resp, err := jf.get(ctx, "/Artists", &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -500,12 +605,12 @@ func (jf *Jellyfin) GetArtists(query *interfaces.QueryOpts) (artistList []*model
 }
 
 // getArtists return artists defined by paging and total number of artists
-func (jf *Jellyfin) getArtists(paging interfaces.Paging) (artistList []*models.Artist, numRecords int, err error) {
+func (jf *Jellyfin) getArtists(ctx context.Context, paging interfaces.Paging) (artistList []*models.Artist, numRecords int, err error) {
 	params := *jf.defaultParams()
 	params.enableRecursive()
 	params.setSorting("SortName", "Ascending")
 	params.setPaging(paging)
-	resp, err := jf.get("/Artists", &params)
+	resp, err := jf.get(ctx, "/Artists", &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -516,13 +621,13 @@ func (jf *Jellyfin) getArtists(paging interfaces.Paging) (artistList []*models.A
 	return jf.parseArtists(resp)
 }
 
-func (jf *Jellyfin) GetAlbumArtists(query *interfaces.QueryOpts) (artistList []*models.Artist, numRecords int, err error) {
+func (jf *Jellyfin) GetAlbumArtists(ctx context.Context, query *interfaces.QueryOpts) (artistList []*models.Artist, numRecords int, err error) {
 	params := *jf.defaultParams()
 	params.enableRecursive()
 	params.setFilter(models.TypeArtist, query.Filter)
 	params.setPaging(query.Paging)
 	params.setSortingByType(models.TypeArtist, query.Sort)
-	resp, err := jf.get("/Artists/AlbumArtists", &params)
+	resp, err := jf.get(ctx, "/Artists/AlbumArtists", &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -568,14 +673,14 @@ func (jf *Jellyfin) parseAlbums(resp io.Reader) (albumList []*models.Album, numR
 }
 
 // GetAlbums returns albums with given paging. It also returns number of all albums
-func (jf *Jellyfin) GetAlbums(opts *interfaces.QueryOpts) (albumList []*models.Album, numRecords int, err error) {
+func (jf *Jellyfin) GetAlbums(ctx context.Context, opts *interfaces.QueryOpts) (albumList []*models.Album, numRecords int, err error) {
 	params := *jf.defaultParams()
 	params.enableRecursive()
 	params.setPaging(opts.Paging)
 	params.setSortingByType(models.TypeAlbum, opts.Sort)
 	params.setFilter(models.TypeAlbum, opts.Filter)
 	params.setIncludeTypes(mediaTypeAlbum)
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -586,12 +691,12 @@ func (jf *Jellyfin) GetAlbums(opts *interfaces.QueryOpts) (albumList []*models.A
 	return jf.parseAlbums(resp)
 }
 
-func (jf *Jellyfin) GetSimilarArtists(artist models.Id) ([]*models.Artist, error) {
+func (jf *Jellyfin) GetSimilarArtists(ctx context.Context, artist models.Id) ([]*models.Artist, error) {
 	params := *jf.defaultParams()
 	params.enableRecursive()
 	params.setSorting("SortName", "Ascending")
 	params.setLimit(50)
-	resp, err := jf.get(fmt.Sprintf("/Items/%s/Similar", artist.String()), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Items/%s/Similar", artist.String()), &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -603,12 +708,12 @@ func (jf *Jellyfin) GetSimilarArtists(artist models.Id) ([]*models.Artist, error
 	return artists, err
 }
 
-func (jf *Jellyfin) GetSimilarAlbums(album models.Id) ([]*models.Album, error) {
+func (jf *Jellyfin) GetSimilarAlbums(ctx context.Context, album models.Id) ([]*models.Album, error) {
 	params := *jf.defaultParams()
 	params.enableRecursive()
 	params.setSorting("SortName", "Ascending")
 	params.setLimit(50)
-	resp, err := jf.get(fmt.Sprintf("/Items/%s/Similar", album.String()), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Items/%s/Similar", album.String()), &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -621,14 +726,14 @@ func (jf *Jellyfin) GetSimilarAlbums(album models.Id) ([]*models.Album, error) {
 
 }
 
-func (jf *Jellyfin) GetGenres(paging interfaces.Paging) ([]*models.IdName, int, error) {
+func (jf *Jellyfin) GetGenres(ctx context.Context, paging interfaces.Paging) ([]*models.IdName, int, error) {
 	params := jf.defaultParams()
 	params.enableRecursive()
 	params.setSorting("SortName", "Ascending")
 	params.setPaging(paging)
 	params.setParentId(jf.musicView)
 
-	resp, err := jf.get("/Genres", params)
+	resp, err := jf.get(ctx, "/Genres", params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -656,7 +761,7 @@ func (jf *Jellyfin) GetGenres(paging interfaces.Paging) ([]*models.IdName, int,
 	return ids, body.Count, nil
 }
 
-func (jf *Jellyfin) GetGenreAlbums(genre models.IdName) ([]*models.Album, error) {
+func (jf *Jellyfin) GetGenreAlbums(ctx context.Context, genre models.IdName) ([]*models.Album, error) {
 	params := jf.defaultParams()
 	params.enableRecursive()
 	params.setSorting("SortName", "Ascending")
@@ -664,7 +769,7 @@ func (jf *Jellyfin) GetGenreAlbums(genre models.IdName) ([]*models.Album, error)
 	(*params)["GenreIds"] = genre.Id.String()
 	params.setIncludeTypes(mediaTypeAlbum)
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items", jf.userId), params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -677,10 +782,10 @@ func (jf *Jellyfin) GetGenreAlbums(genre models.IdName) ([]*models.Album, error)
 	return albums, err
 }
 
-func (jf *Jellyfin) GetAlbumArtist(album *models.Album) (*models.Artist, error) {
+func (jf *Jellyfin) GetAlbumArtist(ctx context.Context, album *models.Album) (*models.Artist, error) {
 	artist := jf.cache.GetArtist(album.Id)
 	if artist == nil {
-		artist, err := jf.GetArtist(album.Artist)
+		artist, err := jf.GetArtist(ctx, album.Artist)
 		if err != nil {
 			return nil, fmt.Errorf("get artist: %v", err)
 		}
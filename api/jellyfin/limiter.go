@@ -0,0 +1,171 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package jellyfin
+
+import (
+	"context"
+	"sync"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
+
+// maxConcurrentRequests limits number of simultaneous http requests to server.
+const maxConcurrentRequests = 6
+
+// requestLimiter limits number of concurrent requests to server and collects request metrics.
+// Zero value is ready to use.
+type requestLimiter struct {
+	lock  sync.Mutex
+	slots chan struct{}
+	stats models.RequestStats
+}
+
+// acquire waits for free request slot. If ctx is done before that, return its error.
+func (r *requestLimiter) acquire(ctx context.Context) error {
+	r.lock.Lock()
+	if r.slots == nil {
+		r.slots = make(chan struct{}, maxConcurrentRequests)
+	}
+	slots := r.slots
+	r.stats.Waiting++
+	r.lock.Unlock()
+
+	var err error
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stats.Waiting--
+	if err == nil {
+		r.stats.InFlight++
+	}
+	return err
+}
+
+// release frees request slot and records completed request.
+func (r *requestLimiter) release(took time.Duration, failed bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	<-r.slots
+	r.stats.InFlight--
+	r.stats.Requests++
+	r.stats.TotalTime += took
+	if failed {
+		r.stats.Failed++
+	}
+}
+
+// coalesced records request that was served by another identical request.
+func (r *requestLimiter) coalesced() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stats.Coalesced++
+}
+
+func (r *requestLimiter) getStats() models.RequestStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	stats := r.stats
+	stats.MaxConcurrent = maxConcurrentRequests
+	return stats
+}
+
+// flight is a single in-flight request shared by one or more callers.
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	val     interface{}
+	err     error
+}
+
+// flightGroup coalesces identical concurrent requests, so that only one of them is sent to server
+// and its result is shared with every caller. Zero value is ready to use.
+type flightGroup struct {
+	lock    sync.Mutex
+	flights map[string]*flight
+}
+
+// do runs fn once for all concurrent calls with same key. Fn is cancelled only after every caller
+// has cancelled its context. Shared is true if result was shared from another call.
+func (g *flightGroup) do(ctx context.Context, key string,
+	fn func(ctx context.Context) (interface{}, error)) (val interface{}, shared bool, err error) {
+	g.lock.Lock()
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+	f, shared := g.flights[key]
+	if !shared {
+		fnCtx, cancel := context.WithCancel(context.Background())
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+		go func() {
+			f.val, f.err = fn(fnCtx)
+			g.forget(key, f)
+			cancel()
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.lock.Unlock()
+
+	select {
+	case <-f.done:
+		return f.val, shared, f.err
+	case <-ctx.Done():
+		g.lock.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+		}
+		g.lock.Unlock()
+		return nil, shared, ctx.Err()
+	}
+}
+
+// forget removes completed flight, unless it has already been replaced.
+func (g *flightGroup) forget(key string, f *flight) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// coalesce runs fn once for concurrent requests with same key and shares its result.
+func (jf *Jellyfin) coalesce(ctx context.Context, key string,
+	fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	val, shared, err := jf.flights.do(ctx, key, fn)
+	if shared {
+		jf.requests.coalesced()
+	}
+	return val, err
+}
+
+// GetRequestStats returns metrics of requests to server.
+func (jf *Jellyfin) GetRequestStats() models.RequestStats {
+	return jf.requests.getStats()
+}
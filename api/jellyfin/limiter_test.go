@@ -0,0 +1,252 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package jellyfin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
+
+func Test_flightGroup_do(t *testing.T) {
+	g := flightGroup{}
+	var calls int32
+	release := make(chan bool)
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "result", nil
+	}
+
+	wg := sync.WaitGroup{}
+	results := make([]interface{}, 3)
+	shared := make([]bool, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], shared[i], _ = g.do(context.Background(), "key", fn)
+		}(i)
+		// make sure first call starts the flight
+		time.Sleep(time.Millisecond * 10)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+	for i := range results {
+		if results[i] != "result" {
+			t.Errorf("call %d: invalid result: %v", i, results[i])
+		}
+		if shared[i] != (i > 0) {
+			t.Errorf("call %d: expected shared %t", i, i > 0)
+		}
+	}
+	if len(g.flights) != 0 {
+		t.Errorf("completed flights are not removed: %d", len(g.flights))
+	}
+}
+
+func Test_flightGroup_doCancel(t *testing.T) {
+	g := flightGroup{}
+	fnCancelled := make(chan bool)
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(fnCancelled)
+		return nil, ctx.Err()
+	}
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, _, err := g.do(first, "key", fn)
+		errs <- err
+	}()
+	time.Sleep(time.Millisecond * 10)
+	go func() {
+		_, _, err := g.do(second, "key", fn)
+		errs <- err
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	cancelFirst()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("expected cancelled, got %v", err)
+	}
+	select {
+	case <-fnCancelled:
+		t.Fatalf("request cancelled while another caller is waiting")
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	cancelSecond()
+	<-errs
+	select {
+	case <-fnCancelled:
+	case <-time.After(time.Second):
+		t.Errorf("request not cancelled after all callers cancelled")
+	}
+}
+
+func Test_requestLimiter(t *testing.T) {
+	r := requestLimiter{}
+	for i := 0; i < maxConcurrentRequests; i++ {
+		if err := r.acquire(context.Background()); err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	if err := r.acquire(ctx); err == nil {
+		t.Errorf("acquired more than %d requests", maxConcurrentRequests)
+	}
+
+	r.release(time.Millisecond*10, false)
+	r.release(time.Millisecond*30, true)
+	stats := r.getStats()
+	want := models.RequestStats{
+		Requests:      2,
+		Failed:        1,
+		InFlight:      maxConcurrentRequests - 2,
+		MaxConcurrent: maxConcurrentRequests,
+		TotalTime:     time.Millisecond * 40,
+	}
+	if stats != want {
+		t.Errorf("invalid stats: got %v, want %v", stats, want)
+	}
+	if stats.AverageTime() != time.Millisecond*20 {
+		t.Errorf("invalid average time: %v", stats.AverageTime())
+	}
+}
+
+func TestJellyfin_GetAlbumsById(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := r.URL.Query().Get("Ids")
+		requested = append(requested, ids)
+		items := make([]string, 0)
+		for _, id := range strings.Split(ids, ",") {
+			items = append(items, fmt.Sprintf(`{"Id": "%s", "Name": "album %s", "Type": "MusicAlbum"}`, id, id))
+		}
+		fmt.Fprintf(w, `{"Items": [%s], "TotalRecordCount": %d}`, strings.Join(items, ","), len(items))
+	}))
+	defer server.Close()
+
+	cache, err := NewCache()
+	if err != nil {
+		t.Fatalf("new cache: %v", err)
+	}
+	cache.Put("album-1", &models.Album{Id: "album-1", Name: "cached"}, true)
+	jf := &Jellyfin{host: server.URL, client: server.Client(), cache: cache, userId: "user"}
+
+	ids := []models.Id{"album-1"}
+	for i := 2; i < maxBatchIds+5; i++ {
+		ids = append(ids, models.Id(fmt.Sprintf("album-%d", i)))
+	}
+	albums, err := jf.GetAlbumsById(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("get albums: %v", err)
+	}
+	if len(albums) != len(ids) {
+		t.Errorf("expected %d albums, got %d", len(ids), len(albums))
+	}
+	if albums[0].Name != "cached" {
+		t.Errorf("cached album not used: %v", albums[0])
+	}
+	if len(requested) != 2 || !strings.HasPrefix(requested[0], "album-2,") {
+		t.Errorf("invalid batches: %v", requested)
+	}
+
+	requested = nil
+	_, err = jf.GetAlbumsById(context.Background(), ids[1:3])
+	if err != nil {
+		t.Fatalf("get cached albums: %v", err)
+	}
+	if len(requested) != 0 {
+		t.Errorf("fetched cached albums: %v", requested)
+	}
+}
+
+func TestJellyfin_GetArtistAlbums(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		ids := []string{"album-1", "album-2", "album-3"}
+		if query.Get("Ids") != "" {
+			ids = strings.Split(query.Get("Ids"), ",")
+			requested = append(requested, "ids "+query.Get("Ids"))
+		} else {
+			requested = append(requested, "artist "+query.Get("AlbumArtistIds"))
+		}
+		items := make([]string, 0)
+		for _, id := range ids {
+			items = append(items, fmt.Sprintf(`{"Id": "%s", "Name": "album %s", "Type": "MusicAlbum"}`, id, id))
+		}
+		fmt.Fprintf(w, `{"Items": [%s], "TotalRecordCount": %d}`, strings.Join(items, ","), len(items))
+	}))
+	defer server.Close()
+
+	cache, err := NewCache()
+	if err != nil {
+		t.Fatalf("new cache: %v", err)
+	}
+	// artist from artist list, without albums
+	cache.Put("artist-1", &models.Artist{Id: "artist-1", Name: "artist", AlbumCount: 3}, true)
+	jf := &Jellyfin{host: server.URL, client: server.Client(), cache: cache, userId: "user"}
+
+	artist, err := jf.GetArtist(context.Background(), "artist-1")
+	if err != nil {
+		t.Fatalf("get artist: %v", err)
+	}
+	if artist.Name != "artist" || !reflect.DeepEqual(artist.Albums, []models.Id{"album-1", "album-2", "album-3"}) {
+		t.Errorf("invalid artist: %+v", artist)
+	}
+	if !reflect.DeepEqual(requested, []string{"artist artist-1"}) {
+		t.Errorf("get artist: invalid requests: %v", requested)
+	}
+
+	requested = nil
+	cache.Delete("album-2")
+	albums, err := jf.GetArtistAlbums(context.Background(), "artist-1")
+	if err != nil {
+		t.Fatalf("get artist albums: %v", err)
+	}
+	got := make([]models.Id, len(albums))
+	for i, v := range albums {
+		got[i] = v.Id
+	}
+	if !reflect.DeepEqual(got, artist.Albums) {
+		t.Errorf("artist albums: got %v, want %v", got, artist.Albums)
+	}
+	if !reflect.DeepEqual(requested, []string{"ids album-2"}) {
+		t.Errorf("get artist albums: invalid requests: %v", requested)
+	}
+}
@@ -20,6 +20,8 @@ package jellyfin
 
 import (
 	"strconv"
+	"strings"
+	"time"
 	"tryffel.net/go/jellycli/interfaces"
 	"tryffel.net/go/jellycli/models"
 )
@@ -46,6 +48,15 @@ func (p *params) setIncludeTypes(itemType mediaItemType) {
 	ptr["IncludeItemTypes"] = itemType.String()
 }
 
+// setIds limits items to given ids.
+func (p *params) setIds(ids []models.Id) {
+	list := make([]string, len(ids))
+	for i, v := range ids {
+		list[i] = v.String()
+	}
+	(*p)["Ids"] = strings.Join(list, ",")
+}
+
 func (p *params) enableRecursive() {
 	(*p)["Recursive"] = "true"
 }
@@ -133,6 +144,14 @@ func (p *params) setFilter(tItem models.ItemType, filter interfaces.Filter) {
 		(*p)["Genres"] = genres
 	}
 
+	if !filter.ModifiedSince.IsZero() {
+		(*p)["MinDateLastSaved"] = filter.ModifiedSince.UTC().Format(time.RFC3339)
+	}
+
+	if !filter.UserDataChangedSince.IsZero() {
+		(*p)["MinDateLastSavedForUser"] = filter.UserDataChangedSince.UTC().Format(time.RFC3339)
+	}
+
 	if f != "" {
 		(*p)["Filters"] = f
 	}
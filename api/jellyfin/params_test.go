@@ -21,7 +21,9 @@ package jellyfin
 import (
 	"reflect"
 	"testing"
+	"time"
 	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
 )
 
 func Test_params_setPaging(t *testing.T) {
@@ -53,3 +55,41 @@ func Test_params_setPaging(t *testing.T) {
 		})
 	}
 }
+
+func Test_params_setFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter interfaces.Filter
+		want   params
+	}{
+		{
+			name:   "empty",
+			filter: interfaces.Filter{},
+			want:   params{},
+		},
+		{
+			name:   "modified since",
+			filter: interfaces.Filter{ModifiedSince: time.Date(2020, 10, 1, 14, 30, 0, 0, time.FixedZone("EET", 3*3600))},
+			want:   params{"MinDateLastSaved": "2020-10-01T11:30:00Z"},
+		},
+		{
+			name:   "user data changed since",
+			filter: interfaces.Filter{UserDataChangedSince: time.Date(2020, 10, 1, 11, 30, 0, 0, time.UTC)},
+			want:   params{"MinDateLastSavedForUser": "2020-10-01T11:30:00Z"},
+		},
+		{
+			name:   "favorite",
+			filter: interfaces.Filter{Favorite: true},
+			want:   params{"Filters": "IsFavorite"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := params{}
+			p.setFilter(models.TypeSong, tt.filter)
+			if !reflect.DeepEqual(p, tt.want) {
+				t.Errorf("setFilter() = %v, want %v", p, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package jellyfin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/util"
+)
+
+const (
+	playMethodDirectPlay = "DirectPlay"
+	playMethodTranscode  = "Transcode"
+
+	// how many opened streams are remembered until their playback starts
+	maxOpenStreams = 20
+
+	urlPlaybackStarted  = "/Sessions/Playing"
+	urlPlaybackProgress = "/Sessions/Playing/Progress"
+	urlPlaybackStopped  = "/Sessions/Playing/Stopped"
+)
+
+// playbackProgress is PlaybackStartInfo and PlaybackProgressInfo.
+type playbackProgress struct {
+	QueueableMediaTypes    []string
+	CanSeek                bool
+	ItemId                 string
+	MediaSourceId          string
+	PositionTicks          int64
+	PlaybackStartTimeTicks int64
+	VolumeLevel            int
+	IsPaused               bool
+	IsMuted                bool
+	PlayMethod             string
+	PlaySessionId          string
+	LiveStreamId           string
+	PlaylistItemId         string
+	ShuffleMode            string
+	RepeatMode             string
+	NowPlayingQueue        []queueItem
+	EventName              string `json:",omitempty"`
+}
+
+// playbackStopped is PlaybackStopInfo.
+type playbackStopped struct {
+	ItemId          string
+	MediaSourceId   string
+	PositionTicks   int64
+	PlaySessionId   string
+	LiveStreamId    string
+	PlaylistItemId  string
+	Failed          bool
+	NowPlayingQueue []queueItem
+}
+
+type queueItem struct {
+	Id             string `json:"Id"`
+	PlaylistItemId string `json:"PlaylistItemId"`
+}
+
+// stream is a song that has been opened from server.
+type stream struct {
+	item          models.Id
+	playSessionId string
+	playMethod    string
+}
+
+// playback is ongoing playback that is reported to server.
+type playback struct {
+	stream
+	playlistItemId string
+	started        time.Time
+	// latest reported state
+	state *interfaces.ApiPlaybackState
+}
+
+// playbackReport is a single report to send to server.
+type playbackReport struct {
+	url   string
+	body  interface{}
+	state *interfaces.ApiPlaybackState
+}
+
+// addStream remembers play session and method of opened song, so that its playback is reported with them.
+// Stream of unknown size is being transcoded.
+func (jf *Jellyfin) addStream(item models.Id, playSessionId string, size int64) {
+	method := playMethodDirectPlay
+	if size < 0 {
+		method = playMethodTranscode
+	}
+	jf.playbackLock.Lock()
+	defer jf.playbackLock.Unlock()
+	jf.streams = append(jf.streams, stream{item: item, playSessionId: playSessionId, playMethod: method})
+	if len(jf.streams) > maxOpenStreams {
+		jf.streams = jf.streams[len(jf.streams)-maxOpenStreams:]
+	}
+}
+
+// takeStream returns latest stream opened for item. If item was not opened from server,
+// e.g. it is played from downloads, it is played directly with new play session.
+// Caller must hold playbackLock.
+func (jf *Jellyfin) takeStream(item models.Id) stream {
+	for i := len(jf.streams) - 1; i >= 0; i-- {
+		if jf.streams[i].item == item {
+			s := jf.streams[i]
+			jf.streams = append(jf.streams[:i], jf.streams[i+1:]...)
+			return s
+		}
+	}
+	return stream{item: item, playSessionId: util.RandomKey(20), playMethod: playMethodDirectPlay}
+}
+
+// ReportProgress reports playback status to server. If song changes without being stopped,
+// previous song is reported stopped before new one is started.
+func (jf *Jellyfin) ReportProgress(state *interfaces.ApiPlaybackState) error {
+	jf.playbackLock.Lock()
+	reports := jf.playbackReports(state, time.Now())
+	jf.playbackLock.Unlock()
+
+	var err error
+	for _, v := range reports {
+		reportErr := jf.sendPlaybackReport(v)
+		if reportErr != nil && err == nil {
+			err = reportErr
+		}
+	}
+	logrus.Debug("Progress event: ", state.Event)
+	return err
+}
+
+// playbackReports updates ongoing playback with state and returns reports to send. Caller must hold playbackLock.
+func (jf *Jellyfin) playbackReports(state *interfaces.ApiPlaybackState, now time.Time) []playbackReport {
+	reports := []playbackReport{}
+	queue := jf.updatePlaylistItems(state.Queue)
+
+	current := jf.playback
+	if current != nil && (state.Event == interfaces.EventStart || current.item.String() != state.ItemId) {
+		reports = append(reports, current.stopped(current.state, queue))
+		jf.playback = nil
+		current = nil
+	}
+
+	if state.Event == interfaces.EventStop {
+		if current != nil {
+			reports = append(reports, current.stopped(state, queue))
+			jf.playback = nil
+		}
+		return reports
+	}
+	if state.ItemId == "" {
+		return reports
+	}
+
+	event := state.Event
+	if current == nil {
+		current = &playback{
+			stream:  jf.takeStream(models.Id(state.ItemId)),
+			started: now.Add(-time.Duration(state.Position) * time.Millisecond),
+		}
+		if len(queue) > 0 && queue[0].Id == state.ItemId {
+			current.playlistItemId = queue[0].PlaylistItemId
+		}
+		jf.playback = current
+		event = interfaces.EventStart
+	}
+	current.state = state
+	return append(reports, current.progress(state, event, queue))
+}
+
+// updatePlaylistItems sets queue to report. Caller must hold playbackLock.
+func (jf *Jellyfin) updatePlaylistItems(queue []models.Id) []queueItem {
+	jf.playlistItems = matchPlaylistItems(jf.playlistItems, queue, func() string {
+		jf.playlistItemCounter += 1
+		return "playlistItem" + strconv.Itoa(jf.playlistItemCounter)
+	})
+	return jf.playlistItems
+}
+
+// matchPlaylistItems returns queue items for ids. Items that remain in queue in same order keep their
+// playlist item ids, so that server can follow items while queue advances. Other items get new ids.
+func matchPlaylistItems(previous []queueItem, ids []models.Id, newId func() string) []queueItem {
+	items := make([]queueItem, len(ids))
+	next := 0
+	for i, id := range ids {
+		items[i].Id = id.String()
+		for j := next; j < len(previous); j++ {
+			if previous[j].Id == items[i].Id {
+				items[i].PlaylistItemId = previous[j].PlaylistItemId
+				next = j + 1
+				break
+			}
+		}
+		if items[i].PlaylistItemId == "" {
+			items[i].PlaylistItemId = newId()
+		}
+	}
+	return items
+}
+
+func (p *playback) progress(state *interfaces.ApiPlaybackState, event interfaces.ApiPlaybackEvent,
+	queue []queueItem) playbackReport {
+	info := playbackProgress{
+		QueueableMediaTypes:    []string{"Audio"},
+		CanSeek:                true,
+		ItemId:                 state.ItemId,
+		MediaSourceId:          state.ItemId,
+		PositionTicks:          int64(state.Position) * ticksToMillisecond,
+		PlaybackStartTimeTicks: p.started.UnixNano() / 100,
+		VolumeLevel:            state.Volume,
+		IsPaused:               state.IsPaused,
+		IsMuted:                state.IsMuted,
+		PlayMethod:             p.playMethod,
+		PlaySessionId:          p.playSessionId,
+		PlaylistItemId:         p.playlistItemId,
+		ShuffleMode:            "Sorted",
+		RepeatMode:             repeatModes[state.Repeat],
+		NowPlayingQueue:        queue,
+	}
+	if state.Shuffle {
+		info.ShuffleMode = "Shuffle"
+	}
+
+	url := urlPlaybackStarted
+	if event != interfaces.EventStart {
+		url = urlPlaybackProgress
+		info.EventName = string(event)
+	}
+	return playbackReport{url: url, body: info, state: state}
+}
+
+func (p *playback) stopped(state *interfaces.ApiPlaybackState, queue []queueItem) playbackReport {
+	info := playbackStopped{
+		ItemId:          p.item.String(),
+		MediaSourceId:   p.item.String(),
+		PositionTicks:   int64(state.Position) * ticksToMillisecond,
+		PlaySessionId:   p.playSessionId,
+		PlaylistItemId:  p.playlistItemId,
+		NowPlayingQueue: queue,
+	}
+	return playbackReport{url: urlPlaybackStopped, body: info, state: state}
+}
+
+func (jf *Jellyfin) sendPlaybackReport(report playbackReport) error {
+	body, err := json.Marshal(report.body)
+	if err != nil {
+		return fmt.Errorf("json marshaling failed: %v", err)
+	}
+	resp, err := jf.makeRequest(context.Background(), http.MethodPost, report.url, &body, jf.defaultParams(),
+		map[string]string{"X-Emby-Authorization": jf.authHeader()})
+	if err != nil {
+		if report.url == urlPlaybackStopped {
+			// store stopped report, so that play count is not lost
+			playedAt := time.Now().Add(-time.Duration(report.state.Position) * time.Millisecond)
+			op := models.NewOperation(models.OperationPlaybackStopped, models.Id(report.state.ItemId),
+				playedAt, string(body))
+			return &api.OperationError{Operation: op, Err: err}
+		}
+		return fmt.Errorf("push progress: %v", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// resendPlayback reports ongoing playback started again, e.g. after server has lost session.
+// Position is taken from latest player status.
+func (jf *Jellyfin) resendPlayback() error {
+	jf.playbackLock.Lock()
+	current := jf.playback
+	if current == nil || current.state == nil {
+		jf.playbackLock.Unlock()
+		return nil
+	}
+	state := *current.state
+	status := jf.getPlayerStatus()
+	if status.Song != nil && status.Song.Id == current.item {
+		state.Position = status.SongPast
+		state.IsPaused = status.Paused
+	}
+	current.state = &state
+	report := current.progress(&state, interfaces.EventStart, jf.playlistItems)
+	jf.playbackLock.Unlock()
+	return jf.sendPlaybackReport(report)
+}
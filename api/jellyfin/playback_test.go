@@ -0,0 +1,244 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package jellyfin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+func Test_matchPlaylistItems(t *testing.T) {
+	previous := []queueItem{{"a", "p1"}, {"b", "p2"}, {"c", "p3"}}
+	tests := []struct {
+		name string
+		ids  []models.Id
+		want []queueItem
+	}{
+		{
+			name: "queue advanced",
+			ids:  []models.Id{"b", "c"},
+			want: []queueItem{{"b", "p2"}, {"c", "p3"}},
+		},
+		{
+			name: "songs added",
+			ids:  []models.Id{"a", "d", "b", "c", "a"},
+			want: []queueItem{{"a", "p1"}, {"d", "n1"}, {"b", "p2"}, {"c", "p3"}, {"a", "n2"}},
+		},
+		{
+			name: "song moved",
+			ids:  []models.Id{"a", "c", "b"},
+			want: []queueItem{{"a", "p1"}, {"c", "p3"}, {"b", "n1"}},
+		},
+		{
+			name: "empty queue",
+			ids:  []models.Id{},
+			want: []queueItem{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := 0
+			newId := func() string {
+				counter += 1
+				return "n" + strconv.Itoa(counter)
+			}
+			if got := matchPlaylistItems(previous, tt.ids, newId); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchPlaylistItems() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJellyfin_playbackReports(t *testing.T) {
+	jf := &Jellyfin{}
+	now := time.Date(2020, 11, 2, 18, 0, 0, 0, time.UTC)
+	jf.addStream("a", "session-a", -1)
+	jf.addStream("b", "session-b", 4000000)
+
+	state := func(event interfaces.ApiPlaybackEvent, item string, position interfaces.AudioTick) *interfaces.ApiPlaybackState {
+		queue := []models.Id{"a", "b"}
+		if item == "b" {
+			queue = queue[1:]
+		}
+		return &interfaces.ApiPlaybackState{Event: event, ItemId: item, Position: position, Queue: queue}
+	}
+
+	// url, play session, play method, position ticks
+	type report struct {
+		url      string
+		session  string
+		method   string
+		position int64
+	}
+	summary := func(reports []playbackReport) []report {
+		out := make([]report, len(reports))
+		for i, v := range reports {
+			switch body := v.body.(type) {
+			case playbackProgress:
+				out[i] = report{v.url, body.PlaySessionId, body.PlayMethod, body.PositionTicks}
+				if body.PlaylistItemId == "" || body.PlaybackStartTimeTicks == 0 {
+					t.Errorf("report %s is missing playlist item or start time", v.url)
+				}
+			case playbackStopped:
+				out[i] = report{v.url, body.PlaySessionId, "", body.PositionTicks}
+			}
+		}
+		return out
+	}
+
+	steps := []struct {
+		name  string
+		state *interfaces.ApiPlaybackState
+		want  []report
+	}{
+		{
+			name:  "start transcoded",
+			state: state(interfaces.EventStart, "a", 0),
+			want:  []report{{urlPlaybackStarted, "session-a", playMethodTranscode, 0}},
+		},
+		{
+			name:  "progress",
+			state: state(interfaces.EventTimeUpdate, "a", 10500),
+			want:  []report{{urlPlaybackProgress, "session-a", playMethodTranscode, 105000000}},
+		},
+		{
+			name:  "next song stops previous",
+			state: state(interfaces.EventStart, "b", 0),
+			want: []report{
+				{urlPlaybackStopped, "session-a", "", 105000000},
+				{urlPlaybackStarted, "session-b", playMethodDirectPlay, 0},
+			},
+		},
+		{
+			name:  "stop",
+			state: state(interfaces.EventStop, "b", 1250),
+			want:  []report{{urlPlaybackStopped, "session-b", "", 12500000}},
+		},
+		{
+			name:  "already stopped",
+			state: state(interfaces.EventStop, "b", 1250),
+			want:  []report{},
+		},
+	}
+	for _, step := range steps {
+		got := summary(jf.playbackReports(step.state, now))
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: got %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	// song that was not streamed is played directly with new session
+	got := jf.playbackReports(state(interfaces.EventTimeUpdate, "a", 2000), now)
+	if len(got) != 1 || got[0].url != urlPlaybackStarted {
+		t.Fatalf("progress without start: got %v, want playback started", got)
+	}
+	body := got[0].body.(playbackProgress)
+	if body.PlayMethod != playMethodDirectPlay || body.PlaySessionId == "" || body.PlaySessionId == "session-a" {
+		t.Errorf("local song: got method %s, session %s", body.PlayMethod, body.PlaySessionId)
+	}
+}
+
+func TestJellyfin_Download(t *testing.T) {
+	path := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "audio/flac")
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	jf := &Jellyfin{host: server.URL, client: server.Client()}
+	download, format, err := jf.Download(context.Background(), &models.Song{Id: "song-1"})
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	download.Close()
+	if path != "/Items/song-1/Download" || format != interfaces.AudioFormatFlac {
+		t.Errorf("download: got path %s, format %s", path, format)
+	}
+	// only streams opened for playback are reported to server
+	if len(jf.streams) != 0 {
+		t.Errorf("download registered as playback stream: %v", jf.streams)
+	}
+
+	stream, _, err := jf.StreamFile(context.Background(), &models.Song{Id: "song-1"})
+	if err != nil {
+		t.Fatalf("stream file: %v", err)
+	}
+	stream.Close()
+	if path != "/Audio/song-1/universal" || len(jf.streams) != 1 {
+		t.Errorf("stream file: got path %s, streams %v", path, jf.streams)
+	}
+}
+
+func TestJellyfin_Download_transcode(t *testing.T) {
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/Items/song-1/Download" {
+			w.Header().Set("Content-Type", "audio/mp4")
+		} else {
+			w.Header().Set("Content-Type", "audio/mpeg")
+		}
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	jf := &Jellyfin{host: server.URL, client: server.Client()}
+	download, format, err := jf.Download(context.Background(), &models.Song{Id: "song-1"})
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	download.Close()
+	want := []string{"/Items/song-1/Download", "/Audio/song-1/universal"}
+	if !reflect.DeepEqual(paths, want) || format != interfaces.AudioFormatMp3 {
+		t.Errorf("download: got paths %v, format %s, want %v, mp3", paths, format, want)
+	}
+	if len(jf.streams) != 0 {
+		t.Errorf("transcoded download registered as playback stream: %v", jf.streams)
+	}
+}
+
+func TestJellyfin_Replay_favorite(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	jf := &Jellyfin{host: server.URL, client: server.Client(), userId: "user-1"}
+	now := time.Now()
+	for _, favorite := range []bool{true, false} {
+		err := jf.Replay(models.NewFavoriteOperation("album-1", models.TypeAlbum, favorite, now))
+		if err != nil {
+			t.Fatalf("replay favorite: %v", err)
+		}
+	}
+	want := []string{"POST /Users/user-1/FavoriteItems/album-1", "DELETE /Users/user-1/FavoriteItems/album-1"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("replay favorite: got requests %v, want %v", requests, want)
+	}
+}
@@ -20,12 +20,14 @@ package jellyfin
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"time"
+	"tryffel.net/go/jellycli/api"
 )
 
 const (
@@ -44,16 +46,16 @@ func (jf *Jellyfin) defaultParams() *params {
 	return &params
 }
 
-func (jf *Jellyfin) get(url string, params *params) (io.ReadCloser, error) {
-	resp, err := jf.makeRequest("GET", url, nil, params, nil)
+func (jf *Jellyfin) get(ctx context.Context, url string, params *params) (io.ReadCloser, error) {
+	resp, err := jf.makeRequest(ctx, "GET", url, nil, params, nil)
 	if resp != nil {
 		return resp.Body, err
 	}
 	return nil, err
 }
 
-func (jf *Jellyfin) post(url string, body *[]byte, params *params) (io.ReadCloser, error) {
-	resp, err := jf.makeRequest("POST", url, body, params, nil)
+func (jf *Jellyfin) post(ctx context.Context, url string, body *[]byte, params *params) (io.ReadCloser, error) {
+	resp, err := jf.makeRequest(ctx, "POST", url, body, params, nil)
 	if resp != nil {
 		return resp.Body, err
 	}
@@ -63,19 +65,21 @@ func (jf *Jellyfin) post(url string, body *[]byte, params *params) (io.ReadClose
 //Construct request
 // Set authorization header and build url query
 // Make request, parse response code and raise error if needed. Else return response body
-func (jf *Jellyfin) makeRequest(method, url string, body *[]byte, params *params,
+// Request is limited with configured timeout, which lasts until response body is closed.
+// Requests wait for a free slot if there are already maxConcurrentRequests running.
+func (jf *Jellyfin) makeRequest(ctx context.Context, method, url string, body *[]byte, params *params,
 	headers map[string]string) (*http.Response, error) {
-	var reader *bytes.Buffer
+	ctx, cancel := api.WithRequestTimeout(ctx)
 	var req *http.Request
 	var err error
 	if body != nil {
-		reader = bytes.NewBuffer(*body)
-		req, err = http.NewRequest(method, jf.host+url, reader)
+		req, err = http.NewRequestWithContext(ctx, method, jf.host+url, bytes.NewBuffer(*body))
 	} else {
-		req, err = http.NewRequest(method, jf.host+url, nil)
+		req, err = http.NewRequestWithContext(ctx, method, jf.host+url, nil)
 	}
 
 	if err != nil {
+		cancel()
 		return &http.Response{}, fmt.Errorf("failed to make request: %v", err)
 	}
 	if method == "POST" {
@@ -96,13 +100,22 @@ func (jf *Jellyfin) makeRequest(method, url string, body *[]byte, params *params
 		}
 		req.URL.RawQuery = q.Encode()
 	}
+
+	err = jf.requests.acquire(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("wait for request: %v", err)
+	}
 	start := time.Now()
 	resp, err := jf.client.Do(req)
+	took := time.Since(start)
+	jf.requests.release(took, err != nil || (resp.StatusCode != 200 && resp.StatusCode != 204))
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("failed make request: %v", err)
 	}
-	took := time.Since(start)
 	logrus.Debugf("%s %s: %d (%d ms)", req.Method, req.URL.Path, resp.StatusCode, took.Milliseconds())
+	resp.Body = api.CancelOnClose(resp.Body, cancel)
 
 	if resp.StatusCode == 200 || resp.StatusCode == 204 {
 		return resp, nil
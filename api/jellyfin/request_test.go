@@ -0,0 +1,86 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package jellyfin
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/config"
+)
+
+func TestJellyfin_makeRequest(t *testing.T) {
+	oldConfig := config.AppConfig
+	config.AppConfig = &config.Config{Player: config.Player{RequestTimeoutS: 1}}
+	t.Cleanup(func() {
+		config.AppConfig = oldConfig
+	})
+
+	done := make(chan bool)
+	defer close(done)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	jf := &Jellyfin{host: server.URL, client: server.Client()}
+
+	body, err := jf.get(context.Background(), "/fast", nil)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil || string(data) != "ok" {
+		t.Errorf("read body: %s, %v", data, err)
+	}
+
+	start := time.Now()
+	_, err = jf.get(context.Background(), "/slow", nil)
+	if err == nil {
+		t.Errorf("request did not time out")
+	}
+	if took := time.Since(start); took > time.Second*5 {
+		t.Errorf("request timed out after %v", took)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+	}()
+	start = time.Now()
+	_, err = jf.get(ctx, "/slow", nil)
+	if err == nil {
+		t.Errorf("request was not cancelled")
+	}
+	if took := time.Since(start); took > time.Millisecond*500 {
+		t.Errorf("request cancelled after %v", took)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package jellyfin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+// sessionInfo is a session as returned from /Sessions.
+type sessionInfo struct {
+	Id                    string `json:"Id"`
+	UserName              string `json:"UserName"`
+	Client                string `json:"Client"`
+	DeviceName            string `json:"DeviceName"`
+	DeviceId              string `json:"DeviceId"`
+	SupportsRemoteControl bool   `json:"SupportsRemoteControl"`
+	NowPlayingItem        *song  `json:"NowPlayingItem"`
+	PlayState             struct {
+		PositionTicks int64 `json:"PositionTicks"`
+		CanSeek       bool  `json:"CanSeek"`
+		IsPaused      bool  `json:"IsPaused"`
+		IsMuted       bool  `json:"IsMuted"`
+		VolumeLevel   int   `json:"VolumeLevel"`
+	} `json:"PlayState"`
+}
+
+func (s *sessionInfo) toSession() *models.Session {
+	session := &models.Session{
+		Id:         models.Id(s.Id),
+		UserName:   s.UserName,
+		Client:     s.Client,
+		DeviceName: s.DeviceName,
+		Position:   int(s.PlayState.PositionTicks / ticksToSecond),
+		Paused:     s.PlayState.IsPaused,
+		Muted:      s.PlayState.IsMuted,
+		Volume:     s.PlayState.VolumeLevel,
+		CanSeek:    s.PlayState.CanSeek,
+	}
+	if s.NowPlayingItem != nil {
+		session.NowPlaying = s.NowPlayingItem.toSong()
+		session.Album = s.NowPlayingItem.Album
+	}
+	return session
+}
+
+var sessionCommands = map[models.SessionCommand]string{
+	models.SessionPlayPause: "PlayPause",
+	models.SessionNext:      "NextTrack",
+	models.SessionPrevious:  "PreviousTrack",
+	models.SessionStop:      "Stop",
+}
+
+// GetSessions returns other sessions of the user that can be remote controlled.
+func (jf *Jellyfin) GetSessions() ([]*models.Session, error) {
+	params := *jf.defaultParams()
+	params["ControllableByUserId"] = jf.userId
+	resp, err := jf.get(context.Background(), "/Sessions", &params)
+	if resp != nil {
+		defer resp.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sessions: %v", err)
+	}
+	return parseSessions(resp, jf.DeviceId)
+}
+
+// parse sessions that support remote control, excluding sessions of given device.
+func parseSessions(body io.Reader, ownDevice string) ([]*models.Session, error) {
+	dto := []sessionInfo{}
+	err := json.NewDecoder(body).Decode(&dto)
+	if err != nil {
+		return nil, fmt.Errorf("decode json: %v", err)
+	}
+
+	sessions := make([]*models.Session, 0, len(dto))
+	for _, v := range dto {
+		if !v.SupportsRemoteControl || v.DeviceId == ownDevice {
+			continue
+		}
+		sessions = append(sessions, v.toSession())
+	}
+	return sessions, nil
+}
+
+// ControlSession sends playstate command to session.
+func (jf *Jellyfin) ControlSession(session models.Id, command models.SessionCommand) error {
+	name, ok := sessionCommands[command]
+	if !ok {
+		return fmt.Errorf("unknown session command: %d", command)
+	}
+	return jf.postSession(fmt.Sprintf("/Sessions/%s/Playing/%s", session, name), nil, &params{})
+}
+
+// SeekSession seeks currently playing song in session.
+func (jf *Jellyfin) SeekSession(session models.Id, position interfaces.AudioTick) error {
+	params := params{}
+	params["SeekPositionTicks"] = strconv.FormatInt(int64(position)*ticksToMillisecond, 10)
+	return jf.postSession(fmt.Sprintf("/Sessions/%s/Playing/Seek", session), nil, &params)
+}
+
+// SetSessionVolume sets session volume.
+func (jf *Jellyfin) SetSessionVolume(session models.Id, volume interfaces.AudioVolume) error {
+	command := map[string]interface{}{
+		"Name": "SetVolume",
+		"Arguments": map[string]string{
+			"Volume": strconv.Itoa(int(volume)),
+		},
+	}
+	body, err := json.Marshal(command)
+	if err != nil {
+		return fmt.Errorf("json: %v", err)
+	}
+	return jf.postSession(fmt.Sprintf("/Sessions/%s/Command", session), &body, &params{})
+}
+
+// PlayOnSession replaces queue in session with items and starts playing.
+func (jf *Jellyfin) PlayOnSession(session models.Id, items []models.Id) error {
+	if len(items) == 0 {
+		return fmt.Errorf("no items to play")
+	}
+	ids := make([]string, len(items))
+	for i, v := range items {
+		ids[i] = v.String()
+	}
+	params := params{}
+	params["PlayCommand"] = "PlayNow"
+	params["ItemIds"] = strings.Join(ids, ",")
+	return jf.postSession(fmt.Sprintf("/Sessions/%s/Playing", session), nil, &params)
+}
+
+func (jf *Jellyfin) postSession(url string, body *[]byte, params *params) error {
+	resp, err := jf.post(context.Background(), url, body, params)
+	if resp != nil {
+		resp.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("control session: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package jellyfin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"tryffel.net/go/jellycli/models"
+)
+
+func Test_parseSessions(t *testing.T) {
+	body := `[
+{"PlayState":{"PositionTicks":615000000,"CanSeek":true,"IsPaused":true,"IsMuted":false,"VolumeLevel":70,
+"RepeatMode":"RepeatNone"},"Id":"s1","UserId":"user","UserName":"user","Client":"Jellyfin Web",
+"DeviceName":"Firefox","DeviceId":"web","SupportsRemoteControl":true,
+"NowPlayingItem":{"Name":"Song","Id":"song-1","RunTimeTicks":2400000000,"Type":"Audio","AlbumId":"album-1",
+"Album":"Album","ArtistItems":[{"Name":"Artist","Id":"artist-1"}]}},
+{"PlayState":{"CanSeek":false,"IsPaused":false,"IsMuted":false},"Id":"s2","UserName":"user",
+"Client":"Jellyfin Android","DeviceName":"Phone","DeviceId":"phone","SupportsRemoteControl":true},
+{"PlayState":{},"Id":"s3","Client":"Jellycli","DeviceName":"Terminal","DeviceId":"own","SupportsRemoteControl":true},
+{"PlayState":{},"Id":"s4","Client":"Kodi","DeviceName":"TV","DeviceId":"tv","SupportsRemoteControl":false}
+]`
+
+	want := []*models.Session{
+		{
+			Id:         "s1",
+			UserName:   "user",
+			Client:     "Jellyfin Web",
+			DeviceName: "Firefox",
+			NowPlaying: &models.Song{
+				Id:       "song-1",
+				Name:     "Song",
+				Duration: 240,
+				Album:    "album-1",
+				Artists:  []models.IdName{{Id: "artist-1", Name: "Artist"}},
+			},
+			Album:    "Album",
+			Position: 61,
+			Paused:   true,
+			Volume:   70,
+			CanSeek:  true,
+		},
+		{
+			Id:         "s2",
+			UserName:   "user",
+			Client:     "Jellyfin Android",
+			DeviceName: "Phone",
+		},
+	}
+
+	got, err := parseSessions(strings.NewReader(body), "own")
+	if err != nil {
+		t.Errorf("parseSessions() error = %v", err)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSessions() got = %v, want %v", got, want)
+	}
+}
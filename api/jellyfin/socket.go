@@ -19,17 +19,20 @@
 package jellyfin
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 	"math"
+	"math/rand"
 	"net/url"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
+	"tryffel.net/go/jellycli/config"
 	"tryffel.net/go/jellycli/interfaces"
 	"tryffel.net/go/jellycli/models"
 )
@@ -37,10 +40,21 @@ import (
 const (
 	pongTimeout = 10 * time.Second
 	pingPeriod  = (pongTimeout * 9) / 10
+
+	// how often socket state is checked and keepalive sent
+	socketCheckInterval = 2 * time.Second
+	// reconnection delay doubles after every failed attempt up to maximum
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 2 * time.Minute
+
+	// rewind and fast forward amounts, same as in Jellyfin web client
+	remoteRewind      = interfaces.AudioTick(10000)
+	remoteFastForward = interfaces.AudioTick(30000)
 )
 
 func (jf *Jellyfin) connectSocket() error {
 	if jf.token == "" {
+		jf.setSocketState(socketDisconnected)
 		return fmt.Errorf("no access token")
 	}
 	u, err := url.Parse(jf.host)
@@ -58,7 +72,7 @@ func (jf *Jellyfin) connectSocket() error {
 	socket, _, err := dialer.Dial(
 		fmt.Sprintf("%s://%s/socket?api_key=%s&deviceId=%s", scheme, host, jf.token, jf.DeviceId), nil)
 	if err != nil {
-		jf.socketState = socketDisconnected
+		jf.setSocketState(socketDisconnected)
 		return fmt.Errorf("websocket connection failed: %v", err)
 	}
 	jf.socketLock.Lock()
@@ -76,9 +90,17 @@ func (jf *Jellyfin) connectSocket() error {
 	})
 
 	jf.socketState = socketConnected
+	jf.keepAlive = 0
+	jf.lastMessage = time.Now()
 	return nil
 }
 
+func (jf *Jellyfin) setSocketState(state socketState) {
+	jf.socketLock.Lock()
+	defer jf.socketLock.Unlock()
+	jf.socketState = state
+}
+
 func (jf *Jellyfin) handleSocketOutbount(msg interface{}) error {
 	if jf.socket == nil {
 		return fmt.Errorf("socket not open")
@@ -88,17 +110,29 @@ func (jf *Jellyfin) handleSocketOutbount(msg interface{}) error {
 
 // read next message from socket in blocking mode. Messages are read as long as socket connection is ok
 func (jf *Jellyfin) readMessage() {
-	if jf.WebsocketOk() {
-		msgType, buff, err := jf.socket.ReadMessage()
+	jf.socketLock.RLock()
+	socket := jf.socket
+	ok := jf.socketState == socketConnected && socket != nil
+	jf.socketLock.RUnlock()
+	if !ok {
+		return
+	}
+
+	msgType, buff, err := socket.ReadMessage()
+	if err != nil {
+		jf.handleSocketError(socket, err)
+		return
+	}
+	jf.socketLock.Lock()
+	jf.lastMessage = time.Now()
+	jf.socketLock.Unlock()
+	if msgType == websocket.TextMessage {
+		err = jf.parseInboudMessage(&buff)
 		if err != nil {
-			jf.handleSocketError(err)
-		}
-		if msgType == websocket.TextMessage {
-			err = jf.parseInboudMessage(&buff)
-			jf.handleSocketError(err)
+			logrus.Errorf("handle websocket message: %v", err)
 		}
-		go jf.readMessage()
 	}
+	go jf.readMessage()
 }
 
 type webSocketInboudMsg struct {
@@ -106,6 +140,28 @@ type webSocketInboudMsg struct {
 	Data        interface{} `json:"Data"`
 }
 
+// libraryChanged is data of LibraryChanged message.
+type libraryChanged struct {
+	ItemsAdded   []models.Id `json:"ItemsAdded"`
+	ItemsUpdated []models.Id `json:"ItemsUpdated"`
+	ItemsRemoved []models.Id `json:"ItemsRemoved"`
+}
+
+// userDataChanged is data of UserDataChanged message.
+type userDataChanged struct {
+	UserId       string `json:"UserId"`
+	UserDataList []struct {
+		userData
+		ItemId models.Id `json:"ItemId"`
+	} `json:"UserDataList"`
+}
+
+// refreshProgress is data of RefreshProgress message. Progress is percentage as string.
+type refreshProgress struct {
+	ItemId   string `json:"ItemId"`
+	Progress string `json:"Progress"`
+}
+
 type controlCommand struct {
 	Name      string `json:"Name"`
 	Arguments interface{}
@@ -122,43 +178,43 @@ func (jf *Jellyfin) parseInboudMessage(buff *[]byte) error {
 		return fmt.Errorf("parse json: %v, body: %s", err, str)
 	}
 
+	switch msg.MessageType {
+	case "ForceKeepAlive":
+		// server closes connection unless keepalive is sent within given seconds
+		seconds, _ := msg.Data.(float64)
+		jf.socketLock.Lock()
+		jf.keepAlive = time.Duration(seconds) * time.Second
+		jf.socketLock.Unlock()
+		return nil
+	case "KeepAlive":
+		return nil
+	}
+
 	dataMap, ok := msg.Data.(map[string]interface{})
 	if !ok {
-		if msg.MessageType != "ForceKeepAlive" {
-			logrus.Errorf("Unknown websocket event: %v", msg)
-		}
+		logrus.Errorf("Unknown websocket event: %v", msg)
 		return nil
 	}
 
 	cmd := strings.ToLower(msg.MessageType)
+	switch cmd {
+	case "librarychanged", "userdatachanged", "refreshprogress":
+		return jf.parseLibraryMessage(cmd, buff)
+	case "syncplaygroupupdate", "syncplaycommand":
+		return jf.parseSyncPlayMessage(cmd, buff)
+	}
 	if cmd == "generalcommand" {
-		name := dataMap["Name"]
-		ar := dataMap["Arguments"]
-		args, ok := ar.(map[string]interface{})
-		if ok {
-			switch name {
-			case "SetVolume":
-				vol := args["Volume"]
-				volume, err := strconv.Atoi(vol.(string))
-				if err != nil {
-					logrus.Error("Invalid volume parameter")
-				} else {
-					volume := interfaces.AudioVolume(volume)
-					jf.player.SetVolume(volume)
-				}
-			case "ToggleMute":
-				jf.player.ToggleMute()
-			default:
-				logrus.Warning("unknown socket command: ", name)
-			}
-		} else {
-			logrus.Error("unexpected command format from websocket, expected general command args map[string]interface, got", jf)
+		name, _ := dataMap["Name"].(string)
+		args, ok := dataMap["Arguments"].(map[string]interface{})
+		if !ok && dataMap["Arguments"] != nil {
+			logrus.Error("unexpected command format from websocket, expected general command args map[string]interface, got", msg.Data)
 		}
+		err = jf.pushGeneralCommand(name, args)
 	} else if cmd == "playstate" {
 		rawCmd := dataMap["Command"]
 		cmd, ok := rawCmd.(string)
 		if ok {
-			err = jf.pushCommand(cmd)
+			err = jf.pushCommand(cmd, dataMap)
 		}
 	} else if cmd == "play" {
 		var items []string
@@ -175,7 +231,7 @@ func (jf *Jellyfin) parseInboudMessage(buff *[]byte) error {
 		}
 		index, ok := dataMap["StartIndex"].(float64)
 		startIndex := 0
-		if ok {
+		if ok && int(index) < len(items) {
 			startIndex = int(index)
 		}
 
@@ -189,12 +245,165 @@ func (jf *Jellyfin) parseInboudMessage(buff *[]byte) error {
 	return err
 }
 
-func (jf *Jellyfin) pushCommand(cmd string) error {
+// parseLibraryMessage parses message about changes in library. Changed items are removed from cache
+// and library changed callbacks are called.
+func (jf *Jellyfin) parseLibraryMessage(cmd string, buff *[]byte) error {
+	changes := &models.LibraryChanges{}
+	switch cmd {
+	case "librarychanged":
+		msg := struct {
+			Data libraryChanged `json:"Data"`
+		}{}
+		err := json.Unmarshal(*buff, &msg)
+		if err != nil {
+			return fmt.Errorf("parse library changed: %v", err)
+		}
+		changes.Added = msg.Data.ItemsAdded
+		changes.Updated = msg.Data.ItemsUpdated
+		changes.Removed = msg.Data.ItemsRemoved
+		// lists of items are not tracked by id, so they all might be outdated
+		jf.cache.Flush()
+		logrus.Debugf("Library changed: %d added, %d updated, %d removed",
+			len(changes.Added), len(changes.Updated), len(changes.Removed))
+	case "userdatachanged":
+		msg := struct {
+			Data userDataChanged `json:"Data"`
+		}{}
+		err := json.Unmarshal(*buff, &msg)
+		if err != nil {
+			return fmt.Errorf("parse user data changed: %v", err)
+		}
+		if msg.Data.UserId != jf.userId {
+			return nil
+		}
+		for _, v := range msg.Data.UserDataList {
+			jf.cache.Delete(v.ItemId)
+			changes.UserData = append(changes.UserData, models.UserData{
+				Item:      v.ItemId,
+				Favorite:  v.IsFavorite,
+				PlayCount: v.PlayCount,
+				Played:    v.Played,
+			})
+		}
+	case "refreshprogress":
+		msg := struct {
+			Data refreshProgress `json:"Data"`
+		}{}
+		err := json.Unmarshal(*buff, &msg)
+		if err != nil {
+			return fmt.Errorf("parse refresh progress: %v", err)
+		}
+		progress, err := strconv.ParseFloat(msg.Data.Progress, 64)
+		if err != nil {
+			return fmt.Errorf("parse refresh progress: %v", err)
+		}
+		logrus.Tracef("Library scan progress: %.1f %%", progress)
+		if progress < 100 {
+			return nil
+		}
+		changes.ScanCompleted = true
+	}
+
+	jf.callbackLock.RLock()
+	callbacks := jf.libraryChangedCb
+	jf.callbackLock.RUnlock()
+	for _, cb := range callbacks {
+		cb(changes)
+	}
+	return nil
+}
+
+// pushGeneralCommand handles general command. Arguments are strings.
+func (jf *Jellyfin) pushGeneralCommand(name string, args map[string]interface{}) error {
+	if jf.player == nil {
+		return nil
+	}
+
+	arg := func(key string) string {
+		value, _ := args[key].(string)
+		return value
+	}
+
+	switch name {
+	case "SetVolume":
+		volume, err := strconv.Atoi(arg("Volume"))
+		if err != nil {
+			return fmt.Errorf("invalid volume: %v", err)
+		}
+		jf.player.SetVolume(interfaces.AudioVolume(0).Add(volume))
+	case "VolumeUp":
+		jf.player.SetVolume(jf.getPlayerStatus().Volume.Add(config.VolumeStepSize))
+	case "VolumeDown":
+		jf.player.SetVolume(jf.getPlayerStatus().Volume.Add(-config.VolumeStepSize))
+	case "Mute":
+		jf.player.SetMute(true)
+	case "Unmute":
+		jf.player.SetMute(false)
+	case "ToggleMute":
+		jf.player.ToggleMute()
+	case "SetShuffleQueue":
+		switch mode := arg("ShuffleMode"); mode {
+		case "Shuffle":
+			jf.player.SetShuffle(true)
+		case "Sorted":
+			jf.player.SetShuffle(false)
+		default:
+			return fmt.Errorf("unknown shuffle mode: %s", mode)
+		}
+	case "SetRepeatMode":
+		mode := arg("RepeatMode")
+		for repeat, v := range repeatModes {
+			if v == mode {
+				jf.player.SetRepeat(repeat)
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown repeat mode: %s", mode)
+	case "DisplayMessage":
+		msg := models.ServerMessage{Header: arg("Header"), Text: arg("Text")}
+		if timeout, err := strconv.Atoi(arg("TimeoutMs")); err == nil && timeout > 0 {
+			msg.Timeout = time.Duration(timeout) * time.Millisecond
+		}
+		logrus.Infof("Message from server: %s: %s", msg.Header, msg.Text)
+		jf.callbackLock.RLock()
+		callbacks := jf.messageCb
+		jf.callbackLock.RUnlock()
+		for _, cb := range callbacks {
+			cb(msg)
+		}
+	case "PlayMediaSource":
+		// songs have only one media source, so item is played like with PlayNow
+		id := arg("ItemId")
+		if id == "" {
+			return fmt.Errorf("play media source: no item id: %v", args)
+		}
+		go jf.pushSongsToQueue([]string{id}, "PlayNow")
+	case "SetAudioStreamIndex":
+		// songs are always played from their only audio stream
+		logrus.Debugf("Ignore remote command %s: %v", name, args)
+	default:
+		return fmt.Errorf("unknown socket command: %s", name)
+	}
+	return nil
+}
+
+// pushCommand handles playstate command. Data contains additional arguments, e.g. seek position.
+func (jf *Jellyfin) pushCommand(cmd string, data map[string]interface{}) error {
 	if jf.player == nil {
 		return nil
 	}
 
 	switch cmd {
+	case "Seek":
+		ticks, ok := data["SeekPositionTicks"].(float64)
+		if !ok {
+			return fmt.Errorf("seek: invalid position: %v", data["SeekPositionTicks"])
+		}
+		jf.player.SeekTo(interfaces.AudioTick(int64(ticks) / ticksToMillisecond))
+	case "Rewind":
+		jf.player.Seek(-remoteRewind)
+	case "FastForward":
+		jf.player.Seek(remoteFastForward)
 	case "PlayPause":
 		jf.player.PlayPause()
 	case "NextTrack":
@@ -217,38 +426,32 @@ func (jf *Jellyfin) pushCommand(cmd string) error {
 	return nil
 }
 
-// handle errors and try reconnecting
-func (jf *Jellyfin) handleSocketError(err error) {
+// handle socket errors and schedule reconnecting. Errors from previous, already replaced sockets are ignored.
+func (jf *Jellyfin) handleSocketError(socket *websocket.Conn, err error) {
 	if err == nil {
 		return
 	}
 
 	jf.socketLock.Lock()
 	defer jf.socketLock.Unlock()
-	if jf.socketState == socketReConnecting {
+	if socket != jf.socket || jf.socketState != socketConnected {
 		return
 	}
 
-	awaitReconnect := func(reason string) {
-		if jf.socketState == socketConnected {
-			jf.socketState = socketAwaitsReconnecting
-			logrus.Warning("Websocket closed: ", reason)
-		}
-	}
-
+	reason := err.Error()
 	if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
-		awaitReconnect("going away")
+		reason = "going away"
 	} else if websocket.IsUnexpectedCloseError(err, websocket.CloseAbnormalClosure) {
-		awaitReconnect("abnormal closure")
+		reason = "abnormal closure"
 	} else if errors.Is(err, syscall.ECONNABORTED) {
 		// happens when disconnected from network, e.g. computer on sleep
-		awaitReconnect("connection aborted")
+		reason = "connection aborted"
 	} else if strings.Contains(err.Error(), "i/o timeout") {
 		// happens when disconnected from network, e.g. computer on sleep
-		awaitReconnect("io timeout")
-	} else {
-		logrus.Errorf("unknown socket err: %v", err)
+		reason = "io timeout"
 	}
+	jf.socketState = socketAwaitsReconnecting
+	logrus.Warning("Websocket closed: ", reason)
 }
 
 // WebsocketOk returns true if websocket connection is ok
@@ -258,32 +461,183 @@ func (jf *Jellyfin) WebsocketOk() bool {
 	return jf.socketState == socketConnected
 }
 
-// try reconnecting socket. Return true if success
-func (jf *Jellyfin) reconnectSocket() bool {
+// reconnectSocket closes previous socket, if any, and connects again.
+func (jf *Jellyfin) reconnectSocket() error {
 	jf.socketLock.Lock()
 	jf.socketState = socketReConnecting
-	var err error
 	if jf.socket != nil {
-		err = jf.socket.Close()
+		err := jf.socket.Close()
 		if err != nil {
 			logrus.Debugf("reconnect socket: close socket: %v", err)
-		} else {
-			jf.socket = nil
 		}
+		jf.socket = nil
 	}
-
 	jf.socketLock.Unlock()
-	err = jf.connectSocket()
+
+	err := jf.connectSocket()
 	if err != nil {
 		logrus.Debugf("reconnect socket: %v", err)
-		return false
+		return err
 	}
 	logrus.Warning("Websocket reconnected")
-	return true
+	return nil
+}
+
+// socketReconnected restores session after reconnecting. Server may have created a new session
+// for this device, so capabilities and playback state are sent again.
+func (jf *Jellyfin) socketReconnected() {
+	err := jf.ReportCapabilities()
+	if err != nil {
+		logrus.Errorf("report capabilities after reconnecting: %v", err)
+	}
+	err = jf.resendPlayback()
+	if err != nil {
+		logrus.Errorf("report playback after reconnecting: %v", err)
+	}
+}
+
+// reconnectDelay returns delay before next reconnection attempt. Delay doubles with every failed attempt
+// and half of it is random, so that clients don't all reconnect at once e.g. after server restart.
+// Jitter is in range [0, 1).
+func reconnectDelay(attempt int, jitter float64) time.Duration {
+	delay := reconnectMaxDelay
+	if attempt < 16 && reconnectMinDelay<<uint(attempt) < delay {
+		delay = reconnectMinDelay << uint(attempt)
+	}
+	return delay/2 + time.Duration(jitter*float64(delay/2))
+}
+
+// keepAliveSocket sends keepalive message if server has requested it. Server replies to keepalive,
+// so if nothing has been received within keepalive timeout, connection is considered lost.
+func (jf *Jellyfin) keepAliveSocket() {
+	jf.socketLock.Lock()
+	socket := jf.socket
+	if socket == nil || jf.keepAlive <= 0 {
+		jf.socketLock.Unlock()
+		return
+	}
+	var err error
+	if time.Since(jf.lastMessage) > jf.keepAlive {
+		err = errors.New("keepalive timeout")
+	} else if time.Since(jf.lastKeepAlive) >= jf.keepAlive/2 {
+		logrus.Trace("Websocket send keepalive")
+		jf.lastKeepAlive = time.Now()
+		err = socket.SetWriteDeadline(time.Now().Add(time.Second * 15))
+		if err == nil {
+			err = socket.WriteJSON(map[string]string{"MessageType": "KeepAlive"})
+		}
+	}
+	jf.socketLock.Unlock()
+	jf.handleSocketError(socket, err)
+}
+
+func (jf *Jellyfin) sendPing() {
+	jf.socketLock.Lock()
+	socket := jf.socket
+	var err error
+	if jf.socketState == socketConnected && socket != nil {
+		logrus.Tracef("Websocket send ping")
+		err = socket.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(time.Second*15))
+	}
+	jf.socketLock.Unlock()
+	if err != nil {
+		logrus.Errorf("send ping to socket: %v", err)
+		jf.handleSocketError(socket, err)
+	}
+}
+
+// closeSocket closes socket gracefully, if it's open.
+func (jf *Jellyfin) closeSocket() {
+	jf.socketLock.Lock()
+	defer jf.socketLock.Unlock()
+	if jf.socket == nil {
+		return
+	}
+	err := jf.socket.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	if err != nil {
+		logrus.Errorf("close websocket: %v", err)
+	}
+	jf.socketState = socketDisconnected
+}
+
+// GetRemoteControlStatus returns state of websocket connection.
+func (jf *Jellyfin) GetRemoteControlStatus() models.RemoteControlStatus {
+	jf.callbackLock.RLock()
+	defer jf.callbackLock.RUnlock()
+	return jf.remoteStatus
+}
+
+// AddRemoteControlCallback adds a function that is called every time websocket connection state changes.
+func (jf *Jellyfin) AddRemoteControlCallback(cb func(status models.RemoteControlStatus)) {
+	jf.callbackLock.Lock()
+	defer jf.callbackLock.Unlock()
+	jf.remoteControlCb = append(jf.remoteControlCb, cb)
+}
+
+// AddMessageCallback adds a function that is called every time server sends a message to display.
+func (jf *Jellyfin) AddMessageCallback(cb func(msg models.ServerMessage)) {
+	jf.callbackLock.Lock()
+	defer jf.callbackLock.Unlock()
+	jf.messageCb = append(jf.messageCb, cb)
+}
+
+func (jf *Jellyfin) setRemoteControlStatus(status models.RemoteControlStatus) {
+	jf.callbackLock.Lock()
+	if status == jf.remoteStatus {
+		jf.callbackLock.Unlock()
+		return
+	}
+	jf.remoteStatus = status
+	callbacks := jf.remoteControlCb
+	jf.callbackLock.Unlock()
+	for _, cb := range callbacks {
+		cb(status)
+	}
 }
 
 // push songs to queue.
 func (jf *Jellyfin) pushSongsToQueue(items []string, mode string) {
+	var songs []*models.Song
+	var err error
+	if mode == "PlayInstantMix" {
+		if len(items) == 0 {
+			return
+		}
+		// instant mix is created from first item, which can be any type
+		songs, err = jf.GetInstantMix(context.Background(), &models.Song{Id: models.Id(items[0])})
+	} else {
+		songs, err = jf.getSongsByIds(items)
+	}
+
+	if err != nil {
+		logrus.Errorf("remote control: add songs to queue: get songs from ids: %v", err)
+		return
+	}
+	logrus.Debug("received play event: ", mode)
+
+	// some modes are swapped in other clients, use those for consistency
+	if mode == "PlayNow" || mode == "PlayInstantMix" || mode == "PlayShuffle" {
+		if mode == "PlayShuffle" {
+			rand.Shuffle(len(songs), func(i, j int) {
+				songs[i], songs[j] = songs[j], songs[i]
+			})
+		}
+		jf.player.StopMedia()
+		jf.queue.ClearQueue(true)
+		jf.queue.PlayNext(songs)
+	} else if mode == "PlayLast" {
+		//} else if mode == "PlayNext" {
+		jf.queue.PlayNext(songs)
+	} else if mode == "PlayNext" {
+		//} else if mode == "PlayLast" {
+		jf.queue.AddSongs(songs)
+	} else {
+		logrus.Errorf("unknown remote play mode: %s", mode)
+	}
+}
+
+// get songs by ids, splitting query if needed.
+func (jf *Jellyfin) getSongsByIds(items []string) ([]*models.Song, error) {
 	ids := []models.Id{}
 	for _, v := range items {
 		ids = append(ids, models.Id(v))
@@ -303,7 +657,7 @@ func (jf *Jellyfin) pushSongsToQueue(items []string, mode string) {
 				to = len(ids)
 			}
 			logrus.Debugf("Download songs [%d, %d]", from, to)
-			s, err := jf.GetSongsById(ids[from:to])
+			s, err := jf.GetSongsById(context.Background(), ids[from:to])
 			if err != nil {
 				logrus.Errorf("download songs: %v", err)
 			}
@@ -313,27 +667,8 @@ func (jf *Jellyfin) pushSongsToQueue(items []string, mode string) {
 			logrus.Errorf("some songs were not downloaded: expect %d, got %d", len(ids), len(songs))
 		}
 	} else {
-		songs, err = jf.GetSongsById(ids)
+		songs, err = jf.GetSongsById(context.Background(), ids)
 	}
 
-	if err != nil {
-		logrus.Errorf("remote control: add songs to queue: get songs from ids: %v", err)
-		return
-	}
-	logrus.Debug("received play event: ", mode)
-
-	// some modes are swapped in other clients, use those for consistency
-	if mode == "PlayNow" {
-		jf.player.StopMedia()
-		jf.queue.ClearQueue(true)
-		jf.queue.PlayNext(songs)
-	} else if mode == "PlayLast" {
-		//} else if mode == "PlayNext" {
-		jf.queue.PlayNext(songs)
-	} else if mode == "PlayNext" {
-		//} else if mode == "PlayLast" {
-		jf.queue.AddSongs(songs)
-	} else {
-		logrus.Errorf("unknown remote play mode: %s", mode)
-	}
+	return songs, err
 }
@@ -0,0 +1,398 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package jellyfin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+func TestJellyfin_parseLibraryMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want *models.LibraryChanges
+		// cached item is removed
+		invalidated bool
+	}{
+		{
+			name: "library changed",
+			msg: `{"MessageType":"LibraryChanged","Data":{"CollectionFolders":[],"FoldersAddedTo":[],
+"FoldersRemovedFrom":[],"ItemsAdded":["a1"],"ItemsRemoved":["s2"],"ItemsUpdated":["s3", "s4"]}}`,
+			want: &models.LibraryChanges{
+				Added:   []models.Id{"a1"},
+				Updated: []models.Id{"s3", "s4"},
+				Removed: []models.Id{"s2"},
+			},
+			invalidated: true,
+		},
+		{
+			name: "user data changed",
+			msg: `{"MessageType":"UserDataChanged","Data":{"UserId":"user","UserDataList":[{"Rating":0,
+"PlayedPercentage":0,"PlaybackPositionTicks":0,"PlayCount":3,"IsFavorite":true,"Played":true,
+"Key":"s1","ItemId":"s1"}]}}`,
+			want: &models.LibraryChanges{
+				UserData: []models.UserData{{Item: "s1", Favorite: true, PlayCount: 3, Played: true}},
+			},
+			invalidated: true,
+		},
+		{
+			name: "user data of other user",
+			msg:  `{"MessageType":"UserDataChanged","Data":{"UserId":"other","UserDataList":[{"ItemId":"s1"}]}}`,
+		},
+		{
+			name: "scan ongoing",
+			msg:  `{"MessageType":"RefreshProgress","Data":{"ItemId":"f1","Progress":"45.5"}}`,
+		},
+		{
+			name: "scan completed",
+			msg:  `{"MessageType":"RefreshProgress","Data":{"ItemId":"f1","Progress":"100"}}`,
+			want: &models.LibraryChanges{ScanCompleted: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, _ := NewCache()
+			cache.Put("s1", &models.Song{Id: "s1"}, true)
+			jf := &Jellyfin{cache: cache, userId: "user"}
+
+			var got *models.LibraryChanges
+			jf.AddLibraryChangedCallback(func(changes *models.LibraryChanges) {
+				got = changes
+			})
+
+			buff := []byte(tt.msg)
+			err := jf.parseInboudMessage(&buff)
+			if err != nil {
+				t.Fatalf("parse message: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("library changes: got %+v, want %+v", got, tt.want)
+			}
+			_, found := cache.Get("s1")
+			if found == tt.invalidated {
+				t.Errorf("cached item found: %t, want %t", found, !tt.invalidated)
+			}
+		})
+	}
+}
+
+// mockPlayer records player calls.
+type mockPlayer struct {
+	lock  sync.Mutex
+	calls []string
+}
+
+func (m *mockPlayer) call(name string, args ...interface{}) {
+	if len(args) > 0 {
+		name += fmt.Sprint(args...)
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.calls = append(m.calls, name)
+}
+
+// waitCalls waits until there are at least n calls, since play commands are handled in background.
+func (m *mockPlayer) waitCalls(n int) []string {
+	deadline := time.Now().Add(time.Second * 2)
+	for {
+		m.lock.Lock()
+		calls := m.calls
+		m.lock.Unlock()
+		if len(calls) >= n || time.Now().After(deadline) {
+			return calls
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func (m *mockPlayer) PlayPause()                                            { m.call("PlayPause") }
+func (m *mockPlayer) Pause()                                                { m.call("Pause") }
+func (m *mockPlayer) Continue()                                             { m.call("Continue") }
+func (m *mockPlayer) StopMedia()                                            { m.call("StopMedia") }
+func (m *mockPlayer) Next()                                                 { m.call("Next") }
+func (m *mockPlayer) Previous()                                             { m.call("Previous") }
+func (m *mockPlayer) Seek(ticks interfaces.AudioTick)                       { m.call("Seek", ticks) }
+func (m *mockPlayer) SeekTo(position interfaces.AudioTick)                  { m.call("SeekTo", position) }
+func (m *mockPlayer) AddStatusCallback(func(status interfaces.AudioStatus)) {}
+func (m *mockPlayer) SetVolume(volume interfaces.AudioVolume)               { m.call("SetVolume", volume) }
+func (m *mockPlayer) SetMute(muted bool)                                    { m.call("SetMute", muted) }
+func (m *mockPlayer) ToggleMute()                                           { m.call("ToggleMute") }
+func (m *mockPlayer) SetShuffle(enabled bool)                               { m.call("SetShuffle", enabled) }
+func (m *mockPlayer) SetRepeat(mode interfaces.RepeatMode)                  { m.call("SetRepeat", mode) }
+func (m *mockPlayer) SetRadio(enabled bool)                                 { m.call("SetRadio", enabled) }
+func (m *mockPlayer) SetSleepTimer(timer interfaces.SleepTimer)             { m.call("SetSleepTimer") }
+func (m *mockPlayer) SetStopAfterCurrent(enabled bool)                      { m.call("SetStopAfterCurrent", enabled) }
+
+// mockQueue records clearing queue and added songs. Other methods are not used.
+type mockQueue struct {
+	interfaces.QueueController
+	player *mockPlayer
+}
+
+func (m *mockQueue) ClearQueue(first bool)         { m.player.call("ClearQueue", first) }
+func (m *mockQueue) AddSongs(songs []*models.Song) { m.player.call("AddSongs", songIds(songs)) }
+func (m *mockQueue) PlayNext(songs []*models.Song) { m.player.call("PlayNext", songIds(songs)) }
+
+func songIds(songs []*models.Song) []models.Id {
+	ids := make([]models.Id, len(songs))
+	for i, v := range songs {
+		ids[i] = v.Id
+	}
+	return ids
+}
+
+func TestJellyfin_parseInboudMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    string
+		volume interfaces.AudioVolume
+		want   []string
+		// message shown to user
+		message *models.ServerMessage
+		wantErr bool
+	}{
+		{
+			name: "set volume",
+			msg:  `{"MessageType":"GeneralCommand","Data":{"Name":"SetVolume","ControllingUserId":"user","Arguments":{"Volume":"35"}}}`,
+			want: []string{"SetVolume35"},
+		},
+		{
+			name:   "volume up",
+			msg:    `{"MessageType":"GeneralCommand","Data":{"Name":"VolumeUp","ControllingUserId":"user","Arguments":{}}}`,
+			volume: 50,
+			want:   []string{"SetVolume55"},
+		},
+		{
+			name:   "volume down",
+			msg:    `{"MessageType":"GeneralCommand","Data":{"Name":"VolumeDown","ControllingUserId":"user","Arguments":{}}}`,
+			volume: 2,
+			want:   []string{"SetVolume0"},
+		},
+		{
+			name: "mute",
+			msg:  `{"MessageType":"GeneralCommand","Data":{"Name":"Mute","ControllingUserId":"user","Arguments":{}}}`,
+			want: []string{"SetMutetrue"},
+		},
+		{
+			name: "unmute",
+			msg:  `{"MessageType":"GeneralCommand","Data":{"Name":"Unmute","ControllingUserId":"user","Arguments":{}}}`,
+			want: []string{"SetMutefalse"},
+		},
+		{
+			name: "toggle mute",
+			msg:  `{"MessageType":"GeneralCommand","Data":{"Name":"ToggleMute","ControllingUserId":"user"}}`,
+			want: []string{"ToggleMute"},
+		},
+		{
+			name: "shuffle",
+			msg:  `{"MessageType":"GeneralCommand","Data":{"Name":"SetShuffleQueue","ControllingUserId":"user","Arguments":{"ShuffleMode":"Shuffle"}}}`,
+			want: []string{"SetShuffletrue"},
+		},
+		{
+			name: "sorted",
+			msg:  `{"MessageType":"GeneralCommand","Data":{"Name":"SetShuffleQueue","ControllingUserId":"user","Arguments":{"ShuffleMode":"Sorted"}}}`,
+			want: []string{"SetShufflefalse"},
+		},
+		{
+			name: "repeat all",
+			msg:  `{"MessageType":"GeneralCommand","Data":{"Name":"SetRepeatMode","ControllingUserId":"user","Arguments":{"RepeatMode":"RepeatAll"}}}`,
+			want: []string{fmt.Sprint("SetRepeat", interfaces.RepeatAll)},
+		},
+		{
+			name: "repeat one",
+			msg:  `{"MessageType":"GeneralCommand","Data":{"Name":"SetRepeatMode","ControllingUserId":"user","Arguments":{"RepeatMode":"RepeatOne"}}}`,
+			want: []string{fmt.Sprint("SetRepeat", interfaces.RepeatOne)},
+		},
+		{
+			name: "display message",
+			msg: `{"MessageType":"GeneralCommand","Data":{"Name":"DisplayMessage","ControllingUserId":"user",
+"Arguments":{"Header":"Hello","Text":"world","TimeoutMs":"5000"}}}`,
+			message: &models.ServerMessage{Header: "Hello", Text: "world", Timeout: time.Second * 5},
+		},
+		{
+			name: "display message without timeout",
+			msg: `{"MessageType":"GeneralCommand","Data":{"Name":"DisplayMessage","ControllingUserId":"user",
+"Arguments":{"Header":"Hello","Text":"world"}}}`,
+			message: &models.ServerMessage{Header: "Hello", Text: "world"},
+		},
+		{
+			// accepted without doing anything, songs have only one audio stream
+			name: "audio stream index",
+			msg:  `{"MessageType":"GeneralCommand","Data":{"Name":"SetAudioStreamIndex","ControllingUserId":"user","Arguments":{"Index":"1"}}}`,
+		},
+		{
+			name: "play media source",
+			msg: `{"MessageType":"GeneralCommand","Data":{"Name":"PlayMediaSource","ControllingUserId":"user",
+"Arguments":{"ItemId":"s1","MediaSourceId":"s1","AudioStreamIndex":"1"}}}`,
+			want: []string{"StopMedia", "ClearQueuetrue", "PlayNext[s1]"},
+		},
+		{
+			name:    "play media source without item",
+			msg:     `{"MessageType":"GeneralCommand","Data":{"Name":"PlayMediaSource","ControllingUserId":"user","Arguments":{}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown command",
+			msg:     `{"MessageType":"GeneralCommand","Data":{"Name":"GoHome","ControllingUserId":"user","Arguments":{}}}`,
+			wantErr: true,
+		},
+		{
+			name: "seek",
+			msg:  `{"MessageType":"Playstate","Data":{"Command":"Seek","SeekPositionTicks":1235000000,"ControllingUserId":"user"}}`,
+			want: []string{"SeekTo123500"},
+		},
+		{
+			name: "rewind",
+			msg:  `{"MessageType":"Playstate","Data":{"Command":"Rewind","ControllingUserId":"user"}}`,
+			want: []string{"Seek-10000"},
+		},
+		{
+			name: "fast forward",
+			msg:  `{"MessageType":"Playstate","Data":{"Command":"FastForward","ControllingUserId":"user"}}`,
+			want: []string{"Seek30000"},
+		},
+		{
+			name: "play pause",
+			msg:  `{"MessageType":"Playstate","Data":{"Command":"PlayPause","ControllingUserId":"user"}}`,
+			want: []string{"PlayPause"},
+		},
+		{
+			name: "next",
+			msg:  `{"MessageType":"Playstate","Data":{"Command":"NextTrack","ControllingUserId":"user"}}`,
+			want: []string{"Next"},
+		},
+		{
+			name: "stop",
+			msg:  `{"MessageType":"Playstate","Data":{"Command":"Stop","ControllingUserId":"user"}}`,
+			want: []string{"StopMedia", "ClearQueuetrue"},
+		},
+		{
+			name: "play now",
+			msg: `{"MessageType":"Play","Data":{"ItemIds":["s1","s2","s3"],"StartPositionTicks":0,"PlayCommand":"PlayNow",
+"ControllingUserId":"user","SubtitleStreamIndex":null,"AudioStreamIndex":null,"MediaSourceId":null,"StartIndex":1}}`,
+			want: []string{"StopMedia", "ClearQueuetrue", "PlayNext[s2 s3]"},
+		},
+		{
+			name: "play next",
+			msg: `{"MessageType":"Play","Data":{"ItemIds":["s1","s2"],"StartPositionTicks":0,"PlayCommand":"PlayNext",
+"ControllingUserId":"user","SubtitleStreamIndex":null,"AudioStreamIndex":null,"MediaSourceId":null}}`,
+			want: []string{"AddSongs[s1 s2]"},
+		},
+		{
+			name: "play last",
+			msg: `{"MessageType":"Play","Data":{"ItemIds":["s1","s2"],"StartPositionTicks":0,"PlayCommand":"PlayLast",
+"ControllingUserId":"user","SubtitleStreamIndex":null,"AudioStreamIndex":null,"MediaSourceId":null}}`,
+			want: []string{"PlayNext[s1 s2]"},
+		},
+		{
+			name: "play shuffle",
+			msg: `{"MessageType":"Play","Data":{"ItemIds":["s1"],"StartPositionTicks":0,"PlayCommand":"PlayShuffle",
+"ControllingUserId":"user","SubtitleStreamIndex":null,"AudioStreamIndex":null,"MediaSourceId":null}}`,
+			want: []string{"StopMedia", "ClearQueuetrue", "PlayNext[s1]"},
+		},
+		{
+			name: "play instant mix",
+			msg: `{"MessageType":"Play","Data":{"ItemIds":["a1"],"StartPositionTicks":0,"PlayCommand":"PlayInstantMix",
+"ControllingUserId":"user","SubtitleStreamIndex":null,"AudioStreamIndex":null,"MediaSourceId":null}}`,
+			want: []string{"StopMedia", "ClearQueuetrue", "PlayNext[m1 m2]"},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := strings.Split(r.URL.Query().Get("Ids"), ",")
+		if r.URL.Path == "/Items/a1/InstantMix" {
+			ids = []string{"m1", "m2"}
+		}
+		items := make([]string, len(ids))
+		for i, id := range ids {
+			items[i] = fmt.Sprintf(`{"Id":"%s","Type":"Audio"}`, id)
+		}
+		fmt.Fprintf(w, `{"Items":[%s],"TotalRecordCount":%d}`, strings.Join(items, ","), len(items))
+	}))
+	defer server.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player := &mockPlayer{}
+			jf := &Jellyfin{host: server.URL, client: server.Client(), userId: "user",
+				queue: &mockQueue{player: player}}
+			jf.SetPlayer(player)
+			jf.playerStatusChanged(interfaces.AudioStatus{Volume: tt.volume})
+			var message *models.ServerMessage
+			jf.AddMessageCallback(func(msg models.ServerMessage) {
+				message = &msg
+			})
+
+			buff := []byte(tt.msg)
+			err := jf.parseInboudMessage(&buff)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseInboudMessage() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if calls := player.waitCalls(len(tt.want)); !reflect.DeepEqual(calls, tt.want) {
+				t.Errorf("parseInboudMessage() calls = %v, want %v", calls, tt.want)
+			}
+			if !reflect.DeepEqual(message, tt.message) {
+				t.Errorf("parseInboudMessage() message = %+v, want %+v", message, tt.message)
+			}
+		})
+	}
+}
+
+func Test_reconnectDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		attempt int
+		jitter  float64
+		want    time.Duration
+	}{
+		{name: "first attempt", attempt: 0, jitter: 0, want: time.Millisecond * 500},
+		{name: "first attempt jitter", attempt: 0, jitter: 0.5, want: time.Millisecond * 750},
+		{name: "doubles", attempt: 3, jitter: 0, want: time.Second * 4},
+		{name: "limited", attempt: 10, jitter: 0.99, want: reconnectMaxDelay/2 + time.Duration(0.99*float64(reconnectMaxDelay/2))},
+		{name: "many attempts", attempt: 100, jitter: 0, want: reconnectMaxDelay / 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reconnectDelay(tt.attempt, tt.jitter); got != tt.want {
+				t.Errorf("reconnectDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJellyfin_parseKeepAlive(t *testing.T) {
+	jf := &Jellyfin{}
+	buff := []byte(`{"MessageType":"ForceKeepAlive","Data":60}`)
+	err := jf.parseInboudMessage(&buff)
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	if jf.keepAlive != time.Minute {
+		t.Errorf("keepalive: got %v, want %v", jf.keepAlive, time.Minute)
+	}
+}
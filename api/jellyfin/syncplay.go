@@ -0,0 +1,352 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package jellyfin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/models"
+)
+
+// syncPlayGroup is group info in SyncPlay messages and group list.
+type syncPlayGroup struct {
+	GroupId      string   `json:"GroupId"`
+	GroupName    string   `json:"GroupName"`
+	State        string   `json:"State"`
+	Participants []string `json:"Participants"`
+}
+
+func (g *syncPlayGroup) toGroup() *models.SyncPlayGroup {
+	return &models.SyncPlayGroup{
+		Id:           models.Id(g.GroupId),
+		Name:         g.GroupName,
+		State:        g.State,
+		Participants: g.Participants,
+	}
+}
+
+// syncPlayGroupUpdate is data of SyncPlayGroupUpdate message. Content of data depends on type.
+type syncPlayGroupUpdate struct {
+	GroupId string          `json:"GroupId"`
+	Type    string          `json:"Type"`
+	Data    json.RawMessage `json:"Data"`
+}
+
+type syncPlayState struct {
+	State  string `json:"State"`
+	Reason string `json:"Reason"`
+}
+
+type syncPlayQueue struct {
+	Reason   string `json:"Reason"`
+	Playlist []struct {
+		ItemId         models.Id `json:"ItemId"`
+		PlaylistItemId models.Id `json:"PlaylistItemId"`
+	} `json:"Playlist"`
+	PlayingItemIndex   int   `json:"PlayingItemIndex"`
+	StartPositionTicks int64 `json:"StartPositionTicks"`
+	IsPlaying          bool  `json:"IsPlaying"`
+}
+
+// syncPlayCommand is data of SyncPlayCommand message.
+type syncPlayCommand struct {
+	GroupId        string    `json:"GroupId"`
+	PlaylistItemId models.Id `json:"PlaylistItemId"`
+	When           time.Time `json:"When"`
+	PositionTicks  int64     `json:"PositionTicks"`
+	Command        string    `json:"Command"`
+}
+
+var syncPlayCommands = map[string]models.SyncPlayCommandType{
+	"Unpause": models.SyncPlayUnpause,
+	"Pause":   models.SyncPlayPause,
+	"Seek":    models.SyncPlaySeek,
+	"Stop":    models.SyncPlayStop,
+}
+
+// errors that server sends as group updates
+var syncPlayErrors = map[string]string{
+	"GroupDoesNotExist":   "group does not exist",
+	"CreateGroupDenied":   "not allowed to create group",
+	"JoinGroupDenied":     "not allowed to join group",
+	"LibraryAccessDenied": "no access to all items in group queue",
+}
+
+func durationToTicks(d time.Duration) int64 {
+	return d.Milliseconds() * ticksToMillisecond
+}
+
+func ticksToDuration(ticks int64) time.Duration {
+	return time.Duration(ticks/ticksToMillisecond) * time.Millisecond
+}
+
+// SetSyncPlayHandler sets handler for SyncPlay updates.
+func (jf *Jellyfin) SetSyncPlayHandler(handler api.SyncPlayHandler) {
+	jf.callbackLock.Lock()
+	defer jf.callbackLock.Unlock()
+	jf.syncPlayHandler = handler
+}
+
+func (jf *Jellyfin) getSyncPlayHandler() api.SyncPlayHandler {
+	jf.callbackLock.RLock()
+	defer jf.callbackLock.RUnlock()
+	return jf.syncPlayHandler
+}
+
+// GetSyncPlayGroups returns SyncPlay groups.
+func (jf *Jellyfin) GetSyncPlayGroups() ([]*models.SyncPlayGroup, error) {
+	resp, err := jf.get(context.Background(), "/SyncPlay/List", jf.defaultParams())
+	if resp != nil {
+		defer resp.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get groups: %v", err)
+	}
+
+	dto := []syncPlayGroup{}
+	err = json.NewDecoder(resp).Decode(&dto)
+	if err != nil {
+		return nil, fmt.Errorf("decode json: %v", err)
+	}
+	groups := make([]*models.SyncPlayGroup, len(dto))
+	for i, v := range dto {
+		groups[i] = v.toGroup()
+	}
+	return groups, nil
+}
+
+// CreateSyncPlayGroup creates new group. If name is empty, device name is used.
+func (jf *Jellyfin) CreateSyncPlayGroup(name string) error {
+	if name == "" {
+		name = fmt.Sprintf("%s on %s", config.AppName, jf.deviceName())
+	}
+	return jf.postSyncPlay("New", map[string]interface{}{"GroupName": name})
+}
+
+// JoinSyncPlayGroup joins group.
+func (jf *Jellyfin) JoinSyncPlayGroup(group models.Id) error {
+	return jf.postSyncPlay("Join", map[string]interface{}{"GroupId": group})
+}
+
+// LeaveSyncPlayGroup leaves current group.
+func (jf *Jellyfin) LeaveSyncPlayGroup() error {
+	return jf.postSyncPlay("Leave", nil)
+}
+
+// SyncPlayRequest sends request to group.
+func (jf *Jellyfin) SyncPlayRequest(request *models.SyncPlayRequest) error {
+	state := map[string]interface{}{
+		"When":           request.When.UTC(),
+		"PositionTicks":  durationToTicks(request.Position),
+		"IsPlaying":      request.IsPlaying,
+		"PlaylistItemId": request.PlaylistItem,
+	}
+
+	switch request.Type {
+	case models.SyncPlayRequestPause:
+		return jf.postSyncPlay("Pause", nil)
+	case models.SyncPlayRequestUnpause:
+		return jf.postSyncPlay("Unpause", nil)
+	case models.SyncPlayRequestSeek:
+		return jf.postSyncPlay("Seek", map[string]interface{}{"PositionTicks": durationToTicks(request.Position)})
+	case models.SyncPlayRequestNext:
+		return jf.postSyncPlay("NextItem", map[string]interface{}{"PlaylistItemId": request.PlaylistItem})
+	case models.SyncPlayRequestPrevious:
+		return jf.postSyncPlay("PreviousItem", map[string]interface{}{"PlaylistItemId": request.PlaylistItem})
+	case models.SyncPlayRequestBuffering:
+		return jf.postSyncPlay("Buffering", state)
+	case models.SyncPlayRequestReady:
+		return jf.postSyncPlay("Ready", state)
+	case models.SyncPlayRequestSetQueue:
+		return jf.postSyncPlay("SetNewQueue", map[string]interface{}{
+			"PlayingQueue":        request.Items,
+			"PlayingItemPosition": 0,
+			"StartPositionTicks":  durationToTicks(request.Position),
+		})
+	case models.SyncPlayRequestPing:
+		return jf.postSyncPlay("Ping", map[string]interface{}{"Ping": request.Ping.Milliseconds()})
+	}
+	return fmt.Errorf("unknown SyncPlay request: %d", request.Type)
+}
+
+func (jf *Jellyfin) postSyncPlay(endpoint string, data map[string]interface{}) error {
+	var body *[]byte
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("json: %v", err)
+		}
+		body = &b
+	}
+	resp, err := jf.post(context.Background(), "/SyncPlay/"+endpoint, body, jf.defaultParams())
+	if resp != nil {
+		resp.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("SyncPlay %s: %v", endpoint, err)
+	}
+	return nil
+}
+
+// GetServerTime returns server time when it received request and sent response.
+func (jf *Jellyfin) GetServerTime() (time.Time, time.Time, error) {
+	resp, err := jf.get(context.Background(), "/GetUtcTime", nil)
+	if resp != nil {
+		defer resp.Close()
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("get server time: %v", err)
+	}
+
+	dto := struct {
+		RequestReceptionTime     time.Time `json:"RequestReceptionTime"`
+		ResponseTransmissionTime time.Time `json:"ResponseTransmissionTime"`
+	}{}
+	err = json.NewDecoder(resp).Decode(&dto)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("decode json: %v", err)
+	}
+	return dto.RequestReceptionTime, dto.ResponseTransmissionTime, nil
+}
+
+// parseSyncPlayMessage parses SyncPlay group update or command and passes it to SyncPlay handler.
+func (jf *Jellyfin) parseSyncPlayMessage(cmd string, buff *[]byte) error {
+	handler := jf.getSyncPlayHandler()
+	if handler == nil {
+		return nil
+	}
+
+	if cmd == "syncplaycommand" {
+		msg := struct {
+			Data syncPlayCommand `json:"Data"`
+		}{}
+		err := json.Unmarshal(*buff, &msg)
+		if err != nil {
+			return fmt.Errorf("parse SyncPlay command: %v", err)
+		}
+		command, ok := syncPlayCommands[msg.Data.Command]
+		if !ok {
+			return fmt.Errorf("unknown SyncPlay command: %s", msg.Data.Command)
+		}
+		handler.OnSyncPlayCommand(&models.SyncPlayCommand{
+			Command:      command,
+			When:         msg.Data.When,
+			Position:     ticksToDuration(msg.Data.PositionTicks),
+			PlaylistItem: msg.Data.PlaylistItemId,
+		})
+		return nil
+	}
+
+	msg := struct {
+		Data syncPlayGroupUpdate `json:"Data"`
+	}{}
+	err := json.Unmarshal(*buff, &msg)
+	if err != nil {
+		return fmt.Errorf("parse SyncPlay group update: %v", err)
+	}
+	data := msg.Data.Data
+	update := &models.SyncPlayGroupUpdate{}
+
+	switch msg.Data.Type {
+	case "GroupJoined":
+		group := syncPlayGroup{}
+		err = json.Unmarshal(data, &group)
+		update.Type = models.SyncPlayGroupJoined
+		update.Group = group.toGroup()
+	case "GroupLeft", "NotInGroup":
+		update.Type = models.SyncPlayGroupLeft
+	case "UserJoined", "UserLeft":
+		update.Type = models.SyncPlayUserJoined
+		if msg.Data.Type == "UserLeft" {
+			update.Type = models.SyncPlayUserLeft
+		}
+		err = json.Unmarshal(data, &update.User)
+	case "StateUpdate":
+		state := syncPlayState{}
+		err = json.Unmarshal(data, &state)
+		update.Type = models.SyncPlayStateChanged
+		update.State = state.State
+	case "PlayQueue":
+		queue := syncPlayQueue{}
+		err = json.Unmarshal(data, &queue)
+		if err != nil {
+			return fmt.Errorf("parse SyncPlay queue: %v", err)
+		}
+		// songs are fetched from server, don't block reading socket
+		go jf.pushSyncPlayQueue(&queue, handler)
+		return nil
+	default:
+		message, ok := syncPlayErrors[msg.Data.Type]
+		if !ok {
+			logrus.Debugf("Unknown SyncPlay group update: %s", msg.Data.Type)
+			return nil
+		}
+		update.Type = models.SyncPlayError
+		update.Message = message
+	}
+	if err != nil {
+		return fmt.Errorf("parse SyncPlay %s: %v", msg.Data.Type, err)
+	}
+	handler.OnSyncPlayGroup(update)
+	return nil
+}
+
+// fetch songs in group queue and pass queue to handler.
+func (jf *Jellyfin) pushSyncPlayQueue(dto *syncPlayQueue, handler api.SyncPlayHandler) {
+	queue := &models.SyncPlayQueue{
+		Reason:        dto.Reason,
+		PlayingIndex:  dto.PlayingItemIndex,
+		StartPosition: ticksToDuration(dto.StartPositionTicks),
+		IsPlaying:     dto.IsPlaying,
+		Items:         make([]models.SyncPlayItem, 0, len(dto.Playlist)),
+	}
+
+	if len(dto.Playlist) > 0 {
+		ids := make([]string, len(dto.Playlist))
+		for i, v := range dto.Playlist {
+			ids[i] = v.ItemId.String()
+		}
+		songs, err := jf.getSongsByIds(ids)
+		if err != nil {
+			logrus.Errorf("get SyncPlay queue songs: %v", err)
+			return
+		}
+		songMap := make(map[models.Id]*models.Song, len(songs))
+		for _, v := range songs {
+			songMap[v.Id] = v
+		}
+		for i, v := range dto.Playlist {
+			song, ok := songMap[v.ItemId]
+			if !ok {
+				logrus.Warningf("SyncPlay queue item %s is not a song, skip", v.ItemId)
+				if i < dto.PlayingItemIndex {
+					queue.PlayingIndex -= 1
+				}
+				continue
+			}
+			queue.Items = append(queue.Items, models.SyncPlayItem{Song: song, PlaylistItem: v.PlaylistItemId})
+		}
+	}
+	handler.OnSyncPlayQueue(queue)
+}
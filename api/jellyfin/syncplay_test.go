@@ -0,0 +1,115 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package jellyfin
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
+
+// mockSyncPlayHandler records SyncPlay messages.
+type mockSyncPlayHandler struct {
+	group   *models.SyncPlayGroupUpdate
+	queue   *models.SyncPlayQueue
+	command *models.SyncPlayCommand
+}
+
+func (m *mockSyncPlayHandler) OnSyncPlayGroup(update *models.SyncPlayGroupUpdate) { m.group = update }
+func (m *mockSyncPlayHandler) OnSyncPlayQueue(queue *models.SyncPlayQueue)        { m.queue = queue }
+func (m *mockSyncPlayHandler) OnSyncPlayCommand(command *models.SyncPlayCommand)  { m.command = command }
+
+func TestJellyfin_parseSyncPlayMessage(t *testing.T) {
+	when := time.Date(2020, 11, 2, 18, 30, 5, 250000000, time.UTC)
+	tests := []struct {
+		name    string
+		msg     string
+		group   *models.SyncPlayGroupUpdate
+		command *models.SyncPlayCommand
+	}{
+		{
+			name: "group joined",
+			msg: `{"MessageType":"SyncPlayGroupUpdate","Data":{"GroupId":"g1","Type":"GroupJoined",
+"Data":{"GroupId":"g1","GroupName":"Party","State":"Idle","Participants":["user"],"LastUpdatedAt":"2020-11-02T18:30:00Z"}}}`,
+			group: &models.SyncPlayGroupUpdate{
+				Type: models.SyncPlayGroupJoined,
+				Group: &models.SyncPlayGroup{
+					Id: "g1", Name: "Party", State: "Idle", Participants: []string{"user"},
+				},
+			},
+		},
+		{
+			name:  "user joined",
+			msg:   `{"MessageType":"SyncPlayGroupUpdate","Data":{"GroupId":"g1","Type":"UserJoined","Data":"other"}}`,
+			group: &models.SyncPlayGroupUpdate{Type: models.SyncPlayUserJoined, User: "other"},
+		},
+		{
+			name: "state update",
+			msg: `{"MessageType":"SyncPlayGroupUpdate","Data":{"GroupId":"g1","Type":"StateUpdate",
+"Data":{"State":"Waiting","Reason":"Buffer"}}}`,
+			group: &models.SyncPlayGroupUpdate{Type: models.SyncPlayStateChanged, State: "Waiting"},
+		},
+		{
+			name:  "group left",
+			msg:   `{"MessageType":"SyncPlayGroupUpdate","Data":{"GroupId":"g1","Type":"GroupLeft","Data":"g1"}}`,
+			group: &models.SyncPlayGroupUpdate{Type: models.SyncPlayGroupLeft},
+		},
+		{
+			name: "group does not exist",
+			msg:  `{"MessageType":"SyncPlayGroupUpdate","Data":{"GroupId":"g2","Type":"GroupDoesNotExist","Data":""}}`,
+			group: &models.SyncPlayGroupUpdate{
+				Type: models.SyncPlayError, Message: "group does not exist",
+			},
+		},
+		{
+			name: "unpause",
+			msg: `{"MessageType":"SyncPlayCommand","Data":{"GroupId":"g1","PlaylistItemId":"p1",
+"When":"2020-11-02T18:30:05.25Z","PositionTicks":1234560000,"Command":"Unpause"}}`,
+			command: &models.SyncPlayCommand{
+				Command:      models.SyncPlayUnpause,
+				When:         when,
+				Position:     time.Second * 123456 / 1000,
+				PlaylistItem: "p1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &mockSyncPlayHandler{}
+			jf := &Jellyfin{}
+			jf.SetSyncPlayHandler(handler)
+
+			buff := []byte(tt.msg)
+			err := jf.parseInboudMessage(&buff)
+			if err != nil {
+				t.Fatalf("parse message: %v", err)
+			}
+			if !reflect.DeepEqual(handler.group, tt.group) {
+				t.Errorf("group update: got %+v, want %+v", handler.group, tt.group)
+			}
+			if handler.command != nil && tt.command != nil && handler.command.When.Equal(tt.command.When) {
+				handler.command.When = tt.command.When
+			}
+			if !reflect.DeepEqual(handler.command, tt.command) {
+				t.Errorf("command: got %+v, want %+v", handler.command, tt.command)
+			}
+		})
+	}
+}
@@ -19,12 +19,13 @@
 package jellyfin
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"runtime"
-	"strconv"
+	"time"
 
 	"github.com/denisbrodbeck/machineid"
 	"github.com/sirupsen/logrus"
@@ -36,8 +37,16 @@ import (
 
 const (
 	ticksToSecond = int64(10000000)
+	// ticks are 100 nanoseconds
+	ticksToMillisecond = int64(10000)
 )
 
+var repeatModes = map[interfaces.RepeatMode]string{
+	interfaces.RepeatNone: "RepeatNone",
+	interfaces.RepeatAll:  "RepeatAll",
+	interfaces.RepeatOne:  "RepeatOne",
+}
+
 type infoResponse struct {
 	ServerName      string `json:"ServerName"`
 	Version         string `json:"Version"`
@@ -47,7 +56,7 @@ type infoResponse struct {
 }
 
 func (jf *Jellyfin) getserverInfo() (*infoResponse, error) {
-	body, err := jf.get("/System/Info/Public", nil)
+	body, err := jf.get(context.Background(), "/System/Info/Public", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -72,138 +81,35 @@ func (jf *Jellyfin) VerifyServerId() error {
 	return nil
 }
 
-type playbackStarted struct {
-	QueueableMediaTypes []string
-	CanSeek             bool
-	ItemId              string
-	MediaSourceId       string
-	PositionTicks       int64
-	VolumeLevel         int
-	IsPaused            bool
-	IsMuted             bool
-	PlayMethod          string
-	PlaySessionId       string
-	LiveStreamId        string
-	PlaylistLength      int64
-	PlaylistIndex       int
-	ShuffleMode         string
-	Queue               []queueItem `json:"NowPlayingQueue"`
-}
-
-type playbackStoppedInfo struct {
-	PlayedToCompletion bool
-}
-
-type playbackStopped struct {
-	playbackStarted
-	PlaybackStoppedInfo playbackStoppedInfo `json:"PlaybackStoppedInfo"`
-}
-
-type queueItem struct {
-	Id    string `json:"Id"`
-	Index string `json:"PlaylistItemId"`
-}
-
-func idsToQueue(ids []models.Id) []queueItem {
-	out := []queueItem{}
-	for i, v := range ids {
-		out = append(out, queueItem{
-			Id:    v.String(),
-			Index: "playlistItem" + strconv.Itoa(i),
-		})
-	}
-	return out
-}
-
-type playbackProgress struct {
-	playbackStarted
-	Event interfaces.ApiPlaybackEvent
-}
-
-// ReportProgress reports playback status to server
-func (jf *Jellyfin) ReportProgress(state *interfaces.ApiPlaybackState) error {
+// Replay sends operation from outbox to server.
+func (jf *Jellyfin) Replay(op *models.Operation) error {
+	var resp *http.Response
 	var err error
-	var report interface{}
-	var url string
-
-	started := playbackStarted{
-		QueueableMediaTypes: []string{"Audio"},
-		CanSeek:             false,
-		ItemId:              state.ItemId,
-		MediaSourceId:       state.ItemId,
-		PositionTicks:       int64(state.Position) * ticksToSecond,
-		VolumeLevel:         state.Volume,
-		IsPaused:            state.IsPaused,
-		IsMuted:             state.IsMuted,
-		PlayMethod:          "DirectPlay",
-		PlaySessionId:       jf.SessionId,
-		LiveStreamId:        "",
-		PlaylistLength:      int64(state.PlaylistLength) * ticksToSecond,
-		Queue:               idsToQueue(state.Queue),
-	}
-
-	if state.Shuffle {
-		started.ShuffleMode = "Shuffle"
-	} else {
-		started.ShuffleMode = "Sorted"
-	}
-
-	if state.Event == interfaces.EventStart {
-		url = "/Sessions/Playing"
-		report = started
-	} else if state.Event == interfaces.EventStop {
-		url = "/Sessions/Playing/Stopped"
-		report = playbackStopped{
-			playbackStarted: started,
-			PlaybackStoppedInfo: playbackStoppedInfo{
-				PlayedToCompletion: true,
-			},
-		}
-	} else {
-		url = "/Sessions/Playing/Progress"
-		report = playbackProgress{
-			playbackStarted: started,
-			Event:           state.Event,
+	switch op.Type {
+	case models.OperationPlaybackStopped:
+		body := []byte(op.Data)
+		resp, err = jf.makeRequest(context.Background(), http.MethodPost, urlPlaybackStopped, &body, jf.defaultParams(),
+			map[string]string{"X-Emby-Authorization": jf.authHeader()})
+	case models.OperationScrobble:
+		params := jf.defaultParams()
+		(*params)["DatePlayed"] = op.PlayedAt.UTC().Format(time.RFC3339)
+		url := fmt.Sprintf("/Users/%s/PlayedItems/%s", jf.userId, op.Item)
+		resp, err = jf.makeRequest(context.Background(), http.MethodPost, url, nil, params, nil)
+	case models.OperationFavorite, models.OperationUnfavorite:
+		method := http.MethodPost
+		if op.Type == models.OperationUnfavorite {
+			method = http.MethodDelete
 		}
+		url := fmt.Sprintf("/Users/%s/FavoriteItems/%s", jf.userId, op.Item)
+		resp, err = jf.makeRequest(context.Background(), method, url, nil, jf.defaultParams(), nil)
+	default:
+		return fmt.Errorf("unsupported operation: %s", op.Type)
 	}
-
-	// webui does not accept websocket response for now, so fall back to http posts. No p
-	//if jf.socket == nil || state.Event == interfaces.EventStart || state.Event == interfaces.EventStop {
-	params := *jf.defaultParams()
-	body, err := json.Marshal(&report)
-	if err != nil {
-		return fmt.Errorf("json marshaling failed: %v", err)
-	}
-	resp, err := jf.makeRequest(http.MethodPost, url, &body, &params,
-		map[string]string{"X-Emby-Authorization": jf.authHeader()})
 	if err != nil {
-		return fmt.Errorf("push progress: %v", err)
+		return err
 	}
 	resp.Body.Close()
-
-	/*
-		} else {
-			content := map[string]interface{}{}
-			content["MessageType"] = "ReportPlaybackStatus"
-			content["Data"] = report
-
-			jf.socketLock.Lock()
-			jf.socket.SetWriteDeadline(time.Now().Add(time.Second * 15))
-			err = jf.socket.WriteJSON(content)
-			jf.socketLock.Unlock()
-			if err != nil {
-				logrus.Errorf("Send playback status via websocket: %v", err)
-			}
-		}
-	*/
-
-	logrus.Debug("Progress event: ", state.Event)
-
-	if err == nil {
-		return nil
-	} else {
-		return fmt.Errorf("push progress: %v", err)
-	}
+	return nil
 }
 
 func (jf *Jellyfin) GetCacheItems() int {
@@ -228,6 +134,10 @@ func (jf *Jellyfin) ReportCapabilities() error {
 		"ToggleMute",
 		"SetVolume",
 		"SetShuffleQueue",
+		"SetRepeatMode",
+		"DisplayMessage",
+		"PlayMediaSource",
+		"SetAudioStreamIndex",
 	}
 	data["SupportsMediaControl"] = jf.remoteControlEnabled
 	data["SupportsPersistentIdentifier"] = false
@@ -246,7 +156,7 @@ func (jf *Jellyfin) ReportCapabilities() error {
 
 	url := "/Sessions/Capabilities/Full"
 
-	resp, err := jf.makeRequest(http.MethodPost, url, &body, &params,
+	resp, err := jf.makeRequest(context.Background(), http.MethodPost, url, &body, &params,
 		map[string]string{"X-Emby-Authorization": jf.authHeader()})
 	if err != nil {
 		return err
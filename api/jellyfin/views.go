@@ -19,6 +19,7 @@
 package jellyfin
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"tryffel.net/go/jellycli/config"
@@ -26,11 +27,11 @@ import (
 	"tryffel.net/go/jellycli/models"
 )
 
-func (jf *Jellyfin) GetViews() ([]*models.View, error) {
+func (jf *Jellyfin) GetViews(ctx context.Context) ([]*models.View, error) {
 	params := *jf.defaultParams()
 
 	url := fmt.Sprintf("/Users/%s/Views", jf.userId)
-	resp, err := jf.get(url, &params)
+	resp, err := jf.get(ctx, url, &params)
 	if err != nil {
 		return nil, fmt.Errorf("get views: %v", err)
 	}
@@ -48,12 +49,12 @@ func (jf *Jellyfin) GetViews() ([]*models.View, error) {
 	return views, nil
 }
 
-func (jf *Jellyfin) GetLatestAlbums() ([]*models.Album, error) {
+func (jf *Jellyfin) GetLatestAlbums(ctx context.Context) ([]*models.Album, error) {
 	params := *jf.defaultParams()
 	params["UserId"] = jf.userId
 	params.setParentId(jf.musicView)
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items/Latest", jf.userId), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items/Latest", jf.userId), &params)
 	if err != nil {
 		return nil, fmt.Errorf("request latest albums: %v", err)
 	}
@@ -74,7 +75,7 @@ func (jf *Jellyfin) GetLatestAlbums() ([]*models.Album, error) {
 	return albums, nil
 }
 
-func (jf *Jellyfin) GetRecentlyPlayed(paging interfaces.Paging) ([]*models.Song, int, error) {
+func (jf *Jellyfin) GetRecentlyPlayed(ctx context.Context, paging interfaces.Paging) ([]*models.Song, int, error) {
 	params := *jf.defaultParams()
 
 	params.setIncludeTypes(mediaTypeSong)
@@ -91,7 +92,7 @@ func (jf *Jellyfin) GetRecentlyPlayed(paging interfaces.Paging) ([]*models.Song,
 	}
 	params.setPaging(paging)
 
-	resp, err := jf.get(fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
+	resp, err := jf.get(ctx, fmt.Sprintf("/Users/%s/Items", jf.userId), &params)
 	if err != nil {
 		return nil, 0, fmt.Errorf("request latest albums: %v", err)
 	}
@@ -117,14 +118,14 @@ func (jf *Jellyfin) GetRecentlyPlayed(paging interfaces.Paging) ([]*models.Song,
 }
 
 // GetInstantMix returns instant mix for given item.
-func (jf *Jellyfin) GetInstantMix(item models.Item) ([]*models.Song, error) {
+func (jf *Jellyfin) GetInstantMix(ctx context.Context, item models.Item) ([]*models.Song, error) {
 	params := *jf.defaultParams()
 	params.setIncludeTypes(mediaTypeSong)
 	params["UserId"] = jf.userId
 	params.setParentId(jf.musicView)
 
 	url := fmt.Sprintf("/Items/%s/InstantMix", item.GetId().String())
-	resp, err := jf.get(url, &params)
+	resp, err := jf.get(ctx, url, &params)
 	if resp != nil {
 		defer resp.Close()
 	}
@@ -19,6 +19,8 @@
 package api
 
 import (
+	"context"
+	"io"
 	"tryffel.net/go/jellycli/config"
 	"tryffel.net/go/jellycli/interfaces"
 	"tryffel.net/go/jellycli/models"
@@ -188,7 +190,7 @@ func NewMockServer() *MockServer {
 	return server
 }
 
-func (m *MockServer) GetArtists(query *interfaces.QueryOpts) ([]*models.Artist, int, error) {
+func (m *MockServer) GetArtists(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Artist, int, error) {
 	if query == nil {
 		return m.Artists, len(m.Artists), nil
 	}
@@ -199,14 +201,14 @@ func (m *MockServer) GetArtists(query *interfaces.QueryOpts) ([]*models.Artist,
 	return artists, len(m.Artists), nil
 }
 
-func (m *MockServer) GetAlbumArtists(query *interfaces.QueryOpts) ([]*models.Artist, int, error) {
+func (m *MockServer) GetAlbumArtists(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Artist, int, error) {
 	offset := query.Paging.Offset()
 	last := limitPaging(query.Paging.CurrentPage*query.Paging.PageSize, len(m.AlbumArtists))
 	artists := m.AlbumArtists[offset:last]
 	return artists, len(m.Artists), nil
 }
 
-func (m *MockServer) GetAlbums(query *interfaces.QueryOpts) ([]*models.Album, int, error) {
+func (m *MockServer) GetAlbums(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Album, int, error) {
 	if query == nil {
 		return m.Albums, len(m.Albums), nil
 	}
@@ -216,59 +218,59 @@ func (m *MockServer) GetAlbums(query *interfaces.QueryOpts) ([]*models.Album, in
 	return albums, len(m.Albums), nil
 }
 
-func (m *MockServer) GetArtistAlbums(artist models.Id) ([]*models.Album, error) {
+func (m *MockServer) GetArtistAlbums(ctx context.Context, artist models.Id) ([]*models.Album, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetAlbumSongs(album models.Id) ([]*models.Song, error) {
+func (m *MockServer) GetAlbumSongs(ctx context.Context, album models.Id) ([]*models.Song, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetPlaylists() ([]*models.Playlist, error) {
+func (m *MockServer) GetPlaylists(ctx context.Context) ([]*models.Playlist, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetPlaylistSongs(playlist models.Id) ([]*models.Song, error) {
+func (m *MockServer) GetPlaylistSongs(ctx context.Context, playlist models.Id) ([]*models.Song, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetFavoriteAlbums(paging interfaces.Paging) ([]*models.Album, int, error) {
+func (m *MockServer) GetFavoriteAlbums(ctx context.Context, paging interfaces.Paging) ([]*models.Album, int, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetSimilarArtists(artist models.Id) ([]*models.Artist, error) {
+func (m *MockServer) GetSimilarArtists(ctx context.Context, artist models.Id) ([]*models.Artist, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetSimilarAlbums(album models.Id) ([]*models.Album, error) {
+func (m *MockServer) GetSimilarAlbums(ctx context.Context, album models.Id) ([]*models.Album, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetLatestAlbums() ([]*models.Album, error) {
+func (m *MockServer) GetLatestAlbums(ctx context.Context) ([]*models.Album, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetRecentlyPlayed(paging interfaces.Paging) ([]*models.Song, int, error) {
+func (m *MockServer) GetRecentlyPlayed(ctx context.Context, paging interfaces.Paging) ([]*models.Song, int, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetSongs(query *interfaces.QueryOpts) ([]*models.Song, int, error) {
+func (m *MockServer) GetSongs(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Song, int, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetGenres(paging interfaces.Paging) ([]*models.IdName, int, error) {
+func (m *MockServer) GetGenres(ctx context.Context, paging interfaces.Paging) ([]*models.IdName, int, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetGenreAlbums(genre models.IdName) ([]*models.Album, error) {
+func (m *MockServer) GetGenreAlbums(ctx context.Context, genre models.IdName) ([]*models.Album, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetAlbumArtist(album *models.Album) (*models.Artist, error) {
+func (m *MockServer) GetAlbumArtist(ctx context.Context, album *models.Album) (*models.Artist, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetInstantMix(item models.Item) ([]*models.Song, error) {
+func (m *MockServer) GetInstantMix(ctx context.Context, item models.Item) ([]*models.Song, error) {
 	panic("not implemented")
 }
 
@@ -276,15 +278,15 @@ func (m *MockServer) GetLink(item models.Item) string {
 	panic("not implemented")
 }
 
-func (m *MockServer) Search(query string, itemType models.ItemType, maxResults int) ([]models.Item, error) {
+func (m *MockServer) Search(ctx context.Context, query string, itemType models.ItemType, maxResults int) ([]models.Item, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetAlbum(id models.Id) (*models.Album, error) {
+func (m *MockServer) GetAlbum(ctx context.Context, id models.Id) (*models.Album, error) {
 	panic("not implemented")
 }
 
-func (m *MockServer) GetArtist(id models.Id) (*models.Artist, error) {
+func (m *MockServer) GetArtist(ctx context.Context, id models.Id) (*models.Artist, error) {
 	panic("not implemented")
 }
 
@@ -321,3 +323,19 @@ func (m *MockServer) Start() error {
 func (m *MockServer) Stop() error {
 	return nil
 }
+
+func (m *MockServer) Stream(ctx context.Context, song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	panic("not implemented")
+}
+
+func (m *MockServer) StreamFile(ctx context.Context, song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	panic("not implemented")
+}
+
+func (m *MockServer) Download(ctx context.Context, song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	panic("not implemented")
+}
+
+func (m *MockServer) GetId() string {
+	return "mock"
+}
@@ -0,0 +1,158 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package offline
+
+import (
+	"context"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+func (s *Server) GetArtists(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Artist, int, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetArtists(ctx, query)
+	}
+	return s.db.GetArtists(query)
+}
+
+func (s *Server) GetAlbumArtists(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Artist, int, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetAlbumArtists(ctx, query)
+	}
+	return s.db.GetArtists(query)
+}
+
+func (s *Server) GetAlbums(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Album, int, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetAlbums(ctx, query)
+	}
+	return s.db.GetAlbums(query)
+}
+
+func (s *Server) GetArtistAlbums(ctx context.Context, artist models.Id) ([]*models.Album, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetArtistAlbums(ctx, artist)
+	}
+	return s.db.GetArtistAlbums(artist)
+}
+
+func (s *Server) GetAlbumSongs(ctx context.Context, album models.Id) ([]*models.Song, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetAlbumSongs(ctx, album)
+	}
+	return s.db.GetAlbumSongs(album)
+}
+
+func (s *Server) GetPlaylists(ctx context.Context) ([]*models.Playlist, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetPlaylists(ctx)
+	}
+	return s.db.GetPlaylists()
+}
+
+func (s *Server) GetPlaylistSongs(ctx context.Context, playlist models.Id) ([]*models.Song, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetPlaylistSongs(ctx, playlist)
+	}
+	return s.db.GetPlaylistSongs(playlist)
+}
+
+func (s *Server) GetSimilarArtists(ctx context.Context, artist models.Id) ([]*models.Artist, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetSimilarArtists(ctx, artist)
+	}
+	return nil, ErrOffline
+}
+
+func (s *Server) GetSimilarAlbums(ctx context.Context, album models.Id) ([]*models.Album, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetSimilarAlbums(ctx, album)
+	}
+	return nil, ErrOffline
+}
+
+func (s *Server) GetRecentlyPlayed(ctx context.Context, paging interfaces.Paging) ([]*models.Song, int, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetRecentlyPlayed(ctx, paging)
+	}
+	return nil, 0, ErrOffline
+}
+
+func (s *Server) GetSongs(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Song, int, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetSongs(ctx, query)
+	}
+	return s.db.GetSongs(query.Paging.CurrentPage, query.Paging.PageSize)
+}
+
+func (s *Server) GetGenres(ctx context.Context, paging interfaces.Paging) ([]*models.IdName, int, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetGenres(ctx, paging)
+	}
+	return nil, 0, ErrOffline
+}
+
+func (s *Server) GetAlbumArtist(ctx context.Context, album *models.Album) (*models.Artist, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetAlbumArtist(ctx, album)
+	}
+	return s.db.GetArtist(album.Artist)
+}
+
+func (s *Server) GetInstantMix(ctx context.Context, item models.Item) ([]*models.Song, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetInstantMix(ctx, item)
+	}
+	return nil, ErrOffline
+}
+
+func (s *Server) GetLink(item models.Item) string {
+	if remote := s.online(); remote != nil {
+		return remote.GetLink(item)
+	}
+	return ""
+}
+
+func (s *Server) Search(ctx context.Context, query string, itemType models.ItemType, maxResults int) ([]models.Item, error) {
+	if remote := s.online(); remote != nil {
+		return remote.Search(ctx, query, itemType, maxResults)
+	}
+	return s.db.Search(query, itemType, maxResults)
+}
+
+func (s *Server) GetAlbum(ctx context.Context, id models.Id) (*models.Album, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetAlbum(ctx, id)
+	}
+	return s.db.GetAlbum(id)
+}
+
+func (s *Server) GetArtist(ctx context.Context, id models.Id) (*models.Artist, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetArtist(ctx, id)
+	}
+	return s.db.GetArtist(id)
+}
+
+func (s *Server) GetImageUrl(item models.Id, itemType models.ItemType) string {
+	if remote := s.online(); remote != nil {
+		return remote.GetImageUrl(item, itemType)
+	}
+	return ""
+}
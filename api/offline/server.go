@@ -0,0 +1,574 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package offline implements api.MediaServer that serves items from local database
+// when remote server is not reachable.
+package offline
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"sync"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/storage"
+	"tryffel.net/go/jellycli/task"
+)
+
+// ErrOffline is returned for requests that cannot be served without remote server.
+var ErrOffline = errors.New("server is offline")
+
+// how often to try connecting to remote server, or check that connected server is reachable
+const reconnectInterval = time.Second * 30
+
+// song is considered played after this many seconds
+const scrobbleAfterS = 5
+
+// ConnectFunc connects to remote server. It must not prompt user for input.
+type ConnectFunc func() (api.MediaServer, error)
+
+// Server serves cached items from local database and tries to connect to remote server in background.
+// Once connected, all requests are forwarded to remote server. If connection is lost, Server
+// goes offline and starts connecting again.
+// Server implements api.MediaServer, api.RemoteController, api.LibraryNotifier, api.SyncPlay,
+// api.RemoteControlNotifier, api.RequestMetrics, api.CacheMetrics, api.AlbumBatcher and interfaces.Connection.
+type Server struct {
+	task.Task
+	lock    *sync.RWMutex
+	id      string
+	db      *storage.Db
+	connect ConnectFunc
+
+	remote api.MediaServer
+
+	player      interfaces.Player
+	queue       interfaces.QueueController
+	connectedCb []func(online bool)
+	libraryCb   []func(changes *models.LibraryChanges)
+	remoteCb    []func(status models.RemoteControlStatus)
+	messageCb   []func(msg models.ServerMessage)
+	syncPlay    api.SyncPlayHandler
+
+	// song being played while offline
+	currentSong models.Id
+	scrobbled   bool
+}
+
+// NewServer opens local database for server id. Connect is used for connecting to remote server.
+func NewServer(id string, connect ConnectFunc) (*Server, error) {
+	db, err := storage.NewDb(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		lock:    &sync.RWMutex{},
+		id:      id,
+		db:      db,
+		connect: connect,
+	}
+	s.Name = "Offline server"
+	s.SetLoop(s.loop)
+	return s, nil
+}
+
+// NewOnlineServer returns server that is connected to remote. Local database is used if connection
+// to remote is lost. Connect is used for reconnecting to remote server.
+func NewOnlineServer(remote api.MediaServer, connect ConnectFunc) (*Server, error) {
+	s, err := NewServer(remote.GetId(), connect)
+	if err != nil {
+		return nil, err
+	}
+	s.remote = remote
+	return s, nil
+}
+
+// online returns remote server, or nil if offline.
+func (s *Server) online() api.MediaServer {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.remote
+}
+
+func (s *Server) IsOnline() bool {
+	return s.online() != nil
+}
+
+func (s *Server) AddConnectionCallback(cb func(online bool)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.connectedCb = append(s.connectedCb, cb)
+}
+
+// AddLibraryChangedCallback adds callback to remote server once it is connected.
+func (s *Server) AddLibraryChangedCallback(cb func(changes *models.LibraryChanges)) {
+	s.lock.Lock()
+	s.libraryCb = append(s.libraryCb, cb)
+	remote := s.remote
+	s.lock.Unlock()
+	if notifier, ok := remote.(api.LibraryNotifier); ok {
+		notifier.AddLibraryChangedCallback(cb)
+	}
+}
+
+// GetRemoteControlStatus returns remote control status of remote server. When offline,
+// remote control is disconnected.
+func (s *Server) GetRemoteControlStatus() models.RemoteControlStatus {
+	if notifier, ok := s.online().(api.RemoteControlNotifier); ok {
+		return notifier.GetRemoteControlStatus()
+	}
+	return models.RemoteControlStatus{}
+}
+
+// AddRemoteControlCallback adds callback to remote server once it is connected.
+func (s *Server) AddRemoteControlCallback(cb func(status models.RemoteControlStatus)) {
+	s.lock.Lock()
+	s.remoteCb = append(s.remoteCb, cb)
+	remote := s.remote
+	s.lock.Unlock()
+	if notifier, ok := remote.(api.RemoteControlNotifier); ok {
+		notifier.AddRemoteControlCallback(cb)
+	}
+}
+
+// AddMessageCallback adds callback to remote server once it is connected.
+func (s *Server) AddMessageCallback(cb func(msg models.ServerMessage)) {
+	s.lock.Lock()
+	s.messageCb = append(s.messageCb, cb)
+	remote := s.remote
+	s.lock.Unlock()
+	if notifier, ok := remote.(api.MessageNotifier); ok {
+		notifier.AddMessageCallback(cb)
+	}
+}
+
+// GetRequestStats returns request metrics of remote server, if it collects them.
+func (s *Server) GetRequestStats() models.RequestStats {
+	if metrics, ok := s.online().(api.RequestMetrics); ok {
+		return metrics.GetRequestStats()
+	}
+	return models.RequestStats{}
+}
+
+// GetCacheStats returns metadata cache metrics of remote server, if it caches metadata.
+func (s *Server) GetCacheStats() models.CacheStats {
+	if metrics, ok := s.online().(api.CacheMetrics); ok {
+		return metrics.GetCacheStats()
+	}
+	return models.CacheStats{}
+}
+
+// GetAlbumsById returns albums for ids. If remote server cannot batch requests, albums are fetched one by one.
+func (s *Server) GetAlbumsById(ctx context.Context, ids []models.Id) ([]*models.Album, error) {
+	if batcher, ok := s.online().(api.AlbumBatcher); ok {
+		return batcher.GetAlbumsById(ctx, ids)
+	}
+	albums := make([]*models.Album, 0, len(ids))
+	for _, id := range ids {
+		album, err := s.GetAlbum(ctx, id)
+		if err != nil {
+			return albums, err
+		}
+		albums = append(albums, album)
+	}
+	return albums, nil
+}
+
+func (s *Server) loop() {
+	ticker := time.NewTicker(reconnectInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.StopChan():
+			return
+		case <-ticker.C:
+			if s.online() == nil {
+				s.tryConnect()
+			} else {
+				s.checkConnection()
+			}
+		}
+	}
+}
+
+// checkConnection checks that remote server is still reachable. If not, server goes offline.
+// Returns true if remote server is reachable.
+func (s *Server) checkConnection() bool {
+	remote := s.online()
+	if remote == nil {
+		return false
+	}
+	_, err := remote.GetInfo()
+	if err == nil {
+		return true
+	}
+	logrus.Warningf("Lost connection to server, going offline: %v", err)
+
+	s.lock.Lock()
+	if s.remote == remote {
+		s.remote = nil
+	}
+	callbacks := s.connectedCb
+	remoteCallbacks := s.remoteCb
+	s.lock.Unlock()
+
+	err = remote.Stop()
+	if err != nil {
+		logrus.Errorf("stop server: %v", err)
+	}
+	for _, cb := range remoteCallbacks {
+		cb(models.RemoteControlStatus{})
+	}
+	for _, cb := range callbacks {
+		cb(false)
+	}
+	return false
+}
+
+// tryConnect tries to connect to remote server. Returns true if connection was successful.
+func (s *Server) tryConnect() bool {
+	remote, err := s.connect()
+	if err != nil {
+		logrus.Debugf("connect to server: %v", err)
+		return false
+	}
+	if remote.GetId() != s.id {
+		logrus.Errorf("connected to different server (%s), expected %s", remote.GetId(), s.id)
+		return false
+	}
+	logrus.Info("Connected to server")
+
+	s.lock.Lock()
+	if remoteController, ok := remote.(api.RemoteController); ok {
+		if s.player != nil {
+			remoteController.SetPlayer(s.player)
+		}
+		if s.queue != nil {
+			remoteController.SetQueue(s.queue)
+		}
+	}
+	if notifier, ok := remote.(api.LibraryNotifier); ok {
+		for _, cb := range s.libraryCb {
+			notifier.AddLibraryChangedCallback(cb)
+		}
+	}
+	if notifier, ok := remote.(api.RemoteControlNotifier); ok {
+		for _, cb := range s.remoteCb {
+			notifier.AddRemoteControlCallback(cb)
+		}
+	}
+	if notifier, ok := remote.(api.MessageNotifier); ok {
+		for _, cb := range s.messageCb {
+			notifier.AddMessageCallback(cb)
+		}
+	}
+	if syncPlay, ok := remote.(api.SyncPlay); ok && s.syncPlay != nil {
+		syncPlay.SetSyncPlayHandler(s.syncPlay)
+	}
+	s.remote = remote
+	callbacks := s.connectedCb
+	s.lock.Unlock()
+
+	err = remote.Start()
+	if err != nil {
+		logrus.Errorf("start server: %v", err)
+	}
+	for _, cb := range callbacks {
+		cb(true)
+	}
+	return true
+}
+
+func (s *Server) Start() error {
+	if remote := s.online(); remote != nil {
+		err := remote.Start()
+		if err != nil {
+			return err
+		}
+	}
+	return s.Task.Start()
+}
+
+func (s *Server) Stop() error {
+	var err error
+	if s.IsRunning() {
+		err = s.Task.Stop()
+	}
+	if remote := s.online(); remote != nil {
+		err = remote.Stop()
+	}
+	dbErr := s.db.Close()
+	if err == nil {
+		err = dbErr
+	}
+	return err
+}
+
+func (s *Server) SetPlayer(player interfaces.Player) {
+	s.lock.Lock()
+	s.player = player
+	s.lock.Unlock()
+	if remoteController, ok := s.online().(api.RemoteController); ok {
+		remoteController.SetPlayer(player)
+	}
+}
+
+func (s *Server) SetQueue(q interfaces.QueueController) {
+	s.lock.Lock()
+	s.queue = q
+	s.lock.Unlock()
+	if remoteController, ok := s.online().(api.RemoteController); ok {
+		remoteController.SetQueue(q)
+	}
+}
+
+func (s *Server) RemoteControlEnabled() error {
+	remote := s.online()
+	if remote == nil {
+		return ErrOffline
+	}
+	if remoteController, ok := remote.(api.RemoteController); ok {
+		return remoteController.RemoteControlEnabled()
+	}
+	return errors.New("not supported")
+}
+
+func (s *Server) CanCacheSongs() bool {
+	if cacher, ok := s.online().(api.Cacher); ok {
+		return cacher.CanCacheSongs()
+	}
+	return false
+}
+
+func (s *Server) CanFilterUserData() bool {
+	if cacher, ok := s.online().(api.Cacher); ok {
+		return cacher.CanFilterUserData()
+	}
+	return false
+}
+
+func (s *Server) GetInfo() (*models.ServerInfo, error) {
+	if remote := s.online(); remote != nil {
+		return remote.GetInfo()
+	}
+	return &models.ServerInfo{
+		ServerType: config.AppConfig.Player.Server,
+		Id:         s.id,
+		Message:    "Offline",
+	}, nil
+}
+
+func (s *Server) ConnectionOk() error {
+	if remote := s.online(); remote != nil {
+		return remote.ConnectionOk()
+	}
+	return ErrOffline
+}
+
+func (s *Server) GetConfig() config.Backend {
+	if remote := s.online(); remote != nil {
+		return remote.GetConfig()
+	}
+	return nil
+}
+
+// ReportProgress forwards report to remote server. When offline, songs that have been played
+// are returned as scrobble operations so that they can be sent once server is reachable.
+func (s *Server) ReportProgress(state *interfaces.ApiPlaybackState) error {
+	if remote := s.online(); remote != nil {
+		return remote.ReportProgress(state)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if state.Event == interfaces.EventStart {
+		s.currentSong = models.Id(state.ItemId)
+		s.scrobbled = false
+	}
+	if state.Event == interfaces.EventTimeUpdate && models.Id(state.ItemId) == s.currentSong &&
+		state.Position.Seconds() > scrobbleAfterS && !s.scrobbled {
+		s.scrobbled = true
+		playedAt := time.Now().Add(-time.Millisecond * time.Duration(state.Position))
+		op := models.NewOperation(models.OperationScrobble, s.currentSong, playedAt, "")
+		return &api.OperationError{Operation: op, Err: ErrOffline}
+	}
+	return nil
+}
+
+// Replay sends operation to remote server.
+func (s *Server) Replay(op *models.Operation) error {
+	remote := s.online()
+	if remote == nil {
+		return ErrOffline
+	}
+	if replayer, ok := remote.(api.Replayer); ok {
+		return replayer.Replay(op)
+	}
+	return errors.New("not supported")
+}
+
+func (s *Server) GetId() string {
+	return s.id
+}
+
+func (s *Server) Stream(ctx context.Context, song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	if remote := s.online(); remote != nil {
+		return remote.Stream(ctx, song)
+	}
+	return nil, interfaces.AudioFormatNil, ErrOffline
+}
+
+func (s *Server) StreamFile(ctx context.Context, song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	if remote := s.online(); remote != nil {
+		return remote.StreamFile(ctx, song)
+	}
+	return nil, interfaces.AudioFormatNil, ErrOffline
+}
+
+func (s *Server) Download(ctx context.Context, song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	if remote := s.online(); remote != nil {
+		return remote.Download(ctx, song)
+	}
+	return nil, interfaces.AudioFormatNil, ErrOffline
+}
+
+// sessionController returns remote server if it is connected and can control other sessions.
+func (s *Server) sessionController() (interfaces.SessionController, error) {
+	remote := s.online()
+	if remote == nil {
+		return nil, ErrOffline
+	}
+	if controller, ok := remote.(interfaces.SessionController); ok {
+		return controller, nil
+	}
+	return nil, errors.New("not supported")
+}
+
+func (s *Server) GetSessions() ([]*models.Session, error) {
+	controller, err := s.sessionController()
+	if err != nil {
+		return nil, err
+	}
+	return controller.GetSessions()
+}
+
+func (s *Server) ControlSession(session models.Id, command models.SessionCommand) error {
+	controller, err := s.sessionController()
+	if err != nil {
+		return err
+	}
+	return controller.ControlSession(session, command)
+}
+
+func (s *Server) SeekSession(session models.Id, position interfaces.AudioTick) error {
+	controller, err := s.sessionController()
+	if err != nil {
+		return err
+	}
+	return controller.SeekSession(session, position)
+}
+
+func (s *Server) SetSessionVolume(session models.Id, volume interfaces.AudioVolume) error {
+	controller, err := s.sessionController()
+	if err != nil {
+		return err
+	}
+	return controller.SetSessionVolume(session, volume)
+}
+
+func (s *Server) PlayOnSession(session models.Id, items []models.Id) error {
+	controller, err := s.sessionController()
+	if err != nil {
+		return err
+	}
+	return controller.PlayOnSession(session, items)
+}
+
+// syncPlayServer returns remote server if it is connected and supports SyncPlay.
+func (s *Server) syncPlayServer() (api.SyncPlay, error) {
+	remote := s.online()
+	if remote == nil {
+		return nil, ErrOffline
+	}
+	if syncPlay, ok := remote.(api.SyncPlay); ok {
+		return syncPlay, nil
+	}
+	return nil, errors.New("not supported")
+}
+
+func (s *Server) GetSyncPlayGroups() ([]*models.SyncPlayGroup, error) {
+	syncPlay, err := s.syncPlayServer()
+	if err != nil {
+		return nil, err
+	}
+	return syncPlay.GetSyncPlayGroups()
+}
+
+func (s *Server) CreateSyncPlayGroup(name string) error {
+	syncPlay, err := s.syncPlayServer()
+	if err != nil {
+		return err
+	}
+	return syncPlay.CreateSyncPlayGroup(name)
+}
+
+func (s *Server) JoinSyncPlayGroup(group models.Id) error {
+	syncPlay, err := s.syncPlayServer()
+	if err != nil {
+		return err
+	}
+	return syncPlay.JoinSyncPlayGroup(group)
+}
+
+func (s *Server) LeaveSyncPlayGroup() error {
+	syncPlay, err := s.syncPlayServer()
+	if err != nil {
+		return err
+	}
+	return syncPlay.LeaveSyncPlayGroup()
+}
+
+func (s *Server) SyncPlayRequest(request *models.SyncPlayRequest) error {
+	syncPlay, err := s.syncPlayServer()
+	if err != nil {
+		return err
+	}
+	return syncPlay.SyncPlayRequest(request)
+}
+
+func (s *Server) GetServerTime() (time.Time, time.Time, error) {
+	syncPlay, err := s.syncPlayServer()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return syncPlay.GetServerTime()
+}
+
+func (s *Server) SetSyncPlayHandler(handler api.SyncPlayHandler) {
+	s.lock.Lock()
+	s.syncPlay = handler
+	s.lock.Unlock()
+	if syncPlay, ok := s.online().(api.SyncPlay); ok {
+		syncPlay.SetSyncPlayHandler(handler)
+	}
+}
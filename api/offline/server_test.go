@@ -0,0 +1,106 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package offline
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/models"
+)
+
+// testRemote is remote server that can be made unreachable.
+type testRemote struct {
+	*api.MockServer
+	lock    *sync.Mutex
+	err     error
+	stopped bool
+}
+
+func (r *testRemote) GetInfo() (*models.ServerInfo, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.MockServer.GetInfo()
+}
+
+func (r *testRemote) Stop() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stopped = true
+	return nil
+}
+
+func (r *testRemote) setErr(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.err = err
+}
+
+func TestServer_checkConnection(t *testing.T) {
+	remote := &testRemote{MockServer: api.NewMockServer(), lock: &sync.Mutex{}}
+	connected := 0
+	s := &Server{
+		lock:   &sync.RWMutex{},
+		id:     remote.GetId(),
+		remote: remote,
+		connect: func() (api.MediaServer, error) {
+			connected++
+			_, err := remote.GetInfo()
+			if err != nil {
+				return nil, err
+			}
+			return remote, nil
+		},
+	}
+	states := []bool{}
+	s.AddConnectionCallback(func(online bool) {
+		states = append(states, online)
+	})
+
+	if !s.checkConnection() || !s.IsOnline() {
+		t.Fatalf("reachable server: got offline")
+	}
+
+	remote.setErr(errors.New("connection refused"))
+	if s.checkConnection() || s.IsOnline() {
+		t.Fatalf("unreachable server: got online")
+	}
+	if !remote.stopped {
+		t.Errorf("unreachable server was not stopped")
+	}
+	if s.tryConnect() {
+		t.Errorf("connect to unreachable server: got connected")
+	}
+
+	remote.setErr(nil)
+	if !s.tryConnect() || !s.IsOnline() {
+		t.Errorf("connect to reachable server: got offline")
+	}
+	if connected != 2 {
+		t.Errorf("connection attempts: got %d, want 2", connected)
+	}
+	if want := []bool{false, true}; !reflect.DeepEqual(states, want) {
+		t.Errorf("connection states: got %v, want %v", states, want)
+	}
+}
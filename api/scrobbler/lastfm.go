@@ -0,0 +1,213 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package scrobbler
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/models"
+)
+
+// default Last.fm api url
+const lastFmUrl = "https://ws.audioscrobbler.com/2.0/"
+
+// LastFm scrobbles to Last.fm api or compatible server.
+type LastFm struct {
+	url        string
+	apiKey     string
+	apiSecret  string
+	sessionKey string
+}
+
+// NewLastFm creates new Last.fm scrobbler. If there is no session key, login with username and
+// password from provider and store session key to conf.
+func NewLastFm(conf *config.LastFm, provider config.KeyValueProvider) (*LastFm, error) {
+	if conf.ApiKey == "" || conf.ApiSecret == "" {
+		return nil, errors.New("last.fm api key or secret is not set")
+	}
+	l := &LastFm{
+		url:        conf.Url,
+		apiKey:     conf.ApiKey,
+		apiSecret:  conf.ApiSecret,
+		sessionKey: conf.SessionKey,
+	}
+	if l.url == "" {
+		l.url = lastFmUrl
+	}
+
+	if l.sessionKey == "" {
+		username, err := provider.Get("lastfm.username", false, "Last.fm username")
+		if err != nil {
+			return nil, err
+		}
+		password, err := provider.Get("lastfm.password", true, "Last.fm password")
+		if err != nil {
+			return nil, err
+		}
+		err = l.login(username, password)
+		if err != nil {
+			return nil, fmt.Errorf("last.fm login: %v", err)
+		}
+		conf.Username = username
+		conf.SessionKey = l.sessionKey
+	}
+	return l, nil
+}
+
+func (l *LastFm) Name() string {
+	return "Last.fm"
+}
+
+func (l *LastFm) OperationType() models.OperationType {
+	return models.OperationLastFmScrobble
+}
+
+func (l *LastFm) NowPlaying(play *models.Play) error {
+	params := playParams(play)
+	params.Set("method", "track.updateNowPlaying")
+	return l.post(params, nil)
+}
+
+func (l *LastFm) Scrobble(play *models.Play) error {
+	params := playParams(play)
+	params.Set("method", "track.scrobble")
+	params.Set("timestamp", strconv.FormatInt(play.Started.Unix(), 10))
+	return l.post(params, nil)
+}
+
+// Replay sends scrobble from outbox.
+func (l *LastFm) Replay(op *models.Operation) error {
+	return Replay(l, op)
+}
+
+func (l *LastFm) login(username, password string) error {
+	params := url.Values{}
+	params.Set("method", "auth.getMobileSession")
+	params.Set("username", username)
+	params.Set("password", password)
+
+	dto := &struct {
+		Session struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"session"`
+	}{}
+	err := l.post(params, dto)
+	if err != nil {
+		return err
+	}
+	if dto.Session.Key == "" {
+		return errors.New("no session key in response")
+	}
+	logrus.Infof("Logged in to Last.fm as %s", dto.Session.Name)
+	l.sessionKey = dto.Session.Key
+	return nil
+}
+
+func playParams(play *models.Play) url.Values {
+	params := url.Values{}
+	params.Set("artist", play.ArtistName)
+	params.Set("track", play.SongName)
+	if play.AlbumName != "" {
+		params.Set("album", play.AlbumName)
+	}
+	if play.Duration > 0 {
+		params.Set("duration", strconv.Itoa(play.Duration))
+	}
+	return params
+}
+
+// post signs and sends api request. Response is decoded to dto, if set.
+func (l *LastFm) post(params url.Values, dto interface{}) error {
+	params.Set("api_key", l.apiKey)
+	if l.sessionKey != "" {
+		params.Set("sk", l.sessionKey)
+	}
+	params.Set("api_sig", l.signature(params))
+	params.Set("format", "json")
+
+	req, err := http.NewRequest(http.MethodPost, l.url, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp := &lastFmResponse{dto: dto}
+	err = doRequest(req, resp)
+	if err != nil {
+		return err
+	}
+	if resp.Error != 0 {
+		return fmt.Errorf("last.fm error %d: %s", resp.Error, resp.Message)
+	}
+	return nil
+}
+
+// signature calculates api signature: md5 of parameters sorted by name and api secret.
+func (l *LastFm) signature(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if key != "format" && key != "callback" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	builder := strings.Builder{}
+	for _, key := range keys {
+		builder.WriteString(key)
+		builder.WriteString(params.Get(key))
+	}
+	builder.WriteString(l.apiSecret)
+	sum := md5.Sum([]byte(builder.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// lastFmResponse decodes both error and response to dto.
+type lastFmResponse struct {
+	Error   int    `json:"error"`
+	Message string `json:"message"`
+	dto     interface{}
+}
+
+func (l *lastFmResponse) UnmarshalJSON(data []byte) error {
+	errDto := &struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}{}
+	err := json.Unmarshal(data, errDto)
+	if err != nil {
+		return err
+	}
+	l.Error = errDto.Error
+	l.Message = errDto.Message
+	if l.Error != 0 || l.dto == nil {
+		return nil
+	}
+	return json.Unmarshal(data, l.dto)
+}
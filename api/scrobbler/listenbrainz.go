@@ -0,0 +1,134 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package scrobbler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/models"
+)
+
+// default ListenBrainz api url
+const listenBrainzUrl = "https://api.listenbrainz.org"
+
+// ListenBrainz submits listens to ListenBrainz api or compatible server.
+type ListenBrainz struct {
+	url   string
+	token string
+}
+
+// NewListenBrainz creates new ListenBrainz scrobbler.
+func NewListenBrainz(conf *config.ListenBrainz) (*ListenBrainz, error) {
+	if conf.Token == "" {
+		return nil, errors.New("listenbrainz token is not set")
+	}
+	l := &ListenBrainz{
+		url:   strings.TrimSuffix(conf.Url, "/"),
+		token: conf.Token,
+	}
+	if l.url == "" {
+		l.url = listenBrainzUrl
+	}
+	return l, nil
+}
+
+func (l *ListenBrainz) Name() string {
+	return "ListenBrainz"
+}
+
+func (l *ListenBrainz) OperationType() models.OperationType {
+	return models.OperationListenBrainzScrobble
+}
+
+func (l *ListenBrainz) NowPlaying(play *models.Play) error {
+	listen := NewListenBrainzListen(play)
+	// playing now must not contain timestamp
+	listen.ListenedAt = 0
+	return l.submit(&ListenBrainzListens{
+		ListenType: "playing_now",
+		Payload:    []ListenBrainzListen{listen},
+	})
+}
+
+func (l *ListenBrainz) Scrobble(play *models.Play) error {
+	return l.submit(&ListenBrainzListens{
+		ListenType: "single",
+		Payload:    []ListenBrainzListen{NewListenBrainzListen(play)},
+	})
+}
+
+// Replay sends scrobble from outbox.
+func (l *ListenBrainz) Replay(op *models.Operation) error {
+	return Replay(l, op)
+}
+
+func (l *ListenBrainz) submit(listens *ListenBrainzListens) error {
+	body, err := json.Marshal(listens)
+	if err != nil {
+		return fmt.Errorf("encode listens: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, l.url+"/1/submit-listens", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+l.token)
+	req.Header.Set("Content-Type", "application/json")
+	return doRequest(req, nil)
+}
+
+// ListenBrainzListens is a ListenBrainz submission payload.
+type ListenBrainzListens struct {
+	ListenType string               `json:"listen_type"`
+	Payload    []ListenBrainzListen `json:"payload"`
+}
+
+// ListenBrainzListen is a single listen.
+type ListenBrainzListen struct {
+	ListenedAt    int64                     `json:"listened_at,omitempty"`
+	TrackMetadata ListenBrainzTrackMetadata `json:"track_metadata"`
+}
+
+type ListenBrainzTrackMetadata struct {
+	ArtistName     string                 `json:"artist_name"`
+	TrackName      string                 `json:"track_name"`
+	ReleaseName    string                 `json:"release_name,omitempty"`
+	AdditionalInfo map[string]interface{} `json:"additional_info,omitempty"`
+}
+
+// NewListenBrainzListen creates listen from play.
+func NewListenBrainzListen(play *models.Play) ListenBrainzListen {
+	return ListenBrainzListen{
+		ListenedAt: play.Started.Unix(),
+		TrackMetadata: ListenBrainzTrackMetadata{
+			ArtistName:  play.ArtistName,
+			TrackName:   play.SongName,
+			ReleaseName: play.AlbumName,
+			AdditionalInfo: map[string]interface{}{
+				"duration_ms":               play.Duration * 1000,
+				"media_player":              config.AppName,
+				"submission_client":         config.AppName,
+				"submission_client_version": config.Version,
+			},
+		},
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+// Package scrobbler implements submitting listens to external scrobbling services,
+// independent of media server.
+package scrobbler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
+
+// Scrobbler submits plays to external service.
+type Scrobbler interface {
+	// Name returns name of the service.
+	Name() string
+	// NowPlaying reports that song was started.
+	NowPlaying(play *models.Play) error
+	// Scrobble submits play that has been listened.
+	Scrobble(play *models.Play) error
+	// OperationType is the outbox operation type for failed scrobbles.
+	OperationType() models.OperationType
+}
+
+// NewOperation creates outbox operation for scrobble that failed.
+func NewOperation(s Scrobbler, play *models.Play) (*models.Operation, error) {
+	data, err := json.Marshal(play)
+	if err != nil {
+		return nil, fmt.Errorf("encode play: %v", err)
+	}
+	return models.NewOperation(s.OperationType(), play.Song, play.Started, string(data)), nil
+}
+
+// Replay sends scrobble from outbox with scrobbler.
+func Replay(s Scrobbler, op *models.Operation) error {
+	if op.Type != s.OperationType() {
+		return fmt.Errorf("unsupported operation: %s", op.Type)
+	}
+	play := &models.Play{}
+	err := json.Unmarshal([]byte(op.Data), play)
+	if err != nil {
+		return fmt.Errorf("decode play: %v", err)
+	}
+	return s.Scrobble(play)
+}
+
+var client = &http.Client{Timeout: time.Second * 15}
+
+// doRequest sends request and decodes response body to dto, if set. Response body is returned with error
+// on non-2xx status codes.
+func doRequest(req *http.Request, dto interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("http %d: %s", resp.StatusCode, string(body))
+	}
+	if dto == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(dto)
+}
@@ -0,0 +1,154 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package scrobbler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/models"
+)
+
+func testPlay() *models.Play {
+	return &models.Play{
+		Song:       "song-1",
+		SongName:   "Song 1",
+		AlbumName:  "Album 1",
+		ArtistName: "Artist 1",
+		Duration:   200,
+		Started:    time.Unix(1600000000, 0),
+		Played:     150 * time.Second,
+	}
+}
+
+func TestListenBrainz(t *testing.T) {
+	var got ListenBrainzListens
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/listenbrainz/1/submit-listens" {
+			t.Errorf("invalid path: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Token lbtoken" {
+			t.Errorf("invalid authorization: %s", r.Header.Get("Authorization"))
+		}
+		got = ListenBrainzListens{}
+		err := json.NewDecoder(r.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("decode listens: %v", err)
+		}
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer server.Close()
+
+	l, err := NewListenBrainz(&config.ListenBrainz{Url: server.URL + "/apis/listenbrainz/", Token: "lbtoken"})
+	if err != nil {
+		t.Fatalf("new listenbrainz: %v", err)
+	}
+
+	err = l.NowPlaying(testPlay())
+	if err != nil {
+		t.Errorf("now playing: %v", err)
+	}
+	if got.ListenType != "playing_now" || len(got.Payload) != 1 || got.Payload[0].ListenedAt != 0 {
+		t.Errorf("invalid now playing: %v", got)
+	}
+
+	// scrobble from outbox
+	op, err := NewOperation(l, testPlay())
+	if err != nil {
+		t.Fatalf("new operation: %v", err)
+	}
+	err = l.Replay(op)
+	if err != nil {
+		t.Errorf("replay: %v", err)
+	}
+	if got.ListenType != "single" || len(got.Payload) != 1 {
+		t.Fatalf("invalid listen: %v", got)
+	}
+	listen := got.Payload[0]
+	if listen.ListenedAt != 1600000000 || listen.TrackMetadata.TrackName != "Song 1" ||
+		listen.TrackMetadata.ArtistName != "Artist 1" || listen.TrackMetadata.ReleaseName != "Album 1" {
+		t.Errorf("invalid listen: %v", listen)
+	}
+}
+
+type testProvider map[string]string
+
+func (t testProvider) Get(key string, sensitive bool, label string) (string, error) {
+	return t[key], nil
+}
+
+func TestLastFm(t *testing.T) {
+	requests := make([]map[string]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		params := map[string]string{}
+		for key := range r.PostForm {
+			params[key] = r.PostForm.Get(key)
+		}
+		requests = append(requests, params)
+
+		switch params["method"] {
+		case "auth.getMobileSession":
+			w.Write([]byte(`{"session": {"name": "user", "key": "session-key"}}`))
+		case "track.scrobble":
+			w.Write([]byte(`{"error": 9, "message": "Invalid session key"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	conf := &config.LastFm{Url: server.URL, ApiKey: "key", ApiSecret: "secret"}
+	l, err := NewLastFm(conf, testProvider{"lastfm.username": "user", "lastfm.password": "pass"})
+	if err != nil {
+		t.Fatalf("new last.fm: %v", err)
+	}
+	if conf.SessionKey != "session-key" || conf.Username != "user" {
+		t.Errorf("session not stored: %v", conf)
+	}
+
+	// md5("api_keykeymethodauth.getMobileSessionpasswordpassusernameusersecret")
+	login := requests[0]
+	if login["api_sig"] != "9c54f6cf8fc68a3826368902af94331e" {
+		t.Errorf("invalid signature: %s", login["api_sig"])
+	}
+
+	err = l.NowPlaying(testPlay())
+	if err != nil {
+		t.Errorf("now playing: %v", err)
+	}
+	nowPlaying := requests[1]
+	if nowPlaying["method"] != "track.updateNowPlaying" || nowPlaying["sk"] != "session-key" ||
+		nowPlaying["track"] != "Song 1" || nowPlaying["duration"] != "200" {
+		t.Errorf("invalid now playing: %v", nowPlaying)
+	}
+
+	err = l.Scrobble(testPlay())
+	if err == nil {
+		t.Errorf("scrobble error not returned")
+	}
+	if requests[2]["timestamp"] != "1600000000" {
+		t.Errorf("invalid scrobble: %v", requests[2])
+	}
+}
@@ -19,17 +19,21 @@
 package subsonic
 
 import (
+	"context"
 	"errors"
 	"strconv"
+	"time"
 	"tryffel.net/go/jellycli/interfaces"
 	"tryffel.net/go/jellycli/models"
 )
 
 func (s *Subsonic) CanCacheSongs() bool { return false }
 
-func (s *Subsonic) getFavorites() error {
+func (s *Subsonic) CanFilterUserData() bool { return false }
+
+func (s *Subsonic) getFavorites(ctx context.Context) error {
 	if len(s.favoriteAlbums) == 0 || len(s.favoriteArtists) == 0 {
-		resp, err := s.get("/getStarred2", nil)
+		resp, err := s.get(ctx, "/getStarred2", nil)
 		if err != nil {
 			return err
 		}
@@ -46,14 +50,27 @@ func (s *Subsonic) getFavorites() error {
 	return nil
 }
 
-func (s *Subsonic) GetArtists(query *interfaces.QueryOpts) (artists []*models.Artist, n int, err error) {
+func (s *Subsonic) GetArtists(ctx context.Context, query *interfaces.QueryOpts) (artists []*models.Artist, n int, err error) {
 	if query.Filter.Favorite {
-		err := s.getFavorites()
+		err := s.getFavorites(ctx)
 		return s.favoriteArtists, len(s.favoriteArtists), err
 	}
 
 	var resp *response
-	resp, err = s.get("/getArtists", nil)
+	if !query.Filter.ModifiedSince.IsZero() {
+		// getIndexes returns empty index if nothing has changed since given time.
+		params := &params{}
+		(*params)["ifModifiedSince"] = strconv.FormatInt(query.Filter.ModifiedSince.UnixNano()/int64(time.Millisecond), 10)
+		resp, err = s.get(ctx, "/getIndexes", params)
+		if err != nil {
+			return nil, 0, err
+		}
+		if resp.Indexes == nil || resp.Indexes.Indexes == nil || len(*resp.Indexes.Indexes) == 0 {
+			return []*models.Artist{}, 0, nil
+		}
+	}
+
+	resp, err = s.get(ctx, "/getArtists", nil)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -71,12 +88,12 @@ func (s *Subsonic) GetArtists(query *interfaces.QueryOpts) (artists []*models.Ar
 	return artists, len(artists), nil
 }
 
-func (s *Subsonic) GetAlbumArtists(query *interfaces.QueryOpts) ([]*models.Artist, int, error) {
-	return s.GetArtists(query)
+func (s *Subsonic) GetAlbumArtists(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Artist, int, error) {
+	return s.GetArtists(ctx, query)
 }
 
-func (s *Subsonic) getAlbums(params *params) ([]*models.Album, error) {
-	resp, err := s.get("/getAlbumList2", params)
+func (s *Subsonic) getAlbums(ctx context.Context, params *params) ([]*models.Album, error) {
+	resp, err := s.get(ctx, "/getAlbumList2", params)
 	if err != nil {
 		return nil, err
 	}
@@ -89,12 +106,14 @@ func (s *Subsonic) getAlbums(params *params) ([]*models.Album, error) {
 	return albums, nil
 }
 
-func (s *Subsonic) GetAlbums(opts *interfaces.QueryOpts) ([]*models.Album, int, error) {
+func (s *Subsonic) GetAlbums(ctx context.Context, opts *interfaces.QueryOpts) ([]*models.Album, int, error) {
 	// subsonic does not support sorting and filtering at the same time
 	params := &params{}
 	(*params)["type"] = "alphabeticalByName"
 	params.setPaging(opts.Paging)
-	if opts.Filter.YearRangeValid() && opts.Filter.YearRange[0] != 0 {
+	if !opts.Filter.ModifiedSince.IsZero() {
+		return s.getNewAlbums(ctx, params, opts.Filter.ModifiedSince)
+	} else if opts.Filter.YearRangeValid() && opts.Filter.YearRange[0] != 0 {
 		(*params)["type"] = "byYear"
 		(*params)["fromYear"] = strconv.Itoa(opts.Filter.YearRange[0])
 		(*params)["toYear"] = strconv.Itoa(opts.Filter.YearRange[1])
@@ -123,14 +142,34 @@ func (s *Subsonic) GetAlbums(opts *interfaces.QueryOpts) ([]*models.Album, int,
 			}
 		}
 	}
-	albums, err := s.getAlbums(params)
+	albums, err := s.getAlbums(ctx, params)
 	return albums, len(albums), err
 }
 
-func (s *Subsonic) GetArtistAlbums(artist models.Id) (albums []*models.Album, err error) {
+// getNewAlbums returns albums from newest list that were created after since.
+// Subsonic has no way to list albums that were modified.
+func (s *Subsonic) getNewAlbums(ctx context.Context, params *params, since time.Time) ([]*models.Album, int, error) {
+	(*params)["type"] = "newest"
+	resp, err := s.get(ctx, "/getAlbumList2", params)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	albums := make([]*models.Album, 0, len(resp.AlbumList.Albums))
+	for _, v := range resp.AlbumList.Albums {
+		created, err := time.Parse(time.RFC3339, v.Created)
+		if err == nil && created.Before(since) {
+			break
+		}
+		albums = append(albums, v.toAlbum())
+	}
+	return albums, len(albums), nil
+}
+
+func (s *Subsonic) GetArtistAlbums(ctx context.Context, artist models.Id) (albums []*models.Album, err error) {
 	params := &params{}
 	params.setId(artist.String())
-	resp, err := s.get("/getArtist", params)
+	resp, err := s.get(ctx, "/getArtist", params)
 	if err != nil {
 		return nil, err
 	}
@@ -143,12 +182,12 @@ func (s *Subsonic) GetArtistAlbums(artist models.Id) (albums []*models.Album, er
 
 }
 
-func (s *Subsonic) GetAlbumSongs(album models.Id) ([]*models.Song, error) {
+func (s *Subsonic) GetAlbumSongs(ctx context.Context, album models.Id) ([]*models.Song, error) {
 
 	params := &params{}
 	params.setId(album.String())
 
-	resp, err := s.get("/getAlbum", params)
+	resp, err := s.get(ctx, "/getAlbum", params)
 	if err != nil {
 		return nil, err
 	}
@@ -163,8 +202,8 @@ func (s *Subsonic) GetAlbumSongs(album models.Id) ([]*models.Song, error) {
 
 }
 
-func (s *Subsonic) GetPlaylists() ([]*models.Playlist, error) {
-	resp, err := s.get("/getPlaylists", nil)
+func (s *Subsonic) GetPlaylists(ctx context.Context) ([]*models.Playlist, error) {
+	resp, err := s.get(ctx, "/getPlaylists", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -175,10 +214,10 @@ func (s *Subsonic) GetPlaylists() ([]*models.Playlist, error) {
 	return playlists, nil
 }
 
-func (s *Subsonic) GetPlaylistSongs(playlist models.Id) ([]*models.Song, error) {
+func (s *Subsonic) GetPlaylistSongs(ctx context.Context, playlist models.Id) ([]*models.Song, error) {
 	params := &params{}
 	params.setId(playlist.String())
-	resp, err := s.get("/getPlaylist", params)
+	resp, err := s.get(ctx, "/getPlaylist", params)
 	if err != nil {
 		return nil, err
 	}
@@ -189,25 +228,25 @@ func (s *Subsonic) GetPlaylistSongs(playlist models.Id) ([]*models.Song, error)
 	return songs, nil
 }
 
-func (s *Subsonic) GetSimilarArtists(artist models.Id) ([]*models.Artist, error) {
+func (s *Subsonic) GetSimilarArtists(ctx context.Context, artist models.Id) ([]*models.Artist, error) {
 
 	return nil, errors.New("not implemented")
 }
 
-func (s *Subsonic) GetSimilarAlbums(album models.Id) ([]*models.Album, error) {
+func (s *Subsonic) GetSimilarAlbums(ctx context.Context, album models.Id) ([]*models.Album, error) {
 	return nil, errors.New("not implemented")
 }
 
-func (s *Subsonic) GetRecentlyPlayed(paging interfaces.Paging) ([]*models.Song, int, error) {
+func (s *Subsonic) GetRecentlyPlayed(ctx context.Context, paging interfaces.Paging) ([]*models.Song, int, error) {
 	return nil, 0, errors.New("not implemented")
 }
 
-func (s *Subsonic) GetSongs(query *interfaces.QueryOpts) ([]*models.Song, int, error) {
+func (s *Subsonic) GetSongs(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Song, int, error) {
 	return nil, 0, errors.New("not implemented")
 }
 
-func (s *Subsonic) GetGenres(paging interfaces.Paging) ([]*models.IdName, int, error) {
-	resp, err := s.get("/getGenres", nil)
+func (s *Subsonic) GetGenres(ctx context.Context, paging interfaces.Paging) ([]*models.IdName, int, error) {
+	resp, err := s.get(ctx, "/getGenres", nil)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -218,18 +257,18 @@ func (s *Subsonic) GetGenres(paging interfaces.Paging) ([]*models.IdName, int, e
 	return genres, len(genres), nil
 }
 
-func (s *Subsonic) GetGenreAlbums(genre models.IdName) ([]*models.Album, error) {
+func (s *Subsonic) GetGenreAlbums(ctx context.Context, genre models.IdName) ([]*models.Album, error) {
 	params := &params{}
 	(*params)["type"] = "byGenre"
 	(*params)["genre"] = genre.Name
-	albums, err := s.getAlbums(params)
+	albums, err := s.getAlbums(ctx, params)
 	return albums, err
 }
 
-func (s *Subsonic) GetAlbumArtist(album *models.Album) (*models.Artist, error) {
+func (s *Subsonic) GetAlbumArtist(ctx context.Context, album *models.Album) (*models.Artist, error) {
 	params := &params{}
 	params.setId(album.Artist.String())
-	resp, err := s.get("/getArtist", params)
+	resp, err := s.get(ctx, "/getArtist", params)
 	if err != nil {
 		return nil, err
 	}
@@ -242,12 +281,12 @@ func (s *Subsonic) GetAlbumArtist(album *models.Album) (*models.Artist, error) {
 	return artist, nil
 }
 
-func (s *Subsonic) GetInstantMix(item models.Item) ([]*models.Song, error) {
+func (s *Subsonic) GetInstantMix(ctx context.Context, item models.Item) ([]*models.Song, error) {
 	params := &params{}
 	params.setId(item.GetId().String())
 	(*params)["count"] = "200"
 
-	resp, err := s.get("/getSimilarSongs", params)
+	resp, err := s.get(ctx, "/getSimilarSongs", params)
 	if err != nil {
 		return nil, err
 	}
@@ -265,7 +304,7 @@ func (s *Subsonic) GetLink(item models.Item) string {
 	return ""
 }
 
-func (s *Subsonic) Search(query string, itemType models.ItemType, maxResults int) ([]models.Item, error) {
+func (s *Subsonic) Search(ctx context.Context, query string, itemType models.ItemType, maxResults int) ([]models.Item, error) {
 	params := &params{}
 	(*params)["query"] = query
 	(*params)["artistCount"] = "0"
@@ -283,7 +322,7 @@ func (s *Subsonic) Search(query string, itemType models.ItemType, maxResults int
 		(*params)["songCount"] = limit
 	}
 
-	resp, err := s.get("/search3", params)
+	resp, err := s.get(ctx, "/search3", params)
 	if err != nil {
 		return nil, err
 	}
@@ -314,11 +353,11 @@ func (s *Subsonic) Search(query string, itemType models.ItemType, maxResults int
 	return items, nil
 }
 
-func (s *Subsonic) GetAlbum(id models.Id) (*models.Album, error) {
+func (s *Subsonic) GetAlbum(ctx context.Context, id models.Id) (*models.Album, error) {
 	params := &params{}
 	params.setId(id.String())
 
-	resp, err := s.get("/getAlbum", params)
+	resp, err := s.get(ctx, "/getAlbum", params)
 	if err != nil {
 		return nil, err
 	}
@@ -327,11 +366,11 @@ func (s *Subsonic) GetAlbum(id models.Id) (*models.Album, error) {
 	return album, nil
 }
 
-func (s *Subsonic) GetArtist(id models.Id) (*models.Artist, error) {
+func (s *Subsonic) GetArtist(ctx context.Context, id models.Id) (*models.Artist, error) {
 	params := &params{}
 	params.setId(id.String())
 
-	resp, err := s.get("/getArtist", params)
+	resp, err := s.get(ctx, "/getArtist", params)
 	if err != nil {
 		return nil, err
 	}
@@ -22,6 +22,7 @@
 package subsonic
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"errors"
@@ -59,19 +60,11 @@ type Subsonic struct {
 	songScrobbled bool
 }
 
-func (s *Subsonic) Stream(Song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
-	params := &params{}
-	params.setId(Song.Id.String())
-	(*params)["estimateContentLength"] = "true"
-	(*params)["s"] = s.salt
-	(*params)["t"] = s.token
-	(*params)["u"] = s.user
-	(*params)["c"] = s.client
-	(*params)["v"] = s.apiversion
-
+func (s *Subsonic) Stream(ctx context.Context, Song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	params := s.streamParams(Song)
 	url := s.host + "/rest/stream"
 
-	stream, err := api.NewStreamDownload(url, nil, *params, http.DefaultClient, Song.Duration)
+	stream, err := api.NewStreamDownload(ctx, url, nil, *params, http.DefaultClient, Song.Duration)
 	if err != nil {
 		return nil, interfaces.AudioFormatNil, err
 	}
@@ -80,8 +73,68 @@ func (s *Subsonic) Stream(Song *models.Song) (io.ReadCloser, interfaces.AudioFor
 	return stream, format, err
 }
 
-func (s *Subsonic) Download(Song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
-	return s.Stream(Song)
+// StreamFile streams song without buffering it in background.
+func (s *Subsonic) StreamFile(ctx context.Context, Song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	params := s.streamParams(Song)
+	url := s.host + "/rest/stream"
+
+	download, err := api.NewFileDownload(ctx, url, nil, *params, http.DefaultClient)
+	if err != nil {
+		return nil, interfaces.AudioFormatNil, err
+	}
+
+	format, err := download.AudioFormat()
+	if err != nil {
+		download.Close()
+		return nil, interfaces.AudioFormatNil, err
+	}
+	return download, format, nil
+}
+
+// Download downloads original audio file. If original format is not supported, transcoded audio is downloaded.
+func (s *Subsonic) Download(ctx context.Context, Song *models.Song) (io.ReadCloser, interfaces.AudioFormat, error) {
+	params := s.streamParams(Song)
+	delete(*params, "estimateContentLength")
+	url := s.host + "/rest/download"
+
+	download, err := api.NewFileDownload(ctx, url, nil, *params, http.DefaultClient)
+	if err != nil {
+		return nil, interfaces.AudioFormatNil, err
+	}
+
+	format, err := download.AudioFormat()
+	if err == nil {
+		return download, format, nil
+	}
+	download.Close()
+	logrus.Debugf("download song %s: %v, downloading transcoded audio instead", Song.Id, err)
+
+	// original format is not playable, let server transcode it
+	params = s.streamParams(Song)
+	delete(*params, "estimateContentLength")
+	(*params)["format"] = interfaces.AudioFormatMp3.String()
+	download, err = api.NewFileDownload(ctx, s.host+"/rest/stream", nil, *params, http.DefaultClient)
+	if err != nil {
+		return nil, interfaces.AudioFormatNil, err
+	}
+	format, err = download.AudioFormat()
+	if err != nil {
+		download.Close()
+		return nil, interfaces.AudioFormatNil, err
+	}
+	return download, format, nil
+}
+
+func (s *Subsonic) streamParams(Song *models.Song) *params {
+	params := &params{}
+	params.setId(Song.Id.String())
+	(*params)["estimateContentLength"] = "true"
+	(*params)["s"] = s.salt
+	(*params)["t"] = s.token
+	(*params)["u"] = s.user
+	(*params)["c"] = s.client
+	(*params)["v"] = s.apiversion
+	return params
 }
 
 func (s *Subsonic) GetInfo() (*models.ServerInfo, error) {
@@ -89,7 +142,7 @@ func (s *Subsonic) GetInfo() (*models.ServerInfo, error) {
 		ServerType: "Subsonic",
 	}
 
-	resp, err := s.get("/ping", nil)
+	resp, err := s.get(context.Background(), "/ping", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -143,7 +196,7 @@ func NewSubsonic(conf *config.Subsonic, provider config.KeyValueProvider) (*Subs
 		}
 	}
 
-	resp, err := s.get("/getMusicFolders", nil)
+	resp, err := s.get(context.Background(), "/getMusicFolders", nil)
 	if err != nil {
 		return s, fmt.Errorf("get music folders: %v", err)
 	}
@@ -161,10 +214,12 @@ func NewSubsonic(conf *config.Subsonic, provider config.KeyValueProvider) (*Subs
 	return s, nil
 }
 
-func (s *Subsonic) get(url string, params *params) (*response, error) {
+func (s *Subsonic) get(ctx context.Context, url string, params *params) (*response, error) {
 	fullUrl := s.host + "/rest" + url
+	ctx, cancel := api.WithRequestTimeout(ctx)
+	defer cancel()
 	start := time.Now()
-	req, _ := http.NewRequest(http.MethodGet, fullUrl, nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
 
 	q := req.URL.Query()
 	q.Add("s", s.salt)
@@ -206,7 +261,7 @@ func (s *Subsonic) get(url string, params *params) (*response, error) {
 }
 
 func (s *Subsonic) checkConnection() error {
-	resp, err := s.get("/ping", nil)
+	resp, err := s.get(context.Background(), "/ping", nil)
 	if err != nil {
 		if resp != nil {
 			s.connectionError = resp.Error
@@ -270,20 +325,57 @@ func (s *Subsonic) ReportProgress(state *interfaces.ApiPlaybackState) (err error
 	}
 
 	if state.Event == interfaces.EventTimeUpdate && models.Id(state.ItemId) == s.currentSong {
-		if state.Position > 5 && !s.songScrobbled {
-			params := &params{}
-			params.setId(s.currentSong.String())
-			_, err := s.get("/scrobble", params)
+		if state.Position.Seconds() > 5 && !s.songScrobbled {
+			s.songScrobbled = true
+			playedAt := time.Now().Add(-time.Millisecond * time.Duration(state.Position))
+			op := models.NewOperation(models.OperationScrobble, s.currentSong, playedAt, "")
+			err = s.Replay(op)
 			if err != nil {
-				logrus.Errorf("Scrobble song: %v", err)
-			} else {
-				s.songScrobbled = true
+				return &api.OperationError{Operation: op, Err: err}
 			}
 		}
 	}
 	return
 }
 
+// Replay sends operation to server. Subsonic has no playback reports, so only scrobbles and favorites are sent.
+func (s *Subsonic) Replay(op *models.Operation) error {
+	switch op.Type {
+	case models.OperationScrobble:
+		params := &params{}
+		params.setId(op.Item.String())
+		(*params)["time"] = strconv.FormatInt(op.PlayedAt.UnixNano()/int64(time.Millisecond), 10)
+		(*params)["submission"] = "true"
+		_, err := s.get(context.Background(), "/scrobble", params)
+		return err
+	case models.OperationFavorite, models.OperationUnfavorite:
+		params := &params{}
+		switch models.ItemType(op.Data) {
+		case models.TypeArtist:
+			(*params)["artistId"] = op.Item.String()
+		case models.TypeAlbum:
+			(*params)["albumId"] = op.Item.String()
+		default:
+			params.setId(op.Item.String())
+		}
+		url := "/star"
+		if op.Type == models.OperationUnfavorite {
+			url = "/unstar"
+		}
+		_, err := s.get(context.Background(), url, params)
+		if err == nil {
+			// favorites are fetched again when needed
+			s.favoriteAlbums = nil
+			s.favoriteArtists = nil
+		}
+		return err
+	case models.OperationPlaybackStopped:
+		return nil
+	default:
+		return fmt.Errorf("unsupported operation: %s", op.Type)
+	}
+}
+
 func (s *Subsonic) Start() error {
 	return nil
 }
@@ -293,5 +385,10 @@ func (s *Subsonic) Stop() error {
 }
 
 func (s *Subsonic) GetId() string {
-	return fmt.Sprintf("%x", md5.Sum([]byte(s.host+s.user)))
+	return ServerId(s.host, s.user)
+}
+
+// ServerId returns server id for given server url and username.
+func ServerId(url, username string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(url+username)))
 }
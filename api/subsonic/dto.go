@@ -138,6 +138,7 @@ type album struct {
 	Year      int    `json:"year"`
 	Duration  int    `json:"duration"`
 	Starred   string `json:"starred"`
+	Created   string `json:"created"`
 }
 
 func (a *album) toAlbum() *models.Album {
@@ -185,6 +186,9 @@ type child struct {
 	ArtistId   string `json:"artistId"`
 	Type       string `json:"type"`
 	SongCount  int    `json:"songCount"`
+	PlayCount  int    `json:"playCount"`
+	UserRating int    `json:"userRating"`
+	Starred    string `json:"starred"`
 }
 
 func (c *child) toAlbum() *models.Album {
@@ -212,7 +216,9 @@ func (c *child) toSong() *models.Song {
 		DiscNumber:  c.DiscNumber,
 		Artists:     nil,
 		AlbumArtist: models.Id(c.ArtistId),
-		Favorite:    false,
+		Favorite:    c.Starred != "",
+		PlayCount:   c.PlayCount,
+		Rating:      c.UserRating,
 	}
 }
 
@@ -0,0 +1,120 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/player"
+	"tryffel.net/go/jellycli/storage"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Show songs in local song cache",
+	Long: `Show songs that have been cached to local disk after playing them.
+Song cache size is limited with player.song_cache_size_mb.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cache, db := openSongCache()
+		defer db.Close()
+
+		songs, err := cache.Songs()
+		if err != nil {
+			logrus.Fatalf("get cached songs: %v", err)
+		}
+		size, limit, err := cache.Size()
+		if err != nil {
+			logrus.Fatalf("get cache size: %v", err)
+		}
+
+		for _, v := range songs {
+			lastPlayed := "never"
+			if !v.LastPlayed.IsZero() {
+				lastPlayed = v.LastPlayed.Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%s - %s (%s), played %d times, last played %s\n",
+				v.Song.Name, v.AlbumName, models.ByteSize(v.Size), v.PlayCount, lastPlayed)
+		}
+		if limit > 0 {
+			fmt.Printf("%d songs, %s / %s\n", len(songs), models.ByteSize(size), models.ByteSize(limit))
+		} else {
+			fmt.Printf("%d songs, %s, song cache is disabled\n", len(songs), models.ByteSize(size))
+		}
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove songs from cache until it is under size limit",
+	Run: func(cmd *cobra.Command, args []string) {
+		cache, db := openSongCache()
+		defer db.Close()
+
+		removed, size, err := cache.Prune()
+		if err != nil {
+			logrus.Fatalf("prune song cache: %v", err)
+		}
+		fmt.Printf("Removed %d songs (%s)\n", removed, models.ByteSize(size))
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all songs from cache",
+	Run: func(cmd *cobra.Command, args []string) {
+		cache, db := openSongCache()
+		defer db.Close()
+
+		removed, size, err := cache.Clear()
+		if err != nil {
+			logrus.Fatalf("clear song cache: %v", err)
+		}
+		fmt.Printf("Removed %d songs (%s)\n", removed, models.ByteSize(size))
+	},
+}
+
+// openSongCache opens song cache for configured server without connecting to it.
+func openSongCache() (*player.SongCache, *storage.Db) {
+	db, id := openLocalDb()
+	return player.NewSongCache(db, id), db
+}
+
+// openLocalDb opens local database for configured server without connecting to it.
+func openLocalDb() (*storage.Db, string) {
+	disableGui = true
+	initConfig()
+
+	id, err := lastServerId()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	db, err := storage.NewDb(id)
+	if err != nil {
+		logrus.Fatalf("open local database: %v", err)
+	}
+	return db, id
+}
+
+func init() {
+	cacheCmd.AddCommand(cachePruneCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
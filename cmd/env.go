@@ -42,6 +42,17 @@ JELLYCLI_SUBSONIC_USERNAME
 JELLYCLI_SUBSONIC_SALT
 JELLYCLI_SUBSONIC_TOKEN
 
+JELLYCLI_LISTENBRAINZ_ENABLED
+JELLYCLI_LISTENBRAINZ_URL
+JELLYCLI_LISTENBRAINZ_TOKEN
+
+JELLYCLI_LASTFM_ENABLED
+JELLYCLI_LASTFM_URL
+JELLYCLI_LASTFM_API_KEY
+JELLYCLI_LASTFM_API_SECRET
+JELLYCLI_LASTFM_USERNAME
+JELLYCLI_LASTFM_SESSION_KEY
+
 JELLYCLI_PLAYER_SERVER
 JELLYCLI_PLAYER_LOGFILE
 JELLYCLI_PLAYER_LOGLEVEL
@@ -51,6 +62,15 @@ JELLYCLI_PLAYER_AUDIO_BUFFERING_MS
 JELLYCLI_PLAYER_ENABLE_REMOTE_CONTROL
 JELLYCLI_PLAYER_ENABLE_LOCAL_CACHE
 JELLYCLI_PLAYER_ENABLE_LOCAL_CACHE_DIR
+JELLYCLI_PLAYER_LIBRARY_SYNC_MIN
+JELLYCLI_PLAYER_SONG_CACHE_SIZE_MB
+JELLYCLI_PLAYER_PREFETCH_SONGS
+JELLYCLI_PLAYER_PREFETCH_LEAD_S
+JELLYCLI_PLAYER_PREFETCH_BUFFER_MB
+JELLYCLI_PLAYER_ENABLE_RADIO
+JELLYCLI_PLAYER_RADIO_QUEUE_THRESHOLD
+JELLYCLI_PLAYER_SLEEP_FADE_OUT
+JELLYCLI_PLAYER_SHUFFLE_MODE
 
 JELLYCLI_GUI_PAGESIZE
 JELLYCLI_GUI_DEBUG_MODE
@@ -68,6 +88,7 @@ JELLYCLI_GUI_ENABLE_RESULTS_FILTERING
 # Additional environment variables
 JELLYCLI_JELLYFIN_PASSWORD
 JELLYCLI_SUBSONIC_PASSWORD
+JELLYCLI_LASTFM_PASSWORD
 
 # disable gui
 JELLYCLI_PLAYER_NOGUI
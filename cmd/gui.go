@@ -19,6 +19,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -29,10 +30,13 @@ import (
 	"syscall"
 	"tryffel.net/go/jellycli/api"
 	"tryffel.net/go/jellycli/api/jellyfin"
+	"tryffel.net/go/jellycli/api/offline"
+	"tryffel.net/go/jellycli/api/scrobbler"
 	"tryffel.net/go/jellycli/api/subsonic"
 	"tryffel.net/go/jellycli/config"
 	"tryffel.net/go/jellycli/mpris"
 	"tryffel.net/go/jellycli/player"
+	"tryffel.net/go/jellycli/storage"
 	"tryffel.net/go/jellycli/task"
 	"tryffel.net/go/jellycli/ui"
 )
@@ -67,7 +71,7 @@ func initApplication() (*app, error) {
 
 	logrus.Infof("############# %s v%s ############", config.AppName, config.Version)
 
-	err = a.initServerConnection()
+	err = a.initServerConnection(true)
 	if err != nil {
 		logrus.Fatalf("connect to server: %v", err)
 	}
@@ -96,24 +100,61 @@ func initApplication() (*app, error) {
 	return a, nil
 }
 
-func (a *app) initServerConnection() error {
+// initServerConnection connects to remote server. If allowOffline, connection is monitored in background
+// and local database is used whenever server is not reachable. If server is not reachable at start,
+// start in offline mode and try to connect in background.
+func (a *app) initServerConnection(allowOffline bool) error {
+	var err error
+	a.server, err = connectServer(&config.ViperStdConfigProvider{})
+	if !allowOffline {
+		return err
+	}
+	reconnect := func() (api.MediaServer, error) {
+		return connectServer(&config.ViperConfigProvider{})
+	}
+	if err == nil {
+		server, offlineErr := offline.NewOnlineServer(a.server, reconnect)
+		if offlineErr != nil {
+			logrus.Warningf("init offline mode, connection is not monitored: %v", offlineErr)
+			return nil
+		}
+		a.server = server
+		return nil
+	}
+
+	id, idErr := lastServerId()
+	if idErr != nil || !storage.DbExists(id) {
+		return err
+	}
+
+	logrus.Warningf("Cannot connect to server, starting in offline mode: %v", err)
+	a.server, err = offline.NewServer(id, reconnect)
+	if err != nil {
+		return fmt.Errorf("init offline mode: %v", err)
+	}
+	return nil
+}
+
+// connectServer connects to configured remote server and updates backend configuration.
+func connectServer(provider config.KeyValueProvider) (api.MediaServer, error) {
+	var server api.MediaServer
 	var err error
 	switch strings.ToLower(config.AppConfig.Player.Server) {
 	case "jellyfin":
-		a.server, err = jellyfin.NewJellyfin(&config.AppConfig.Jellyfin, &config.ViperStdConfigProvider{})
+		server, err = jellyfin.NewJellyfin(&config.AppConfig.Jellyfin, provider)
 	case "subsonic":
-		a.server, err = subsonic.NewSubsonic(&config.AppConfig.Subsonic, &config.ViperStdConfigProvider{})
+		server, err = subsonic.NewSubsonic(&config.AppConfig.Subsonic, provider)
 	default:
-		return fmt.Errorf("unsupported backend: '%s'", config.AppConfig.Player.Server)
+		return nil, fmt.Errorf("unsupported backend: '%s'", config.AppConfig.Player.Server)
 	}
 	if err != nil {
-		return fmt.Errorf("api init: %v", err)
+		return nil, fmt.Errorf("api init: %v", err)
 	}
-	if err := a.server.ConnectionOk(); err != nil {
-		return fmt.Errorf("no connection to server: %v", err)
+	if err := server.ConnectionOk(); err != nil {
+		return nil, fmt.Errorf("no connection to server: %v", err)
 	}
 
-	conf := a.server.GetConfig()
+	conf := server.GetConfig()
 	if config.AppConfig.Player.Server == "jellyfin" {
 		jfConfig, ok := conf.(*config.Jellyfin)
 		if ok {
@@ -125,8 +166,46 @@ func (a *app) initServerConnection() error {
 			config.AppConfig.Subsonic = *subConfig
 		}
 	}
-	return nil
+	return server, nil
+}
+
+// initScrobblers adds enabled scrobblers to player. Scrobblers that fail to initialize are disabled.
+func (a *app) initScrobblers() {
+	if config.AppConfig.ListenBrainz.Enabled {
+		listenBrainz, err := scrobbler.NewListenBrainz(&config.AppConfig.ListenBrainz)
+		if err != nil {
+			logrus.Errorf("init listenbrainz, scrobbling is disabled: %v", err)
+		} else {
+			a.player.AddScrobbler(listenBrainz)
+		}
+	}
+	if config.AppConfig.LastFm.Enabled {
+		lastFm, err := scrobbler.NewLastFm(&config.AppConfig.LastFm, &config.ViperStdConfigProvider{})
+		if err != nil {
+			logrus.Errorf("init last.fm, scrobbling is disabled: %v", err)
+		} else {
+			a.player.AddScrobbler(lastFm)
+		}
+	}
+}
 
+// lastServerId returns id of configured server. Id is resolved from configuration only,
+// so this works without connection to server.
+func lastServerId() (string, error) {
+	switch strings.ToLower(config.AppConfig.Player.Server) {
+	case "jellyfin":
+		if config.AppConfig.Jellyfin.ServerId == "" {
+			return "", errors.New("jellyfin server id is unknown, connect to server first")
+		}
+		return config.AppConfig.Jellyfin.ServerId, nil
+	case "subsonic":
+		if config.AppConfig.Subsonic.Url == "" {
+			return "", errors.New("subsonic url is not set")
+		}
+		return subsonic.ServerId(config.AppConfig.Subsonic.Url, config.AppConfig.Subsonic.Username), nil
+	default:
+		return "", fmt.Errorf("unsupported backend: '%s'", config.AppConfig.Player.Server)
+	}
 }
 
 func (a *app) initGui() {
@@ -141,6 +220,7 @@ func (a *app) initApp() error {
 	if err != nil {
 		return fmt.Errorf("create player: %v", err)
 	}
+	a.initScrobblers()
 	a.mpris, err = mpris.NewController(a.player)
 	if err != nil {
 		if strings.Contains(err.Error(), "dbus-launch") {
@@ -167,7 +247,7 @@ func (a *app) run() {
 		}
 	}
 	var err error
-	tasks := []task.Tasker{a.player, a.server}
+	tasks := []task.Tasker{a.player.LibrarySync, a.player, a.server}
 
 	for _, v := range tasks {
 		err = v.Start()
@@ -199,7 +279,7 @@ func (a *app) stopOnSignal() {
 
 func (a *app) stop() error {
 	logrus.Info("Stopping application")
-	tasks := []task.Tasker{a.player, a.server}
+	tasks := []task.Tasker{a.player.LibrarySync, a.player, a.server}
 	var err error
 	var hasError bool
 	for _, v := range tasks {
@@ -19,6 +19,7 @@
 package cmd
 
 import (
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"io"
@@ -26,9 +27,15 @@ import (
 	"tryffel.net/go/jellycli/config"
 )
 
+var refreshFull bool
+
 var refreshCmd = &cobra.Command{
 	Use:   "refresh",
 	Short: "Pull latest data from remote server and store to local cache",
+	Long: `Pull latest data from remote server and store to local cache.
+Only items added or changed since previous refresh are pulled. Full refresh pulls all items
+and removes items that no longer exist on server. It is done on first refresh, with --full,
+and when previous full refresh is over a week old.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		disableGui = true
 		initConfig()
@@ -49,7 +56,7 @@ var refreshCmd = &cobra.Command{
 			logrus.Fatalf("Local cache is disabled")
 		}
 
-		err = a.initServerConnection()
+		err = a.initServerConnection(false)
 		if err != nil {
 			logrus.Fatalf("connect to server: %v", err)
 		}
@@ -71,33 +78,26 @@ var refreshCmd = &cobra.Command{
 		}
 		defer quit()
 
-		err = a.player.UpdateLocalArtists(0)
-		if err != nil {
-			logrus.Error(err)
-			return
-		}
-
-		err = a.player.UpdateLocalAlbums(0)
-		if err != nil {
-			logrus.Error(err)
-			return
-		}
-
-		err = a.player.UpdateLocalSongs(0)
+		summary, err := a.player.RefreshLocalCache(refreshFull)
 		if err != nil {
 			logrus.Error(err)
 			return
 		}
 
-		err = a.player.UpdatePlaylists()
-		if err != nil {
-			logrus.Error(err)
-			return
+		kind := "Incremental refresh"
+		if summary.Full {
+			kind = "Full refresh"
 		}
+		fmt.Printf("%s done in %.1f s\n", kind, summary.Took.Seconds())
+		fmt.Printf("Artists:   %s\n", summary.Artists)
+		fmt.Printf("Albums:    %s\n", summary.Albums)
+		fmt.Printf("Songs:     %s\n", summary.Songs)
+		fmt.Printf("Playlists: %s\n", summary.Playlists)
 		ok = true
 	},
 }
 
 func init() {
+	refreshCmd.Flags().BoolVar(&refreshFull, "full", false, "pull all items and remove deleted items")
 	rootCmd.AddCommand(refreshCmd)
 }
@@ -0,0 +1,127 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package cmd
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/player"
+	"tryffel.net/go/jellycli/util"
+)
+
+var statsPeriod string
+var statsOutput string
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show listening statistics",
+	Long: `Show listening statistics from local listening history.
+Every song played with jellycli is recorded locally, regardless of server.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		history, db, since := openPlayHistory()
+		defer db.Close()
+
+		stats, err := history.GetListeningStats(since)
+		if err != nil {
+			logrus.Fatalf("get listening stats: %v", err)
+		}
+
+		fmt.Printf("%s: %d plays, %s listened, %.0f%% skipped\n", models.ListeningPeriod(statsPeriod).Label(),
+			stats.Plays, util.SecToStringApproximate(int(stats.ListeningTime.Seconds())), stats.SkipRate()*100)
+		printPlayCounts("Top artists", stats.TopArtists)
+		printPlayCounts("Top albums", stats.TopAlbums)
+		printPlayCounts("Top songs", stats.TopSongs)
+	},
+}
+
+var statsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export listening history in ListenBrainz format",
+	Long: `Export listening history as ListenBrainz listen import JSON.
+Only songs that have been played for half of their duration or 4 minutes are exported.
+By default all history is exported.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !cmd.Flags().Changed("period") {
+			statsPeriod = string(models.ListeningPeriodAll)
+		}
+		history, db, since := openPlayHistory()
+		defer db.Close()
+
+		var w io.Writer = os.Stdout
+		if statsOutput != "" {
+			file, err := os.Create(statsOutput)
+			if err != nil {
+				logrus.Fatalf("create export file: %v", err)
+			}
+			defer file.Close()
+			w = file
+		}
+
+		n, err := history.ExportListenBrainz(w, since)
+		if err != nil {
+			logrus.Fatalf("export listening history: %v", err)
+		}
+		if statsOutput != "" {
+			fmt.Printf("Exported %d listens to %s\n", n, statsOutput)
+		}
+	},
+}
+
+// openPlayHistory opens play history and returns start of selected period.
+func openPlayHistory() (*player.PlayHistory, io.Closer, time.Time) {
+	period := models.ListeningPeriod(statsPeriod)
+	valid := false
+	for _, v := range models.ListeningPeriods {
+		valid = valid || v == period
+	}
+	if !valid {
+		logrus.Fatalf("invalid period '%s', must be one of: %v", statsPeriod, models.ListeningPeriods)
+	}
+
+	db, _ := openLocalDb()
+	return player.NewPlayHistory(db), db, period.Since(time.Now())
+}
+
+func printPlayCounts(title string, counts []models.PlayCount) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for i, v := range counts {
+		name := strings.TrimSpace(v.Name)
+		if name == "" {
+			name = v.Id.String()
+		}
+		fmt.Printf("%2d. %s, %d plays (%s)\n", i+1, name, v.Plays,
+			util.SecToStringApproximate(int(v.Played.Seconds())))
+	}
+}
+
+func init() {
+	statsCmd.PersistentFlags().StringVarP(&statsPeriod, "period", "p", string(models.ListeningPeriodMonth),
+		"period: week, month, year or all")
+	statsExportCmd.Flags().StringVarP(&statsOutput, "output", "o", "", "output file, default is stdout")
+	statsCmd.AddCommand(statsExportCmd)
+	rootCmd.AddCommand(statsCmd)
+}
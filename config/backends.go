@@ -18,7 +18,10 @@
 
 package config
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+	"github.com/spf13/viper"
+)
 
 type Backend interface {
 	DumpConfig() interface{}
@@ -82,3 +85,15 @@ func (s *ViperStdConfigProvider) Get(key string, sensitive bool, label string) (
 	}
 	return ReadUserInput(label, sensitive)
 }
+
+// ViperConfigProvider reads key only from viper (config file & env). It is used when user
+// cannot be prompted, e.g. when connecting in background.
+type ViperConfigProvider struct{}
+
+func (s *ViperConfigProvider) Get(key string, sensitive bool, label string) (string, error) {
+	val := viper.GetString(key)
+	if val != "" {
+		return val, nil
+	}
+	return "", fmt.Errorf("%s is not set", label)
+}
@@ -45,6 +45,9 @@ type Config struct {
 	Subsonic Subsonic `yaml:"subsonic"`
 	Player   Player   `yaml:"player"`
 	Gui      Gui      `yaml:"gui"`
+
+	ListenBrainz ListenBrainz `yaml:"listenbrainz"`
+	LastFm       LastFm       `yaml:"lastfm"`
 }
 
 type Gui struct {
@@ -79,8 +82,51 @@ type Player struct {
 
 	EnableLocalCache bool   `yaml:"enable_local_cache"`
 	LocalCacheDir    string `yaml:"local_cache_dir"`
+	// LibrarySyncMin is interval in minutes for syncing local cache in background, -1 disables periodic sync.
+	LibrarySyncMin int `yaml:"library_sync_min"`
+
+	// SongCacheSizeMb is size limit in MiB for caching played songs on disk, -1 disables cache.
+	SongCacheSizeMb int `yaml:"song_cache_size_mb"`
+
+	// PrefetchSongs is number of upcoming songs to download beforehand, -1 disables prefetching.
+	PrefetchSongs int `yaml:"prefetch_songs"`
+	// PrefetchLeadS is how many seconds before end of current song to start prefetching.
+	PrefetchLeadS int `yaml:"prefetch_lead_s"`
+	// PrefetchBufferMb is memory limit in MiB for all prefetched songs.
+	PrefetchBufferMb int `yaml:"prefetch_buffer_mb"`
+
+	// EnableRadio fills queue with similar songs when it is about to run out.
+	EnableRadio bool `yaml:"enable_radio"`
+	// RadioQueueThreshold is number of songs left in queue when radio adds more songs.
+	RadioQueueThreshold int `yaml:"radio_queue_threshold"`
+
+	// SleepFadeOut fades out volume before sleep timer pauses playback.
+	SleepFadeOut bool `yaml:"sleep_fade_out"`
+
+	// ShuffleMode is algorithm for shuffling queue, one of Shuffle*.
+	ShuffleMode string `yaml:"shuffle_mode"`
+
+	// RequestTimeoutS is timeout in seconds for single request to server, -1 disables timeout.
+	// Streaming songs is not limited.
+	RequestTimeoutS int `yaml:"request_timeout_s"`
+
+	// MetadataCacheItems is maximum number of artists, albums, songs and playlists kept in metadata cache.
+	// Cache is stored in local cache directory between sessions.
+	MetadataCacheItems int `yaml:"metadata_cache_items"`
 }
 
+// Shuffle modes
+const (
+	// ShuffleRandom shuffles songs completely randomly.
+	ShuffleRandom = "random"
+	// ShuffleArtistSpread spreads songs from each artist evenly over queue.
+	ShuffleArtistSpread = "artist_spread"
+	// ShuffleAlbum shuffles order of albums, but keeps songs of each album in order.
+	ShuffleAlbum = "album"
+	// ShuffleWeighted plays songs with higher rating and play count more likely earlier.
+	ShuffleWeighted = "weighted"
+)
+
 func (g *Gui) sanitize() {
 	if g.PageSize <= 0 || g.PageSize > 500 {
 		g.PageSize = 100
@@ -120,6 +166,50 @@ func (p *Player) sanitize() {
 		p.HttpBufferingLimitMem = 20
 	}
 
+	if p.LibrarySyncMin == 0 {
+		p.LibrarySyncMin = 60
+	} else if p.LibrarySyncMin < 0 {
+		p.LibrarySyncMin = -1
+	}
+
+	if p.SongCacheSizeMb == 0 {
+		p.SongCacheSizeMb = 512
+	} else if p.SongCacheSizeMb < 0 {
+		p.SongCacheSizeMb = -1
+	}
+
+	if p.PrefetchSongs == 0 {
+		p.PrefetchSongs = 2
+	} else if p.PrefetchSongs < 0 {
+		p.PrefetchSongs = -1
+	}
+	if p.PrefetchLeadS <= 0 {
+		p.PrefetchLeadS = 30
+	}
+	if p.PrefetchBufferMb <= 0 {
+		p.PrefetchBufferMb = 100
+	}
+	if p.RadioQueueThreshold <= 0 {
+		p.RadioQueueThreshold = 3
+	}
+	if p.RequestTimeoutS == 0 {
+		p.RequestTimeoutS = 30
+	} else if p.RequestTimeoutS < 0 {
+		p.RequestTimeoutS = -1
+	}
+	if p.MetadataCacheItems <= 0 {
+		p.MetadataCacheItems = 5000
+	}
+
+	switch p.ShuffleMode {
+	case ShuffleRandom, ShuffleArtistSpread, ShuffleAlbum, ShuffleWeighted:
+	case "":
+		p.ShuffleMode = ShuffleRandom
+	default:
+		logrus.Warningf("invalid shuffle mode '%s', using '%s'", p.ShuffleMode, ShuffleRandom)
+		p.ShuffleMode = ShuffleRandom
+	}
+
 	if p.LocalCacheDir == "" {
 		baseCacheDir, err := os.UserCacheDir()
 		if err != nil {
@@ -139,6 +229,7 @@ func (c *Config) initNewConfig() {
 	// booleans are hard to determine whether they are set or not,
 	// so only fill this here
 	c.Gui.LimitRecentlyPlayed = true
+	c.Player.SleepFadeOut = true
 	if c.Player.Server == "" {
 		c.Player.Server = "jellyfin"
 	}
@@ -184,6 +275,8 @@ func ReadUserInput(name string, mask bool) (string, error) {
 
 // ConfigFromViper reads full application configuration from viper.
 func ConfigFromViper() error {
+	// fading out is enabled unless explicitly disabled
+	sleepFadeOut := !viper.IsSet("player.sleep_fade_out") || viper.GetBool("player.sleep_fade_out")
 
 	AppConfig = &Config{
 		Jellyfin: Jellyfin{
@@ -210,6 +303,17 @@ func ConfigFromViper() error {
 			EnableRemoteControl:   viper.GetBool("player.enable_remote_control"),
 			LocalCacheDir:         viper.GetString("player.local_cache_dir"),
 			EnableLocalCache:      viper.GetBool("player.enable_local_cache"),
+			LibrarySyncMin:        viper.GetInt("player.library_sync_min"),
+			SongCacheSizeMb:       viper.GetInt("player.song_cache_size_mb"),
+			PrefetchSongs:         viper.GetInt("player.prefetch_songs"),
+			PrefetchLeadS:         viper.GetInt("player.prefetch_lead_s"),
+			PrefetchBufferMb:      viper.GetInt("player.prefetch_buffer_mb"),
+			EnableRadio:           viper.GetBool("player.enable_radio"),
+			RadioQueueThreshold:   viper.GetInt("player.radio_queue_threshold"),
+			SleepFadeOut:          sleepFadeOut,
+			ShuffleMode:           viper.GetString("player.shuffle_mode"),
+			RequestTimeoutS:       viper.GetInt("player.request_timeout_s"),
+			MetadataCacheItems:    viper.GetInt("player.metadata_cache_items"),
 		},
 		Gui: Gui{
 			PageSize:            viper.GetInt("gui.pagesize"),
@@ -224,6 +328,19 @@ func ConfigFromViper() error {
 			EnableFiltering:        viper.GetBool("gui.enable_filtering"),
 			EnableResultsFiltering: viper.GetBool("gui.enable_results_filtering"),
 		},
+		ListenBrainz: ListenBrainz{
+			Enabled: viper.GetBool("listenbrainz.enabled"),
+			Url:     viper.GetString("listenbrainz.url"),
+			Token:   viper.GetString("listenbrainz.token"),
+		},
+		LastFm: LastFm{
+			Enabled:    viper.GetBool("lastfm.enabled"),
+			Url:        viper.GetString("lastfm.url"),
+			ApiKey:     viper.GetString("lastfm.api_key"),
+			ApiSecret:  viper.GetString("lastfm.api_secret"),
+			Username:   viper.GetString("lastfm.username"),
+			SessionKey: viper.GetString("lastfm.session_key"),
+		},
 	}
 
 	searchTypes := viper.GetStringSlice("gui.search_types")
@@ -287,6 +404,17 @@ func UpdateViper() {
 	viper.Set("subsonic.salt", AppConfig.Subsonic.Salt)
 	viper.Set("subsonic.token", AppConfig.Subsonic.Token)
 
+	viper.Set("listenbrainz.enabled", AppConfig.ListenBrainz.Enabled)
+	viper.Set("listenbrainz.url", AppConfig.ListenBrainz.Url)
+	viper.Set("listenbrainz.token", AppConfig.ListenBrainz.Token)
+
+	viper.Set("lastfm.enabled", AppConfig.LastFm.Enabled)
+	viper.Set("lastfm.url", AppConfig.LastFm.Url)
+	viper.Set("lastfm.api_key", AppConfig.LastFm.ApiKey)
+	viper.Set("lastfm.api_secret", AppConfig.LastFm.ApiSecret)
+	viper.Set("lastfm.username", AppConfig.LastFm.Username)
+	viper.Set("lastfm.session_key", AppConfig.LastFm.SessionKey)
+
 	viper.Set("player.server", AppConfig.Player.Server)
 	viper.Set("player.logfile", AppConfig.Player.LogFile)
 	viper.Set("player.loglevel", AppConfig.Player.LogLevel)
@@ -296,6 +424,17 @@ func UpdateViper() {
 	viper.Set("player.audio_buffering_ms", AppConfig.Player.AudioBufferingMs)
 	viper.Set("player.local_cache_dir", AppConfig.Player.LocalCacheDir)
 	viper.Set("player.enable_local_cache", AppConfig.Player.EnableLocalCache)
+	viper.Set("player.library_sync_min", AppConfig.Player.LibrarySyncMin)
+	viper.Set("player.song_cache_size_mb", AppConfig.Player.SongCacheSizeMb)
+	viper.Set("player.prefetch_songs", AppConfig.Player.PrefetchSongs)
+	viper.Set("player.prefetch_lead_s", AppConfig.Player.PrefetchLeadS)
+	viper.Set("player.prefetch_buffer_mb", AppConfig.Player.PrefetchBufferMb)
+	viper.Set("player.enable_radio", AppConfig.Player.EnableRadio)
+	viper.Set("player.radio_queue_threshold", AppConfig.Player.RadioQueueThreshold)
+	viper.Set("player.sleep_fade_out", AppConfig.Player.SleepFadeOut)
+	viper.Set("player.shuffle_mode", AppConfig.Player.ShuffleMode)
+	viper.Set("player.request_timeout_s", AppConfig.Player.RequestTimeoutS)
+	viper.Set("player.metadata_cache_items", AppConfig.Player.MetadataCacheItems)
 
 	viper.Set("gui.search_results_limit", AppConfig.Gui.SearchResultsLimit)
 	viper.Set("gui.debug_mode", AppConfig.Gui.DebugMode)
@@ -55,6 +55,17 @@ func TestConfigToFromViper(t *testing.T) {
 			EnableRemoteControl:   true,
 			LocalCacheDir:         "/tmp/jellycli",
 			EnableLocalCache:      true,
+			LibrarySyncMin:        30,
+			SongCacheSizeMb:       256,
+			PrefetchSongs:         3,
+			PrefetchLeadS:         20,
+			PrefetchBufferMb:      50,
+			EnableRadio:           true,
+			RadioQueueThreshold:   5,
+			SleepFadeOut:          true,
+			ShuffleMode:           ShuffleArtistSpread,
+			RequestTimeoutS:       10,
+			MetadataCacheItems:    2000,
 		},
 		Gui: Gui{
 			PageSize:               100,
@@ -69,6 +80,19 @@ func TestConfigToFromViper(t *testing.T) {
 			EnableResultsFiltering: true,
 			VolumeSteps:            20,
 		},
+		ListenBrainz: ListenBrainz{
+			Enabled: true,
+			Url:     "http://localhost/apis/listenbrainz",
+			Token:   "lbtoken",
+		},
+		LastFm: LastFm{
+			Enabled:    true,
+			Url:        "http://localhost/2.0/",
+			ApiKey:     "lastfmkey",
+			ApiSecret:  "lastfmsecret",
+			Username:   "lastfmuser",
+			SessionKey: "lastfmsession",
+		},
 	}
 
 	viper.Reset()
@@ -118,6 +142,16 @@ func TestInitEmptyConfig(t *testing.T) {
 			EnableRemoteControl:   true,
 			LocalCacheDir:         path.Join(cachedir, AppNameLower),
 			EnableLocalCache:      false,
+			LibrarySyncMin:        60,
+			SongCacheSizeMb:       512,
+			PrefetchSongs:         2,
+			PrefetchLeadS:         30,
+			PrefetchBufferMb:      100,
+			RadioQueueThreshold:   3,
+			SleepFadeOut:          true,
+			ShuffleMode:           ShuffleRandom,
+			RequestTimeoutS:       30,
+			MetadataCacheItems:    5000,
 		},
 		Gui: Gui{
 			PageSize:            100,
@@ -211,6 +245,15 @@ func TestSanitizeConfig(t *testing.T) {
 	invalidConf.Player.HttpBufferingS = 5
 	invalidConf.Player.HttpBufferingLimitMem = 20
 	invalidConf.Player.LocalCacheDir = path.Join(cachedir, AppNameLower)
+	invalidConf.Player.LibrarySyncMin = 60
+	invalidConf.Player.SongCacheSizeMb = 512
+	invalidConf.Player.PrefetchSongs = 2
+	invalidConf.Player.PrefetchLeadS = 30
+	invalidConf.Player.PrefetchBufferMb = 100
+	invalidConf.Player.RadioQueueThreshold = 3
+	invalidConf.Player.ShuffleMode = ShuffleRandom
+	invalidConf.Player.RequestTimeoutS = 30
+	invalidConf.Player.MetadataCacheItems = 5000
 
 	invalidConf.Gui.PageSize = 100
 	invalidConf.Gui.DoubleClickMs = 220
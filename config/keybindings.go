@@ -36,6 +36,12 @@ type GlobalBindings struct {
 	VolumeDown tcell.Key
 	MuteUnmute tcell.Key
 	Shuffle    tcell.Key
+	Radio      tcell.Key
+	// SleepTimer cycles through sleep timer presets
+	SleepTimer       tcell.Key
+	StopAfterCurrent tcell.Key
+	// SyncLibrary syncs local cache with server
+	SyncLibrary tcell.Key
 }
 
 // NavigationBarBindings also override every other key
@@ -77,16 +83,20 @@ type KeyBindings struct {
 func DefaultKeyBindings() KeyBindings {
 	k := KeyBindings{
 		Global: GlobalBindings{
-			PlayPause:  tcell.KeyF6,
-			Stop:       tcell.KeyF5,
-			Next:       tcell.KeyF7,
-			Previous:   tcell.KeyF4,
-			Forward:    0,
-			Backward:   0,
-			VolumeUp:   tcell.KeyF10,
-			VolumeDown: tcell.KeyF9,
-			MuteUnmute: tcell.KeyCtrlU,
-			Shuffle:    tcell.KeyCtrlD,
+			PlayPause:        tcell.KeyF6,
+			Stop:             tcell.KeyF5,
+			Next:             tcell.KeyF7,
+			Previous:         tcell.KeyF4,
+			Forward:          0,
+			Backward:         0,
+			VolumeUp:         tcell.KeyF10,
+			VolumeDown:       tcell.KeyF9,
+			MuteUnmute:       tcell.KeyCtrlU,
+			Shuffle:          tcell.KeyCtrlD,
+			Radio:            tcell.KeyCtrlR,
+			SleepTimer:       tcell.KeyCtrlT,
+			StopAfterCurrent: tcell.KeyCtrlE,
+			SyncLibrary:      tcell.KeyCtrlY,
 		},
 		NavigationBar: NavigationBarBindings{
 			Help:    tcell.KeyF1,
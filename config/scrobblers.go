@@ -0,0 +1,38 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package config
+
+// ListenBrainz configures submitting listens to ListenBrainz or a compatible server, e.g. Maloja.
+type ListenBrainz struct {
+	Enabled bool `yaml:"enabled"`
+	// Url is api root url. Empty url uses ListenBrainz.
+	Url   string `yaml:"url"`
+	Token string `yaml:"token"`
+}
+
+// LastFm configures scrobbling to Last.fm or a compatible server, e.g. Libre.fm.
+type LastFm struct {
+	Enabled bool `yaml:"enabled"`
+	// Url is api root url. Empty url uses Last.fm.
+	Url       string `yaml:"url"`
+	ApiKey    string `yaml:"api_key"`
+	ApiSecret string `yaml:"api_secret"`
+	Username  string `yaml:"username"`
+	// SessionKey is received after first login
+	SessionKey string `yaml:"session_key"`
+}
@@ -58,12 +58,13 @@ type ApiPlaybackState struct {
 	IsMuted  bool
 	// Total length of current playlist in seconds
 	PlaylistLength int
-	// Position in seconds
-	Position int
+	// Position in current song
+	Position AudioTick
 	// Volume in 0-100
 	Volume int
 
 	Shuffle bool
+	Repeat  RepeatMode
 
 	Queue []models.Id
 }
@@ -20,6 +20,7 @@
 package interfaces
 
 import (
+	"context"
 	"errors"
 	"math"
 	"time"
@@ -59,63 +60,193 @@ type QueueController interface {
 
 	// SetHistoryChangedCallback sets a function that gets called every time history items update
 	SetHistoryChangedCallback(func(songs []*models.Song))
+
+	// MoveSongs moves songs in indices to given index, keeping their order. First song cannot be moved.
+	// Returns true if queue changed.
+	MoveSongs(indices []int, index int) bool
+
+	// RemoveSongs removes songs in given indices. First song cannot be removed.
+	RemoveSongs(indices []int)
+
+	// SortQueue sorts upcoming songs by SortByArtist, SortByAlbum or SortByName.
+	SortQueue(field SortField)
+
+	// RemoveDuplicates removes songs that exist earlier in queue and returns number of removed songs.
+	RemoveDuplicates() int
+
+	// UndoQueueEdit reverts latest queue edit. Returns false if there is nothing to undo.
+	UndoQueueEdit() bool
 }
 
 //MediaManager manages media: artists, albums, songs
 type ItemController interface {
 	// Search returns list of items based on search query. Item types
 	// Queue and history returns error.
-	Search(itemType models.ItemType, query string) ([]models.Item, error)
+	Search(ctx context.Context, itemType models.ItemType, query string) ([]models.Item, error)
 	// GetArtists gets artist with given paging. Only PageSize and CurrentPage are used. Total count is returned
-	GetArtists(opts *QueryOpts) ([]*models.Artist, int, error)
+	GetArtists(ctx context.Context, opts *QueryOpts) ([]*models.Artist, int, error)
 
 	// GetAlbumArtists returns artists that are marked as album artists. See GetArtists.
-	GetAlbumArtists(paging Paging) ([]*models.Artist, int, error)
+	GetAlbumArtists(ctx context.Context, paging Paging) ([]*models.Artist, int, error)
 	// GetAlbums gets albums with given paging. Only PageSize and CurrentPage are used. Total count is returned
-	GetAlbums(opts *QueryOpts) ([]*models.Album, int, error)
+	GetAlbums(ctx context.Context, opts *QueryOpts) ([]*models.Album, int, error)
 
-	GetArtistAlbums(artist models.Id) ([]*models.Album, error)
+	GetArtistAlbums(ctx context.Context, artist models.Id) ([]*models.Album, error)
 
-	GetAlbumSongs(album models.Id) ([]*models.Song, error)
-	GetPlaylists() ([]*models.Playlist, error)
+	GetAlbumSongs(ctx context.Context, album models.Id) ([]*models.Song, error)
+	GetPlaylists(ctx context.Context) ([]*models.Playlist, error)
 	// GetPlaylistSongs fills songs array for playlist. If there's error, songs will not be filled
-	GetPlaylistSongs(playlist *models.Playlist) error
-	GetFavoriteArtists() ([]*models.Artist, error)
-	GetFavoriteAlbums(paging Paging) ([]*models.Album, int, error)
+	GetPlaylistSongs(ctx context.Context, playlist *models.Playlist) error
+	GetFavoriteArtists(ctx context.Context) ([]*models.Artist, error)
+	GetFavoriteAlbums(ctx context.Context, paging Paging) ([]*models.Album, int, error)
 
 	// GetSimilarArtists returns similar artists for artist id
-	GetSimilarArtists(artist models.Id) ([]*models.Artist, error)
+	GetSimilarArtists(ctx context.Context, artist models.Id) ([]*models.Artist, error)
 
-	GetSimilarAlbums(album models.Id) ([]*models.Album, error)
+	GetSimilarAlbums(ctx context.Context, album models.Id) ([]*models.Album, error)
 
-	GetLatestAlbums() ([]*models.Album, error)
+	GetLatestAlbums(ctx context.Context) ([]*models.Album, error)
 
-	GetRecentlyPlayed(paging Paging) ([]*models.Song, int, error)
+	GetRecentlyPlayed(ctx context.Context, paging Paging) ([]*models.Song, int, error)
 
 	// GetStatistics returns application statistics
 	GetStatistics() models.Stats
 
 	// GetSongs returns songs by paging. It also returns total number of songs.
-	GetSongs(page, pageSize int) ([]*models.Song, int, error)
+	GetSongs(ctx context.Context, page, pageSize int) ([]*models.Song, int, error)
 
 	// GetGenres returns music genres with paging. Return genres, total genres and possible error
-	GetGenres(paging Paging) ([]*models.IdName, int, error)
+	GetGenres(ctx context.Context, paging Paging) ([]*models.IdName, int, error)
 
 	// GetGenreAlbums returns all albums that belong to given genre
-	GetGenreAlbums(genre models.IdName) ([]*models.Album, error)
+	GetGenreAlbums(ctx context.Context, genre models.IdName) ([]*models.Album, error)
 
-	GetAlbumArtist(album *models.Album) (*models.Artist, error)
+	GetAlbumArtist(ctx context.Context, album *models.Album) (*models.Artist, error)
 
-	GetSongArtistAlbum(song *models.Song) (*models.Album, *models.Artist, error)
+	GetSongArtistAlbum(ctx context.Context, song *models.Song) (*models.Album, *models.Artist, error)
 
 	// GetInstantMix returns instant mix based on given item.
-	GetInstantMix(item models.Item) ([]*models.Song, error)
+	GetInstantMix(ctx context.Context, item models.Item) ([]*models.Song, error)
 
 	// GetLink returns a link to item that can be opened with browser.
 	// If there is no link or item is invalid, empty link is returned.
 	GetLink(item models.Item) string
 }
 
+// DownloadController manages songs that are downloaded to local disk for offline use.
+type DownloadController interface {
+	// DownloadItem downloads all songs of item in background. Item can be album, artist, playlist or song.
+	DownloadItem(item models.Item) error
+
+	// GetDownloads returns pending, ongoing and completed downloads.
+	GetDownloads() []*models.Download
+
+	// RemoveDownload removes downloaded song from local disk.
+	RemoveDownload(song models.Id) error
+
+	// AddDownloadsChangedCallback adds a function that is called every time downloads change.
+	AddDownloadsChangedCallback(func())
+}
+
+// OutboxController manages write operations that failed and are waiting to be sent to server.
+type OutboxController interface {
+	// GetOperations returns operations in the order they are going to be sent.
+	GetOperations() []*models.Operation
+
+	// DiscardOperation removes operation from outbox without sending it.
+	DiscardOperation(id int64) error
+
+	// SetFavorite marks item as favorite or removes it from favorites. If server is not reachable,
+	// change is sent once it is.
+	SetFavorite(item models.Item, favorite bool) error
+
+	// AddOutboxChangedCallback adds a function that is called every time outbox changes.
+	AddOutboxChangedCallback(func())
+}
+
+// LibrarySync syncs local cache with remote server in background.
+type LibrarySync interface {
+	// SyncNow starts syncing library. If sync is already running, another sync is started after it.
+	SyncNow()
+
+	// GetSyncStatus returns current state of sync.
+	GetSyncStatus() models.SyncStatus
+
+	// AddSyncChangedCallback adds a function that is called every time sync status changes.
+	AddSyncChangedCallback(func(status models.SyncStatus))
+
+	// AddLibraryChangedCallback adds a function that is called every time items in library change,
+	// either by sync or by changes that server reports.
+	AddLibraryChangedCallback(func())
+}
+
+// SessionController controls other sessions of the same user on remote server.
+type SessionController interface {
+	// GetSessions returns other sessions that can be controlled remotely.
+	GetSessions() ([]*models.Session, error)
+
+	// ControlSession sends playstate command to session.
+	ControlSession(session models.Id, command models.SessionCommand) error
+
+	// SeekSession seeks currently playing song in session to given position.
+	SeekSession(session models.Id, position AudioTick) error
+
+	// SetSessionVolume sets volume of session.
+	SetSessionVolume(session models.Id, volume AudioVolume) error
+
+	// PlayOnSession replaces queue in session with given items and starts playing them.
+	// Items can be songs, albums, artists or playlists.
+	PlayOnSession(session models.Id, items []models.Id) error
+}
+
+// SyncPlay allows listening together with other users in a group. While in group,
+// playback controls are sent to the group and player follows group queue and play state.
+type SyncPlay interface {
+	// GetSyncPlayGroups returns groups that user can join.
+	GetSyncPlayGroups() ([]*models.SyncPlayGroup, error)
+
+	// CreateSyncPlayGroup creates new group and joins it. If name is empty, default name is used.
+	CreateSyncPlayGroup(name string) error
+
+	// JoinSyncPlayGroup joins existing group.
+	JoinSyncPlayGroup(group models.Id) error
+
+	// LeaveSyncPlayGroup leaves current group.
+	LeaveSyncPlayGroup() error
+
+	// GetSyncPlayStatus returns current group, if any.
+	GetSyncPlayStatus() models.SyncPlayStatus
+
+	// AddSyncPlayChangedCallback adds a function that is called every time SyncPlay status changes.
+	AddSyncPlayChangedCallback(func(status models.SyncPlayStatus))
+}
+
+// ListeningHistory provides statistics from songs played with this application.
+type ListeningHistory interface {
+	// GetListeningStats returns statistics for songs played since given time. Zero time returns
+	// statistics for all songs.
+	GetListeningStats(since time.Time) (*models.ListeningStats, error)
+}
+
+// Connection tells whether remote server is reachable. When offline, only
+// locally cached items and songs are available.
+type Connection interface {
+	// IsOnline returns true if remote server is connected.
+	IsOnline() bool
+
+	// AddConnectionCallback adds a function that is called every time connection state changes.
+	AddConnectionCallback(func(online bool))
+
+	// GetRemoteControlStatus returns state of remote control connection to server.
+	GetRemoteControlStatus() models.RemoteControlStatus
+
+	// AddRemoteControlCallback adds a function that is called every time remote control connection state changes.
+	AddRemoteControlCallback(func(status models.RemoteControlStatus))
+
+	// AddMessageCallback adds a function that is called every time server sends a message to be displayed.
+	AddMessageCallback(func(msg models.ServerMessage))
+}
+
 // Paging. First page is 0
 type Paging struct {
 	TotalItems  int
@@ -218,6 +349,12 @@ type Filter struct {
 	Genres []models.IdName
 	// YearRange contains two elements, items must be within these boundaries.
 	YearRange [2]int
+	// ModifiedSince includes only items that were added or changed after given time.
+	// Zero time disables filter. Servers may ignore this filter for some item types.
+	ModifiedSince time.Time
+	// UserDataChangedSince includes only items whose favorite status or play count changed after given time.
+	// Zero time disables filter.
+	UserDataChangedSince time.Time
 }
 
 // YearRangeValid returns true if year range is considered valid and sane.
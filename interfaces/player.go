@@ -18,7 +18,11 @@
 
 package interfaces
 
-import "tryffel.net/go/jellycli/models"
+import (
+	"fmt"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
 
 // AudioState is audio player state, playing song, stopped
 type AudioState int
@@ -52,6 +56,22 @@ const (
 	AudioActionSetVolume
 
 	AudioActionShuffleChanged
+	// AudioActionRadioChanged toggles radio mode
+	AudioActionRadioChanged
+	// AudioActionRepeatChanged sets repeat mode
+	AudioActionRepeatChanged
+)
+
+// RepeatMode tells whether songs are played again once they are complete.
+type RepeatMode int
+
+const (
+	// RepeatNone, play queue once
+	RepeatNone RepeatMode = iota
+	// RepeatAll, add completed songs back to the end of queue
+	RepeatAll
+	// RepeatOne, play current song again
+	RepeatOne
 )
 
 // AudioTick is alias for millisecond
@@ -109,6 +129,14 @@ type AudioStatus struct {
 	Muted    bool
 	Paused   bool
 	Shuffle  bool
+	Repeat   RepeatMode
+	// Radio fills queue with similar songs when it runs out
+	Radio bool
+
+	// SleepTimer is currently active sleep timer
+	SleepTimer SleepTimer
+	// SleepRemaining is approximate time until sleep timer pauses playback
+	SleepRemaining time.Duration
 }
 
 func (a *AudioStatus) Clear() {
@@ -135,9 +163,10 @@ type Player interface {
 	Next()
 	//Previous plays last played song (first in history) if there is one.
 	Previous()
-	//Seek seeks forward given seconds
+	//Seek seeks forward given ticks. Negative ticks seek backwards.
 	Seek(ticks AudioTick)
-	//SeekBackwards seeks backwards given seconds
+	// SeekTo seeks to given position in current song.
+	SeekTo(position AudioTick)
 	//AddStatusCallback adds callback that get's called every time status has changed,
 	//including playback progress
 	AddStatusCallback(func(status AudioStatus))
@@ -149,6 +178,43 @@ type Player interface {
 	ToggleMute()
 
 	SetShuffle(enabled bool)
+
+	// SetRepeat sets repeat mode.
+	SetRepeat(mode RepeatMode)
+
+	// SetRadio enables or disables radio mode. When enabled, queue is filled with similar songs
+	// before it runs out.
+	SetRadio(enabled bool)
+
+	// SetSleepTimer sets or cancels sleep timer, that pauses playback.
+	SetSleepTimer(timer SleepTimer)
+
+	// SetStopAfterCurrent pauses playback once current song has ended. This is same as
+	// sleep timer with SleepEndOfSong.
+	SetStopAfterCurrent(enabled bool)
+}
+
+// SleepMode is the condition that ends playback.
+type SleepMode int
+
+const (
+	// SleepOff, no sleep timer
+	SleepOff SleepMode = iota
+	// SleepAfterDuration, pause after given duration
+	SleepAfterDuration
+	// SleepEndOfSong, pause after current song
+	SleepEndOfSong
+	// SleepEndOfAlbum, pause once current album has been played
+	SleepEndOfAlbum
+)
+
+// SleepTimer pauses playback once it expires.
+type SleepTimer struct {
+	Mode SleepMode
+	// Duration for SleepAfterDuration
+	Duration time.Duration
+	// FadeOut fades volume out before pausing when using SleepAfterDuration
+	FadeOut bool
 }
 
 // Queuer contains read-only methods for song queue.
@@ -156,3 +222,55 @@ type Queuer interface {
 	GetQueue() []*models.Song
 	GetTotalDuration() AudioTick
 }
+
+// BufferState tells whether upcoming song has been downloaded beforehand.
+type BufferState int
+
+const (
+	// BufferNone, song is not being prefetched
+	BufferNone BufferState = iota
+	// BufferLoading, song is being downloaded
+	BufferLoading
+	// BufferReady, song is completely downloaded
+	BufferReady
+	// BufferLocal, song is available on local disk
+	BufferLocal
+	// BufferFailed, prefetching song failed
+	BufferFailed
+)
+
+// SongBuffer is buffering status of single song in queue.
+type SongBuffer struct {
+	State BufferState
+	// Buffered is number of bytes downloaded
+	Buffered int64
+	// Size is total size of song in bytes, or -1 if not known.
+	Size int64
+}
+
+func (s SongBuffer) String() string {
+	switch s.State {
+	case BufferLoading:
+		if s.Size > 0 {
+			return fmt.Sprintf("buffering %d%%", s.Buffered*100/s.Size)
+		}
+		return fmt.Sprintf("buffering %.1f MiB", float64(s.Buffered)/1024/1024)
+	case BufferReady:
+		return "buffered"
+	case BufferLocal:
+		return "local"
+	case BufferFailed:
+		return "buffering failed"
+	default:
+		return ""
+	}
+}
+
+// BufferController prefetches upcoming songs in queue.
+type BufferController interface {
+	// GetSongBuffers returns buffering status for songs that are being prefetched.
+	GetSongBuffers() map[models.Id]SongBuffer
+
+	// AddBufferChangedCallback adds a function that is called every time buffering status changes.
+	AddBufferChangedCallback(func())
+}
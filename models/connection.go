@@ -0,0 +1,52 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package models
+
+import "time"
+
+// RemoteControlState is state of remote control connection to server.
+type RemoteControlState int
+
+const (
+	// RemoteControlDisconnected means there is no connection and no reconnection is scheduled.
+	RemoteControlDisconnected RemoteControlState = iota
+	// RemoteControlConnected means server is able to send commands and events.
+	RemoteControlConnected
+	// RemoteControlReconnecting means connection was lost and reconnection is scheduled.
+	RemoteControlReconnecting
+)
+
+// RemoteControlStatus describes remote control connection to server.
+type RemoteControlStatus struct {
+	State RemoteControlState
+	// Attempt is number of failed reconnection attempts.
+	Attempt int
+	// RetryAt is the time of next reconnection attempt.
+	RetryAt time.Time
+	// Error from latest connection attempt, if any.
+	Error string
+}
+
+// ServerMessage is a message that server wants to show to user.
+type ServerMessage struct {
+	Header string
+	Text   string
+	// Timeout after which message is closed, 0 means message stays until closed by user.
+	Timeout time.Duration
+}
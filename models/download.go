@@ -0,0 +1,68 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package models
+
+import "time"
+
+// DownloadState describes state of a song download.
+type DownloadState string
+
+const (
+	DownloadPending     DownloadState = "pending"
+	DownloadDownloading DownloadState = "downloading"
+	DownloadComplete    DownloadState = "complete"
+	DownloadFailed      DownloadState = "failed"
+)
+
+// Download is a song that is stored on local disk for offline use.
+type Download struct {
+	Song *Song
+	// AlbumName is name of the album song belongs to, if known.
+	AlbumName string
+	// File is local file name
+	File string
+	// Size is file size in bytes. For ongoing download, this is the expected size or 0 if unknown.
+	Size int64
+	// Downloaded is number of bytes downloaded so far.
+	Downloaded int64
+	State      DownloadState
+	// Checksum is sha256 checksum of the file.
+	Checksum   string
+	PlayCount  int
+	LastPlayed time.Time
+}
+
+// Progress returns download progress in percents.
+func (d *Download) Progress() int {
+	if d.State == DownloadComplete {
+		return 100
+	}
+	if d.Size <= 0 {
+		return 0
+	}
+	return int(d.Downloaded * 100 / d.Size)
+}
+
+// SizeString returns (downloaded) size in human-readable format.
+func (d *Download) SizeString() string {
+	if d.State == DownloadComplete {
+		return ByteSize(d.Size)
+	}
+	return ByteSize(d.Downloaded)
+}
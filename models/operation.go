@@ -0,0 +1,108 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// OperationType describes write operation to remote server.
+type OperationType string
+
+const (
+	// OperationScrobble marks song as played.
+	OperationScrobble OperationType = "scrobble"
+	// OperationPlaybackStopped reports that playing song was stopped.
+	OperationPlaybackStopped OperationType = "playback_stopped"
+	// OperationListenBrainzScrobble submits listen to ListenBrainz.
+	OperationListenBrainzScrobble OperationType = "listenbrainz_scrobble"
+	// OperationLastFmScrobble submits scrobble to Last.fm.
+	OperationLastFmScrobble OperationType = "lastfm_scrobble"
+	// OperationFavorite marks item as favorite. Data contains item type.
+	OperationFavorite OperationType = "favorite"
+	// OperationUnfavorite removes item from favorites. Data contains item type.
+	OperationUnfavorite OperationType = "unfavorite"
+)
+
+// Operation is a write operation to remote server that has failed
+// and is stored in outbox to be sent again later.
+type Operation struct {
+	Id   int64
+	Type OperationType
+	// Key identifies duplicate operations. Only one operation with same key is stored.
+	Key  string
+	Item Id
+	// Data contains optional payload as json.
+	Data string
+
+	// PlayedAt is the time song was started, or the time operation was made
+	PlayedAt time.Time
+	// Attempts is number of failed attempts to send operation.
+	Attempts int
+	// Error is latest error.
+	Error string
+}
+
+// NewOperation creates new operation for item that was played at given time.
+func NewOperation(opType OperationType, item Id, playedAt time.Time, data string) *Operation {
+	return &Operation{
+		Type:     opType,
+		Key:      fmt.Sprintf("%s:%s:%d", opType, item, playedAt.Truncate(time.Minute).Unix()),
+		Item:     item,
+		Data:     data,
+		PlayedAt: playedAt,
+	}
+}
+
+// NewFavoriteOperation creates new operation that marks item as favorite or removes it from favorites.
+// Every favorite change is a separate operation, so that they are sent in the order they were made.
+func NewFavoriteOperation(item Id, itemType ItemType, favorite bool, at time.Time) *Operation {
+	opType := OperationUnfavorite
+	if favorite {
+		opType = OperationFavorite
+	}
+	return &Operation{
+		Type:     opType,
+		Key:      fmt.Sprintf("%s:%s:%d", opType, item, at.UnixNano()),
+		Item:     item,
+		Data:     string(itemType),
+		PlayedAt: at,
+	}
+}
+
+// Label returns human-readable description of operation type.
+func (o OperationType) Label() string {
+	switch o {
+	case OperationScrobble:
+		return "Scrobble"
+	case OperationPlaybackStopped:
+		return "Playback stopped"
+	case OperationListenBrainzScrobble:
+		return "ListenBrainz scrobble"
+	case OperationLastFmScrobble:
+		return "Last.fm scrobble"
+	case OperationFavorite:
+		return "Favorite"
+	case OperationUnfavorite:
+		return "Remove favorite"
+	default:
+		return string(o)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package models
+
+import (
+	"time"
+)
+
+// Play is a single play of a song recorded to local listening history.
+type Play struct {
+	Id         int64
+	Song       Id
+	SongName   string
+	Album      Id
+	AlbumName  string
+	Artist     Id
+	ArtistName string
+	// Duration is song duration in seconds
+	Duration int
+
+	// Started is the time song was started.
+	Started time.Time
+	// Played is time song was actually played, excluding pauses.
+	Played time.Duration
+	// Completed is true if song was played to the end, else it was skipped.
+	Completed bool
+}
+
+// Listened returns true if play is long enough to be counted as a listen.
+// Song must be played for half of its duration or for 4 minutes.
+func (p *Play) Listened() bool {
+	if p.Played >= 4*time.Minute {
+		return true
+	}
+	return p.Duration > 0 && p.Played >= time.Duration(p.Duration)*time.Second/2
+}
+
+// ListeningPeriod is a time period for listening statistics.
+type ListeningPeriod string
+
+const (
+	ListeningPeriodWeek  ListeningPeriod = "week"
+	ListeningPeriodMonth ListeningPeriod = "month"
+	ListeningPeriodYear  ListeningPeriod = "year"
+	ListeningPeriodAll   ListeningPeriod = "all"
+)
+
+// ListeningPeriods are all supported listening periods.
+var ListeningPeriods = []ListeningPeriod{
+	ListeningPeriodWeek, ListeningPeriodMonth, ListeningPeriodYear, ListeningPeriodAll}
+
+// Since returns the start of period counting back from now. ListeningPeriodAll and
+// unknown periods return zero time.
+func (l ListeningPeriod) Since(now time.Time) time.Time {
+	switch l {
+	case ListeningPeriodWeek:
+		return now.AddDate(0, 0, -7)
+	case ListeningPeriodMonth:
+		return now.AddDate(0, -1, 0)
+	case ListeningPeriodYear:
+		return now.AddDate(-1, 0, 0)
+	default:
+		return time.Time{}
+	}
+}
+
+// Label returns human-readable period.
+func (l ListeningPeriod) Label() string {
+	switch l {
+	case ListeningPeriodWeek:
+		return "Last 7 days"
+	case ListeningPeriodMonth:
+		return "Last month"
+	case ListeningPeriodYear:
+		return "Last year"
+	case ListeningPeriodAll:
+		return "All time"
+	default:
+		return string(l)
+	}
+}
+
+// PlayCount is number of plays for single item.
+type PlayCount struct {
+	Id     Id
+	Name   string
+	Plays  int
+	Played time.Duration
+}
+
+// ListeningStats are statistics calculated from local listening history.
+type ListeningStats struct {
+	Since time.Time
+	Plays int
+	Skips int
+	// ListeningTime is total time songs were played
+	ListeningTime time.Duration
+
+	TopArtists []PlayCount
+	TopAlbums  []PlayCount
+	TopSongs   []PlayCount
+}
+
+// SkipRate returns ratio of skipped songs to all plays, in range 0-1.
+func (l *ListeningStats) SkipRate() float64 {
+	if l.Plays == 0 {
+		return 0
+	}
+	return float64(l.Skips) / float64(l.Plays)
+}
@@ -0,0 +1,91 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ItemChanges tells how many items were changed in local cache.
+type ItemChanges struct {
+	Added   int
+	Updated int
+	Removed int
+}
+
+func (c ItemChanges) String() string {
+	return fmt.Sprintf("%d added, %d updated, %d removed", c.Added, c.Updated, c.Removed)
+}
+
+// RefreshSummary describes changes made to local cache in a refresh.
+type RefreshSummary struct {
+	// Full is true if all items were pulled from server and removed items were detected.
+	Full bool
+	// Since is the time of previous refresh. Only items changed after it were pulled, unless Full is true.
+	Since time.Time
+	Took  time.Duration
+
+	Artists   ItemChanges
+	Albums    ItemChanges
+	Songs     ItemChanges
+	Playlists ItemChanges
+}
+
+// Changed returns true if any items were added, updated or removed.
+func (s RefreshSummary) Changed() bool {
+	for _, v := range []ItemChanges{s.Artists, s.Albums, s.Songs, s.Playlists} {
+		if v.Added > 0 || v.Updated > 0 || v.Removed > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncStatus describes state of background library sync.
+type SyncStatus struct {
+	// Running is true while sync is in progress.
+	Running bool
+	// Stage is the kind of items currently being synced.
+	Stage string
+	// LastSync is the time previous sync completed.
+	LastSync time.Time
+	// Summary of previous sync, nil if sync has not completed.
+	Summary *RefreshSummary
+	// Error from previous sync, if any.
+	Error string
+}
+
+// UserData is user specific data of an item.
+type UserData struct {
+	Item      Id
+	Favorite  bool
+	PlayCount int
+	Played    bool
+}
+
+// LibraryChanges describes changes in library that server reported.
+type LibraryChanges struct {
+	Added   []Id
+	Updated []Id
+	Removed []Id
+	// UserData contains changed favorites and play counts.
+	UserData []UserData
+	// ScanCompleted is set when server has completed scanning library, and any items might have changed.
+	ScanCompleted bool
+}
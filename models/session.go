@@ -0,0 +1,57 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package models
+
+// Session is another client connected to remote server, that can be controlled remotely.
+type Session struct {
+	Id         Id
+	UserName   string
+	Client     string
+	DeviceName string
+
+	// NowPlaying is the song session is playing, or nil if it is not playing anything.
+	NowPlaying *Song
+	// Album is name of the album that is playing.
+	Album string
+	// Position in seconds
+	Position int
+	Paused   bool
+	Muted    bool
+	// Volume in range 0-100
+	Volume  int
+	CanSeek bool
+}
+
+// Name returns human readable name for session.
+func (s *Session) Name() string {
+	if s.DeviceName == "" {
+		return s.Client
+	}
+	return s.DeviceName + " (" + s.Client + ")"
+}
+
+// SessionCommand is a playstate command that is sent to another session.
+type SessionCommand int
+
+const (
+	SessionPlayPause SessionCommand = iota
+	SessionNext
+	SessionPrevious
+	SessionStop
+)
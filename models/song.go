@@ -35,6 +35,11 @@ type Song struct {
 	AlbumArtist Id `db:"artist"`
 
 	Favorite bool `db:"favorite"`
+
+	// PlayCount is number of times user has played song, if known.
+	PlayCount int `db:"play_count"`
+	// Rating is user rating in range 1-5, 0 if not rated.
+	Rating int `db:"-"`
 }
 
 func (s *Song) GetId() Id {
@@ -38,6 +38,12 @@ type Stats struct {
 	ServerInfo *ServerInfo
 
 	StorageInfo StorageInfo
+
+	// Requests contains metrics of requests to remote server
+	Requests RequestStats
+
+	// Cache contains metrics of remote server metadata cache
+	Cache CacheStats
 }
 
 // HeapString returns heap usage in human-readable format
@@ -66,6 +72,60 @@ type ServerInfo struct {
 	Misc map[string]string
 }
 
+// RequestStats contains metrics of http requests to remote server.
+type RequestStats struct {
+	// Requests is number of completed requests.
+	Requests int
+	// Failed is number of requests that failed or returned error status.
+	Failed int
+	// Coalesced is number of requests that were served by another identical request.
+	Coalesced int
+	// InFlight is number of running requests.
+	InFlight int
+	// Waiting is number of requests waiting for a free connection.
+	Waiting int
+	// MaxConcurrent is maximum number of concurrent requests.
+	MaxConcurrent int
+	// TotalTime is total duration of completed requests.
+	TotalTime time.Duration
+}
+
+// AverageTime returns average duration of completed requests.
+func (r RequestStats) AverageTime() time.Duration {
+	if r.Requests == 0 {
+		return 0
+	}
+	return r.TotalTime / time.Duration(r.Requests)
+}
+
+// CacheStats contains metrics of metadata cache.
+type CacheStats struct {
+	// Items is number of items in cache.
+	Items int
+	// MaxItems is maximum number of items in cache.
+	MaxItems int
+	// Hits is number of items found from cache, including stale ones.
+	Hits int
+	// StaleHits is number of expired items that were returned and then revalidated.
+	StaleHits int
+	// Misses is number of items not found from cache.
+	Misses int
+	// Evictions is number of least recently used items that were removed to make space.
+	Evictions int
+	// Revalidated is number of stale items that server reported unmodified.
+	Revalidated int
+	// Invalidated is number of stale items that were modified and removed from cache.
+	Invalidated int
+}
+
+// HitRate returns percentage of cache hits.
+func (c CacheStats) HitRate() float64 {
+	if c.Hits+c.Misses == 0 {
+		return 0
+	}
+	return float64(c.Hits) / float64(c.Hits+c.Misses) * 100
+}
+
 type StorageInfo struct {
 	DbSize      int
 	DbFile      string
@@ -84,6 +144,11 @@ func (s StorageInfo) LastUpdatedString() string {
 
 }
 
+// ByteSize returns size in human-readable format.
+func ByteSize(bytes int64) string {
+	return byteToString(int(bytes))
+}
+
 func byteToString(bytes int) string {
 	f := float32(bytes)
 	if bytes < 1024 {
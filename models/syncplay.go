@@ -0,0 +1,145 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package models
+
+import "time"
+
+// SyncPlayGroup is a group of users listening to same queue together.
+type SyncPlayGroup struct {
+	Id           Id
+	Name         string
+	State        string
+	Participants []string
+}
+
+// SyncPlayUpdateType is type of group update.
+type SyncPlayUpdateType int
+
+const (
+	// SyncPlayGroupJoined, user joined or created group
+	SyncPlayGroupJoined SyncPlayUpdateType = iota
+	// SyncPlayGroupLeft, user left group or is not in group
+	SyncPlayGroupLeft
+	// SyncPlayUserJoined, another user joined group
+	SyncPlayUserJoined
+	// SyncPlayUserLeft, another user left group
+	SyncPlayUserLeft
+	// SyncPlayStateChanged, group state changed, e.g. to waiting or playing
+	SyncPlayStateChanged
+	// SyncPlayError, request was denied or group does not exist
+	SyncPlayError
+)
+
+// SyncPlayGroupUpdate tells about changes in group.
+type SyncPlayGroupUpdate struct {
+	Type  SyncPlayUpdateType
+	Group *SyncPlayGroup
+	// User that joined or left group
+	User string
+	// State of the group
+	State string
+	// Message describes error
+	Message string
+}
+
+// SyncPlayItem is a song in group queue. Same song can be in queue multiple times,
+// so PlaylistItem identifies item in queue.
+type SyncPlayItem struct {
+	Song         *Song
+	PlaylistItem Id
+}
+
+// SyncPlayQueue is current queue of the group.
+type SyncPlayQueue struct {
+	// Reason is the change that caused update, e.g. NewPlaylist or NextItem.
+	Reason       string
+	Items        []SyncPlayItem
+	PlayingIndex int
+	// StartPosition is position to start playing current item from.
+	StartPosition time.Duration
+	IsPlaying     bool
+}
+
+// Playing returns currently playing item, or nil if there is none.
+func (q *SyncPlayQueue) Playing() *SyncPlayItem {
+	if q == nil || q.PlayingIndex < 0 || q.PlayingIndex >= len(q.Items) {
+		return nil
+	}
+	return &q.Items[q.PlayingIndex]
+}
+
+// SyncPlayCommandType is a command that all group members execute at the same time.
+type SyncPlayCommandType int
+
+const (
+	SyncPlayUnpause SyncPlayCommandType = iota
+	SyncPlayPause
+	SyncPlaySeek
+	SyncPlayStop
+)
+
+// SyncPlayCommand is a command to execute at given server time.
+type SyncPlayCommand struct {
+	Command SyncPlayCommandType
+	// When is the server time to execute command at.
+	When         time.Time
+	Position     time.Duration
+	PlaylistItem Id
+}
+
+// SyncPlayRequestType is a request that user sends to group.
+type SyncPlayRequestType int
+
+const (
+	SyncPlayRequestPause SyncPlayRequestType = iota
+	SyncPlayRequestUnpause
+	SyncPlayRequestSeek
+	SyncPlayRequestNext
+	SyncPlayRequestPrevious
+	// SyncPlayRequestBuffering tells group that client is loading current item
+	SyncPlayRequestBuffering
+	// SyncPlayRequestReady tells group that client is ready to play current item
+	SyncPlayRequestReady
+	// SyncPlayRequestSetQueue replaces group queue with Items
+	SyncPlayRequestSetQueue
+	// SyncPlayRequestPing reports round trip time to server
+	SyncPlayRequestPing
+)
+
+// SyncPlayRequest is a request to group. Fields that are used depend on request type.
+type SyncPlayRequest struct {
+	Type SyncPlayRequestType
+	// When is the server time request was made at.
+	When         time.Time
+	Position     time.Duration
+	IsPlaying    bool
+	PlaylistItem Id
+	Items        []Id
+	Ping         time.Duration
+}
+
+// SyncPlayStatus is current state of SyncPlay.
+type SyncPlayStatus struct {
+	// Group is the group user is in, or nil.
+	Group *SyncPlayGroup
+	// TimeOffset is difference between server clock and local clock.
+	TimeOffset time.Duration
+	// Error is latest error, if any.
+	Error string
+}
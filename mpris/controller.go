@@ -19,6 +19,7 @@
 package mpris
 
 import (
+	"errors"
 	"fmt"
 	"github.com/godbus/dbus"
 	"github.com/godbus/dbus/introspect"
@@ -34,6 +35,8 @@ const (
 	baseObject = "org.mpris.MediaPlayer2"
 )
 
+var errInvalidMinutes = errors.New("minutes must be positive")
+
 func objectName(name string) string {
 	return baseObject + "." + name
 }
@@ -72,7 +75,10 @@ func NewController(controller interfaces.Player) (c *MediaController, err error)
 	player := &Player{MediaController: c}
 	c.dbus.Export(player, basePath, objectName("Player"))
 
-	c.dbus.Export(introspect.NewIntrospectable(c.IntrospectNode()), basePath,
+	jellycli := &Jellycli{controller: controller}
+	c.dbus.Export(jellycli, basePath, jellycliObject)
+
+	c.dbus.Export(introspect.NewIntrospectable(c.IntrospectNode(jellycli)), basePath,
 		"org.freedesktop.DBus.Introspectable")
 
 	c.props = prop.New(c.dbus, basePath, map[string]map[string]*prop.Prop{
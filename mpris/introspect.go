@@ -23,7 +23,7 @@ import (
 )
 
 // IntrospectNode returns the root node of the library's introspection output.
-func (m *MediaController) IntrospectNode() *introspect.Node {
+func (m *MediaController) IntrospectNode(jellycli *Jellycli) *introspect.Node {
 	return &introspect.Node{
 		Name: m.Name(),
 		Interfaces: []introspect.Interface{
@@ -202,6 +202,7 @@ func (m *MediaController) IntrospectNode() *introspect.Node {
 					},
 				},
 			},
+			jellycli.introspectInterface(),
 			// TODO: This interface is not fully implemented.
 			// introspect.Interface{
 			// 	Name: "org.mpris.MediaPlayer2.TrackList",
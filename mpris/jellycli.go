@@ -0,0 +1,92 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package mpris
+
+import (
+	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/introspect"
+	"time"
+	"tryffel.net/go/jellycli/interfaces"
+)
+
+// jellycliObject is DBus interface for jellycli-specific controls that are not part of Mpris.
+const jellycliObject = "net.tryffel.Jellycli"
+
+// Jellycli implements jellycli-specific DBus interface.
+type Jellycli struct {
+	controller interfaces.Player
+}
+
+// SleepAfter pauses playback after given minutes. If fadeOut, volume is faded out before pausing.
+func (j *Jellycli) SleepAfter(minutes int32, fadeOut bool) *dbus.Error {
+	if minutes <= 0 {
+		return dbus.MakeFailedError(errInvalidMinutes)
+	}
+	j.controller.SetSleepTimer(interfaces.SleepTimer{
+		Mode:     interfaces.SleepAfterDuration,
+		Duration: time.Duration(minutes) * time.Minute,
+		FadeOut:  fadeOut,
+	})
+	return nil
+}
+
+// SleepAfterAlbum pauses playback once current album has been played.
+func (j *Jellycli) SleepAfterAlbum() *dbus.Error {
+	j.controller.SetSleepTimer(interfaces.SleepTimer{Mode: interfaces.SleepEndOfAlbum})
+	return nil
+}
+
+// StopAfterCurrent pauses playback once current song has ended.
+func (j *Jellycli) StopAfterCurrent(enabled bool) *dbus.Error {
+	j.controller.SetStopAfterCurrent(enabled)
+	return nil
+}
+
+// CancelSleep cancels sleep timer.
+func (j *Jellycli) CancelSleep() *dbus.Error {
+	j.controller.SetSleepTimer(interfaces.SleepTimer{})
+	return nil
+}
+
+func (j *Jellycli) introspectInterface() introspect.Interface {
+	return introspect.Interface{
+		Name: jellycliObject,
+		Methods: []introspect.Method{
+			{
+				Name: "SleepAfter",
+				Args: []introspect.Arg{
+					{Name: "Minutes", Type: "i", Direction: "in"},
+					{Name: "FadeOut", Type: "b", Direction: "in"},
+				},
+			},
+			{
+				Name: "SleepAfterAlbum",
+			},
+			{
+				Name: "StopAfterCurrent",
+				Args: []introspect.Arg{
+					{Name: "Enabled", Type: "b", Direction: "in"},
+				},
+			},
+			{
+				Name: "CancelSleep",
+			},
+		},
+	}
+}
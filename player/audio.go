@@ -29,6 +29,7 @@ import (
 	"github.com/faiface/beep/wav"
 	"github.com/sirupsen/logrus"
 	"io"
+	"math"
 	"time"
 	"tryffel.net/go/jellycli/config"
 	"tryffel.net/go/jellycli/interfaces"
@@ -78,6 +79,7 @@ func newAudio() *Audio {
 	a.volume.Streamer = a.ctrl
 	a.volume.Silent = false
 	a.status.Volume = 50
+	a.status.Radio = config.AppConfig != nil && config.AppConfig.Player.EnableRadio
 
 	a.currentSampleRate = config.AudioSamplingRate
 	return a
@@ -106,6 +108,20 @@ func (a *Audio) SetShuffle(shuffle bool) {
 	go a.flushStatus()
 }
 
+func (a *Audio) SetRadio(radio bool) {
+	if radio {
+		logrus.Info("Enable radio")
+	} else {
+		logrus.Info("Disable radio")
+	}
+
+	speaker.Lock()
+	defer speaker.Unlock()
+	a.status.Radio = radio
+	a.status.Action = interfaces.AudioActionRadioChanged
+	go a.flushStatus()
+}
+
 func (a *Audio) getStatus() interfaces.AudioStatus {
 	speaker.Lock()
 	defer speaker.Unlock()
@@ -199,6 +215,49 @@ func (a *Audio) Previous() {
 
 // Seek seeks given ticks. If there is no audio, do nothing.
 func (a *Audio) Seek(ticks interfaces.AudioTick) {
+	a.SeekTo(a.getPastTicks() + ticks)
+}
+
+// SeekTo seeks to given position in current song. Position is limited to song length.
+// If there is no audio, do nothing.
+func (a *Audio) SeekTo(position interfaces.AudioTick) {
+	if position < 0 {
+		position = 0
+	}
+	speaker.Lock()
+	if a.streamer == nil {
+		speaker.Unlock()
+		return
+	}
+	rate := a.sampleRate()
+	sample := int(int64(position) * int64(rate) / 1000)
+	if length := a.streamer.Len(); sample >= length {
+		sample = length - 1
+	}
+	if sample < 0 {
+		sample = 0
+	}
+	logrus.Debugf("Seek to %d ms", position)
+	err := a.streamer.Seek(sample)
+	if err != nil {
+		speaker.Unlock()
+		logrus.Errorf("seek: %v", err)
+		return
+	}
+	a.status.SongPast = interfaces.AudioTick(int64(sample) * 1000 / int64(rate))
+	a.status.Action = interfaces.AudioActionSeek
+	speaker.Unlock()
+	go a.flushStatus()
+}
+
+// SetRepeat sets repeat mode.
+func (a *Audio) SetRepeat(mode interfaces.RepeatMode) {
+	logrus.Infof("Set repeat mode %d", mode)
+	speaker.Lock()
+	a.status.Repeat = mode
+	a.status.Action = interfaces.AudioActionRepeatChanged
+	speaker.Unlock()
+	go a.flushStatus()
 }
 
 // AddStatusCallback adds a callback that gets called every time audio status is changed, or after certain time.
@@ -231,6 +290,28 @@ func (a *Audio) SetVolume(volume interfaces.AudioVolume) {
 	go a.flushStatus()
 }
 
+// setFade scales volume with level in range [0,1] without changing volume in status.
+func (a *Audio) setFade(level float64) {
+	speaker.Lock()
+	defer speaker.Unlock()
+	decibels := float64(volumeTodB(int(float64(a.status.Volume) * level)))
+	if decibels <= config.AudioMinVolumedB {
+		a.volume.Silent = true
+		a.volume.Volume = config.AudioMinVolumedB
+	} else {
+		a.volume.Silent = a.status.Muted
+		a.volume.Volume = math.Min(decibels, config.AudioMaxVolumedB)
+	}
+}
+
+// setSleepStatus updates sleep timer in status.
+func (a *Audio) setSleepStatus(timer interfaces.SleepTimer, remaining time.Duration) {
+	speaker.Lock()
+	defer speaker.Unlock()
+	a.status.SleepTimer = timer
+	a.status.SleepRemaining = remaining
+}
+
 // SetMute mutes and un-mutes audio
 func (a *Audio) SetMute(muted bool) {
 
@@ -402,6 +483,13 @@ func (a *Audio) getPastTicks() interfaces.AudioTick {
 	if a.streamer == nil {
 		return 0
 	}
-	left := a.streamer.Position() / config.AudioSamplingRate
-	return interfaces.AudioTick((time.Second * time.Duration(left)).Milliseconds())
+	return interfaces.AudioTick(int64(a.streamer.Position()) * 1000 / int64(a.sampleRate()))
+}
+
+// sample rate of current song. Caller must hold speaker lock.
+func (a *Audio) sampleRate() int {
+	if a.currentSampleRate > 0 {
+		return a.currentSampleRate
+	}
+	return config.AudioSamplingRate
 }
@@ -19,6 +19,7 @@
 package player
 
 import (
+	"context"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"time"
@@ -27,109 +28,305 @@ import (
 	"tryffel.net/go/jellycli/models"
 )
 
-// UpdateLocalArtists pulls latest info on artists from server and stores/updates on local database.
-func (i *Items) UpdateLocalArtists(limit int) error {
+const (
+	// refreshOverlap is subtracted from previous refresh time to tolerate clock differences with server.
+	refreshOverlap = time.Hour
+	// reconcileInterval is max interval for full refresh, which detects removed items.
+	reconcileInterval = time.Hour * 24 * 7
+)
+
+// RefreshLocalCache pulls items that were added or changed since previous refresh from server
+// and stores them on local database. If full is set, or previous full refresh is older than reconcileInterval,
+// all items are pulled and items that no longer exist on server are removed.
+func (i *Items) RefreshLocalCache(full bool) (*models.RefreshSummary, error) {
+	return i.refreshLocalCache(context.Background(), full, func(string) {})
+}
+
+// refreshLocalCache refreshes local cache and calls progress with the kind of items that are being refreshed.
+// Refresh stops before next kind of items if ctx is cancelled.
+func (i *Items) refreshLocalCache(ctx context.Context, full bool, progress func(stage string)) (*models.RefreshSummary, error) {
+	stage := func(name string) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("refresh cancelled: %v", err)
+		}
+		progress(name)
+		return nil
+	}
+	start := time.Now()
+	refreshed, reconciled, err := i.db.LastRefresh()
+	if err != nil {
+		return nil, fmt.Errorf("get last refresh: %v", err)
+	}
+
+	summary := &models.RefreshSummary{
+		Full:  full || refreshed.IsZero() || start.Sub(reconciled) > reconcileInterval,
+		Since: refreshed,
+	}
+	since := time.Time{}
+	if !summary.Full {
+		since = refreshed.Add(-refreshOverlap)
+		logrus.Infof("Refresh items changed since %s", refreshed.Format(time.RFC3339))
+	} else {
+		logrus.Info("Refresh all items")
+	}
+
+	before, err := i.countItems()
+	if err != nil {
+		return nil, err
+	}
+	removed := map[models.ItemType]int{}
+	retrieved := map[models.ItemType]int{}
+
+	if err = stage("artists"); err != nil {
+		return nil, err
+	}
+	artists, artistsComplete, err := i.UpdateLocalArtists(since)
+	if err != nil {
+		return nil, err
+	}
+	retrieved[models.TypeArtist] = len(artists)
+
+	if err = stage("albums"); err != nil {
+		return nil, err
+	}
+	albums, albumsComplete, err := i.UpdateLocalAlbums(since)
+	if err != nil {
+		return nil, err
+	}
+	retrieved[models.TypeAlbum] = len(albums)
+
+	// items that were not pulled would be considered removed
+	if summary.Full && !albumsComplete {
+		logrus.Warning("Not all albums were pulled, skip removing albums and songs")
+	}
+	if summary.Full && albumsComplete {
+		// remove albums first, so that songs of removed albums are not pulled
+		removed[models.TypeAlbum], err = i.db.RemoveMissing(models.TypeAlbum, albums)
+		if err != nil {
+			return nil, fmt.Errorf("remove albums: %v", err)
+		}
+	}
+
+	if err = stage("songs"); err != nil {
+		return nil, err
+	}
+	songs, songsComplete, err := i.UpdateLocalSongs(since, albums)
+	if err != nil {
+		return nil, err
+	}
+	retrieved[models.TypeSong] = len(songs)
+
+	if !summary.Full {
+		if err = stage("user data"); err != nil {
+			return nil, err
+		}
+		err = i.UpdateLocalUserData(since)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = stage("playlists"); err != nil {
+		return nil, err
+	}
+	playlists, err := i.UpdatePlaylists()
+	if err != nil {
+		return nil, err
+	}
+	retrieved[models.TypePlaylist] = len(playlists)
+
+	// playlists are always pulled completely
+	removed[models.TypePlaylist], err = i.db.RemoveMissing(models.TypePlaylist, playlists)
+	if err != nil {
+		return nil, fmt.Errorf("remove playlists: %v", err)
+	}
+
+	if summary.Full && !artistsComplete {
+		logrus.Warning("Not all artists were pulled, skip removing artists")
+	}
+	if summary.Full && artistsComplete {
+		removed[models.TypeArtist], err = i.db.RemoveMissing(models.TypeArtist, artists)
+		if err != nil {
+			return nil, fmt.Errorf("remove artists: %v", err)
+		}
+	}
+	if summary.Full && albumsComplete && !songsComplete {
+		logrus.Warning("Not all songs were pulled, skip removing songs")
+	}
+	if summary.Full && albumsComplete && songsComplete {
+		removed[models.TypeSong], err = i.db.RemoveMissing(models.TypeSong, songs)
+		if err != nil {
+			return nil, fmt.Errorf("remove songs: %v", err)
+		}
+	}
+
+	if err = stage("genres"); err != nil {
+		return nil, err
+	}
+	if summary.Full || len(albums) > 0 {
+		err = i.UpdateLocalGenres()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = i.UpdateLatestAlbums()
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := i.countItems()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := func(itemType models.ItemType) models.ItemChanges {
+		c := models.ItemChanges{Removed: removed[itemType]}
+		c.Added = after[itemType] - before[itemType] + c.Removed
+		if c.Added < 0 {
+			c.Added = 0
+		}
+		c.Updated = retrieved[itemType] - c.Added
+		if c.Updated < 0 {
+			c.Updated = 0
+		}
+		return c
+	}
+	summary.Artists = changes(models.TypeArtist)
+	summary.Albums = changes(models.TypeAlbum)
+	summary.Songs = changes(models.TypeSong)
+	summary.Playlists = changes(models.TypePlaylist)
+
+	err = i.db.SetRefreshed(start, summary.Full)
+	if err != nil {
+		return summary, fmt.Errorf("save refresh time: %v", err)
+	}
+	summary.Took = time.Now().Sub(start)
+	return summary, nil
+}
+
+func (i *Items) countItems() (map[models.ItemType]int, error) {
+	counts := map[models.ItemType]int{}
+	for _, v := range []models.ItemType{models.TypeArtist, models.TypeAlbum, models.TypeSong, models.TypePlaylist} {
+		n, err := i.db.CountItems(v)
+		if err != nil {
+			return counts, fmt.Errorf("count items: %v", err)
+		}
+		counts[v] = n
+	}
+	return counts, nil
+}
+
+// UpdateLocalArtists pulls artists changed since given time from server and stores/updates on local database.
+// Zero time pulls all artists. It returns ids of pulled artists and whether all artists that server
+// reported were pulled.
+func (i *Items) UpdateLocalArtists(since time.Time) ([]models.Id, bool, error) {
 	logrus.Debugf("Refresh artists from remote server")
 	start := time.Now()
-	retrieved := 0
+	ids := make([]models.Id, 0)
 	totalArtists := 0
 
 	query := interfaces.DefaultQueryOpts()
 	query.Paging.PageSize = 200
-	if 0 < limit && limit < 200 {
-		query.Paging.PageSize = limit
-	}
 	query.Paging.CurrentPage = 0
+	query.Filter.ModifiedSince = since
 
 	for {
-		artists, n, err := i.browser.GetArtists(query)
+		artists, n, err := i.browser.GetArtists(context.Background(), query)
 		if err != nil {
-			return fmt.Errorf("pull artists: %v", err)
+			return ids, false, fmt.Errorf("pull artists: %v", err)
 		}
 		totalArtists = n
 
 		if len(artists) == 0 {
 			logrus.Debugf("no artists found")
-			return nil
+			break
 		}
 
 		err = i.db.UpdateArtists(artists)
 		if err != nil {
-			return fmt.Errorf("save artists: %v", err)
+			return ids, false, fmt.Errorf("save artists: %v", err)
 		}
 		query.Paging.CurrentPage += 1
-		retrieved += len(artists)
+		for _, v := range artists {
+			ids = append(ids, v.Id)
+		}
 
-		logrus.Debugf("retrieved %d artists", retrieved)
+		logrus.Debugf("retrieved %d artists", len(ids))
 
-		if (limit != 0 && retrieved >= limit) || len(artists) < query.Paging.PageSize || totalArtists <= retrieved {
+		if len(artists) < query.Paging.PageSize || totalArtists <= len(ids) {
 			break
 		}
 	}
 
-	if totalArtists != retrieved {
-		logrus.Warningf("not all artists were updated: (%d - %d)", totalArtists, retrieved)
+	if totalArtists != len(ids) {
+		logrus.Warningf("not all artists were updated: (%d - %d)", totalArtists, len(ids))
 
 	}
 
 	took := time.Now().Sub(start)
 
-	logrus.Infof("Updated %d artists in %.2f s", retrieved, float32(took.Milliseconds())/1000)
-	return nil
+	logrus.Infof("Updated %d artists in %.2f s", len(ids), float32(took.Milliseconds())/1000)
+	return ids, totalArtists == len(ids), nil
 }
 
-// UpdateLocalAlbums pulls latest info on albums from server and stores/updates on local database.
-func (i *Items) UpdateLocalAlbums(limit int) error {
+// UpdateLocalAlbums pulls albums changed since given time from server and stores/updates on local database.
+// Zero time pulls all albums. It returns ids of pulled albums and whether all albums that server
+// reported were pulled.
+func (i *Items) UpdateLocalAlbums(since time.Time) ([]models.Id, bool, error) {
 	logrus.Debugf("Refresh album from remote server")
 	start := time.Now()
-	retrieved := 0
+	ids := make([]models.Id, 0)
 	totalAlbums := 0
 
 	query := interfaces.DefaultQueryOpts()
 	query.Paging.PageSize = 100
-	if 0 < limit && limit < 100 {
-		query.Paging.PageSize = limit
-	}
 	query.Paging.CurrentPage = 0
+	query.Filter.ModifiedSince = since
 
 	for {
-		albums, n, err := i.browser.GetAlbums(query)
+		albums, n, err := i.browser.GetAlbums(context.Background(), query)
 		if err != nil {
-			return fmt.Errorf("pull albums: %v", err)
+			return ids, false, fmt.Errorf("pull albums: %v", err)
 		}
 		totalAlbums = n
 
 		if len(albums) == 0 {
 			logrus.Debugf("no albums found")
-			return nil
+			break
 		}
 
 		err = i.db.UpdateAlbums(albums)
 		if err != nil {
-			return fmt.Errorf("save albums: %v", err)
+			return ids, false, fmt.Errorf("save albums: %v", err)
 		}
 
 		query.Paging.CurrentPage += 1
-		retrieved += len(albums)
+		for _, v := range albums {
+			ids = append(ids, v.Id)
+		}
 
-		if (limit != 0 && retrieved >= limit) || len(albums) < query.Paging.PageSize {
+		if len(albums) < query.Paging.PageSize {
 			break
 		}
 	}
 
-	if totalAlbums != retrieved {
-		logrus.Warningf("not all albums were updated: (%d - %d)", totalAlbums, retrieved)
+	if totalAlbums != len(ids) {
+		logrus.Warningf("not all albums were updated: (%d - %d)", totalAlbums, len(ids))
 
 	}
 
 	took := time.Now().Sub(start)
 
-	logrus.Infof("Updated %d albums in %.2f s", retrieved, float32(took.Milliseconds())/1000)
-	return nil
+	logrus.Infof("Updated %d albums in %.2f s", len(ids), float32(took.Milliseconds())/1000)
+	return ids, totalAlbums == len(ids), nil
 
 }
 
-func (i *Items) UpdateLocalSongs(limit int) error {
+// UpdateLocalSongs pulls songs changed since given time from server and stores/updates on local database.
+// If server cannot list songs directly, songs of given albums are pulled instead.
+// It returns ids of pulled songs and whether all songs that server reported were pulled.
+func (i *Items) UpdateLocalSongs(since time.Time, albums []models.Id) ([]models.Id, bool, error) {
 	logrus.Debugf("Refresh songs from remote server")
 
 	pullSongsDirectly := false
@@ -143,143 +340,300 @@ func (i *Items) UpdateLocalSongs(limit int) error {
 
 	start := time.Now()
 	var err error
+	var ids []models.Id
+	// songs of albums that could not be pulled are kept
+	complete := true
 
 	if pullSongsDirectly {
-		err = i.pullSongs(limit)
+		ids, complete, err = i.pullSongs(since)
 	} else {
-		err = i.pullSongsByAlbums(limit)
+		ids, err = i.pullSongsByAlbums(albums)
 	}
 
 	took := time.Now().Sub(start)
 
 	if err == nil {
-		logrus.Infof("Updated songs in %.2f s", float32(took.Milliseconds())/1000)
+		logrus.Infof("Updated %d songs in %.2f s", len(ids), float32(took.Milliseconds())/1000)
 	}
-	return err
+	return ids, complete, err
 }
 
-func (i *Items) pullSongs(limit int) error {
+func (i *Items) pullSongs(since time.Time) ([]models.Id, bool, error) {
 	logrus.Infof("Pull songs from server")
-	retrieved := 0
+	ids := make([]models.Id, 0)
 	totalSongs := 0
 	query := interfaces.DefaultQueryOpts()
 	query.Paging.PageSize = 200
-	if 0 < limit && limit < 200 {
-		query.Paging.PageSize = limit
-	}
 	query.Paging.CurrentPage = 0
+	query.Filter.ModifiedSince = since
 
 	for {
-		songs, n, err := i.browser.GetSongs(query)
+		songs, n, err := i.browser.GetSongs(context.Background(), query)
 		if err != nil {
-			return fmt.Errorf("pull songs: %v", err)
+			return ids, false, fmt.Errorf("pull songs: %v", err)
 		}
 		totalSongs = n
 
 		if len(songs) == 0 {
 			logrus.Debugf("no songs found")
-			return nil
+			break
 		}
 
 		err = i.db.UpdateSongs(songs)
 		if err != nil {
-			return fmt.Errorf("save songs: %v", err)
+			return ids, false, fmt.Errorf("save songs: %v", err)
 		}
 
 		query.Paging.CurrentPage += 1
-		retrieved += len(songs)
-		logrus.Debugf("retrieved %d songs", retrieved)
+		for _, v := range songs {
+			ids = append(ids, v.Id)
+		}
+		logrus.Debugf("retrieved %d songs", len(ids))
 
-		if (limit != 0 && retrieved >= limit) || len(songs) < query.Paging.PageSize {
+		if len(songs) < query.Paging.PageSize {
 			break
 		}
 	}
 
-	if totalSongs != retrieved {
-		logrus.Warningf("not all songs were updated: (%d - %d)", totalSongs, retrieved)
+	if totalSongs != len(ids) {
+		logrus.Warningf("not all songs were updated: (%d - %d)", totalSongs, len(ids))
 	}
 
-	return nil
+	return ids, totalSongs == len(ids), nil
 
 }
 
-func (i *Items) pullSongsByAlbums(limit int) error {
-	logrus.Info("Pull album songs from server")
+func (i *Items) pullSongsByAlbums(albums []models.Id) ([]models.Id, error) {
+	logrus.Infof("Pull songs of %d albums from server", len(albums))
 
+	ids := make([]models.Id, 0)
 	retrieved := 0
-	totalSongs := 0
 	failed := 0
-	query := interfaces.DefaultQueryOpts()
-	query.Paging.PageSize = 100
-	if 0 < limit && limit < 100 {
-		query.Paging.PageSize = limit
+
+	for _, album := range albums {
+		songs, err := i.browser.GetAlbumSongs(context.Background(), album)
+		if err != nil {
+			logrus.Errorf("get album songs: %v", err)
+			failed += 1
+			// keep cached songs, so that they are not considered removed
+			cached, err := i.db.GetAlbumSongs(album)
+			if err != nil {
+				return ids, fmt.Errorf("get cached album songs: %v", err)
+			}
+			for _, v := range cached {
+				ids = append(ids, v.Id)
+			}
+			continue
+		} else {
+			retrieved += 1
+		}
+
+		if len(songs) == 0 {
+			logrus.Debugf("no songs found")
+			continue
+		}
+
+		err = i.db.UpdateSongs(songs)
+		if err != nil {
+			return ids, fmt.Errorf("save songs: %v", err)
+		}
+		for _, v := range songs {
+			ids = append(ids, v.Id)
+		}
+		logrus.Debugf("retrieved %d songs", len(ids))
 	}
-	query.Paging.CurrentPage = 0
 
-	var err error
-	var albums []*models.Album
+	logrus.Infof("Cached songs for %d albums, %d failed", retrieved, failed)
+	return ids, nil
+}
 
-	for {
-		logrus.Infof("get albums, page %d", query.Paging.CurrentPage)
-		albums, _, err = i.GetAlbums(query)
+// UpdateLocalUserData pulls artists, albums and songs whose favorite status or play count changed since given
+// time and updates them on local database. Changes in user data do not change items' modification time,
+// so they are not included in incremental refresh otherwise. If server cannot filter items by user data,
+// user data is only updated with full refresh.
+func (i *Items) UpdateLocalUserData(since time.Time) error {
+	cacher, ok := i.browser.(api.Cacher)
+	if !ok || !cacher.CanFilterUserData() {
+		return nil
+	}
+
+	newQuery := func() *interfaces.QueryOpts {
+		query := interfaces.DefaultQueryOpts()
+		query.Paging.PageSize = 200
+		query.Paging.CurrentPage = 0
+		query.Filter.UserDataChangedSince = since
+		return query
+	}
+
+	updated := 0
+	n, err := pullPages(newQuery(), func(query *interfaces.QueryOpts) (int, int, error) {
+		artists, total, err := i.browser.GetArtists(context.Background(), query)
 		if err != nil {
-			logrus.Errorf("get albums (page %d): %v", query.Paging.CurrentPage, err)
-			continue
+			return 0, 0, fmt.Errorf("pull artists: %v", err)
+		}
+		if len(artists) == 0 {
+			return 0, total, nil
 		}
-		for _, album := range albums {
-			songs, err := i.browser.GetAlbumSongs(album.Id)
+		return len(artists), total, i.db.UpdateArtists(artists)
+	})
+	updated += n
+	if err != nil {
+		return err
+	}
+	n, err = pullPages(newQuery(), func(query *interfaces.QueryOpts) (int, int, error) {
+		albums, total, err := i.browser.GetAlbums(context.Background(), query)
+		if err != nil {
+			return 0, 0, fmt.Errorf("pull albums: %v", err)
+		}
+		if len(albums) == 0 {
+			return 0, total, nil
+		}
+		return len(albums), total, i.db.UpdateAlbums(albums)
+	})
+	updated += n
+	if err != nil {
+		return err
+	}
+	if cacher.CanCacheSongs() {
+		n, err = pullPages(newQuery(), func(query *interfaces.QueryOpts) (int, int, error) {
+			songs, total, err := i.browser.GetSongs(context.Background(), query)
 			if err != nil {
-				logrus.Errorf("get album songs: %v", err)
-				failed += 1
-				continue
-			} else {
-				totalSongs += len(songs)
-				retrieved += 1
+				return 0, 0, fmt.Errorf("pull songs: %v", err)
 			}
-
 			if len(songs) == 0 {
-				logrus.Debugf("no songs found")
+				return 0, total, nil
 			}
+			return len(songs), total, i.db.UpdateSongs(songs)
+		})
+		updated += n
+		if err != nil {
+			return err
+		}
+	}
+	logrus.Infof("Updated user data of %d items", updated)
+	return nil
+}
 
-			err = i.db.UpdateSongs(songs)
-			if err != nil {
-				return fmt.Errorf("save songs: %v", err)
-			}
-			logrus.Debugf("retrieved %d songs", totalSongs)
+// pullPages calls pull with query until all pages have been pulled. Pull returns number of items in page and
+// total number of items. It returns number of pulled items.
+func pullPages(query *interfaces.QueryOpts, pull func(query *interfaces.QueryOpts) (int, int, error)) (int, error) {
+	pulled := 0
+	for {
+		n, total, err := pull(query)
+		if err != nil {
+			return pulled, err
 		}
-		if len(albums) < query.Paging.PageSize {
-			break
+		pulled += n
+		if n < query.Paging.PageSize || pulled >= total {
+			return pulled, nil
 		}
 		query.Paging.CurrentPage += 1
 	}
-
-	logrus.Infof("Cached songs for %d albums, %d failed", retrieved, failed)
-	return err
 }
 
-func (i *Items) UpdatePlaylists() error {
+// UpdatePlaylists pulls all playlists from server and stores/updates on local database.
+// It returns ids of pulled playlists.
+func (i *Items) UpdatePlaylists() ([]models.Id, error) {
 	logrus.Info("Update playlists from server")
 
-	playlists, err := i.browser.GetPlaylists()
+	playlists, err := i.browser.GetPlaylists(context.Background())
 	if err != nil {
-		return fmt.Errorf("get playlists: %v", err)
+		return nil, fmt.Errorf("get playlists: %v", err)
 	}
+	ids := make([]models.Id, len(playlists))
 	if len(playlists) == 0 {
 		logrus.Info("no playlists")
-		return nil
+		return ids, nil
 	}
 
 	for index, v := range playlists {
-		songs, err := i.browser.GetPlaylistSongs(v.Id)
+		songs, err := i.browser.GetPlaylistSongs(context.Background(), v.Id)
 		if err != nil {
-			return fmt.Errorf("get playlist songs: %v", err)
+			return ids, fmt.Errorf("get playlist songs: %v", err)
 		}
 		playlists[index].Songs = songs
+		ids[index] = v.Id
 	}
 
 	err = i.db.UpdatePlaylists(playlists)
 	if err == nil {
 		logrus.Infof("Updated %d playlists", len(playlists))
 	}
+	return ids, err
+}
+
+// UpdateLocalGenres pulls genres and albums of each genre from server and stores them on local database.
+func (i *Items) UpdateLocalGenres() error {
+	logrus.Info("Update genres from server")
+	start := time.Now()
+
+	paging := interfaces.DefaultPaging()
+	paging.PageSize = 200
+	genres := make([]*models.IdName, 0)
+	for {
+		page, n, err := i.browser.GetGenres(context.Background(), paging)
+		if err != nil {
+			return fmt.Errorf("get genres: %v", err)
+		}
+		genres = append(genres, page...)
+		if len(page) < paging.PageSize || len(genres) >= n {
+			break
+		}
+		paging.CurrentPage += 1
+	}
+
+	err := i.db.UpdateGenres(genres)
+	if err != nil {
+		return fmt.Errorf("save genres: %v", err)
+	}
+
+	for _, genre := range genres {
+		query := interfaces.DefaultQueryOpts()
+		query.Paging.PageSize = 200
+		query.Filter.Genres = []models.IdName{*genre}
+
+		ids := make([]models.Id, 0)
+		for {
+			albums, n, err := i.browser.GetAlbums(context.Background(), query)
+			if err != nil {
+				return fmt.Errorf("get genre '%s' albums: %v", genre.Name, err)
+			}
+			for _, v := range albums {
+				ids = append(ids, v.Id)
+			}
+			if len(albums) < query.Paging.PageSize || len(ids) >= n {
+				break
+			}
+			query.Paging.CurrentPage += 1
+		}
+
+		err = i.db.UpdateGenreAlbums(genre.Id, ids)
+		if err != nil {
+			return fmt.Errorf("save genre albums: %v", err)
+		}
+	}
+
+	took := time.Now().Sub(start)
+	logrus.Infof("Updated %d genres in %.2f s", len(genres), float32(took.Milliseconds())/1000)
+	return nil
+}
+
+// UpdateLatestAlbums pulls latest albums from server and stores their order on local database.
+// Albums are expected to already exist.
+func (i *Items) UpdateLatestAlbums() error {
+	logrus.Info("Update latest albums from server")
+	albums, _, err := i.browser.GetAlbums(context.Background(), latestAlbumsQuery())
+	if err != nil {
+		return fmt.Errorf("get latest albums: %v", err)
+	}
+
+	ids := make([]models.Id, len(albums))
+	for index, v := range albums {
+		ids[index] = v.Id
+	}
+	err = i.db.UpdateLatestAlbums(ids)
+	if err == nil {
+		logrus.Infof("Updated %d latest albums", len(albums))
+	}
 	return err
 }
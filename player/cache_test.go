@@ -0,0 +1,226 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package player
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+// refreshServer serves library for refresh and records ModifiedSince filters.
+type refreshServer struct {
+	*api.MockServer
+	artists []*models.Artist
+	albums  []*models.Album
+	songs   map[models.Id][]*models.Song
+	since   []time.Time
+	// missing is number of artists and albums that server reports but does not return
+	missing int
+	// userData contains artists whose user data has changed, if server filters user data
+	userData      []*models.Artist
+	userDataSince []time.Time
+}
+
+func (r *refreshServer) CanCacheSongs() bool { return false }
+
+func (r *refreshServer) CanFilterUserData() bool { return r.userData != nil }
+
+func (r *refreshServer) GetArtists(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Artist, int, error) {
+	if !query.Filter.UserDataChangedSince.IsZero() {
+		r.userDataSince = append(r.userDataSince, query.Filter.UserDataChangedSince)
+		return r.userData, len(r.userData), nil
+	}
+	r.since = append(r.since, query.Filter.ModifiedSince)
+	return r.artists, len(r.artists) + r.missing, nil
+}
+
+func (r *refreshServer) GetAlbums(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Album, int, error) {
+	if len(query.Filter.Genres) > 0 || query.Sort.Field == interfaces.SortByLatest ||
+		!query.Filter.UserDataChangedSince.IsZero() {
+		return []*models.Album{}, 0, nil
+	}
+	return r.albums, len(r.albums) + r.missing, nil
+}
+
+func (r *refreshServer) GetAlbumSongs(ctx context.Context, album models.Id) ([]*models.Song, error) {
+	return r.songs[album], nil
+}
+
+func (r *refreshServer) GetPlaylists(ctx context.Context) ([]*models.Playlist, error) {
+	return []*models.Playlist{}, nil
+}
+
+func (r *refreshServer) GetGenres(ctx context.Context, paging interfaces.Paging) ([]*models.IdName, int, error) {
+	return []*models.IdName{}, 0, nil
+}
+
+func TestItems_RefreshLocalCache(t *testing.T) {
+	_, db := testPlayHistory(t)
+	server := &refreshServer{
+		MockServer: api.NewMockServer(),
+		artists:    []*models.Artist{{Id: "artist-1", Name: "artist 1"}, {Id: "artist-2", Name: "artist 2"}},
+		albums: []*models.Album{
+			{Id: "album-1", Name: "album 1", Artist: "artist-1"},
+			{Id: "album-2", Name: "album 2", Artist: "artist-2"},
+		},
+		songs: map[models.Id][]*models.Song{
+			"album-1": {{Id: "song-1", Album: "album-1"}, {Id: "song-2", Album: "album-1"}},
+			"album-2": {{Id: "song-3", Album: "album-2"}},
+		},
+	}
+	items := &Items{browser: server, db: db}
+
+	summary, err := items.RefreshLocalCache(false)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if !summary.Full {
+		t.Errorf("first refresh is not full")
+	}
+	want := models.ItemChanges{Added: 3}
+	if summary.Songs != want {
+		t.Errorf("first refresh songs: %s, want %s", summary.Songs, want)
+	}
+
+	// incremental refresh only gets changed album
+	server.albums = server.albums[1:]
+	summary, err = items.RefreshLocalCache(false)
+	if err != nil {
+		t.Fatalf("second refresh: %v", err)
+	}
+	if summary.Full {
+		t.Errorf("second refresh is full")
+	}
+	if server.since[1].IsZero() || !server.since[1].Before(time.Now()) {
+		t.Errorf("incremental refresh did not filter by time: %s", server.since[1])
+	}
+	want = models.ItemChanges{Updated: 1}
+	if summary.Albums != want {
+		t.Errorf("incremental refresh albums: %s, want %s", summary.Albums, want)
+	}
+
+	// full refresh removes missing items
+	server.artists = server.artists[1:]
+	summary, err = items.RefreshLocalCache(true)
+	if err != nil {
+		t.Fatalf("full refresh: %v", err)
+	}
+	if !server.since[2].IsZero() {
+		t.Errorf("full refresh filtered by time")
+	}
+	wantChanges := map[string][2]models.ItemChanges{
+		"artists": {summary.Artists, {Updated: 1, Removed: 1}},
+		"albums":  {summary.Albums, {Updated: 1, Removed: 1}},
+		"songs":   {summary.Songs, {Updated: 1, Removed: 2}},
+	}
+	for name, v := range wantChanges {
+		if v[0] != v[1] {
+			t.Errorf("full refresh %s: %s, want %s", name, v[0], v[1])
+		}
+	}
+
+	songs, err := db.GetAlbumSongs("album-1")
+	if err != nil {
+		t.Fatalf("get album songs: %v", err)
+	}
+	if len(songs) != 0 {
+		t.Errorf("songs of removed album still exist: %d", len(songs))
+	}
+}
+
+func TestItems_RefreshLocalCacheIncomplete(t *testing.T) {
+	_, db := testPlayHistory(t)
+	server := &refreshServer{
+		MockServer: api.NewMockServer(),
+		artists:    []*models.Artist{{Id: "artist-1", Name: "artist 1"}, {Id: "artist-2", Name: "artist 2"}},
+		albums: []*models.Album{
+			{Id: "album-1", Name: "album 1", Artist: "artist-1"},
+			{Id: "album-2", Name: "album 2", Artist: "artist-2"},
+		},
+		songs: map[models.Id][]*models.Song{
+			"album-1": {{Id: "song-1", Album: "album-1"}},
+			"album-2": {{Id: "song-2", Album: "album-2"}},
+		},
+	}
+	items := &Items{browser: server, db: db}
+	_, err := items.RefreshLocalCache(true)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+
+	// server fails to return all items, which must not be removed
+	server.artists = server.artists[1:]
+	server.albums = server.albums[1:]
+	server.missing = 1
+	summary, err := items.RefreshLocalCache(true)
+	if err != nil {
+		t.Fatalf("full refresh: %v", err)
+	}
+	for name, v := range map[string]models.ItemChanges{
+		"artists": summary.Artists, "albums": summary.Albums, "songs": summary.Songs} {
+		if v.Removed != 0 {
+			t.Errorf("incomplete refresh removed %d %s", v.Removed, name)
+		}
+	}
+	songs, err := db.GetAlbumSongs("album-1")
+	if err != nil {
+		t.Fatalf("get album songs: %v", err)
+	}
+	if len(songs) != 1 {
+		t.Errorf("songs of album that was not pulled were removed")
+	}
+}
+
+func TestItems_RefreshLocalCacheUserData(t *testing.T) {
+	_, db := testPlayHistory(t)
+	server := &refreshServer{
+		MockServer: api.NewMockServer(),
+		artists:    []*models.Artist{{Id: "artist-1", Name: "artist 1"}},
+		userData:   []*models.Artist{},
+	}
+	items := &Items{browser: server, db: db}
+	_, err := items.RefreshLocalCache(false)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if len(server.userDataSince) != 0 {
+		t.Errorf("full refresh pulled user data separately")
+	}
+
+	// favorite does not change modification time
+	server.artists = []*models.Artist{}
+	server.userData = []*models.Artist{{Id: "artist-1", Name: "artist 1", Favorite: true}}
+	_, err = items.RefreshLocalCache(false)
+	if err != nil {
+		t.Fatalf("incremental refresh: %v", err)
+	}
+	if len(server.userDataSince) != 1 || server.userDataSince[0].IsZero() {
+		t.Fatalf("incremental refresh did not pull user data: %v", server.userDataSince)
+	}
+	artist, err := db.GetArtist("artist-1")
+	if err != nil {
+		t.Fatalf("get artist: %v", err)
+	}
+	if !artist.Favorite {
+		t.Errorf("favorite was not updated")
+	}
+}
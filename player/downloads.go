@@ -0,0 +1,449 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/storage"
+)
+
+var errDownloadCancelled = errors.New("download cancelled")
+
+// Downloads implements interfaces.DownloadController. Songs are downloaded one by one in background
+// and stored in local cache directory. Completed downloads are stored in database,
+// while pending downloads are only kept in memory.
+type Downloads struct {
+	lock   *sync.RWMutex
+	server api.MediaServer
+	db     *storage.Db
+	dir    string
+
+	// pending, ongoing and failed downloads
+	pending []*models.Download
+	running bool
+
+	changedFuncs []func()
+	lastNotify   time.Time
+}
+
+func newDownloads(server api.MediaServer, db *storage.Db) *Downloads {
+	return &Downloads{
+		lock:    &sync.RWMutex{},
+		server:  server,
+		db:      db,
+		dir:     path.Join(config.AppConfig.Player.LocalCacheDir, "downloads", server.GetId()),
+		pending: make([]*models.Download, 0),
+	}
+}
+
+func (d *Downloads) DownloadItem(item models.Item) error {
+	if d.db == nil {
+		return errors.New("local database is not available")
+	}
+	if item == nil {
+		return errors.New("empty item")
+	}
+
+	switch item.GetType() {
+	case models.TypeSong, models.TypeAlbum, models.TypeArtist, models.TypePlaylist:
+	default:
+		return fmt.Errorf("cannot download item of type %s", item.GetType())
+	}
+
+	go d.addItem(item)
+	return nil
+}
+
+func (d *Downloads) GetDownloads() []*models.Download {
+	d.lock.RLock()
+	downloads := make([]*models.Download, len(d.pending))
+	for i, v := range d.pending {
+		download := *v
+		downloads[i] = &download
+	}
+	d.lock.RUnlock()
+
+	if d.db == nil {
+		return downloads
+	}
+
+	completed, err := d.db.GetDownloads()
+	if err != nil {
+		logrus.Errorf("get downloads: %v", err)
+	}
+	return append(downloads, completed...)
+}
+
+func (d *Downloads) RemoveDownload(song models.Id) error {
+	if d.removePending(song) {
+		d.notify()
+		return nil
+	}
+	if d.db == nil {
+		return errors.New("local database is not available")
+	}
+
+	download, err := d.db.GetDownload(song)
+	if err != nil {
+		return fmt.Errorf("get download: %v", err)
+	}
+	if download == nil {
+		return fmt.Errorf("song %s is not downloaded", song)
+	}
+
+	err = os.Remove(download.File)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove file: %v", err)
+	}
+
+	err = d.db.RemoveDownload(song)
+	if err != nil {
+		return fmt.Errorf("remove download: %v", err)
+	}
+	d.notify()
+	return nil
+}
+
+func (d *Downloads) AddDownloadsChangedCallback(cb func()) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.changedFuncs = append(d.changedFuncs, cb)
+}
+
+// openSong opens downloaded song. If song has not been downloaded, return false.
+func (d *Downloads) openSong(song *models.Song) (io.ReadCloser, interfaces.AudioFormat, bool) {
+	if d.db == nil {
+		return nil, interfaces.AudioFormatNil, false
+	}
+	download, err := d.db.GetDownload(song.Id)
+	if err != nil {
+		logrus.Errorf("get download: %v", err)
+		return nil, interfaces.AudioFormatNil, false
+	}
+	if download == nil {
+		return nil, interfaces.AudioFormatNil, false
+	}
+
+	file, format, err := openVerifiedFile(download)
+	if err != nil {
+		// file has been removed by hand or it is corrupted
+		logrus.Warningf("invalid downloaded song %s, removing download: %v", song.Id, err)
+		err = d.RemoveDownload(song.Id)
+		if err != nil {
+			logrus.Error(err)
+		}
+		return nil, interfaces.AudioFormatNil, false
+	}
+
+	err = d.db.SongPlayed(song.Id)
+	if err != nil {
+		logrus.Errorf("update download: %v", err)
+	}
+	return file, format, true
+}
+
+func (d *Downloads) addItem(item models.Item) {
+	songs, err := d.itemSongs(item)
+	if err != nil {
+		logrus.Errorf("get songs to download: %v", err)
+		return
+	}
+
+	added := 0
+	d.lock.Lock()
+	for _, song := range songs {
+		if pending := d.getPending(song.Id); pending != nil {
+			// retry failed download
+			if pending.State == models.DownloadFailed {
+				pending.State = models.DownloadPending
+				added += 1
+			}
+			continue
+		}
+		download, err := d.db.GetDownload(song.Id)
+		if err != nil {
+			logrus.Errorf("get download: %v", err)
+			continue
+		}
+		if download != nil {
+			continue
+		}
+		d.pending = append(d.pending, &models.Download{Song: song, State: models.DownloadPending})
+		added += 1
+	}
+	start := !d.running && added > 0
+	if start {
+		d.running = true
+	}
+	d.lock.Unlock()
+
+	logrus.Infof("Added %d songs to downloads", added)
+	d.notify()
+	if start {
+		go d.process()
+	}
+}
+
+// get all songs for item
+func (d *Downloads) itemSongs(item models.Item) ([]*models.Song, error) {
+	switch item.GetType() {
+	case models.TypeSong:
+		song, ok := item.(*models.Song)
+		if !ok {
+			return nil, errors.New("invalid song")
+		}
+		return []*models.Song{song}, nil
+	case models.TypeAlbum:
+		return d.server.GetAlbumSongs(context.Background(), item.GetId())
+	case models.TypePlaylist:
+		return d.server.GetPlaylistSongs(context.Background(), item.GetId())
+	case models.TypeArtist:
+		albums, err := d.server.GetArtistAlbums(context.Background(), item.GetId())
+		if err != nil {
+			return nil, fmt.Errorf("get artist albums: %v", err)
+		}
+		songs := make([]*models.Song, 0)
+		for _, album := range albums {
+			albumSongs, err := d.server.GetAlbumSongs(context.Background(), album.Id)
+			if err != nil {
+				return nil, fmt.Errorf("get album songs: %v", err)
+			}
+			songs = append(songs, albumSongs...)
+		}
+		return songs, nil
+	default:
+		return nil, fmt.Errorf("cannot download item of type %s", item.GetType())
+	}
+}
+
+// process pending downloads until there are none left
+func (d *Downloads) process() {
+	albums := map[models.Id]*models.Album{}
+	for {
+		d.lock.Lock()
+		var download *models.Download
+		for _, v := range d.pending {
+			if v.State == models.DownloadPending {
+				download = v
+				break
+			}
+		}
+		if download == nil {
+			d.running = false
+			d.lock.Unlock()
+			return
+		}
+		download.State = models.DownloadDownloading
+		d.lock.Unlock()
+		d.notify()
+
+		err := d.download(download, albums)
+		if err == errDownloadCancelled {
+			logrus.Infof("Cancelled download of song %s", download.Song.Id)
+		} else if err != nil {
+			logrus.Errorf("download song %s: %v", download.Song.Id, err)
+			d.lock.Lock()
+			download.State = models.DownloadFailed
+			d.lock.Unlock()
+		} else {
+			d.removePending(download.Song.Id)
+		}
+		d.notify()
+	}
+}
+
+func (d *Downloads) download(download *models.Download, albums map[models.Id]*models.Album) error {
+	song := download.Song
+	err := os.MkdirAll(d.dir, 0760)
+	if err != nil {
+		return fmt.Errorf("create download directory: %v", err)
+	}
+
+	reader, format, err := d.server.Download(context.Background(), song)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if sized, ok := reader.(interface{ Size() int64 }); ok {
+		d.lock.Lock()
+		download.Size = sized.Size()
+		d.lock.Unlock()
+	}
+
+	fileName := path.Join(d.dir, song.Id.String()+"."+format.String())
+	tmpName := fileName + ".part"
+	file, err := os.Create(tmpName)
+	if err != nil {
+		return fmt.Errorf("create file: %v", err)
+	}
+
+	progress := &downloadProgress{downloads: d, download: download}
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hasher), io.TeeReader(reader, progress))
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		removeErr := os.Remove(tmpName)
+		if removeErr != nil {
+			logrus.Warningf("remove incomplete download: %v", removeErr)
+		}
+		return err
+	}
+
+	err = os.Rename(tmpName, fileName)
+	if err != nil {
+		return fmt.Errorf("rename file: %v", err)
+	}
+
+	album, ok := albums[song.Album]
+	if !ok && song.Album != "" {
+		d.fetchAlbums(albums)
+		album, ok = albums[song.Album]
+	}
+	if !ok && song.Album != "" {
+		album, err = d.server.GetAlbum(context.Background(), song.Album)
+		if err != nil {
+			logrus.Warningf("get album for downloaded song: %v", err)
+			album = nil
+		}
+		albums[song.Album] = album
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	err = d.db.AddDownload(song, album, fileName, size, checksum)
+	if err != nil {
+		removeErr := os.Remove(fileName)
+		if removeErr != nil {
+			logrus.Warningf("remove unsaved download: %v", removeErr)
+		}
+		return fmt.Errorf("save download: %v", err)
+	}
+	logrus.Debugf("Downloaded song %s (%d B)", song.Id, size)
+	return nil
+}
+
+// fetchAlbums fetches albums of pending downloads that are missing from albums,
+// if server can fetch them in batches.
+func (d *Downloads) fetchAlbums(albums map[models.Id]*models.Album) {
+	batcher, ok := d.server.(api.AlbumBatcher)
+	if !ok {
+		return
+	}
+	d.lock.RLock()
+	ids := make([]models.Id, 0)
+	missing := map[models.Id]bool{}
+	for _, v := range d.pending {
+		id := v.Song.Album
+		if _, found := albums[id]; id == "" || found || missing[id] || v.State == models.DownloadFailed {
+			continue
+		}
+		missing[id] = true
+		ids = append(ids, id)
+	}
+	d.lock.RUnlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	fetched, err := batcher.GetAlbumsById(context.Background(), ids)
+	if err != nil {
+		logrus.Warningf("get albums for downloads: %v", err)
+	}
+	for _, v := range fetched {
+		albums[v.Id] = v
+	}
+}
+
+// isPending returns true if song is pending, ongoing or failed download. Caller must hold lock.
+func (d *Downloads) isPending(song models.Id) bool {
+	return d.getPending(song) != nil
+}
+
+// getPending returns pending, ongoing or failed download for song. Caller must hold lock.
+func (d *Downloads) getPending(song models.Id) *models.Download {
+	for _, v := range d.pending {
+		if v.Song.Id == song {
+			return v
+		}
+	}
+	return nil
+}
+
+func (d *Downloads) removePending(song models.Id) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i, v := range d.pending {
+		if v.Song.Id == song {
+			d.pending = append(d.pending[:i], d.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Downloads) notify() {
+	d.lock.Lock()
+	d.lastNotify = time.Now()
+	funcs := d.changedFuncs
+	d.lock.Unlock()
+
+	for _, f := range funcs {
+		f()
+	}
+}
+
+// downloadProgress counts bytes downloaded and cancels download if it's no longer pending.
+type downloadProgress struct {
+	downloads *Downloads
+	download  *models.Download
+}
+
+func (p *downloadProgress) Write(b []byte) (int, error) {
+	d := p.downloads
+	d.lock.Lock()
+	p.download.Downloaded += int64(len(b))
+	cancelled := !d.isPending(p.download.Song.Id)
+	notify := time.Since(d.lastNotify) > time.Millisecond*500
+	d.lock.Unlock()
+
+	if cancelled {
+		return 0, errDownloadCancelled
+	}
+	if notify {
+		d.notify()
+	}
+	return len(b), nil
+}
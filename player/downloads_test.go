@@ -0,0 +1,54 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"testing"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/storage"
+)
+
+func TestDownloads_retryFailed(t *testing.T) {
+	oldConfig := config.AppConfig
+	config.AppConfig = &config.Config{Player: config.Player{LocalCacheDir: t.TempDir()}}
+	t.Cleanup(func() {
+		config.AppConfig = oldConfig
+	})
+	db, err := storage.NewDb("test-123")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	downloads := newDownloads(api.NewMockServer(), db)
+	// don't start processing downloads
+	downloads.running = true
+	song := &models.Song{Id: "song-1", Name: "Song 1"}
+	downloads.pending = []*models.Download{{Song: song, State: models.DownloadFailed}}
+
+	downloads.addItem(song)
+	if len(downloads.pending) != 1 {
+		t.Fatalf("expected 1 pending download, got %d", len(downloads.pending))
+	}
+	if downloads.pending[0].State != models.DownloadPending {
+		t.Errorf("failed download not retried: %s", downloads.pending[0].State)
+	}
+}
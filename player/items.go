@@ -19,6 +19,7 @@
 package player
 
 import (
+	"context"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"runtime"
@@ -29,6 +30,13 @@ import (
 	"tryffel.net/go/jellycli/storage"
 )
 
+const (
+	// max number of similar items returned from local cache
+	localSimilarLimit = 50
+	// max number of songs in instant mix from local cache
+	localInstantMixLimit = 200
+)
+
 // Items implements interfaces.ItemController
 type Items struct {
 	browser api.MediaServer
@@ -48,10 +56,23 @@ func newItems(api api.MediaServer) (*Items, error) {
 		if err != nil {
 			return items, fmt.Errorf("init local database: %v", err)
 		}
+	} else {
+		// downloads are stored in database even if metadata caching is disabled
+		items.db, err = storage.NewDb(serverId)
+		if err != nil {
+			logrus.Warningf("init local database, downloads are disabled: %v", err)
+			items.db = nil
+			err = nil
+		}
 	}
 	return items, err
 }
 
+// useCache returns true if items are served from local cache.
+func (i *Items) useCache() bool {
+	return config.AppConfig.Player.EnableLocalCache && i.db != nil
+}
+
 func (i *Items) closeDb() {
 	if i.db != nil {
 		err := i.db.Close()
@@ -61,49 +82,68 @@ func (i *Items) closeDb() {
 	}
 }
 
-func (i *Items) Search(itemType models.ItemType, query string) ([]models.Item, error) {
-	return i.browser.Search(query, itemType, config.AppConfig.Gui.SearchResultsLimit)
+// Search searches local cache if it's enabled, else server.
+func (i *Items) Search(ctx context.Context, itemType models.ItemType, query string) ([]models.Item, error) {
+	if i.useCache() {
+		return i.db.Search(query, itemType, config.AppConfig.Gui.SearchResultsLimit)
+	}
+	return i.browser.Search(ctx, query, itemType, config.AppConfig.Gui.SearchResultsLimit)
 }
 
-func (i *Items) GetArtists(opts *interfaces.QueryOpts) ([]*models.Artist, int, error) {
-	if config.AppConfig.Player.EnableLocalCache {
+func (i *Items) GetArtists(ctx context.Context, opts *interfaces.QueryOpts) ([]*models.Artist, int, error) {
+	if i.useCache() {
 		return i.db.GetArtists(opts)
 	} else {
-		return i.browser.GetArtists(opts)
+		return i.browser.GetArtists(ctx, opts)
 	}
 }
 
-func (i *Items) GetAlbumArtists(paging interfaces.Paging) ([]*models.Artist, int, error) {
-	return i.browser.GetAlbumArtists(interfaces.DefaultQueryOpts())
+func (i *Items) GetAlbumArtists(ctx context.Context, paging interfaces.Paging) ([]*models.Artist, int, error) {
+	if i.useCache() {
+		return i.db.GetAlbumArtists(interfaces.DefaultQueryOpts())
+	}
+	return i.browser.GetAlbumArtists(ctx, interfaces.DefaultQueryOpts())
 }
 
-func (i *Items) GetAlbums(opts *interfaces.QueryOpts) ([]*models.Album, int, error) {
-	if config.AppConfig.Player.EnableLocalCache {
+func (i *Items) GetAlbums(ctx context.Context, opts *interfaces.QueryOpts) ([]*models.Album, int, error) {
+	if i.useCache() {
 		return i.db.GetAlbums(opts)
 	} else {
-		return i.browser.GetAlbums(opts)
+		return i.browser.GetAlbums(ctx, opts)
 	}
 }
 
-func (i *Items) GetArtistAlbums(artist models.Id) ([]*models.Album, error) {
-	return i.browser.GetArtistAlbums(artist)
+func (i *Items) GetArtistAlbums(ctx context.Context, artist models.Id) ([]*models.Album, error) {
+	if i.useCache() {
+		return i.db.GetArtistAlbums(artist)
+	}
+	return i.browser.GetArtistAlbums(ctx, artist)
 }
 
-func (i *Items) GetAlbumSongs(album models.Id) ([]*models.Song, error) {
-	return i.browser.GetAlbumSongs(album)
+func (i *Items) GetAlbumSongs(ctx context.Context, album models.Id) ([]*models.Song, error) {
+	if i.useCache() {
+		return i.db.GetAlbumSongs(album)
+	}
+	return i.browser.GetAlbumSongs(ctx, album)
 }
 
-func (i *Items) GetPlaylists() ([]*models.Playlist, error) {
-	if config.AppConfig.Player.EnableLocalCache {
+func (i *Items) GetPlaylists(ctx context.Context) ([]*models.Playlist, error) {
+	if i.useCache() {
 		return i.db.GetPlaylists()
 	} else {
-		return i.browser.GetPlaylists()
+		return i.browser.GetPlaylists(ctx)
 	}
 
 }
 
-func (i *Items) GetPlaylistSongs(playlist *models.Playlist) error {
-	songs, err := i.browser.GetPlaylistSongs(playlist.Id)
+func (i *Items) GetPlaylistSongs(ctx context.Context, playlist *models.Playlist) error {
+	var songs []*models.Song
+	var err error
+	if i.useCache() {
+		songs, err = i.db.GetPlaylistSongs(playlist.Id)
+	} else {
+		songs, err = i.browser.GetPlaylistSongs(ctx, playlist.Id)
+	}
 	if err != nil {
 		return err
 	}
@@ -112,52 +152,78 @@ func (i *Items) GetPlaylistSongs(playlist *models.Playlist) error {
 	return nil
 }
 
-func (i *Items) GetFavoriteArtists() ([]*models.Artist, error) {
+func (i *Items) GetFavoriteArtists(ctx context.Context) ([]*models.Artist, error) {
 	query := interfaces.DefaultQueryOpts()
 	query.Filter.Favorite = true
-	artists, _, err := i.browser.GetArtists(query)
+	artists, _, err := i.GetArtists(ctx, query)
 	return artists, err
 }
 
-func (i *Items) GetFavoriteAlbums(paging interfaces.Paging) ([]*models.Album, int, error) {
+func (i *Items) GetFavoriteAlbums(ctx context.Context, paging interfaces.Paging) ([]*models.Album, int, error) {
 	query := interfaces.DefaultQueryOpts()
 	query.Filter.Favorite = true
 	query.Paging = paging
-	return i.browser.GetAlbums(query)
+	return i.GetAlbums(ctx, query)
+}
+
+func (i *Items) GetLatestAlbums(ctx context.Context) ([]*models.Album, error) {
+	if i.useCache() {
+		return i.db.GetLatestAlbums()
+	}
+	albums, _, err := i.browser.GetAlbums(ctx, latestAlbumsQuery())
+	return albums, err
 }
 
-func (i *Items) GetLatestAlbums() ([]*models.Album, error) {
+func latestAlbumsQuery() *interfaces.QueryOpts {
 	query := interfaces.DefaultQueryOpts()
 	if config.AppConfig.Gui.LimitRecentlyPlayed {
 		query.Paging.PageSize = 100
 	}
 	query.Sort.Field = interfaces.SortByLatest
 	query.Sort.Mode = interfaces.SortDesc
-	albums, _, err := i.browser.GetAlbums(query)
-	return albums, err
+	return query
 }
 
-func (i *Items) GetRecentlyPlayed(paging interfaces.Paging) ([]*models.Song, int, error) {
-	return i.browser.GetRecentlyPlayed(paging)
+// GetRecentlyPlayed returns songs recently played on any client. When server is offline,
+// songs are from local listening history.
+func (i *Items) GetRecentlyPlayed(ctx context.Context, paging interfaces.Paging) ([]*models.Song, int, error) {
+	if conn, ok := i.browser.(interfaces.Connection); ok && !conn.IsOnline() && i.useCache() {
+		return i.db.GetRecentlyPlayed(paging)
+	}
+	return i.browser.GetRecentlyPlayed(ctx, paging)
 }
 
-func (i *Items) GetSimilarArtists(artist models.Id) ([]*models.Artist, error) {
-	return i.browser.GetSimilarArtists(artist)
+// GetSimilarArtists returns similar artists. With local cache artists are similar by genres.
+func (i *Items) GetSimilarArtists(ctx context.Context, artist models.Id) ([]*models.Artist, error) {
+	if i.useCache() {
+		return i.db.GetSimilarArtists(artist, localSimilarLimit)
+	}
+	return i.browser.GetSimilarArtists(ctx, artist)
 }
 
-func (i *Items) GetSimilarAlbums(album models.Id) ([]*models.Album, error) {
-	return i.browser.GetSimilarAlbums(album)
+// GetSimilarAlbums returns similar albums. With local cache albums are similar by genres.
+func (i *Items) GetSimilarAlbums(ctx context.Context, album models.Id) ([]*models.Album, error) {
+	if i.useCache() {
+		return i.db.GetSimilarAlbums(album, localSimilarLimit)
+	}
+	return i.browser.GetSimilarAlbums(ctx, album)
 }
 
-func (i *Items) GetGenres(paging interfaces.Paging) ([]*models.IdName, int, error) {
-	return i.browser.GetGenres(paging)
+func (i *Items) GetGenres(ctx context.Context, paging interfaces.Paging) ([]*models.IdName, int, error) {
+	if i.useCache() {
+		return i.db.GetGenres(paging)
+	}
+	return i.browser.GetGenres(ctx, paging)
 }
 
-func (i *Items) GetGenreAlbums(genre models.IdName) ([]*models.Album, error) {
+func (i *Items) GetGenreAlbums(ctx context.Context, genre models.IdName) ([]*models.Album, error) {
+	if i.useCache() {
+		return i.db.GetGenreAlbums(genre.Id)
+	}
 	query := interfaces.DefaultQueryOpts()
 	query.Filter.Genres = []models.IdName{genre}
 
-	albums, _, err := i.browser.GetAlbums(query)
+	albums, _, err := i.browser.GetAlbums(ctx, query)
 	return albums, err
 }
 
@@ -177,7 +243,14 @@ func (i *Items) GetStatistics() models.Stats {
 		logrus.Errorf("get server info: %v", err)
 	}
 
-	if i.db != nil {
+	if metrics, ok := i.browser.(api.RequestMetrics); ok {
+		stats.Requests = metrics.GetRequestStats()
+	}
+	if metrics, ok := i.browser.(api.CacheMetrics); ok {
+		stats.Cache = metrics.GetCacheStats()
+	}
+
+	if i.db != nil && config.AppConfig.Player.EnableLocalCache {
 		stats.StorageInfo, err = i.db.GetStats()
 		if err != nil {
 			logrus.Errorf("get local storage info: %v", err)
@@ -186,34 +259,53 @@ func (i *Items) GetStatistics() models.Stats {
 	return stats
 }
 
-func (i *Items) GetSongs(page, pageSize int) ([]*models.Song, int, error) {
-	if config.AppConfig.Player.EnableLocalCache {
+func (i *Items) GetSongs(ctx context.Context, page, pageSize int) ([]*models.Song, int, error) {
+	if i.useCache() {
 		return i.db.GetSongs(page, pageSize)
 	} else {
-		return i.browser.GetSongs(interfaces.DefaultQueryOpts())
+		return i.browser.GetSongs(ctx, interfaces.DefaultQueryOpts())
 	}
 }
 
-func (i *Items) GetAlbumArtist(album *models.Album) (*models.Artist, error) {
-	return i.browser.GetAlbumArtist(album)
+func (i *Items) GetAlbumArtist(ctx context.Context, album *models.Album) (*models.Artist, error) {
+	if i.useCache() {
+		return i.db.GetArtist(album.Artist)
+	}
+	return i.browser.GetAlbumArtist(ctx, album)
 }
 
-func (i *Items) GetSongArtistAlbum(song *models.Song) (*models.Album, *models.Artist, error) {
+func (i *Items) GetSongArtistAlbum(ctx context.Context, song *models.Song) (*models.Album, *models.Artist, error) {
 	id := song.AlbumArtist
 	if id == "" && len(song.Artists) > 0 {
 		id = song.Artists[0].Id
 	}
 
-	artist, err := i.browser.GetArtist(id)
+	var album *models.Album
+	var artist *models.Artist
+	var err error
+	if i.useCache() {
+		artist, err = i.db.GetArtist(id)
+		if err != nil {
+			return nil, artist, err
+		}
+		album, err = i.db.GetAlbum(song.Album)
+		return album, artist, err
+	}
+
+	artist, err = i.browser.GetArtist(ctx, id)
 	if err != nil {
 		return nil, artist, err
 	}
-	album, err := i.browser.GetAlbum(song.Album)
+	album, err = i.browser.GetAlbum(ctx, song.Album)
 	return album, artist, err
 }
 
-func (i *Items) GetInstantMix(item models.Item) ([]*models.Song, error) {
-	return i.browser.GetInstantMix(item)
+// GetInstantMix returns songs similar to item. With local cache songs are selected by genres.
+func (i *Items) GetInstantMix(ctx context.Context, item models.Item) ([]*models.Song, error) {
+	if i.useCache() {
+		return i.db.GetInstantMix(item, localInstantMixLimit)
+	}
+	return i.browser.GetInstantMix(ctx, item)
 }
 
 func (i *Items) GetLink(item models.Item) string {
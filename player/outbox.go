@@ -0,0 +1,223 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/storage"
+)
+
+// how often to try sending operations in outbox
+const outboxInterval = time.Minute
+
+// Outbox implements interfaces.OutboxController. Write operations that fail are stored in outbox
+// and sent again in the order they were added once server is reachable.
+type Outbox struct {
+	lock     *sync.Mutex
+	server   api.MediaServer
+	db       *storage.Db
+	flushing bool
+	// replayers send operations that are not sent to media server, by operation type.
+	replayers map[models.OperationType]api.Replayer
+
+	changedFuncs []func()
+}
+
+func newOutbox(server api.MediaServer, db *storage.Db) *Outbox {
+	return &Outbox{
+		lock:      &sync.Mutex{},
+		server:    server,
+		db:        db,
+		replayers: map[models.OperationType]api.Replayer{},
+	}
+}
+
+// addReplayer sets replayer for operations of given type. Other operations are sent to media server.
+func (o *Outbox) addReplayer(opType models.OperationType, replayer api.Replayer) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.replayers[opType] = replayer
+}
+
+// replayer returns replayer for operation, or nil if operation cannot be sent now.
+func (o *Outbox) replayer(op *models.Operation) api.Replayer {
+	o.lock.Lock()
+	replayer := o.replayers[op.Type]
+	o.lock.Unlock()
+	if replayer != nil {
+		return replayer
+	}
+
+	replayer, ok := o.server.(api.Replayer)
+	if !ok {
+		return nil
+	}
+	if conn, ok := o.server.(interfaces.Connection); ok && !conn.IsOnline() {
+		return nil
+	}
+	return replayer
+}
+
+func (o *Outbox) GetOperations() []*models.Operation {
+	if o.db == nil {
+		return []*models.Operation{}
+	}
+	operations, err := o.db.GetOperations()
+	if err != nil {
+		logrus.Errorf("get outbox operations: %v", err)
+	}
+	return operations
+}
+
+func (o *Outbox) DiscardOperation(id int64) error {
+	if o.db == nil {
+		return errors.New("local database is not available")
+	}
+	err := o.db.RemoveOperation(id)
+	if err != nil {
+		return err
+	}
+	o.notify()
+	return nil
+}
+
+// SetFavorite marks item as favorite or removes it from favorites. Change is saved to local database
+// and sent to server through outbox, so that it is kept until server is reachable.
+func (o *Outbox) SetFavorite(item models.Item, favorite bool) error {
+	op := models.NewFavoriteOperation(item.GetId(), item.GetType(), favorite, time.Now())
+	if o.db == nil {
+		replayer, ok := o.server.(api.Replayer)
+		if !ok {
+			return errors.New("server does not support favorites")
+		}
+		return replayer.Replay(op)
+	}
+
+	err := o.db.SetFavorite(item.GetId(), favorite)
+	if err != nil {
+		logrus.Warningf("update favorite in local database: %v", err)
+	}
+	_, err = o.db.AddOperation(op)
+	if err != nil {
+		return fmt.Errorf("add operation to outbox: %v", err)
+	}
+	o.notify()
+	go o.flush()
+	return nil
+}
+
+func (o *Outbox) AddOutboxChangedCallback(cb func()) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.changedFuncs = append(o.changedFuncs, cb)
+}
+
+// handleError stores operation to outbox if err contains one. Else err is logged.
+func (o *Outbox) handleError(err error) {
+	var opErr *api.OperationError
+	if !errors.As(err, &opErr) || o.db == nil {
+		logrus.Error(err)
+		return
+	}
+
+	logrus.Warningf("Add operation to outbox: %v", opErr)
+	opErr.Operation.Attempts = 1
+	opErr.Operation.Error = opErr.Err.Error()
+	added, err := o.db.AddOperation(opErr.Operation)
+	if err != nil {
+		logrus.Errorf("add operation to outbox: %v", err)
+	} else if added {
+		o.notify()
+	}
+}
+
+// flush sends operations in outbox to media server and scrobblers. Sending to each of them stops
+// to first failure to keep operations in order.
+func (o *Outbox) flush() {
+	if o.db == nil {
+		return
+	}
+
+	o.lock.Lock()
+	if o.flushing {
+		o.lock.Unlock()
+		return
+	}
+	o.flushing = true
+	o.lock.Unlock()
+
+	defer func() {
+		o.lock.Lock()
+		o.flushing = false
+		o.lock.Unlock()
+	}()
+
+	operations, err := o.db.GetOperations()
+	if err != nil {
+		logrus.Errorf("get outbox operations: %v", err)
+		return
+	}
+	if len(operations) == 0 {
+		return
+	}
+
+	sent := 0
+	failed := map[api.Replayer]bool{}
+	for _, op := range operations {
+		replayer := o.replayer(op)
+		if replayer == nil || failed[replayer] {
+			continue
+		}
+		err = replayer.Replay(op)
+		if err != nil {
+			logrus.Warningf("send %s %s from outbox: %v", op.Type, op.Item, err)
+			failed[replayer] = true
+			err = o.db.OperationFailed(op.Id, err)
+			if err != nil {
+				logrus.Errorf("update outbox operation: %v", err)
+			}
+			continue
+		}
+		err = o.db.RemoveOperation(op.Id)
+		if err != nil {
+			logrus.Errorf("remove outbox operation: %v", err)
+			break
+		}
+		sent += 1
+	}
+	logrus.Infof("Sent %d/%d operations from outbox", sent, len(operations))
+	o.notify()
+}
+
+func (o *Outbox) notify() {
+	o.lock.Lock()
+	funcs := o.changedFuncs
+	o.lock.Unlock()
+
+	for _, f := range funcs {
+		f()
+	}
+}
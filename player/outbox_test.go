@@ -0,0 +1,102 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/models"
+)
+
+// replayServer is offlineServer that records replayed operations.
+type replayServer struct {
+	*offlineServer
+	lock     *sync.Mutex
+	replayed []models.OperationType
+}
+
+func (r *replayServer) IsOnline() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.online
+}
+
+func (r *replayServer) setOnline(online bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.online = online
+}
+
+func (r *replayServer) Replay(op *models.Operation) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.replayed = append(r.replayed, op.Type)
+	return nil
+}
+
+func TestOutbox_SetFavorite(t *testing.T) {
+	_, db := testPlayHistory(t)
+	album := &models.Album{Id: "album-1", Name: "album 1"}
+	err := db.UpdateAlbums([]*models.Album{album})
+	if err != nil {
+		t.Fatalf("add album: %v", err)
+	}
+
+	server := &replayServer{
+		offlineServer: &offlineServer{refreshServer: &refreshServer{MockServer: api.NewMockServer()}},
+		lock:          &sync.Mutex{},
+	}
+	outbox := newOutbox(server, db)
+
+	// changes are kept in outbox while offline
+	for _, favorite := range []bool{true, false, true} {
+		err = outbox.SetFavorite(album, favorite)
+		if err != nil {
+			t.Fatalf("set favorite: %v", err)
+		}
+	}
+	operations := outbox.GetOperations()
+	if len(operations) != 3 {
+		t.Fatalf("outbox operations: got %d, want 3", len(operations))
+	}
+	saved, err := db.GetAlbum("album-1")
+	if err != nil {
+		t.Fatalf("get album: %v", err)
+	}
+	if !saved.Favorite {
+		t.Errorf("favorite not saved to local database")
+	}
+
+	server.setOnline(true)
+	deadline := time.Now().Add(time.Second * 5)
+	for len(outbox.GetOperations()) > 0 && time.Now().Before(deadline) {
+		outbox.flush()
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	want := []models.OperationType{models.OperationFavorite, models.OperationUnfavorite, models.OperationFavorite}
+	if !reflect.DeepEqual(server.replayed, want) {
+		t.Errorf("replayed operations: got %v, want %v", server.replayed, want)
+	}
+}
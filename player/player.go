@@ -21,6 +21,7 @@
 package player
 
 import (
+	"context"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"io"
@@ -43,24 +44,36 @@ type songMetadata struct {
 	format        interfaces.AudioFormat
 }
 
-// Player wraps all controllers and implements interfaces.QueueController, interfaces.Player and
-// interfaces.ItemController.
+// Player wraps all controllers and implements interfaces.QueueController, interfaces.Player,
+// interfaces.ItemController, interfaces.LibrarySync and interfaces.SyncPlay.
 type Player struct {
 	task.Task
 	*Audio
 	*Queue
 	*Items
+	*Downloads
+	*Outbox
+	*Prefetcher
+	*PlayHistory
+	*Scrobbles
+	*LibrarySync
+	*SyncPlay
+
+	songCache *SongCache
 
 	lock *sync.RWMutex
 
 	downloadingSong bool
+	radioLoading    bool
+
+	sleepTimer    interfaces.SleepTimer
+	sleepDeadline time.Time
+	sleepAlbum    models.Id
 
 	songComplete   chan bool
 	audioUpdated   chan interfaces.AudioStatus
 	songDownloaded chan songMetadata
 
-	nextSong *songMetadata
-
 	api              api.MediaServer
 	remoteController api.RemoteController
 
@@ -82,10 +95,29 @@ func NewPlayer(browser api.MediaServer) (*Player, error) {
 
 	p.Audio = newAudio()
 	p.Queue = newQueue()
+	p.Queue.getAlbum = browser.GetAlbum
+	p.Queue.getArtist = browser.GetArtist
 	p.Items, err = newItems(browser)
 	if err != nil {
 		return p, err
 	}
+	p.Downloads = newDownloads(browser, p.Items.db)
+	p.songCache = NewSongCache(p.Items.db, browser.GetId())
+	p.Outbox = newOutbox(browser, p.Items.db)
+	p.Prefetcher = newPrefetcher(p.prefetchSong)
+	p.PlayHistory = NewPlayHistory(p.Items.db)
+	p.Scrobbles = newScrobbles(p.Outbox)
+	p.LibrarySync = newLibrarySync(p.Items, browser)
+	p.SyncPlay = newSyncPlay(p, browser)
+	p.AddConnectionCallback(func(online bool) {
+		if online {
+			go p.Outbox.flush()
+			p.LibrarySync.connected()
+		}
+	})
+	if notifier, ok := browser.(api.LibraryNotifier); ok {
+		notifier.AddLibraryChangedCallback(p.LibrarySync.libraryChanged)
+	}
 	if remoteController, ok := browser.(api.RemoteController); ok {
 		p.remoteController = remoteController
 		p.remoteController.SetPlayer(p)
@@ -98,6 +130,7 @@ func NewPlayer(browser api.MediaServer) (*Player, error) {
 
 	p.Audio.songCompleteFunc = p.songCompleted
 	p.Audio.AddStatusCallback(p.audioCallback)
+	p.Audio.AddStatusCallback(p.PlayHistory.statusChanged)
 
 	p.Queue.AddQueueChangedCallback(p.queueChanged)
 	return p, nil
@@ -118,150 +151,312 @@ func (p *Player) isDownloadingSong() bool {
 func (p *Player) loop() {
 	// interval to refresh status. This is the interval gui will be updated.
 	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	outboxTicker := time.NewTicker(outboxInterval)
+	defer outboxTicker.Stop()
+	go p.Outbox.flush()
 
 	for true {
 		select {
 		case <-p.StopChan():
 			// stop application
 			p.Audio.StopMedia()
+			p.Prefetcher.clear()
+			p.PlayHistory.stop()
 			p.Items.closeDb()
-			break
+			return
 		case <-p.songComplete:
 			// stream / song complete, get next song
 			logrus.Debug("song complete")
-			p.Queue.songComplete()
-			if len(p.Queue.GetQueue()) == 0 {
+			if p.SyncPlay.syncPlaySongCompleted() {
+				// group decides next song
+				continue
+			}
+			if p.Audio.getStatus().Repeat != interfaces.RepeatOne {
+				p.completeSong()
+			}
+			queue := p.Queue.GetQueue()
+			if len(queue) == 0 {
+				p.sleepAfterSong(nil)
 				p.Audio.StopMedia()
 			} else {
-				if p.nextSong != nil {
-					err := p.Audio.playSongFromReader(*p.nextSong)
+				pause := p.sleepAfterSong(queue[0])
+				metadata, err := p.openSong(queue[0])
+				if err != nil {
+					logrus.Error(err)
+					p.Audio.StopMedia()
+				} else {
+					err = p.Audio.playSongFromReader(metadata)
 					if err != nil {
 						logrus.Errorf("play track: %v", err)
+					} else if pause {
+						p.Audio.Pause()
 					}
-					p.nextSong = nil
-				} else {
-					p.downloadSong(0)
 				}
 			}
+		case <-outboxTicker.C:
+			go p.Outbox.flush()
 		case status := <-p.audioUpdated:
 			logrus.Infof("got audio status: %v", status)
 		case <-ticker.C:
 			// periodically update status, this will push status to p.audioUpdated
+			p.updateSleep()
+			p.SyncPlay.syncPlayTick()
 			p.Audio.updateStatus()
-			if p.status.Song != nil && p.status.State == interfaces.AudioStatePlaying {
-				if (p.status.Song.Duration-p.status.SongPast.Seconds()) < 5 &&
-					!p.isDownloadingSong() && p.nextSong == nil && len(p.Queue.GetQueue()) >= 2 {
-					p.downloadSong(1)
-				}
-			}
+			p.updatePrefetch(p.Queue.GetQueue())
 		case metadata := <-p.songDownloaded:
 			if p.status.State == interfaces.AudioStateStopped {
 				// download complete, send to audio
 				err := p.Audio.playSongFromReader(metadata)
 				if err != nil {
 					logrus.Errorf("play track: %v", err)
+				} else {
+					p.SyncPlay.syncPlaySongLoaded()
 				}
-				p.nextSong = nil
 			} else {
-				p.nextSong = &metadata
+				// another song was started meanwhile
+				metadata.reader.Close()
 			}
 		}
 	}
 }
 
 // download and play next song asynchronously
-func (p *Player) downloadSong(index int) {
+func (p *Player) downloadSong() {
 	if p.isDownloadingSong() || p.Queue.empty() {
 		return
 	}
-	song := p.Queue.GetQueue()[index]
+	song := p.Queue.GetQueue()[0]
 
 	p.lock.Lock()
 	p.downloadingSong = true
 	p.lock.Unlock()
-	ok := false
 
-	reader, format, err := p.api.Stream(song)
+	metadata, err := p.openSong(song)
+
+	p.lock.Lock()
+	p.downloadingSong = false
+	p.lock.Unlock()
+
 	if err != nil {
-		if strings.Contains(err.Error(), "A task was canceled") {
-			// server task may fail sometimes, retry
-			logrus.Warningf("Failed to download song, retrying: %v", err)
-			time.Sleep(time.Second)
-			reader, format, err = p.api.Stream(song)
-			if err == nil {
-				ok = true
-			} else {
-				logrus.Errorf("retry downloading song: %v", err)
-			}
-		} else {
-			logrus.Errorf("download song: %v", err)
+		logrus.Error(err)
+		return
+	}
+	// push song to audio
+	p.songDownloaded <- metadata
+}
+
+// openSong opens song for playing, either from prefetched songs, local files or from server.
+func (p *Player) openSong(song *models.Song) (songMetadata, error) {
+	if prefetched := p.Prefetcher.take(song.Id); prefetched != nil {
+		<-prefetched.ready
+		if prefetched.err == nil {
+			logrus.Debugf("Play prefetched song %s", song.Id)
+			return prefetched.metadata, nil
 		}
-	} else {
-		ok = true
-	}
-	if ok {
-		// fill metadata
-		albumId := song.GetParent()
-		album, err := p.api.GetAlbum(albumId)
-		artist := &models.Artist{Name: "unknown artist"}
-		var imageId string
-		var imageUrl string
-		if err != nil {
-			logrus.Error("Failed to get album by id: ", err.Error())
-			album = &models.Album{Name: "unknown album"}
-		} else {
-			imageId = album.ImageId
-			imageUrl = p.api.GetImageUrl(album.Id, models.TypeAlbum)
+		logrus.Warningf("Prefetching song %s failed: %v", song.Id, prefetched.err)
+	}
+
+	reader, format, local := p.openLocal(song)
+	if local {
+		logrus.Debugf("Play song %s from local file", song.Id)
+		return p.songMetadata(song, reader, format), nil
+	}
+	if !p.IsOnline() {
+		return songMetadata{}, fmt.Errorf("song %s is not available offline", song.Id)
+	}
+
+	reader, format, err := p.api.Stream(context.Background(), song)
+	if err != nil {
+		if !strings.Contains(err.Error(), "A task was canceled") {
+			return songMetadata{}, fmt.Errorf("download song: %v", err)
 		}
-		a, err := p.api.GetArtist(album.GetParent())
+		// server task may fail sometimes, retry
+		logrus.Warningf("Failed to download song, retrying: %v", err)
+		time.Sleep(time.Second)
+		reader, format, err = p.api.Stream(context.Background(), song)
 		if err != nil {
-			logrus.Errorf("Failed to get artist by id: %v", err)
-		} else {
-			artist = a
-			f := func() {
-				metadata := songMetadata{
-					song:          song,
-					album:         album,
-					artist:        artist,
-					albumImageUrl: imageUrl,
-					albumImageId:  imageId,
-					reader:        reader,
-					format:        format,
-				}
-				p.songDownloaded <- metadata
-			}
-			defer f()
+			return songMetadata{}, fmt.Errorf("retry downloading song: %v", err)
 		}
 	}
+	reader = p.songCache.wrap(song, reader, format)
+	return p.songMetadata(song, reader, format), nil
+}
 
-	p.lock.Lock()
-	p.downloadingSong = false
-	p.lock.Unlock()
+// prefetchSong opens song for prefetching. Song is streamed without background buffering, so that
+// prefetcher alone decides how much of it is held in memory.
+func (p *Player) prefetchSong(song *models.Song) (songMetadata, bool, error) {
+	reader, format, local := p.openLocal(song)
+	if local {
+		return p.songMetadata(song, reader, format), true, nil
+	}
+	if !p.IsOnline() {
+		return songMetadata{}, false, fmt.Errorf("song %s is not available offline", song.Id)
+	}
+	reader, format, err := p.api.StreamFile(context.Background(), song)
+	if err != nil {
+		return songMetadata{}, false, fmt.Errorf("stream song: %v", err)
+	}
+	reader = p.songCache.wrap(song, reader, format)
+	return p.songMetadata(song, reader, format), false, nil
+}
 
-	// push song to audio
+// openLocal opens song from downloads or song cache. If song is not available locally, return false.
+func (p *Player) openLocal(song *models.Song) (io.ReadCloser, interfaces.AudioFormat, bool) {
+	reader, format, local := p.Downloads.openSong(song)
+	if !local {
+		reader, format, local = p.songCache.openSong(song)
+	}
+	return reader, format, local
+}
+
+// songMetadata fills album and artist for song.
+func (p *Player) songMetadata(song *models.Song, reader io.ReadCloser, format interfaces.AudioFormat) songMetadata {
+	metadata := songMetadata{
+		song:   song,
+		artist: &models.Artist{Name: "unknown artist"},
+		reader: reader,
+		format: format,
+	}
+	album, err := p.api.GetAlbum(context.Background(), song.GetParent())
+	if err != nil {
+		logrus.Error("Failed to get album by id: ", err.Error())
+		album = &models.Album{Name: "unknown album"}
+	} else {
+		metadata.albumImageId = album.ImageId
+		metadata.albumImageUrl = p.api.GetImageUrl(album.Id, models.TypeAlbum)
+	}
+	metadata.album = album
+	artist, err := p.api.GetArtist(context.Background(), album.GetParent())
+	if err != nil {
+		// song can still be played, e.g. artist might not be cached when offline
+		logrus.Errorf("Failed to get artist by id: %v", err)
+	} else {
+		metadata.artist = artist
+	}
+	return metadata
+}
+
+// updatePrefetch starts or cancels prefetching upcoming songs.
+func (p *Player) updatePrefetch(queue []*models.Song) {
+	status := p.Audio.getStatus()
+	playing := status.State == interfaces.AudioStatePlaying && status.Song != nil
+	var remaining time.Duration
+	if playing {
+		remaining = time.Duration(status.Song.Duration-status.SongPast.Seconds()) * time.Second
+	}
+	p.Prefetcher.update(queue, remaining, playing)
+}
+
+// IsOnline returns true if remote server is connected.
+func (p *Player) IsOnline() bool {
+	if conn, ok := p.api.(interfaces.Connection); ok {
+		return conn.IsOnline()
+	}
+	return true
+}
+
+// AddConnectionCallback adds a function that gets called when connection state changes.
+// If server does not support offline mode, callback never gets called.
+func (p *Player) AddConnectionCallback(cb func(online bool)) {
+	if conn, ok := p.api.(interfaces.Connection); ok {
+		conn.AddConnectionCallback(cb)
+	}
+}
+
+// GetRemoteControlStatus returns state of remote control connection to server.
+// If server has no remote control connection, it is always disconnected.
+func (p *Player) GetRemoteControlStatus() models.RemoteControlStatus {
+	if notifier, ok := p.api.(api.RemoteControlNotifier); ok {
+		return notifier.GetRemoteControlStatus()
+	}
+	return models.RemoteControlStatus{}
+}
+
+// AddRemoteControlCallback adds a function that gets called when remote control connection state changes.
+func (p *Player) AddRemoteControlCallback(cb func(status models.RemoteControlStatus)) {
+	if notifier, ok := p.api.(api.RemoteControlNotifier); ok {
+		notifier.AddRemoteControlCallback(cb)
+	}
+}
+
+// AddMessageCallback adds a function that gets called when server sends a message to display.
+func (p *Player) AddMessageCallback(cb func(msg models.ServerMessage)) {
+	if notifier, ok := p.api.(api.MessageNotifier); ok {
+		notifier.AddMessageCallback(cb)
+	}
+}
+
+// PlayPause toggles pause. In SyncPlay group, request is sent to group.
+func (p *Player) PlayPause() {
+	request := models.SyncPlayRequestPause
+	if p.Audio.getStatus().Paused {
+		request = models.SyncPlayRequestUnpause
+	}
+	if !p.SyncPlay.syncPlayControl(request, 0) {
+		p.Audio.PlayPause()
+	}
+}
+
+// Pause pauses playback. In SyncPlay group, request is sent to group.
+func (p *Player) Pause() {
+	if !p.SyncPlay.syncPlayControl(models.SyncPlayRequestPause, 0) {
+		p.Audio.Pause()
+	}
+}
+
+// Continue continues playback. In SyncPlay group, request is sent to group.
+func (p *Player) Continue() {
+	if !p.SyncPlay.syncPlayControl(models.SyncPlayRequestUnpause, 0) {
+		p.Audio.Continue()
+	}
+}
+
+// Seek seeks given ticks. In SyncPlay group, request is sent to group.
+func (p *Player) Seek(ticks interfaces.AudioTick) {
+	p.SeekTo(p.Audio.getPastTicks() + ticks)
+}
+
+// SeekTo seeks to given position. In SyncPlay group, request is sent to group.
+func (p *Player) SeekTo(position interfaces.AudioTick) {
+	if position < 0 {
+		position = 0
+	}
+	if !p.SyncPlay.syncPlayControl(models.SyncPlayRequestSeek, time.Duration(position)*time.Millisecond) {
+		p.Audio.SeekTo(position)
+	}
 }
 
 // Next plays next song from queue. Override Audio next to ensure there is track to play and download it
 func (p *Player) Next() {
+	if p.SyncPlay.syncPlayControl(models.SyncPlayRequestNext, 0) {
+		return
+	}
 	if len(p.Queue.GetQueue()) > 1 {
 		p.StopMedia()
-		p.Queue.songComplete()
-		go p.downloadSong(0)
+		p.completeSong()
+		go p.downloadSong()
 	}
 }
 
 // Previous plays previous track. Override Audio previous to ensure there is track to play and download it
 func (p *Player) Previous() {
+	if p.SyncPlay.syncPlayControl(models.SyncPlayRequestPrevious, 0) {
+		return
+	}
 	if len(p.Queue.GetHistory(10)) > 0 {
 		p.StopMedia()
 		p.Queue.playLastSong()
 		p.Audio.Previous()
-		go p.downloadSong(0)
+		go p.downloadSong()
 	}
 }
 
 // report audio status to server
 func (p *Player) audioCallback(status interfaces.AudioStatus) {
+	// scrobblers track every status update, independent of reports to server
+	p.Scrobbles.statusChanged(status)
+
 	p.lock.RLock()
 	lastTime := p.lastApiReport
 	p.lock.RUnlock()
@@ -271,6 +466,11 @@ func (p *Player) audioCallback(status interfaces.AudioStatus) {
 		return
 	}
 
+	if status.Action == interfaces.AudioActionRadioChanged {
+		// radio is local to player
+		return
+	}
+
 	if status.State == interfaces.AudioStateStopped && status.Action == interfaces.AudioActionTimeUpdate {
 		// don't report TimeUpdate if player is stopped
 		return
@@ -280,69 +480,91 @@ func (p *Player) audioCallback(status interfaces.AudioStatus) {
 	p.lastApiReport = time.Now()
 	p.lock.Unlock()
 
-	apiStatus := &interfaces.ApiPlaybackState{
-		Event:          "",
-		ItemId:         "",
-		IsPaused:       false,
-		IsMuted:        status.Muted,
-		PlaylistLength: 0,
-		Position:       status.SongPast.Seconds(),
-		Volume:         int(status.Volume),
-		Shuffle:        status.Shuffle,
-	}
-
+	var event interfaces.ApiPlaybackEvent
 	switch status.Action {
 	case interfaces.AudioActionStop:
-		apiStatus.Event = interfaces.EventStop
+		event = interfaces.EventStop
 	case interfaces.AudioActionPlay:
-		apiStatus.Event = interfaces.EventStart
+		event = interfaces.EventStart
 	case interfaces.AudioActionNext:
-		apiStatus.Event = interfaces.EventAudioTrackChange
+		event = interfaces.EventAudioTrackChange
 	case interfaces.AudioActionPrevious:
-		apiStatus.Event = interfaces.EventAudioTrackChange
+		event = interfaces.EventAudioTrackChange
 	case interfaces.AudioActionSetVolume:
-		apiStatus.Event = interfaces.EventVolumeChange
+		event = interfaces.EventVolumeChange
 	case interfaces.AudioActionTimeUpdate:
-		apiStatus.Event = interfaces.EventTimeUpdate
+		event = interfaces.EventTimeUpdate
 	case interfaces.AudioActionPlayPause:
 		if status.Paused {
-			apiStatus.Event = interfaces.EventPause
+			event = interfaces.EventPause
 		} else {
-			apiStatus.Event = interfaces.EventUnpause
+			event = interfaces.EventUnpause
 		}
 	case interfaces.AudioActionShuffleChanged:
-		apiStatus.Event = interfaces.EventShuffleModeChange
+		event = interfaces.EventShuffleModeChange
+	case interfaces.AudioActionRepeatChanged:
+		event = interfaces.EventRepeatModeChange
+	case interfaces.AudioActionSeek:
+		event = interfaces.EventTimeUpdate
 	default:
-		apiStatus.Event = interfaces.EventTimeUpdate
+		event = interfaces.EventTimeUpdate
 		logrus.Warningf("cannot map audio state to browser event: %v", status.Action)
 	}
 
+	go p.reportPlayback(p.playbackState(status, event))
+}
+
+// playbackState returns state to report to server.
+func (p *Player) playbackState(status interfaces.AudioStatus, event interfaces.ApiPlaybackEvent) *interfaces.ApiPlaybackState {
+	state := &interfaces.ApiPlaybackState{
+		Event:    event,
+		IsPaused: status.Paused,
+		IsMuted:  status.Muted,
+		Position: status.SongPast,
+		Volume:   int(status.Volume),
+		Shuffle:  status.Shuffle,
+		Repeat:   status.Repeat,
+	}
+
 	songs := p.GetQueue()
-	queue := make([]models.Id, len(songs))
+	state.Queue = make([]models.Id, len(songs))
 	for i, v := range songs {
-		queue[i] = v.Id
+		state.Queue[i] = v.Id
 	}
-	apiStatus.Queue = queue
-	apiStatus.IsPaused = status.Paused
-
 	if status.Song != nil {
-		apiStatus.ItemId = status.Song.Id.String()
-		apiStatus.PlaylistLength = status.Song.Duration
+		state.ItemId = status.Song.Id.String()
+		state.PlaylistLength = status.Song.Duration
 	}
-	f := func() {
-		err := p.browser.ReportProgress(apiStatus)
-		if err != nil {
-			logrus.Errorf("report audio progress to server: %v", err)
-		}
+	return state
+}
+
+func (p *Player) reportPlayback(state *interfaces.ApiPlaybackState) {
+	err := p.browser.ReportProgress(state)
+	if err != nil {
+		p.Outbox.handleError(fmt.Errorf("report audio progress to server: %w", err))
 	}
-	go f()
+}
+
+// Stop stops player. Ongoing playback is reported stopped before returning,
+// so that server gets final position before application exits.
+func (p *Player) Stop() error {
+	status := p.Audio.getStatus()
+	if status.Song != nil && status.State != interfaces.AudioStateStopped {
+		status.SongPast = p.Audio.getPastTicks()
+		p.reportPlayback(p.playbackState(status, interfaces.EventStop))
+	}
+	return p.Task.Stop()
 }
 
 func (p *Player) queueChanged(queue []*models.Song) {
+	// cancel prefetches for songs that were removed or reordered
+	p.updatePrefetch(queue)
+	go p.fillRadio()
+
 	// if player has nothing to play, start download
 	state := p.Audio.getStatus()
 	if state.State == interfaces.AudioStateStopped && len(queue) > 0 {
-		go p.downloadSong(0)
+		go p.downloadSong()
 	}
 }
 
@@ -360,6 +582,14 @@ func (p *Player) Reorder(index int, left bool) bool {
 	return p.Queue.Reorder(index, left)
 }
 
+// completeSong moves current song to history. With RepeatAll song is added back to the end of queue.
+func (p *Player) completeSong() {
+	song := p.Queue.songComplete()
+	if song != nil && p.Audio.getStatus().Repeat == interfaces.RepeatAll {
+		p.Queue.repeatSong(song)
+	}
+}
+
 func (p *Player) SetShuffle(enabled bool) {
 	p.Queue.SetShuffle(enabled)
 	p.Audio.SetShuffle(enabled)
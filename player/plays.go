@@ -0,0 +1,117 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package player
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"sync"
+	"time"
+	"tryffel.net/go/jellycli/api/scrobbler"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/storage"
+)
+
+// number of top artists, albums and songs in listening statistics
+const listeningStatsTop = 10
+
+// PlayHistory records every song that is played to local database and provides listening statistics
+// from the recorded plays. Plays are recorded regardless of remote server.
+type PlayHistory struct {
+	lock    *sync.Mutex
+	db      *storage.Db
+	tracker *playTracker
+}
+
+// NewPlayHistory initializes new play history. Db can be nil, in which case plays are not recorded.
+func NewPlayHistory(db *storage.Db) *PlayHistory {
+	return &PlayHistory{
+		lock:    &sync.Mutex{},
+		db:      db,
+		tracker: newPlayTracker(),
+	}
+}
+
+// GetListeningStats returns listening statistics for plays since given time. Zero time returns
+// statistics for all plays.
+func (h *PlayHistory) GetListeningStats(since time.Time) (*models.ListeningStats, error) {
+	if h.db == nil {
+		return nil, errors.New("local database is not available")
+	}
+	return h.db.GetListeningStats(since, listeningStatsTop)
+}
+
+// statusChanged tracks currently playing song. Each song that is started creates new play,
+// which is stored once song changes or player stops.
+func (h *PlayHistory) statusChanged(status interfaces.AudioStatus) {
+	h.lock.Lock()
+	finished, _ := h.tracker.update(status)
+	h.lock.Unlock()
+	h.save(finished)
+}
+
+// stop stores current play, if any.
+func (h *PlayHistory) stop() {
+	h.lock.Lock()
+	play := h.tracker.stop()
+	h.lock.Unlock()
+	h.save(play)
+}
+
+func (h *PlayHistory) save(play *models.Play) {
+	if play == nil || h.db == nil {
+		return
+	}
+	if play.Played < time.Second {
+		// song was never actually played
+		return
+	}
+	err := h.db.AddPlay(play)
+	if err != nil {
+		logrus.Errorf("save play to history: %v", err)
+	}
+}
+
+// ExportListenBrainz writes plays since given time to w in ListenBrainz listen import format.
+// Only plays that count as listens are exported. Returns number of exported listens.
+func (h *PlayHistory) ExportListenBrainz(w io.Writer, since time.Time) (int, error) {
+	if h.db == nil {
+		return 0, errors.New("local database is not available")
+	}
+	plays, err := h.db.GetPlays(since)
+	if err != nil {
+		return 0, err
+	}
+
+	listens := scrobbler.ListenBrainzListens{
+		ListenType: "import",
+		Payload:    make([]scrobbler.ListenBrainzListen, 0, len(plays)),
+	}
+	for _, v := range plays {
+		if v.Listened() && v.ArtistName != "" && v.SongName != "" {
+			listens.Payload = append(listens.Payload, scrobbler.NewListenBrainzListen(v))
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return len(listens.Payload), encoder.Encode(listens)
+}
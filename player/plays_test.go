@@ -0,0 +1,149 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package player
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/api/scrobbler"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/storage"
+)
+
+func testPlayHistory(t *testing.T) (*PlayHistory, *storage.Db) {
+	oldConfig := config.AppConfig
+	config.AppConfig = &config.Config{Player: config.Player{LocalCacheDir: t.TempDir()}}
+	t.Cleanup(func() {
+		config.AppConfig = oldConfig
+	})
+
+	db, err := storage.NewDb("test-123")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return NewPlayHistory(db), db
+}
+
+func TestPlayHistory_statusChanged(t *testing.T) {
+	history, db := testPlayHistory(t)
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	history.tracker.now = func() time.Time { return now }
+
+	songs := []*models.Song{
+		{Id: "song-1", Name: "Song 1", Duration: 30, Album: "album-1",
+			Artists: []models.IdName{{Id: "artist-1", Name: "Artist 1"}}},
+		{Id: "song-2", Name: "Song 2", Duration: 300, Album: "album-1",
+			Artists: []models.IdName{{Id: "artist-1", Name: "Artist 1"}}},
+	}
+	album := &models.Album{Id: "album-1", Name: "Album 1"}
+
+	update := func(song *models.Song, action interfaces.AudioAction, past int, paused bool) {
+		state := interfaces.AudioStatePlaying
+		if song == nil {
+			state = interfaces.AudioStateStopped
+		}
+		history.statusChanged(interfaces.AudioStatus{
+			State:    state,
+			Action:   action,
+			Song:     song,
+			Album:    album,
+			SongPast: interfaces.AudioTick(past * 1000),
+			Paused:   paused,
+		})
+	}
+
+	// play first song to end, pause in middle of song
+	update(songs[0], interfaces.AudioActionPlay, 0, false)
+	for i := 1; i <= 29; i++ {
+		now = now.Add(time.Second)
+		update(songs[0], interfaces.AudioActionTimeUpdate, i, i >= 10 && i < 20)
+	}
+	// skip second song
+	update(songs[1], interfaces.AudioActionPlay, 0, false)
+	now = now.Add(time.Second * 20)
+	update(songs[1], interfaces.AudioActionTimeUpdate, 20, false)
+	update(nil, interfaces.AudioActionStop, 0, false)
+
+	plays, err := db.GetPlays(time.Time{})
+	if err != nil {
+		t.Fatalf("get plays: %v", err)
+	}
+	if len(plays) != 2 {
+		t.Fatalf("invalid plays: %d, want: 2", len(plays))
+	}
+
+	first := plays[0]
+	if first.Song != "song-1" || !first.Completed || first.Played != 19*time.Second || first.ArtistName != "Artist 1" ||
+		first.AlbumName != "Album 1" {
+		t.Errorf("invalid completed play: %v", first)
+	}
+	if !first.Started.Equal(time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("invalid start time: %s", first.Started)
+	}
+	second := plays[1]
+	if second.Song != "song-2" || second.Completed || second.Played != 20*time.Second {
+		t.Errorf("invalid skipped play: %v", second)
+	}
+}
+
+func TestPlayHistory_ExportListenBrainz(t *testing.T) {
+	history, db := testPlayHistory(t)
+	started := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	plays := []*models.Play{
+		{Song: "song-1", SongName: "Song 1", AlbumName: "Album 1", ArtistName: "Artist 1", Duration: 200,
+			Started: started, Played: 150 * time.Second, Completed: true},
+		{Song: "song-2", SongName: "Song 2", AlbumName: "Album 1", ArtistName: "Artist 1", Duration: 200,
+			Started: started.Add(time.Minute * 3), Played: 20 * time.Second},
+	}
+	for _, v := range plays {
+		err := db.AddPlay(v)
+		if err != nil {
+			t.Fatalf("add play: %v", err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	n, err := history.ExportListenBrainz(buf, time.Time{})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("invalid exported listens: %d, want: 1", n)
+	}
+
+	got := scrobbler.ListenBrainzListens{}
+	err = json.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("parse export: %v", err)
+	}
+	if got.ListenType != "import" || len(got.Payload) != 1 {
+		t.Fatalf("invalid export: %v", got)
+	}
+	listen := got.Payload[0]
+	if listen.ListenedAt != started.Unix() || listen.TrackMetadata.TrackName != "Song 1" ||
+		listen.TrackMetadata.ArtistName != "Artist 1" || listen.TrackMetadata.ReleaseName != "Album 1" {
+		t.Errorf("invalid listen: %v", listen)
+	}
+}
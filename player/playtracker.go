@@ -0,0 +1,116 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package player
+
+import (
+	"time"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+// if song is stopped this close to its end, it is considered completed
+const playCompletedMargin = 5 * time.Second
+
+// playTracker tracks currently playing song from audio status updates and measures how long
+// it has actually been played, excluding pauses. Tracker is not safe for concurrent use.
+type playTracker struct {
+	now func() time.Time
+
+	// current is play that is ongoing, or nil
+	current *models.Play
+	// position is latest known position in current song
+	position time.Duration
+	// playing is true if current song was playing on latest status update
+	playing    bool
+	lastUpdate time.Time
+}
+
+func newPlayTracker() *playTracker {
+	return &playTracker{now: time.Now}
+}
+
+// update tracker with status. If play ends, it is returned. Started is true if new play was started.
+func (t *playTracker) update(status interfaces.AudioStatus) (finished *models.Play, started bool) {
+	t.addPlayed()
+
+	if status.State == interfaces.AudioStateStopped || status.Song == nil {
+		finished = t.finish()
+	} else if t.current == nil || status.Action == interfaces.AudioActionPlay || t.current.Song != status.Song.Id {
+		finished = t.finish()
+		t.start(status)
+		started = true
+	}
+	t.playing = status.State == interfaces.AudioStatePlaying && !status.Paused
+	if t.current != nil {
+		t.position = time.Duration(status.SongPast.Seconds()) * time.Second
+	}
+	return
+}
+
+// stop ends current play and returns it, if any.
+func (t *playTracker) stop() *models.Play {
+	t.addPlayed()
+	return t.finish()
+}
+
+// add time since last update to current play if it was playing.
+func (t *playTracker) addPlayed() {
+	now := t.now()
+	if t.current != nil && t.playing {
+		t.current.Played += now.Sub(t.lastUpdate)
+	}
+	t.lastUpdate = now
+}
+
+// start new play from status.
+func (t *playTracker) start(status interfaces.AudioStatus) {
+	song := status.Song
+	play := &models.Play{
+		Song:     song.Id,
+		SongName: song.Name,
+		Album:    song.Album,
+		Artist:   song.AlbumArtist,
+		Duration: song.Duration,
+		Started:  t.now().Add(-time.Duration(status.SongPast.Seconds()) * time.Second),
+	}
+	if status.Album != nil {
+		play.AlbumName = status.Album.Name
+	}
+	if status.Artist != nil {
+		play.Artist = status.Artist.Id
+		play.ArtistName = status.Artist.Name
+	} else if len(song.Artists) > 0 {
+		play.Artist = song.Artists[0].Id
+		play.ArtistName = song.Artists[0].Name
+	}
+	t.current = play
+	t.position = 0
+}
+
+// finish ends current play and returns it.
+func (t *playTracker) finish() *models.Play {
+	play := t.current
+	if play == nil {
+		return nil
+	}
+	t.current = nil
+	t.playing = false
+	duration := time.Duration(play.Duration) * time.Second
+	play.Completed = duration > 0 && t.position+playCompletedMargin >= duration
+	return play
+}
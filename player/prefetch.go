@@ -0,0 +1,354 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+// read size when filling prefetch buffer
+const prefetchChunkSize = 64 * 1024
+
+var errPrefetchCancelled = errors.New("prefetch cancelled")
+
+// prefetchOpenFunc opens song for prefetching. It returns true if song is read from local disk.
+type prefetchOpenFunc func(song *models.Song) (songMetadata, bool, error)
+
+// Prefetcher downloads upcoming songs in queue to memory before they are played.
+// All songs being prefetched share a single memory budget.
+type Prefetcher struct {
+	lock *sync.Mutex
+	// cond signals changes in any prefetch buffer
+	cond *sync.Cond
+
+	open prefetchOpenFunc
+	// number of songs to prefetch
+	depth int
+	// how long before end of current song to start prefetching
+	lead time.Duration
+	// budget is max bytes to hold in memory, used is bytes currently held
+	budget int64
+	used   int64
+
+	entries map[models.Id]*prefetch
+
+	changedFuncs []func()
+	lastNotify   time.Time
+}
+
+func newPrefetcher(open prefetchOpenFunc) *Prefetcher {
+	f := &Prefetcher{
+		lock:    &sync.Mutex{},
+		open:    open,
+		depth:   config.AppConfig.Player.PrefetchSongs,
+		lead:    time.Duration(config.AppConfig.Player.PrefetchLeadS) * time.Second,
+		budget:  int64(config.AppConfig.Player.PrefetchBufferMb) * 1024 * 1024,
+		entries: map[models.Id]*prefetch{},
+	}
+	f.cond = sync.NewCond(f.lock)
+	return f
+}
+
+// prefetch is a single song being prefetched.
+type prefetch struct {
+	song     *models.Song
+	metadata songMetadata
+	// buffer is nil for local songs and before song has been opened
+	buffer *prefetchBuffer
+	local  bool
+	err    error
+	// taken is set when song has been handed to player
+	taken     bool
+	cancelled bool
+	// ready is closed once song has been opened
+	ready chan struct{}
+}
+
+// GetSongBuffers returns buffering status for songs that are being prefetched.
+func (f *Prefetcher) GetSongBuffers() map[models.Id]interfaces.SongBuffer {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	buffers := make(map[models.Id]interfaces.SongBuffer, len(f.entries))
+	for id, entry := range f.entries {
+		buffer := interfaces.SongBuffer{State: interfaces.BufferLoading, Size: -1}
+		if entry.err != nil {
+			buffer.State = interfaces.BufferFailed
+		} else if entry.local {
+			buffer.State = interfaces.BufferLocal
+		} else if entry.buffer != nil {
+			buffer.Buffered = entry.buffer.buffered
+			buffer.Size = entry.buffer.size
+			if entry.buffer.err == io.EOF {
+				buffer.State = interfaces.BufferReady
+			} else if entry.buffer.err != nil {
+				buffer.State = interfaces.BufferFailed
+			}
+		}
+		buffers[id] = buffer
+	}
+	return buffers
+}
+
+// AddBufferChangedCallback adds a function that is called every time buffering status changes.
+func (f *Prefetcher) AddBufferChangedCallback(cb func()) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.changedFuncs = append(f.changedFuncs, cb)
+}
+
+// update starts prefetching upcoming songs once current song has less than lead time remaining,
+// and cancels prefetches for songs that are no longer upcoming. First song in queue is the one being played.
+func (f *Prefetcher) update(queue []*models.Song, remaining time.Duration, playing bool) {
+	if f.depth <= 0 {
+		return
+	}
+	upcoming := queue
+	if len(upcoming) > f.depth+1 {
+		upcoming = upcoming[:f.depth+1]
+	}
+
+	changed := false
+	f.lock.Lock()
+	for id, entry := range f.entries {
+		wanted := false
+		for _, song := range upcoming {
+			if song.Id == id {
+				wanted = true
+				break
+			}
+		}
+		if !wanted {
+			delete(f.entries, id)
+			f.cancel(entry)
+			changed = true
+		}
+	}
+	if playing && remaining <= f.lead && len(upcoming) > 1 {
+		for _, song := range upcoming[1:] {
+			if _, ok := f.entries[song.Id]; ok {
+				continue
+			}
+			entry := &prefetch{song: song, ready: make(chan struct{})}
+			f.entries[song.Id] = entry
+			go f.load(entry)
+			changed = true
+		}
+	}
+	f.lock.Unlock()
+	if changed {
+		f.notify()
+	}
+}
+
+// clear cancels all prefetches.
+func (f *Prefetcher) clear() {
+	f.lock.Lock()
+	for id, entry := range f.entries {
+		delete(f.entries, id)
+		f.cancel(entry)
+	}
+	f.lock.Unlock()
+	f.notify()
+}
+
+// take returns prefetched song and removes it from prefetcher, or nil if song is not being prefetched.
+// Caller must wait for ready before using the song. Taken song no longer counts against the memory budget.
+func (f *Prefetcher) take(song models.Id) *prefetch {
+	f.lock.Lock()
+	entry := f.entries[song]
+	if entry != nil {
+		delete(f.entries, song)
+		entry.taken = true
+		if entry.buffer != nil {
+			entry.buffer.detach()
+		}
+	}
+	f.lock.Unlock()
+	if entry != nil {
+		f.notify()
+	}
+	return entry
+}
+
+// cancel prefetch. Lock must be held.
+func (f *Prefetcher) cancel(entry *prefetch) {
+	entry.cancelled = true
+	if entry.buffer != nil {
+		entry.buffer.close()
+	} else if entry.metadata.reader != nil {
+		entry.metadata.reader.Close()
+	}
+}
+
+// load opens song and starts filling buffer for it.
+func (f *Prefetcher) load(entry *prefetch) {
+	metadata, local, err := f.open(entry.song)
+
+	f.lock.Lock()
+	if err == nil && entry.cancelled {
+		metadata.reader.Close()
+		err = errPrefetchCancelled
+	}
+	entry.metadata = metadata
+	entry.local = local
+	entry.err = err
+	if err == nil && !local {
+		entry.buffer = &prefetchBuffer{
+			prefetcher: f,
+			reader:     metadata.reader,
+			size:       -1,
+			detached:   entry.taken,
+		}
+		if sized, ok := metadata.reader.(interface{ Size() int64 }); ok {
+			entry.buffer.size = sized.Size()
+		}
+		entry.metadata.reader = entry.buffer
+		go entry.buffer.fill()
+	}
+	f.lock.Unlock()
+	close(entry.ready)
+	f.notify()
+}
+
+func (f *Prefetcher) notify() {
+	f.lock.Lock()
+	f.lastNotify = time.Now()
+	funcs := f.changedFuncs
+	f.lock.Unlock()
+
+	for _, cb := range funcs {
+		cb()
+	}
+}
+
+// prefetchBuffer is an in-memory buffer that is filled in background. Reading blocks until there is data
+// available or stream has ended. All fields are protected by Prefetcher lock.
+type prefetchBuffer struct {
+	prefetcher *Prefetcher
+	reader     io.ReadCloser
+	// data contains unread bytes
+	data []byte
+	// size is expected size or -1, buffered is total bytes read from stream
+	size     int64
+	buffered int64
+	// err is stream error, io.EOF when stream is complete
+	err    error
+	closed bool
+	// detached buffer does not count against memory budget
+	detached bool
+}
+
+// fill reads stream until it is complete or buffer is closed.
+func (b *prefetchBuffer) fill() {
+	f := b.prefetcher
+	chunk := make([]byte, prefetchChunkSize)
+	defer b.reader.Close()
+	for {
+		n, err := b.reader.Read(chunk)
+
+		f.lock.Lock()
+		for n > 0 && !b.closed && !b.detached && f.used > 0 && f.used+int64(n) > f.budget {
+			// wait for other buffers to be read
+			f.cond.Wait()
+		}
+		if b.closed {
+			f.lock.Unlock()
+			return
+		}
+		if n > 0 {
+			b.data = append(b.data, chunk[:n]...)
+			b.buffered += int64(n)
+			if !b.detached {
+				f.used += int64(n)
+			}
+		}
+		if err != nil {
+			b.err = err
+		}
+		notify := err != nil || time.Since(f.lastNotify) > time.Millisecond*500
+		f.cond.Broadcast()
+		f.lock.Unlock()
+
+		if notify {
+			f.notify()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (b *prefetchBuffer) Read(p []byte) (int, error) {
+	f := b.prefetcher
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for len(b.data) == 0 && b.err == nil && !b.closed {
+		f.cond.Wait()
+	}
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if len(b.data) == 0 {
+		return 0, b.err
+	}
+
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	if len(b.data) == 0 {
+		b.data = nil
+	}
+	if !b.detached {
+		f.used -= int64(n)
+	}
+	f.cond.Broadcast()
+	return n, nil
+}
+
+func (b *prefetchBuffer) Close() error {
+	b.prefetcher.lock.Lock()
+	defer b.prefetcher.lock.Unlock()
+	b.close()
+	return nil
+}
+
+// close releases buffer. Lock must be held.
+func (b *prefetchBuffer) close() {
+	if b.closed {
+		return
+	}
+	b.detach()
+	b.closed = true
+	b.data = nil
+}
+
+// detach buffer from memory budget. Lock must be held.
+func (b *prefetchBuffer) detach() {
+	if b.detached {
+		return
+	}
+	b.prefetcher.used -= int64(len(b.data))
+	b.detached = true
+	b.prefetcher.cond.Broadcast()
+}
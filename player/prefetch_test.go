@@ -0,0 +1,157 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+func testPrefetcher(depth int, budget int64, data map[models.Id][]byte) *Prefetcher {
+	open := func(song *models.Song) (songMetadata, bool, error) {
+		reader := ioutil.NopCloser(bytes.NewReader(data[song.Id]))
+		return songMetadata{song: song, reader: reader, format: interfaces.AudioFormatMp3}, false, nil
+	}
+	f := &Prefetcher{
+		lock:    &sync.Mutex{},
+		open:    open,
+		depth:   depth,
+		lead:    time.Second * 30,
+		budget:  budget,
+		entries: map[models.Id]*prefetch{},
+	}
+	f.cond = sync.NewCond(f.lock)
+	return f
+}
+
+func TestPrefetcher(t *testing.T) {
+	songs := testSongs()
+	data := map[models.Id][]byte{}
+	for i, v := range songs {
+		data[v.Id] = bytes.Repeat([]byte{byte(i)}, prefetchChunkSize*3)
+	}
+	f := testPrefetcher(2, prefetchChunkSize*2, data)
+
+	f.update(songs, time.Minute, true)
+	if len(f.GetSongBuffers()) != 0 {
+		t.Errorf("prefetch started before lead time")
+	}
+	f.update(songs, time.Second*10, false)
+	if len(f.GetSongBuffers()) != 0 {
+		t.Errorf("prefetch started when not playing")
+	}
+
+	f.update(songs, time.Second*10, true)
+	buffers := f.GetSongBuffers()
+	if len(buffers) != 2 {
+		t.Fatalf("expected 2 prefetches, got %d", len(buffers))
+	}
+	for _, id := range []models.Id{songs[1].Id, songs[2].Id} {
+		if _, ok := buffers[id]; !ok {
+			t.Errorf("song %s is not prefetched", id)
+		}
+	}
+
+	// song 3 is no longer upcoming and gets cancelled
+	reordered := []*models.Song{songs[0], songs[1], songs[4], songs[2]}
+	f.update(reordered, time.Second*10, false)
+	buffers = f.GetSongBuffers()
+	if _, ok := buffers[songs[2].Id]; ok {
+		t.Errorf("prefetch for removed song was not cancelled")
+	}
+
+	entry := f.take(songs[1].Id)
+	if entry == nil {
+		t.Fatalf("take prefetched song: not found")
+	}
+	<-entry.ready
+	if entry.err != nil {
+		t.Fatalf("prefetch song: %v", entry.err)
+	}
+	got, err := ioutil.ReadAll(entry.metadata.reader)
+	if err != nil {
+		t.Fatalf("read prefetched song: %v", err)
+	}
+	if !bytes.Equal(got, data[songs[1].Id]) {
+		t.Errorf("prefetched song content does not match")
+	}
+	entry.metadata.reader.Close()
+
+	f.clear()
+	if len(f.GetSongBuffers()) != 0 {
+		t.Errorf("prefetches not cleared")
+	}
+	f.lock.Lock()
+	used := f.used
+	f.lock.Unlock()
+	if used != 0 {
+		t.Errorf("memory budget not released, %d B in use", used)
+	}
+}
+
+func TestPrefetcher_slowStream(t *testing.T) {
+	data := bytes.Repeat([]byte("audio"), 32000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		// send song in parts, with pauses between them
+		for i := 0; i < len(data); i += len(data) / 5 {
+			w.Write(data[i : i+len(data)/5])
+			w.(http.Flusher).Flush()
+			time.Sleep(time.Millisecond * 50)
+		}
+	}))
+	defer server.Close()
+
+	songs := testSongs()
+	f := testPrefetcher(1, prefetchChunkSize, nil)
+	f.open = func(song *models.Song) (songMetadata, bool, error) {
+		download, err := api.NewFileDownload(context.Background(), server.URL, nil, nil, server.Client())
+		if err != nil {
+			return songMetadata{}, false, err
+		}
+		return songMetadata{song: song, reader: download, format: interfaces.AudioFormatMp3}, false, nil
+	}
+	f.update(songs, time.Second*10, true)
+
+	entry := f.take(songs[1].Id)
+	if entry == nil {
+		t.Fatalf("take prefetched song: not found")
+	}
+	<-entry.ready
+	if entry.err != nil {
+		t.Fatalf("prefetch song: %v", entry.err)
+	}
+	got, err := ioutil.ReadAll(entry.metadata.reader)
+	if err != nil {
+		t.Fatalf("read prefetched song: %v", err)
+	}
+	entry.metadata.reader.Close()
+	if len(got) != len(data) {
+		t.Errorf("prefetched song is incomplete: got %d B, want %d B", len(got), len(data))
+	}
+}
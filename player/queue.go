@@ -19,11 +19,15 @@
 package player
 
 import (
+	"context"
+	"errors"
 	"github.com/sirupsen/logrus"
 	"math/rand"
 	"sort"
+	"strings"
 	"sync"
 	"time"
+	"tryffel.net/go/jellycli/config"
 	"tryffel.net/go/jellycli/interfaces"
 	"tryffel.net/go/jellycli/models"
 )
@@ -35,7 +39,7 @@ type queueItem struct {
 	// index is original priority, which is len(queue) at insertion time.
 	index int
 
-	// priority is random number between 0-len(queue).
+	// priority is position in shuffled queue.
 	priority int
 }
 
@@ -48,6 +52,9 @@ type queueList struct {
 
 	// is shuffling enabled
 	shuffle bool
+	// shuffleMode is one of config.Shuffle*
+	shuffleMode string
+	rand        *rand.Rand
 }
 
 func (q *queueList) Less(i, j int) bool {
@@ -64,9 +71,14 @@ func (q *queueList) Swap(i, j int) {
 
 func newQueueList() *queueList {
 	q := &queueList{
-		maxIndex: 0,
-		shuffle:  false,
-		items:    make([]*queueItem, 0),
+		maxIndex:    0,
+		shuffle:     false,
+		items:       make([]*queueItem, 0),
+		shuffleMode: config.ShuffleRandom,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if config.AppConfig != nil {
+		q.shuffleMode = config.AppConfig.Player.ShuffleMode
 	}
 	return q
 }
@@ -84,8 +96,9 @@ func (q *queueList) SetShuffling(enable bool) {
 	if enable && len(q.items) > 0 {
 		// make sure 1st stays 1st after shuffling
 		q.items[0].priority = 0
-		for _, v := range q.items[1:] {
-			v.priority = rand.Int()
+		shuffleItems(q.shuffleMode, q.items[1:], q.rand)
+		for i, v := range q.items[1:] {
+			v.priority = i + 1
 		}
 	}
 	sort.Sort(q)
@@ -106,7 +119,7 @@ func (q *queueList) Clear(first bool) {
 
 func (q *queueList) AddSong(song *models.Song, playNext bool, playFirst bool) {
 	index := q.maxIndex
-	priority := rand.Int()
+	priority := 0
 	needsSort := false
 
 	if len(q.items) == 0 {
@@ -121,6 +134,10 @@ func (q *queueList) AddSong(song *models.Song, playNext bool, playFirst bool) {
 	} else {
 		// normal insertion
 	}
+	if len(q.items) > 0 && q.shuffle && !playFirst {
+		// add to end of shuffled queue
+		priority = q.items[len(q.items)-1].priority + 1
+	}
 
 	item := &queueItem{
 		song:     song,
@@ -199,6 +216,146 @@ func (q *queueList) Reorder(index1 int, down bool) {
 	}
 }
 
+// validIndices returns unique indices in ascending order, excluding first item and invalid indices.
+func (q *queueList) validIndices(indices []int) []int {
+	valid := make([]int, 0, len(indices))
+	seen := map[int]bool{}
+	for _, v := range indices {
+		if v < 1 || v >= len(q.items) || seen[v] {
+			continue
+		}
+		seen[v] = true
+		valid = append(valid, v)
+	}
+	sort.Ints(valid)
+	return valid
+}
+
+// setOrder sets items in given order and updates their sort keys to match the order.
+func (q *queueList) setOrder(items []*queueItem) {
+	q.items = items
+	for i, v := range items {
+		if q.shuffle {
+			v.priority = i
+		} else {
+			v.index = i
+		}
+	}
+	if !q.shuffle {
+		q.maxIndex = len(items)
+	}
+}
+
+// Move moves items in indices to given index. Items keep their relative order.
+// Index is position of first moved item after moving. First item cannot be moved.
+func (q *queueList) Move(indices []int, index int) bool {
+	indices = q.validIndices(indices)
+	if len(indices) == 0 {
+		return false
+	}
+
+	moved := make([]*queueItem, 0, len(indices))
+	rest := make([]*queueItem, 0, len(q.items)-len(indices))
+	for i, v := range q.items {
+		if len(moved) < len(indices) && indices[len(moved)] == i {
+			moved = append(moved, v)
+		} else {
+			rest = append(rest, v)
+		}
+	}
+
+	if index < 1 {
+		index = 1
+	}
+	if index > len(rest) {
+		index = len(rest)
+	}
+	items := make([]*queueItem, 0, len(q.items))
+	items = append(items, rest[:index]...)
+	items = append(items, moved...)
+	items = append(items, rest[index:]...)
+	q.setOrder(items)
+	return true
+}
+
+// RemoveSongs removes songs in indices, first song cannot be removed. Returns number of removed songs.
+func (q *queueList) RemoveSongs(indices []int) int {
+	indices = q.validIndices(indices)
+	if len(indices) == 0 {
+		return 0
+	}
+	items := make([]*queueItem, 0, len(q.items)-len(indices))
+	for i, v := range q.items {
+		if len(indices) > 0 && indices[0] == i {
+			indices = indices[1:]
+			continue
+		}
+		items = append(items, v)
+	}
+	removed := len(q.items) - len(items)
+	q.items = items
+	return removed
+}
+
+// SortSongs sorts songs after first song.
+func (q *queueList) SortSongs(less func(a, b *models.Song) bool) {
+	if len(q.items) < 3 {
+		return
+	}
+	items := make([]*queueItem, len(q.items))
+	copy(items, q.items)
+	upcoming := items[1:]
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		return less(upcoming[i].song, upcoming[j].song)
+	})
+	q.setOrder(items)
+}
+
+// RemoveDuplicates removes songs that exist earlier in queue. Returns number of removed songs.
+func (q *queueList) RemoveDuplicates() int {
+	seen := map[models.Id]bool{}
+	duplicates := make([]int, 0)
+	for i, v := range q.items {
+		if seen[v.song.Id] {
+			duplicates = append(duplicates, i)
+		}
+		seen[v.song.Id] = true
+	}
+	return q.RemoveSongs(duplicates)
+}
+
+// queueSnapshot is a copy of queue state for undoing queue edits.
+type queueSnapshot struct {
+	items    []queueItem
+	maxIndex int
+}
+
+func (q *queueList) snapshot() queueSnapshot {
+	s := queueSnapshot{
+		items:    make([]queueItem, len(q.items)),
+		maxIndex: q.maxIndex,
+	}
+	for i, v := range q.items {
+		s.items[i] = *v
+	}
+	return s
+}
+
+func (q *queueList) restore(s queueSnapshot) {
+	q.items = make([]*queueItem, len(s.items))
+	for i := range s.items {
+		item := s.items[i]
+		q.items[i] = &item
+	}
+	q.maxIndex = s.maxIndex
+}
+
+// max number of queue edits to keep for undo
+const queueUndoLimit = 20
+
+// how long to wait for album and artist names when sorting queue
+const queueSortTimeout = time.Second * 30
+
 // Queue implements interfaces.QueueController
 type Queue struct {
 	lock               sync.RWMutex
@@ -206,6 +363,17 @@ type Queue struct {
 	history            []*models.Song
 	queueUpdatedFunc   []func([]*models.Song)
 	historyUpdatedFunc func([]*models.Song)
+
+	// undo contains queue states before latest edits, latest being last.
+	undo []queueSnapshot
+
+	// getAlbum and getArtist resolve names for sorting queue, if set.
+	getAlbum  func(ctx context.Context, id models.Id) (*models.Album, error)
+	getArtist func(ctx context.Context, id models.Id) (*models.Artist, error)
+	// cancelSort cancels sort in progress, if any.
+	cancelSort context.CancelFunc
+	// submit runs sorting, default is to run it in background
+	submit func(func())
 }
 
 func newQueue() *Queue {
@@ -213,6 +381,9 @@ func newQueue() *Queue {
 		list:             newQueueList(),
 		history:          []*models.Song{},
 		queueUpdatedFunc: make([]func([]*models.Song), 0),
+		submit: func(f func()) {
+			go f()
+		},
 	}
 	return q
 }
@@ -229,6 +400,12 @@ func (q *Queue) ClearQueue(first bool) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 	defer q.notifyQueueUpdated()
+	if first {
+		// player is stopped, nothing to undo
+		q.undo = nil
+	} else {
+		q.pushUndo()
+	}
 	q.list.Clear(first)
 }
 
@@ -239,6 +416,7 @@ func (q *Queue) AddSongs(songs []*models.Song) {
 	defer q.lock.Unlock()
 	defer q.notifyQueueUpdated()
 
+	q.pushUndo()
 	for _, v := range songs {
 		q.list.AddSong(v, false, false)
 	}
@@ -248,6 +426,7 @@ func (q *Queue) AddSongs(songs []*models.Song) {
 
 func (q *Queue) PlayNext(songs []*models.Song) {
 	q.lock.Lock()
+	q.pushUndo()
 	for i := len(songs); i > 0; i-- {
 		q.list.AddSong(songs[i-1], true, false)
 	}
@@ -260,7 +439,8 @@ func (q *Queue) RemoveSong(index int) {
 	q.lock.Lock()
 	if index == 0 {
 		// if we remove first, we must notify player to move to next song
-	} else {
+	} else if index < q.list.Len() {
+		q.pushUndo()
 		q.list.RemoveSong(index)
 		changed = true
 	}
@@ -286,9 +466,11 @@ func (q *Queue) Reorder(index int, down bool) bool {
 		// illegal index
 	} else if index >= 0 && index < heapLen-2 && !down {
 		changed = true
+		q.pushUndo()
 		q.list.Reorder(index, down)
 	} else if index >= 1 && down {
 		changed = true
+		q.pushUndo()
 		q.list.Reorder(index, down)
 	}
 
@@ -337,22 +519,34 @@ func (q *Queue) notifyHistoryUpdated() {
 	}
 }
 
-// remove first song from queue and move to history
-func (q *Queue) songComplete() {
+// remove first song from queue and move to history. Returns completed song, or nil if queue is empty.
+func (q *Queue) songComplete() *models.Song {
 	q.lock.Lock()
 	defer q.notifyQueueUpdated()
 	defer q.notifyHistoryUpdated()
 	if q.list.Len() == 0 {
-		return
+		q.lock.Unlock()
+		return nil
 	}
 
 	song := q.list.RemoveSong(0)
+	// song indices have changed
+	q.undo = nil
 	if q.history == nil {
 		q.history = []*models.Song{song}
 	} else {
 		q.history = append([]*models.Song{song}, q.history...)
 	}
 	q.lock.Unlock()
+	return song
+}
+
+// add completed song back to the end of queue when repeating whole queue
+func (q *Queue) repeatSong(song *models.Song) {
+	q.lock.Lock()
+	q.list.AddSong(song, false, false)
+	q.lock.Unlock()
+	q.notifyQueueUpdated()
 }
 
 // remove first item from history and move to queue
@@ -365,6 +559,7 @@ func (q *Queue) playLastSong() {
 		return
 	}
 	song := q.history[0]
+	q.undo = nil
 	q.list.AddSong(song, false, true)
 	if q.history == nil {
 		q.history = q.history[1:]
@@ -388,10 +583,204 @@ func (q *Queue) SetShuffle(enabled bool) {
 		return
 	}
 	q.list.SetShuffling(enabled)
+	q.undo = nil
 	q.lock.Unlock()
 	q.notifyQueueUpdated()
 }
 
+// MoveSongs moves songs in indices to given index. Songs keep their relative order and
+// index is position of first moved song after moving. First song cannot be moved.
+func (q *Queue) MoveSongs(indices []int, index int) bool {
+	q.lock.Lock()
+	undo := q.list.snapshot()
+	changed := q.list.Move(indices, index)
+	if changed {
+		q.addUndo(undo)
+	}
+	q.lock.Unlock()
+	if changed {
+		q.notifyQueueUpdated()
+	}
+	return changed
+}
+
+// RemoveSongs removes songs in indices. First song cannot be removed.
+func (q *Queue) RemoveSongs(indices []int) {
+	q.lock.Lock()
+	undo := q.list.snapshot()
+	changed := q.list.RemoveSongs(indices) > 0
+	if changed {
+		q.addUndo(undo)
+	}
+	q.lock.Unlock()
+	if changed {
+		q.notifyQueueUpdated()
+	}
+}
+
+// SortQueue sorts upcoming songs by artist, album or name. Songs in same album are
+// sorted by disc and track number. Album and artist names are resolved in background,
+// and queue is sorted once they are known. New sort cancels previous one.
+func (q *Queue) SortQueue(field interfaces.SortField) {
+	names := &sortNames{albums: map[models.Id]string{}, artists: map[models.Id]string{}}
+	less := sortLess(field, names)
+	if less == nil {
+		logrus.Errorf("cannot sort queue by %s", field)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queueSortTimeout)
+	q.lock.Lock()
+	if q.cancelSort != nil {
+		q.cancelSort()
+	}
+	q.cancelSort = cancel
+	songs := q.list.GetQueue()
+	q.lock.Unlock()
+
+	q.submit(func() {
+		defer cancel()
+		if field != interfaces.SortByName {
+			q.sortNames(ctx, songs, names)
+		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return
+		}
+
+		q.lock.Lock()
+		q.pushUndo()
+		q.list.SortSongs(less)
+		q.lock.Unlock()
+		q.notifyQueueUpdated()
+	})
+}
+
+// sortLess returns function to sort songs by field, or nil if field is not supported.
+func sortLess(field interfaces.SortField, names *sortNames) func(a, b *models.Song) bool {
+	byAlbum := func(a, b *models.Song) bool {
+		albumA, albumB := names.albums[a.Album], names.albums[b.Album]
+		if albumA != albumB {
+			return albumA < albumB
+		}
+		if a.Album != b.Album {
+			// keep albums with same name together
+			return a.Album < b.Album
+		}
+		if a.DiscNumber != b.DiscNumber {
+			return a.DiscNumber < b.DiscNumber
+		}
+		return a.Index < b.Index
+	}
+
+	switch field {
+	case interfaces.SortByArtist:
+		return func(a, b *models.Song) bool {
+			artistA, artistB := names.songArtist(a), names.songArtist(b)
+			if artistA != artistB {
+				return artistA < artistB
+			}
+			return byAlbum(a, b)
+		}
+	case interfaces.SortByAlbum:
+		return byAlbum
+	case interfaces.SortByName:
+		return func(a, b *models.Song) bool {
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+	default:
+		return nil
+	}
+}
+
+// RemoveDuplicates removes songs that are already in queue. Returns number of removed songs.
+func (q *Queue) RemoveDuplicates() int {
+	q.lock.Lock()
+	undo := q.list.snapshot()
+	removed := q.list.RemoveDuplicates()
+	if removed > 0 {
+		q.addUndo(undo)
+	}
+	q.lock.Unlock()
+	if removed > 0 {
+		q.notifyQueueUpdated()
+	}
+	return removed
+}
+
+// UndoQueueEdit reverts latest change to queue. Returns false if there is nothing to undo.
+// Edits cannot be undone after current song has changed.
+func (q *Queue) UndoQueueEdit() bool {
+	q.lock.Lock()
+	if len(q.undo) == 0 {
+		q.lock.Unlock()
+		return false
+	}
+	q.list.restore(q.undo[len(q.undo)-1])
+	q.undo = q.undo[:len(q.undo)-1]
+	q.lock.Unlock()
+	q.notifyQueueUpdated()
+	return true
+}
+
+// pushUndo saves current queue for undo. Lock must be held.
+func (q *Queue) pushUndo() {
+	q.addUndo(q.list.snapshot())
+}
+
+// addUndo adds snapshot to undo stack. Lock must be held.
+func (q *Queue) addUndo(s queueSnapshot) {
+	q.undo = append(q.undo, s)
+	if len(q.undo) > queueUndoLimit {
+		q.undo = q.undo[len(q.undo)-queueUndoLimit:]
+	}
+}
+
+// sortNames contains lower-case album and artist names to sort songs with.
+type sortNames struct {
+	albums  map[models.Id]string
+	artists map[models.Id]string
+}
+
+// songArtist returns name of first artist of song, or name of album artist.
+func (s *sortNames) songArtist(song *models.Song) string {
+	if len(song.Artists) > 0 {
+		return strings.ToLower(song.Artists[0].Name)
+	}
+	return s.artists[song.AlbumArtist]
+}
+
+// sortNames resolves album and album artist names for songs to names. It stops once ctx is done,
+// leaving rest of the names empty.
+func (q *Queue) sortNames(ctx context.Context, songs []*models.Song, names *sortNames) {
+	for _, song := range songs {
+		if ctx.Err() != nil {
+			logrus.Warningf("get names for sorting queue: %v", ctx.Err())
+			return
+		}
+		if _, ok := names.albums[song.Album]; !ok && song.Album != "" && q.getAlbum != nil {
+			names.albums[song.Album] = ""
+			album, err := q.getAlbum(ctx, song.Album)
+			if err != nil {
+				logrus.Warningf("get album for sorting queue: %v", err)
+			} else {
+				names.albums[song.Album] = strings.ToLower(album.Name)
+			}
+		}
+		if len(song.Artists) > 0 || song.AlbumArtist == "" || q.getArtist == nil {
+			continue
+		}
+		if _, ok := names.artists[song.AlbumArtist]; !ok {
+			names.artists[song.AlbumArtist] = ""
+			artist, err := q.getArtist(ctx, song.AlbumArtist)
+			if err != nil {
+				logrus.Warningf("get artist for sorting queue: %v", err)
+			} else {
+				names.artists[song.AlbumArtist] = strings.ToLower(artist.Name)
+			}
+		}
+	}
+}
+
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
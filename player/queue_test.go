@@ -19,9 +19,12 @@
 package player
 
 import (
+	"context"
 	"github.com/google/go-cmp/cmp"
 	"reflect"
 	"testing"
+	"time"
+	"tryffel.net/go/jellycli/interfaces"
 	"tryffel.net/go/jellycli/models"
 )
 
@@ -588,6 +591,219 @@ func TestQueue_TestAddSongsDuringShouffle(t *testing.T) {
 	logDiff(t, wantSongs, gotSongs, "reversed shuffle")
 }
 
+func TestQueue_MoveSongs(t *testing.T) {
+	songs := testSongs()
+	tests := []struct {
+		name      string
+		indices   []int
+		index     int
+		want      bool
+		wantSongs []*models.Song
+	}{
+		{
+			name:      "move to next",
+			indices:   []int{3, 5},
+			index:     1,
+			want:      true,
+			wantSongs: []*models.Song{songs[0], songs[3], songs[5], songs[1], songs[2], songs[4], songs[6], songs[7], songs[8]},
+		},
+		{
+			name:      "move to end",
+			indices:   []int{1, 2},
+			index:     len(songs),
+			want:      true,
+			wantSongs: []*models.Song{songs[0], songs[3], songs[4], songs[5], songs[6], songs[7], songs[8], songs[1], songs[2]},
+		},
+		{
+			name:      "move later",
+			indices:   []int{2},
+			index:     4,
+			want:      true,
+			wantSongs: []*models.Song{songs[0], songs[1], songs[3], songs[4], songs[2], songs[5], songs[6], songs[7], songs[8]},
+		},
+		{
+			name:      "first cannot be moved",
+			indices:   []int{0, 20},
+			index:     3,
+			want:      false,
+			wantSongs: songs,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newQueue()
+			q.AddSongs(songs)
+			if got := q.MoveSongs(tt.indices, tt.index); got != tt.want {
+				t.Errorf("MoveSongs() = %v, want %v", got, tt.want)
+			}
+			logDiff(t, tt.wantSongs, q.GetQueue(), "songs after move")
+
+			// songs added after moving are added to end
+			q.AddSongs([]*models.Song{{Id: "song-10"}})
+			gotSongs := q.GetQueue()
+			if gotSongs[len(gotSongs)-1].Id != "song-10" {
+				t.Errorf("added song not last: %v", gotSongs)
+			}
+		})
+	}
+}
+
+func TestQueue_RemoveSongs(t *testing.T) {
+	songs := testSongs()
+	q := newQueue()
+	q.AddSongs(songs)
+
+	q.RemoveSongs([]int{0, 8, 2, 2, 4})
+	want := []*models.Song{songs[0], songs[1], songs[3], songs[5], songs[6], songs[7]}
+	logDiff(t, want, q.GetQueue(), "songs after removal")
+}
+
+func TestQueue_SortQueue(t *testing.T) {
+	// album and artist ids are not in same order as their names
+	albums := map[models.Id]string{"album-1": "B", "album-2": "a", "album-3": "c"}
+	artists := map[models.Id]string{"artist-1": "Artist-B", "artist-2": "artist-a"}
+	songs := []*models.Song{
+		{Id: "song-1", Name: "d", Album: "album-1", Index: 1, Artists: []models.IdName{{Name: "artist-b"}}},
+		{Id: "song-2", Name: "c", Album: "album-1", Index: 2, AlbumArtist: "artist-1"},
+		{Id: "song-3", Name: "a", Album: "album-2", Index: 2, Artists: []models.IdName{{Name: "artist-b"}}},
+		{Id: "song-4", Name: "b", Album: "album-3", Index: 1, AlbumArtist: "artist-2"},
+		{Id: "song-5", Name: "e", Album: "album-2", Index: 1, Artists: []models.IdName{{Name: "Artist-B"}}},
+	}
+	tests := []struct {
+		name  string
+		field interfaces.SortField
+		want  []*models.Song
+	}{
+		{
+			field: interfaces.SortByArtist,
+			want:  []*models.Song{songs[0], songs[3], songs[4], songs[2], songs[1]},
+		},
+		{
+			field: interfaces.SortByAlbum,
+			want:  []*models.Song{songs[0], songs[4], songs[2], songs[1], songs[3]},
+		},
+		{
+			field: interfaces.SortByName,
+			want:  []*models.Song{songs[0], songs[2], songs[3], songs[1], songs[4]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.field), func(t *testing.T) {
+			q := newQueue()
+			q.submit = func(f func()) { f() }
+			q.getAlbum = func(ctx context.Context, id models.Id) (*models.Album, error) {
+				return &models.Album{Id: id, Name: albums[id]}, nil
+			}
+			q.getArtist = func(ctx context.Context, id models.Id) (*models.Artist, error) {
+				return &models.Artist{Id: id, Name: artists[id]}, nil
+			}
+			q.AddSongs(songs)
+			q.SortQueue(tt.field)
+			logDiff(t, tt.want, q.GetQueue(), "sorted songs")
+		})
+	}
+}
+
+func TestQueue_RemoveDuplicates(t *testing.T) {
+	songs := testSongs()
+	q := newQueue()
+	q.AddSongs(songs[:4])
+	q.AddSongs([]*models.Song{songs[0], songs[2], songs[5], songs[2]})
+
+	if got := q.RemoveDuplicates(); got != 3 {
+		t.Errorf("RemoveDuplicates() = %d, want %d", got, 3)
+	}
+	logDiff(t, []*models.Song{songs[0], songs[1], songs[2], songs[3], songs[5]}, q.GetQueue(), "songs without duplicates")
+}
+
+func TestQueue_SortQueue_background(t *testing.T) {
+	songs := []*models.Song{
+		{Id: "song-1", Name: "c", Album: "album-1"},
+		{Id: "song-2", Name: "b", Album: "album-2"},
+		{Id: "song-3", Name: "a", Album: "album-3"},
+	}
+	q := newQueue()
+	started := make(chan bool, 1)
+	q.getAlbum = func(ctx context.Context, id models.Id) (*models.Album, error) {
+		select {
+		case started <- true:
+		default:
+		}
+		// server does not respond until request is cancelled
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	q.AddSongs(songs)
+	updated := make(chan []*models.Song, 2)
+	q.AddQueueChangedCallback(func(content []*models.Song) {
+		updated <- content
+	})
+
+	// sorting must not block caller while names are resolved
+	q.SortQueue(interfaces.SortByAlbum)
+	select {
+	case <-started:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("album names were not resolved")
+	}
+	// new sort cancels previous one
+	q.SortQueue(interfaces.SortByName)
+	select {
+	case content := <-updated:
+		logDiff(t, []*models.Song{songs[0], songs[2], songs[1]}, content, "sorted songs")
+	case <-time.After(time.Second * 5):
+		t.Fatalf("queue was not sorted")
+	}
+	select {
+	case content := <-updated:
+		t.Errorf("cancelled sort updated queue: %v", content)
+	case <-time.After(time.Millisecond * 100):
+	}
+	if !q.UndoQueueEdit() {
+		t.Errorf("undo sort failed")
+	}
+	logDiff(t, songs, q.GetQueue(), "songs after undo")
+}
+
+func TestQueue_UndoQueueEdit(t *testing.T) {
+	songs := testSongs()
+	q := newQueue()
+	q.submit = func(f func()) { f() }
+	if q.UndoQueueEdit() {
+		t.Errorf("undo with empty queue")
+	}
+
+	q.AddSongs(songs)
+	q.RemoveSongs([]int{1, 2})
+	q.MoveSongs([]int{5}, 1)
+	q.SortQueue(interfaces.SortByName)
+
+	if !q.UndoQueueEdit() {
+		t.Errorf("undo sort failed")
+	}
+	if !q.UndoQueueEdit() {
+		t.Errorf("undo move failed")
+	}
+	logDiff(t, append([]*models.Song{songs[0]}, songs[3:]...), q.GetQueue(), "songs after undo")
+
+	if !q.UndoQueueEdit() {
+		t.Errorf("undo remove failed")
+	}
+	logDiff(t, songs, q.GetQueue(), "songs after undo")
+
+	// songs added after undoing are added to end
+	q.AddSongs([]*models.Song{{Id: "song-10"}})
+	gotSongs := q.GetQueue()
+	if gotSongs[len(gotSongs)-1].Id != "song-10" {
+		t.Errorf("added song not last: %v", gotSongs)
+	}
+
+	q.songComplete()
+	if q.UndoQueueEdit() {
+		t.Errorf("undo after song changed")
+	}
+}
+
 func logDiff(t *testing.T, x, y interface{}, msg string) {
 
 	diff := cmp.Diff(x, y)
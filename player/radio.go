@@ -0,0 +1,174 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/models"
+)
+
+const (
+	// how many songs to add to queue at once
+	radioBatchSize = 10
+	// how many recent songs to use as seeds for instant mix
+	radioSeeds = 5
+	// songs played this long ago (in songs) are not added again
+	radioRecentSongs = 100
+	// artists of this many latest songs are skipped
+	radioRecentArtists = 5
+	// max songs per artist in single batch
+	radioSongsPerArtist = 2
+)
+
+// SetRadio enables or disables radio mode.
+func (p *Player) SetRadio(enabled bool) {
+	p.Audio.SetRadio(enabled)
+	if enabled {
+		go p.fillRadio()
+	}
+}
+
+// fillRadio adds similar songs to queue if radio is enabled and queue is about to run out.
+func (p *Player) fillRadio() {
+	if !p.Audio.getStatus().Radio || len(p.Queue.GetQueue()) >= config.AppConfig.Player.RadioQueueThreshold {
+		return
+	}
+
+	p.lock.Lock()
+	if p.radioLoading {
+		p.lock.Unlock()
+		return
+	}
+	p.radioLoading = true
+	p.lock.Unlock()
+
+	defer func() {
+		p.lock.Lock()
+		p.radioLoading = false
+		p.lock.Unlock()
+	}()
+
+	queue := p.Queue.GetQueue()
+	history := p.Queue.GetHistory(radioRecentSongs)
+
+	// latest songs first
+	seeds := make([]*models.Song, 0, radioSeeds)
+	for i := len(queue) - 1; i >= 0 && len(seeds) < radioSeeds; i-- {
+		seeds = append(seeds, queue[i])
+	}
+	for i := 0; i < len(history) && len(seeds) < radioSeeds; i++ {
+		seeds = append(seeds, history[i])
+	}
+	if len(seeds) == 0 {
+		logrus.Debug("radio: no songs to seed instant mix")
+		return
+	}
+
+	for _, seed := range seeds {
+		mix, err := p.api.GetInstantMix(context.Background(), seed)
+		if err != nil {
+			logrus.Errorf("radio: get instant mix for song %s: %v", seed.Id, err)
+			continue
+		}
+		songs := radioSongs(mix, queue, history, radioBatchSize)
+		if len(songs) > 0 {
+			logrus.Infof("radio: add %d songs to queue", len(songs))
+			p.Queue.AddSongs(songs)
+			return
+		}
+	}
+	logrus.Warning("radio: no new songs found")
+}
+
+// radioSongs picks at most limit songs from instant mix that have not been played or queued recently.
+// Songs from recently played artists are skipped, unless there are no other songs available.
+func radioSongs(mix, queue, history []*models.Song, limit int) []*models.Song {
+	recentSongs := map[models.Id]bool{}
+	for _, v := range queue {
+		recentSongs[v.Id] = true
+	}
+	for _, v := range history {
+		recentSongs[v.Id] = true
+	}
+
+	// latest songs are queue in reverse order and then history
+	latest := make([]*models.Song, 0, radioRecentArtists)
+	for i := len(queue) - 1; i >= 0 && len(latest) < radioRecentArtists; i-- {
+		latest = append(latest, queue[i])
+	}
+	for i := 0; i < len(history) && len(latest) < radioRecentArtists; i++ {
+		latest = append(latest, history[i])
+	}
+	recentArtists := map[models.Id]bool{}
+	for _, v := range latest {
+		for _, artist := range songArtists(v) {
+			recentArtists[artist] = true
+		}
+	}
+
+	pick := func(skipArtists bool) []*models.Song {
+		songs := make([]*models.Song, 0, limit)
+		artistCount := map[models.Id]int{}
+		for _, song := range mix {
+			if len(songs) >= limit {
+				break
+			}
+			if recentSongs[song.Id] {
+				continue
+			}
+			artists := songArtists(song)
+			ok := true
+			for _, artist := range artists {
+				if (skipArtists && recentArtists[artist]) || artistCount[artist] >= radioSongsPerArtist {
+					ok = false
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+			for _, artist := range artists {
+				artistCount[artist] += 1
+			}
+			recentSongs[song.Id] = true
+			songs = append(songs, song)
+		}
+		return songs
+	}
+
+	songs := pick(true)
+	if len(songs) == 0 {
+		songs = pick(false)
+	}
+	return songs
+}
+
+// songArtists returns ids of all artists of song.
+func songArtists(song *models.Song) []models.Id {
+	artists := make([]models.Id, 0, len(song.Artists)+1)
+	for _, v := range song.Artists {
+		artists = append(artists, v.Id)
+	}
+	if len(artists) == 0 && song.AlbumArtist != "" {
+		artists = append(artists, song.AlbumArtist)
+	}
+	return artists
+}
@@ -0,0 +1,94 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"testing"
+	"tryffel.net/go/jellycli/models"
+)
+
+func radioSong(id, artist string) *models.Song {
+	return &models.Song{
+		Id:      models.Id(id),
+		Name:    id,
+		Artists: []models.IdName{{Id: models.Id(artist), Name: artist}},
+	}
+}
+
+func TestRadioSongs(t *testing.T) {
+	history := []*models.Song{radioSong("played-1", "artist-a"), radioSong("played-2", "artist-b")}
+	queue := []*models.Song{radioSong("queued-1", "artist-c")}
+
+	mix := []*models.Song{
+		radioSong("played-1", "artist-a"),
+		radioSong("queued-1", "artist-c"),
+		radioSong("song-1", "artist-a"),
+		radioSong("song-2", "artist-d"),
+		radioSong("song-3", "artist-d"),
+		radioSong("song-4", "artist-d"),
+		radioSong("song-5", "artist-e"),
+		radioSong("song-5", "artist-e"),
+		radioSong("song-6", "artist-f"),
+	}
+
+	tests := []struct {
+		name  string
+		mix   []*models.Song
+		limit int
+		want  []models.Id
+	}{
+		{
+			name:  "skip recent songs and artists",
+			mix:   mix,
+			limit: 10,
+			want:  []models.Id{"song-2", "song-3", "song-5", "song-6"},
+		},
+		{
+			name:  "limit",
+			mix:   mix,
+			limit: 2,
+			want:  []models.Id{"song-2", "song-3"},
+		},
+		{
+			name:  "allow recent artists if nothing else",
+			mix:   []*models.Song{radioSong("played-2", "artist-b"), radioSong("song-7", "artist-b")},
+			limit: 10,
+			want:  []models.Id{"song-7"},
+		},
+		{
+			name:  "nothing new",
+			mix:   []*models.Song{radioSong("played-2", "artist-b")},
+			limit: 10,
+			want:  []models.Id{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := radioSongs(tt.mix, queue, history, tt.limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("radioSongs() got %d songs, want %d", len(got), len(tt.want))
+			}
+			for i, v := range got {
+				if v.Id != tt.want[i] {
+					t.Errorf("radioSongs() song %d = %s, want %s", i, v.Id, tt.want[i])
+				}
+			}
+		})
+	}
+}
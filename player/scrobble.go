@@ -0,0 +1,126 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package player
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/api/scrobbler"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+// Scrobbles submits now playing and listens to external scrobblers, independent of media server.
+// Song is scrobbled once it has been played for half of its duration or 4 minutes.
+// Failed scrobbles are stored to outbox.
+type Scrobbles struct {
+	lock       *sync.Mutex
+	tracker    *playTracker
+	scrobbled  bool
+	scrobblers []scrobbler.Scrobbler
+	outbox     *Outbox
+	// submit runs submit functions, default is to run them in background
+	submit func(func())
+}
+
+func newScrobbles(outbox *Outbox) *Scrobbles {
+	return &Scrobbles{
+		lock:    &sync.Mutex{},
+		tracker: newPlayTracker(),
+		outbox:  outbox,
+		submit: func(f func()) {
+			go f()
+		},
+	}
+}
+
+// AddScrobbler adds scrobbler to submit plays to.
+func (s *Scrobbles) AddScrobbler(sc scrobbler.Scrobbler) {
+	s.lock.Lock()
+	s.scrobblers = append(s.scrobblers, sc)
+	s.lock.Unlock()
+	if replayer, ok := sc.(api.Replayer); ok {
+		s.outbox.addReplayer(sc.OperationType(), replayer)
+	}
+}
+
+func (s *Scrobbles) statusChanged(status interfaces.AudioStatus) {
+	s.lock.Lock()
+	if len(s.scrobblers) == 0 {
+		s.lock.Unlock()
+		return
+	}
+
+	var nowPlaying, listen *models.Play
+	_, started := s.tracker.update(status)
+	play := s.tracker.current
+	if play != nil && play.ArtistName != "" && play.SongName != "" {
+		if started {
+			s.scrobbled = false
+			copied := *play
+			nowPlaying = &copied
+		}
+		if !s.scrobbled && play.Listened() {
+			s.scrobbled = true
+			copied := *play
+			listen = &copied
+		}
+	}
+	s.lock.Unlock()
+
+	if nowPlaying != nil {
+		s.submit(func() { s.nowPlaying(nowPlaying) })
+	}
+	if listen != nil {
+		s.submit(func() { s.scrobble(listen) })
+	}
+}
+
+func (s *Scrobbles) nowPlaying(play *models.Play) {
+	for _, v := range s.getScrobblers() {
+		err := v.NowPlaying(play)
+		if err != nil {
+			logrus.Warningf("report now playing to %s: %v", v.Name(), err)
+		}
+	}
+}
+
+func (s *Scrobbles) scrobble(play *models.Play) {
+	for _, v := range s.getScrobblers() {
+		err := v.Scrobble(play)
+		if err == nil {
+			logrus.Debugf("Scrobbled %s to %s", play.SongName, v.Name())
+			continue
+		}
+		op, opErr := scrobbler.NewOperation(v, play)
+		if opErr != nil {
+			logrus.Errorf("scrobble to %s: %v", v.Name(), opErr)
+			continue
+		}
+		s.outbox.handleError(fmt.Errorf("scrobble to %s: %w", v.Name(),
+			&api.OperationError{Operation: op, Err: err}))
+	}
+}
+
+func (s *Scrobbles) getScrobblers() []scrobbler.Scrobbler {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.scrobblers
+}
@@ -0,0 +1,107 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package player
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/api/scrobbler"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+type testScrobbler struct {
+	nowPlaying []*models.Play
+	scrobbles  []*models.Play
+	err        error
+}
+
+func (t *testScrobbler) Name() string {
+	return "test"
+}
+
+func (t *testScrobbler) NowPlaying(play *models.Play) error {
+	t.nowPlaying = append(t.nowPlaying, play)
+	return nil
+}
+
+func (t *testScrobbler) Scrobble(play *models.Play) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.scrobbles = append(t.scrobbles, play)
+	return nil
+}
+
+func (t *testScrobbler) OperationType() models.OperationType {
+	return models.OperationListenBrainzScrobble
+}
+
+func (t *testScrobbler) Replay(op *models.Operation) error {
+	return scrobbler.Replay(t, op)
+}
+
+func TestScrobbles(t *testing.T) {
+	_, db := testPlayHistory(t)
+	outbox := newOutbox(nil, db)
+	scrobbles := newScrobbles(outbox)
+	scrobbles.submit = func(f func()) { f() }
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	scrobbles.tracker.now = func() time.Time { return now }
+
+	sc := &testScrobbler{err: errors.New("offline")}
+	scrobbles.AddScrobbler(sc)
+
+	song := &models.Song{Id: "song-1", Name: "Song 1", Duration: 100,
+		Artists: []models.IdName{{Id: "artist-1", Name: "Artist 1"}}}
+	update := func(action interfaces.AudioAction, past int) {
+		scrobbles.statusChanged(interfaces.AudioStatus{
+			State:    interfaces.AudioStatePlaying,
+			Action:   action,
+			Song:     song,
+			SongPast: interfaces.AudioTick(past * 1000),
+		})
+	}
+
+	update(interfaces.AudioActionPlay, 0)
+	if len(sc.nowPlaying) != 1 {
+		t.Errorf("now playing not reported")
+	}
+	for i := 1; i <= 60; i++ {
+		now = now.Add(time.Second)
+		update(interfaces.AudioActionTimeUpdate, i)
+	}
+
+	operations := outbox.GetOperations()
+	if len(operations) != 1 || operations[0].Type != models.OperationListenBrainzScrobble {
+		t.Fatalf("failed scrobble not in outbox: %v", operations)
+	}
+
+	sc.err = nil
+	outbox.flush()
+	if len(sc.scrobbles) != 1 {
+		t.Fatalf("scrobble not sent from outbox")
+	}
+	if sc.scrobbles[0].Song != "song-1" || !sc.scrobbles[0].Started.Equal(time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("invalid scrobble: %v", sc.scrobbles[0])
+	}
+	if len(outbox.GetOperations()) != 0 {
+		t.Errorf("operation not removed from outbox")
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"errors"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+// sessionController returns server if it can control other sessions.
+func (p *Player) sessionController() (interfaces.SessionController, error) {
+	if controller, ok := p.api.(interfaces.SessionController); ok {
+		return controller, nil
+	}
+	return nil, errors.New("server does not support controlling other sessions")
+}
+
+// GetSessions returns other sessions that can be controlled remotely.
+func (p *Player) GetSessions() ([]*models.Session, error) {
+	controller, err := p.sessionController()
+	if err != nil {
+		return nil, err
+	}
+	return controller.GetSessions()
+}
+
+// ControlSession sends playstate command to session.
+func (p *Player) ControlSession(session models.Id, command models.SessionCommand) error {
+	controller, err := p.sessionController()
+	if err != nil {
+		return err
+	}
+	return controller.ControlSession(session, command)
+}
+
+// SeekSession seeks currently playing song in session to given position.
+func (p *Player) SeekSession(session models.Id, position interfaces.AudioTick) error {
+	controller, err := p.sessionController()
+	if err != nil {
+		return err
+	}
+	return controller.SeekSession(session, position)
+}
+
+// SetSessionVolume sets volume of session.
+func (p *Player) SetSessionVolume(session models.Id, volume interfaces.AudioVolume) error {
+	controller, err := p.sessionController()
+	if err != nil {
+		return err
+	}
+	return controller.SetSessionVolume(session, volume)
+}
+
+// PlayOnSession replaces queue in session with items and starts playing them.
+func (p *Player) PlayOnSession(session models.Id, items []models.Id) error {
+	controller, err := p.sessionController()
+	if err != nil {
+		return err
+	}
+	return controller.PlayOnSession(session, items)
+}
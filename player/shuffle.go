@@ -0,0 +1,134 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/models"
+)
+
+// shuffleFunc reorders items in place using given random source.
+type shuffleFunc func(items []*queueItem, r *rand.Rand)
+
+// shuffleFuncs maps config.Shuffle* modes to shuffle algorithms.
+var shuffleFuncs = map[string]shuffleFunc{
+	config.ShuffleRandom:       shuffleRandom,
+	config.ShuffleArtistSpread: shuffleArtistSpread,
+	config.ShuffleAlbum:        shuffleAlbums,
+	config.ShuffleWeighted:     shuffleWeighted,
+}
+
+// shuffleItems shuffles items with given mode. Unknown mode falls back to random shuffle.
+func shuffleItems(mode string, items []*queueItem, r *rand.Rand) {
+	shuffle, ok := shuffleFuncs[mode]
+	if !ok {
+		shuffle = shuffleRandom
+	}
+	shuffle(items, r)
+}
+
+func shuffleRandom(items []*queueItem, r *rand.Rand) {
+	r.Shuffle(len(items), func(i, j int) {
+		items[i], items[j] = items[j], items[i]
+	})
+}
+
+// shuffleArtistSpread places songs of each artist at even intervals with random offset,
+// so that same artist is seldom played twice in a row.
+func shuffleArtistSpread(items []*queueItem, r *rand.Rand) {
+	groups := groupItems(items, func(song *models.Song) models.Id {
+		artists := songArtists(song)
+		if len(artists) == 0 {
+			return ""
+		}
+		return artists[0]
+	})
+
+	positions := make(map[*queueItem]float64, len(items))
+	for _, group := range groups {
+		shuffleRandom(group, r)
+		gap := 1 / float64(len(group))
+		offset := r.Float64() * gap
+		for i, item := range group {
+			// small jitter to avoid artists being in identical order throughout the queue
+			jitter := (r.Float64() - 0.5) * gap * 0.2
+			positions[item] = offset + float64(i)*gap + jitter
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return positions[items[i]] < positions[items[j]]
+	})
+}
+
+// shuffleAlbums shuffles order of albums. Songs of each album are kept in original order.
+func shuffleAlbums(items []*queueItem, r *rand.Rand) {
+	groups := groupItems(items, func(song *models.Song) models.Id {
+		return song.Album
+	})
+	r.Shuffle(len(groups), func(i, j int) {
+		groups[i], groups[j] = groups[j], groups[i]
+	})
+
+	i := 0
+	for _, group := range groups {
+		i += copy(items[i:], group)
+	}
+}
+
+// shuffleWeighted does weighted random sampling without replacement, so that songs with higher weight
+// are more likely to be played earlier.
+func shuffleWeighted(items []*queueItem, r *rand.Rand) {
+	keys := make(map[*queueItem]float64, len(items))
+	for _, item := range items {
+		keys[item] = math.Pow(r.Float64(), 1/songWeight(item.song))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return keys[items[i]] > keys[items[j]]
+	})
+}
+
+// songWeight returns weight for weighted shuffle, which is at least 1.
+func songWeight(song *models.Song) float64 {
+	weight := 1 + float64(song.Rating) + math.Log1p(float64(song.PlayCount))
+	if song.Favorite {
+		weight += 2
+	}
+	return weight
+}
+
+// groupItems groups items by key. Groups are in order of first occurrence and items keep their order.
+func groupItems(items []*queueItem, key func(song *models.Song) models.Id) [][]*queueItem {
+	indices := map[models.Id]int{}
+	groups := make([][]*queueItem, 0)
+	for _, item := range items {
+		k := key(item.song)
+		index, ok := indices[k]
+		if !ok {
+			index = len(groups)
+			indices[k] = index
+			groups = append(groups, []*queueItem{})
+		}
+		groups[index] = append(groups[index], item)
+	}
+	return groups
+}
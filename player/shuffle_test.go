@@ -0,0 +1,151 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/models"
+)
+
+// shuffleTestSongs returns songs from 3 artists, each having 2 albums with 2 songs.
+// Songs are ordered by artist and album.
+func shuffleTestSongs() []*models.Song {
+	songs := make([]*models.Song, 0, 12)
+	for artist := 1; artist <= 3; artist++ {
+		for album := 1; album <= 2; album++ {
+			for song := 1; song <= 2; song++ {
+				id := fmt.Sprintf("song-%d-%d-%d", artist, album, song)
+				songs = append(songs, &models.Song{
+					Id:          models.Id(id),
+					Name:        id,
+					Album:       models.Id(fmt.Sprintf("album-%d-%d", artist, album)),
+					AlbumArtist: models.Id(fmt.Sprintf("artist-%d", artist)),
+				})
+			}
+		}
+	}
+	return songs
+}
+
+func shuffledQueue(mode string, seed int64, songs []*models.Song) []*models.Song {
+	q := newQueue()
+	q.list.shuffleMode = mode
+	q.list.rand = rand.New(rand.NewSource(seed))
+	q.AddSongs(songs)
+	q.SetShuffle(true)
+	return q.GetQueue()
+}
+
+func TestQueue_ShuffleModes(t *testing.T) {
+	songs := shuffleTestSongs()
+	modes := []string{config.ShuffleRandom, config.ShuffleArtistSpread, config.ShuffleAlbum, config.ShuffleWeighted}
+
+	for _, mode := range modes {
+		t.Run(mode, func(t *testing.T) {
+			got := shuffledQueue(mode, 1, songs)
+			if len(got) != len(songs) {
+				t.Fatalf("shuffled queue length = %d, want %d", len(got), len(songs))
+			}
+			if got[0] != songs[0] {
+				t.Errorf("1st shuffled song does not match original song")
+			}
+			seen := map[models.Id]bool{}
+			for _, v := range got {
+				if seen[v.Id] {
+					t.Errorf("duplicate song in shuffled queue: %v", v.Id)
+				}
+				seen[v.Id] = true
+			}
+
+			again := shuffledQueue(mode, 1, songs)
+			if !reflect.DeepEqual(got, again) {
+				t.Errorf("shuffle with same seed is not deterministic")
+			}
+		})
+	}
+}
+
+func TestQueue_ShuffleArtistSpread(t *testing.T) {
+	songs := shuffleTestSongs()
+	random, spread := 0, 0
+	for seed := int64(0); seed < 100; seed++ {
+		random += consecutiveArtists(shuffledQueue(config.ShuffleRandom, seed, songs))
+		spread += consecutiveArtists(shuffledQueue(config.ShuffleArtistSpread, seed, songs))
+	}
+	if spread >= random {
+		t.Errorf("artist spread has %d consecutive artists, random has %d", spread, random)
+	}
+}
+
+func consecutiveArtists(songs []*models.Song) int {
+	count := 0
+	for i := 1; i < len(songs); i++ {
+		if songs[i].AlbumArtist == songs[i-1].AlbumArtist {
+			count += 1
+		}
+	}
+	return count
+}
+
+func TestQueue_ShuffleAlbum(t *testing.T) {
+	songs := shuffleTestSongs()
+	for seed := int64(0); seed < 10; seed++ {
+		got := shuffledQueue(config.ShuffleAlbum, seed, songs)
+		// first song is kept in place, rest of the albums must be continuous and in order
+		played := map[models.Id]bool{}
+		for i := 2; i < len(got); i++ {
+			if got[i].Album == got[i-1].Album {
+				if got[i].Name < got[i-1].Name {
+					t.Errorf("seed %d: album songs are not in order: %s, %s", seed, got[i-1].Id, got[i].Id)
+				}
+				continue
+			}
+			if played[got[i].Album] {
+				t.Errorf("seed %d: album %s is not continuous", seed, got[i].Album)
+			}
+			played[got[i-1].Album] = true
+		}
+	}
+}
+
+func TestQueue_ShuffleWeighted(t *testing.T) {
+	songs := shuffleTestSongs()
+	songs[5].Rating = 5
+	songs[5].PlayCount = 100
+	songs[5].Favorite = true
+
+	positions := 0
+	rounds := 200
+	for seed := int64(0); seed < int64(rounds); seed++ {
+		got := shuffledQueue(config.ShuffleWeighted, seed, songs)
+		for i, v := range got {
+			if v == songs[5] {
+				positions += i
+			}
+		}
+	}
+	// average position would be ~6 if song was not weighted
+	if average := float64(positions) / float64(rounds); average > 4 {
+		t.Errorf("weighted song average position = %.1f, want < 4", average)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"github.com/sirupsen/logrus"
+	"time"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+// how long to fade out volume before sleep timer pauses playback
+const sleepFadeDuration = time.Second * 30
+
+// SetSleepTimer sets or cancels sleep timer. Once timer expires, playback is paused.
+func (p *Player) SetSleepTimer(timer interfaces.SleepTimer) {
+	status := p.Audio.getStatus()
+
+	p.lock.Lock()
+	p.sleepTimer = timer
+	switch timer.Mode {
+	case interfaces.SleepAfterDuration:
+		p.sleepDeadline = time.Now().Add(timer.Duration)
+		logrus.Infof("Set sleep timer to %s", timer.Duration)
+	case interfaces.SleepEndOfSong:
+		logrus.Info("Stop after current song")
+	case interfaces.SleepEndOfAlbum:
+		p.sleepAlbum = ""
+		if status.Song != nil {
+			p.sleepAlbum = status.Song.GetParent()
+		}
+		logrus.Info("Stop after current album")
+	default:
+		logrus.Info("Cancel sleep timer")
+	}
+	p.lock.Unlock()
+
+	p.Audio.setFade(1)
+	p.updateSleep()
+	go p.Audio.flushStatus()
+}
+
+// SetStopAfterCurrent pauses playback after current song has ended.
+func (p *Player) SetStopAfterCurrent(enabled bool) {
+	if enabled {
+		p.SetSleepTimer(interfaces.SleepTimer{Mode: interfaces.SleepEndOfSong})
+		return
+	}
+	p.lock.RLock()
+	mode := p.sleepTimer.Mode
+	p.lock.RUnlock()
+	if mode == interfaces.SleepEndOfSong {
+		p.SetSleepTimer(interfaces.SleepTimer{})
+	}
+}
+
+// updateSleep updates remaining time, fades volume and pauses playback if sleep timer has expired.
+func (p *Player) updateSleep() {
+	p.lock.RLock()
+	timer := p.sleepTimer
+	deadline := p.sleepDeadline
+	album := p.sleepAlbum
+	p.lock.RUnlock()
+
+	status := p.Audio.getStatus()
+	var remaining time.Duration
+	switch timer.Mode {
+	case interfaces.SleepAfterDuration:
+		remaining = time.Until(deadline)
+		if remaining <= 0 {
+			p.sleep()
+			return
+		}
+		if timer.FadeOut && remaining < sleepFadeDuration {
+			p.Audio.setFade(float64(remaining) / float64(sleepFadeDuration))
+		}
+	case interfaces.SleepEndOfSong, interfaces.SleepEndOfAlbum:
+		if status.Song == nil {
+			break
+		}
+		remaining = time.Duration(status.Song.Duration-status.SongPast.Seconds()) * time.Second
+		if timer.Mode == interfaces.SleepEndOfAlbum && album != "" {
+			queue := p.Queue.GetQueue()
+			for i := 1; i < len(queue) && queue[i].GetParent() == album; i++ {
+				remaining += time.Duration(queue[i].Duration) * time.Second
+			}
+		}
+	}
+	p.Audio.setSleepStatus(timer, remaining)
+}
+
+// sleepAfterSong returns true if playback should be paused before playing next song.
+// Next song is nil if queue has ended.
+func (p *Player) sleepAfterSong(next *models.Song) bool {
+	p.lock.Lock()
+	switch p.sleepTimer.Mode {
+	case interfaces.SleepEndOfSong:
+	case interfaces.SleepEndOfAlbum:
+		if next != nil && p.sleepAlbum != "" && next.GetParent() == p.sleepAlbum {
+			p.lock.Unlock()
+			return false
+		}
+	default:
+		p.lock.Unlock()
+		return false
+	}
+	p.sleepTimer = interfaces.SleepTimer{}
+	p.lock.Unlock()
+
+	logrus.Info("Sleep timer expired")
+	p.Audio.setSleepStatus(interfaces.SleepTimer{}, 0)
+	return true
+}
+
+// sleep pauses playback and resets sleep timer.
+func (p *Player) sleep() {
+	logrus.Info("Sleep timer expired, pause playback")
+	p.lock.Lock()
+	p.sleepTimer = interfaces.SleepTimer{}
+	p.lock.Unlock()
+
+	status := p.Audio.getStatus()
+	if status.State == interfaces.AudioStatePlaying && !status.Paused {
+		p.Audio.Pause()
+	}
+	p.Audio.setFade(1)
+	p.Audio.setSleepStatus(interfaces.SleepTimer{}, 0)
+}
@@ -0,0 +1,98 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"sync"
+	"testing"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+func TestPlayer_sleepAfterSong(t *testing.T) {
+	albumA := &models.Song{Id: "song-1", Album: "album-a"}
+	albumB := &models.Song{Id: "song-2", Album: "album-b"}
+
+	tests := []struct {
+		name  string
+		timer interfaces.SleepTimer
+		album models.Id
+		next  *models.Song
+		want  bool
+	}{
+		{
+			name:  "no timer",
+			timer: interfaces.SleepTimer{},
+			next:  albumA,
+			want:  false,
+		},
+		{
+			name:  "duration",
+			timer: interfaces.SleepTimer{Mode: interfaces.SleepAfterDuration},
+			next:  albumA,
+			want:  false,
+		},
+		{
+			name:  "end of song",
+			timer: interfaces.SleepTimer{Mode: interfaces.SleepEndOfSong},
+			next:  albumA,
+			want:  true,
+		},
+		{
+			name:  "album continues",
+			timer: interfaces.SleepTimer{Mode: interfaces.SleepEndOfAlbum},
+			album: "album-a",
+			next:  albumA,
+			want:  false,
+		},
+		{
+			name:  "album ended",
+			timer: interfaces.SleepTimer{Mode: interfaces.SleepEndOfAlbum},
+			album: "album-a",
+			next:  albumB,
+			want:  true,
+		},
+		{
+			name:  "queue ended",
+			timer: interfaces.SleepTimer{Mode: interfaces.SleepEndOfAlbum},
+			album: "album-a",
+			next:  nil,
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Player{
+				Audio:      newAudio(),
+				lock:       &sync.RWMutex{},
+				sleepTimer: tt.timer,
+				sleepAlbum: tt.album,
+			}
+			if got := p.sleepAfterSong(tt.next); got != tt.want {
+				t.Errorf("sleepAfterSong() = %v, want %v", got, tt.want)
+			}
+			if tt.want && p.sleepTimer.Mode != interfaces.SleepOff {
+				t.Errorf("sleep timer was not reset")
+			}
+			if !tt.want && p.sleepTimer != tt.timer {
+				t.Errorf("sleep timer changed")
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/storage"
+)
+
+// SongCache stores every song that is streamed to disk, so that playing it again does not require
+// downloading it again. Cache has size limit, and songs that have been played least recently
+// and least often are removed first.
+type SongCache struct {
+	lock    *sync.Mutex
+	db      *storage.Db
+	dir     string
+	maxSize int64
+}
+
+// NewSongCache initializes new song cache. Db can be nil, in which case cache is disabled.
+func NewSongCache(db *storage.Db, serverId string) *SongCache {
+	c := &SongCache{
+		lock: &sync.Mutex{},
+		db:   db,
+		dir:  path.Join(config.AppConfig.Player.LocalCacheDir, "songs", serverId),
+	}
+	if config.AppConfig.Player.SongCacheSizeMb > 0 {
+		c.maxSize = int64(config.AppConfig.Player.SongCacheSizeMb) * 1024 * 1024
+	}
+	return c
+}
+
+func (c *SongCache) enabled() bool {
+	return c.db != nil && c.maxSize > 0
+}
+
+// Songs returns all cached songs in the order they are going to be removed from cache.
+func (c *SongCache) Songs() ([]*models.Download, error) {
+	if c.db == nil {
+		return nil, errors.New("local database is not available")
+	}
+	return c.db.GetCachedSongs()
+}
+
+// Size returns current size and size limit of cache in bytes.
+func (c *SongCache) Size() (int64, int64, error) {
+	if c.db == nil {
+		return 0, c.maxSize, errors.New("local database is not available")
+	}
+	size, err := c.db.GetCacheSize()
+	return size, c.maxSize, err
+}
+
+// Prune removes songs from cache until cache size is under limit. Songs are not read, corrupted songs
+// are removed once they are opened. Returns number of removed songs and bytes.
+func (c *SongCache) Prune() (int, int64, error) {
+	if c.db == nil {
+		return 0, 0, errors.New("local database is not available")
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	songs, err := c.db.GetCachedSongs()
+	if err != nil {
+		return 0, 0, fmt.Errorf("get cached songs: %v", err)
+	}
+
+	var size int64
+	for _, v := range songs {
+		size += v.Size
+	}
+
+	removed := 0
+	var removedSize int64
+	for _, v := range songs {
+		if size <= c.maxSize {
+			break
+		}
+		err = c.remove(v)
+		if err != nil {
+			return removed, removedSize, err
+		}
+		size -= v.Size
+		removed += 1
+		removedSize += v.Size
+	}
+	return removed, removedSize, nil
+}
+
+// Clear removes all songs from cache. Returns number of removed songs and bytes.
+func (c *SongCache) Clear() (int, int64, error) {
+	if c.db == nil {
+		return 0, 0, errors.New("local database is not available")
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	songs, err := c.db.GetCachedSongs()
+	if err != nil {
+		return 0, 0, fmt.Errorf("get cached songs: %v", err)
+	}
+
+	var removedSize int64
+	for i, v := range songs {
+		err = c.remove(v)
+		if err != nil {
+			return i, removedSize, err
+		}
+		removedSize += v.Size
+	}
+	return len(songs), removedSize, nil
+}
+
+func (c *SongCache) remove(song *models.Download) error {
+	err := os.Remove(song.File)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove file: %v", err)
+	}
+	err = c.db.RemoveDownload(song.Song.Id)
+	if err != nil {
+		return fmt.Errorf("remove cached song: %v", err)
+	}
+	return nil
+}
+
+// openSong opens cached song. If song is not cached or file is corrupted, return false.
+func (c *SongCache) openSong(song *models.Song) (io.ReadCloser, interfaces.AudioFormat, bool) {
+	if !c.enabled() {
+		return nil, interfaces.AudioFormatNil, false
+	}
+	cached, err := c.db.GetCachedSong(song.Id)
+	if err != nil {
+		logrus.Errorf("get cached song: %v", err)
+		return nil, interfaces.AudioFormatNil, false
+	}
+	if cached == nil {
+		return nil, interfaces.AudioFormatNil, false
+	}
+
+	file, format, err := openVerifiedFile(cached)
+	if err != nil {
+		logrus.Warningf("invalid cached song %s, removing from cache: %v", song.Id, err)
+		c.lock.Lock()
+		err = c.remove(cached)
+		c.lock.Unlock()
+		if err != nil {
+			logrus.Error(err)
+		}
+		return nil, interfaces.AudioFormatNil, false
+	}
+
+	err = c.db.SongPlayed(song.Id)
+	if err != nil {
+		logrus.Errorf("update cached song: %v", err)
+	}
+	return file, format, true
+}
+
+// wrap stream so that it gets written to cache while reading it.
+func (c *SongCache) wrap(song *models.Song, stream io.ReadCloser, format interfaces.AudioFormat) io.ReadCloser {
+	if !c.enabled() {
+		return stream
+	}
+
+	err := os.MkdirAll(c.dir, 0760)
+	if err != nil {
+		logrus.Errorf("create song cache directory: %v", err)
+		return stream
+	}
+
+	fileName := path.Join(c.dir, song.Id.String()+"."+format.String())
+	file, err := os.Create(fileName + ".part")
+	if err != nil {
+		logrus.Errorf("create cache file: %v", err)
+		return stream
+	}
+
+	reader := &cacheReader{
+		ReadCloser: stream,
+		cache:      c,
+		song:       song,
+		file:       file,
+		fileName:   fileName,
+		hash:       sha256.New(),
+		size:       -1,
+	}
+	if sized, ok := stream.(interface{ Size() int64 }); ok {
+		reader.size = sized.Size()
+	}
+	return reader
+}
+
+// store completely read song to cache
+func (c *SongCache) store(r *cacheReader) {
+	tmpName := r.file.Name()
+	err := r.file.Close()
+	if err == nil && r.failed {
+		err = errors.New("write failed")
+	}
+	if err == nil && !r.complete {
+		err = errors.New("song was not read completely")
+	}
+	if err == nil && r.size <= 0 {
+		// stream may report EOF before it has been fully downloaded, only cache streams with known size
+		err = errors.New("unknown stream size")
+	}
+	if err == nil && r.size != r.written {
+		err = fmt.Errorf("stream size (%d B) does not match expected size (%d B)", r.written, r.size)
+	}
+	if err != nil {
+		logrus.Debugf("do not cache song %s: %v", r.song.Id, err)
+		err = os.Remove(tmpName)
+		if err != nil {
+			logrus.Warningf("remove incomplete cache file: %v", err)
+		}
+		return
+	}
+
+	c.lock.Lock()
+	err = os.Rename(tmpName, r.fileName)
+	if err == nil {
+		checksum := hex.EncodeToString(r.hash.Sum(nil))
+		err = c.db.AddCachedSong(r.song, r.fileName, r.written, checksum)
+	}
+	c.lock.Unlock()
+	if err != nil {
+		logrus.Errorf("store song to cache: %v", err)
+		return
+	}
+	logrus.Debugf("cached song %s (%d B)", r.song.Id, r.written)
+
+	removed, size, err := c.Prune()
+	if err != nil {
+		logrus.Errorf("prune song cache: %v", err)
+	} else if removed > 0 {
+		logrus.Debugf("removed %d songs (%d B) from song cache", removed, size)
+	}
+}
+
+// cacheReader copies stream to cache file while stream is being read.
+type cacheReader struct {
+	io.ReadCloser
+	cache    *SongCache
+	song     *models.Song
+	file     *os.File
+	fileName string
+	hash     hash.Hash
+	// expected size, -1 if unknown
+	size     int64
+	written  int64
+	complete bool
+	failed   bool
+}
+
+func (r *cacheReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 && !r.failed {
+		_, writeErr := r.file.Write(p[:n])
+		if writeErr != nil {
+			logrus.Errorf("write song to cache: %v", writeErr)
+			r.failed = true
+		}
+		r.hash.Write(p[:n])
+		r.written += int64(n)
+	}
+	if err == io.EOF {
+		r.complete = true
+	}
+	return n, err
+}
+
+func (r *cacheReader) Close() error {
+	err := r.ReadCloser.Close()
+	// reader might be closed from audio callback, don't block it
+	go r.cache.store(r)
+	return err
+}
+
+// openVerifiedFile opens downloaded file and verifies its size and checksum.
+// If checksum is empty, only size is verified.
+func openVerifiedFile(download *models.Download) (*os.File, interfaces.AudioFormat, error) {
+	format := interfaces.AudioFormat(strings.TrimPrefix(path.Ext(download.File), "."))
+	file, err := os.Open(download.File)
+	if err != nil {
+		return nil, format, err
+	}
+
+	fail := func(err error) (*os.File, interfaces.AudioFormat, error) {
+		file.Close()
+		return nil, format, err
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return fail(fmt.Errorf("read file: %v", err))
+	}
+	if size != download.Size {
+		return fail(fmt.Errorf("invalid file size: %d B, expected %d B", size, download.Size))
+	}
+	if download.Checksum != "" && hex.EncodeToString(hasher.Sum(nil)) != download.Checksum {
+		return fail(errors.New("invalid checksum"))
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return fail(fmt.Errorf("seek file: %v", err))
+	}
+	return file, format, nil
+}
@@ -0,0 +1,60 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"io/ioutil"
+	"path"
+	"sync"
+	"testing"
+	"tryffel.net/go/jellycli/models"
+)
+
+func TestSongCache_Prune(t *testing.T) {
+	_, db := testPlayHistory(t)
+	cache := &SongCache{lock: &sync.Mutex{}, db: db, dir: t.TempDir(), maxSize: 250}
+
+	for _, id := range []models.Id{"song-1", "song-2", "song-3"} {
+		file := path.Join(cache.dir, id.String()+".mp3")
+		// file content does not match checksum, which is only checked when song is opened
+		err := ioutil.WriteFile(file, make([]byte, 100), 0600)
+		if err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		err = db.AddCachedSong(&models.Song{Id: id}, file, 100, "invalid")
+		if err != nil {
+			t.Fatalf("add cached song: %v", err)
+		}
+	}
+
+	removed, size, err := cache.Prune()
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if removed != 1 || size != 100 {
+		t.Errorf("prune: removed %d songs (%d B), want 1 song (100 B)", removed, size)
+	}
+	songs, err := cache.Songs()
+	if err != nil {
+		t.Fatalf("get cached songs: %v", err)
+	}
+	if len(songs) != 2 {
+		t.Errorf("cached songs: got %d, want 2", len(songs))
+	}
+}
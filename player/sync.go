@@ -0,0 +1,265 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package player
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/task"
+)
+
+// syncDelay is minimum time to wait before next periodic sync, so that startup is not slowed down.
+const syncDelay = time.Second * 30
+
+// LibrarySync syncs local cache with remote server in background. It implements interfaces.LibrarySync.
+// Sync runs periodically with configured interval, or immediately with SyncNow. Changes that server
+// reports with api.LibraryNotifier are applied to local cache immediately.
+type LibrarySync struct {
+	task.Task
+	lock     *sync.RWMutex
+	items    *Items
+	server   api.MediaServer
+	interval time.Duration
+	syncNow  chan bool
+	// ctx is cancelled when sync is stopped
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan bool
+
+	syncStatus   models.SyncStatus
+	syncFuncs    []func(status models.SyncStatus)
+	libraryFuncs []func()
+}
+
+func newLibrarySync(items *Items, server api.MediaServer) *LibrarySync {
+	s := &LibrarySync{
+		lock:    &sync.RWMutex{},
+		items:   items,
+		server:  server,
+		syncNow: make(chan bool, 1),
+		stopped: make(chan bool),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if config.AppConfig.Player.LibrarySyncMin > 0 {
+		s.interval = time.Minute * time.Duration(config.AppConfig.Player.LibrarySyncMin)
+	}
+	if items.useCache() {
+		refreshed, _, err := items.db.LastRefresh()
+		if err != nil {
+			logrus.Errorf("get last refresh: %v", err)
+		}
+		s.syncStatus.LastSync = refreshed
+	}
+	s.Name = "Library sync"
+	s.SetLoop(s.loop)
+	return s
+}
+
+// SyncNow starts syncing library in background. If sync is already running,
+// another sync is started once it has completed.
+func (s *LibrarySync) SyncNow() {
+	select {
+	case s.syncNow <- true:
+	default:
+	}
+}
+
+func (s *LibrarySync) GetSyncStatus() models.SyncStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.syncStatus
+}
+
+func (s *LibrarySync) AddSyncChangedCallback(cb func(status models.SyncStatus)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.syncFuncs = append(s.syncFuncs, cb)
+}
+
+func (s *LibrarySync) AddLibraryChangedCallback(cb func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.libraryFuncs = append(s.libraryFuncs, cb)
+}
+
+// Stop cancels ongoing sync and waits until sync has stopped, so that local database can be closed.
+func (s *LibrarySync) Stop() error {
+	s.cancel()
+	err := s.Task.Stop()
+	if err != nil {
+		return err
+	}
+	<-s.stopped
+	return nil
+}
+
+func (s *LibrarySync) loop() {
+	defer close(s.stopped)
+	lastAttempt := s.GetSyncStatus().LastSync
+	for {
+		var timer *time.Timer
+		var next <-chan time.Time
+		if s.interval > 0 {
+			wait := lastAttempt.Add(s.interval).Sub(time.Now())
+			if wait < syncDelay {
+				wait = syncDelay
+			}
+			timer = time.NewTimer(wait)
+			next = timer.C
+		}
+
+		select {
+		case <-s.StopChan():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-s.syncNow:
+			s.sync()
+		case <-next:
+			s.sync()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		lastAttempt = time.Now()
+	}
+}
+
+// connected syncs library if remote server was offline while periodic sync was due.
+func (s *LibrarySync) connected() {
+	if s.interval > 0 && time.Now().Sub(s.GetSyncStatus().LastSync) > s.interval {
+		s.SyncNow()
+	}
+}
+
+// sync refreshes local cache and notifies callbacks about progress.
+func (s *LibrarySync) sync() {
+	var err error
+	if !s.items.useCache() {
+		err = errors.New("local cache is disabled")
+	} else if conn, ok := s.server.(interfaces.Connection); ok && !conn.IsOnline() {
+		err = errors.New("server is offline")
+	}
+	if err != nil {
+		logrus.Warningf("Skip library sync: %v", err)
+		s.update(func(status *models.SyncStatus) {
+			status.Error = err.Error()
+		})
+		return
+	}
+
+	logrus.Info("Sync library")
+	s.update(func(status *models.SyncStatus) {
+		status.Running = true
+		status.Stage = ""
+		status.Error = ""
+	})
+
+	summary, err := s.items.refreshLocalCache(s.ctx, false, func(stage string) {
+		s.update(func(status *models.SyncStatus) {
+			status.Stage = stage
+		})
+	})
+	if err != nil {
+		logrus.Errorf("sync library: %v", err)
+	} else {
+		logrus.Infof("Library synced in %.1f s", summary.Took.Seconds())
+		if summary.Changed() {
+			s.notifyLibrary()
+		}
+	}
+
+	s.update(func(status *models.SyncStatus) {
+		status.Running = false
+		status.Stage = ""
+		if err != nil {
+			status.Error = err.Error()
+		} else {
+			status.LastSync = time.Now()
+			status.Summary = summary
+		}
+	})
+}
+
+// update modifies status and calls changed callbacks.
+func (s *LibrarySync) update(modify func(status *models.SyncStatus)) {
+	s.lock.Lock()
+	modify(&s.syncStatus)
+	status := s.syncStatus
+	callbacks := s.syncFuncs
+	s.lock.Unlock()
+
+	for _, cb := range callbacks {
+		cb(status)
+	}
+}
+
+// libraryChanged applies changes reported by server to local cache. Added and updated items
+// are pulled with sync, since their type is not known.
+func (s *LibrarySync) libraryChanged(changes *models.LibraryChanges) {
+	if !s.items.useCache() {
+		if len(changes.Added) > 0 || len(changes.Updated) > 0 || len(changes.Removed) > 0 {
+			s.notifyLibrary()
+		}
+		return
+	}
+
+	changed := false
+	if len(changes.Removed) > 0 {
+		n, err := s.items.db.RemoveItems(changes.Removed)
+		if err != nil {
+			logrus.Errorf("remove items from local cache: %v", err)
+		} else {
+			logrus.Debugf("Removed %d items from local cache", n)
+			changed = n > 0
+		}
+	}
+	if len(changes.UserData) > 0 {
+		// play counts change on every play, only refresh views when favorites change
+		n, err := s.items.db.UpdateUserData(changes.UserData)
+		if err != nil {
+			logrus.Errorf("update user data in local cache: %v", err)
+		} else if n > 0 {
+			changed = true
+		}
+	}
+	if changed {
+		s.notifyLibrary()
+	}
+	if len(changes.Added) > 0 || len(changes.Updated) > 0 || changes.ScanCompleted {
+		s.SyncNow()
+	}
+}
+
+// notifyLibrary calls library changed callbacks.
+func (s *LibrarySync) notifyLibrary() {
+	s.lock.RLock()
+	callbacks := s.libraryFuncs
+	s.lock.RUnlock()
+	for _, cb := range callbacks {
+		cb()
+	}
+}
@@ -0,0 +1,245 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package player
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+// offlineServer is refreshServer that can be disconnected.
+type offlineServer struct {
+	*refreshServer
+	online bool
+}
+
+func (o *offlineServer) IsOnline() bool {
+	return o.online
+}
+
+func (o *offlineServer) AddConnectionCallback(func(online bool)) {}
+
+func (o *offlineServer) GetRemoteControlStatus() models.RemoteControlStatus {
+	return models.RemoteControlStatus{}
+}
+
+func (o *offlineServer) AddRemoteControlCallback(func(status models.RemoteControlStatus)) {}
+
+func (o *offlineServer) AddMessageCallback(func(msg models.ServerMessage)) {}
+
+func TestLibrarySync(t *testing.T) {
+	_, db := testPlayHistory(t)
+	config.AppConfig.Player.EnableLocalCache = true
+	server := &offlineServer{
+		refreshServer: &refreshServer{
+			MockServer: api.NewMockServer(),
+			artists:    []*models.Artist{{Id: "artist-1", Name: "artist 1"}},
+			albums:     []*models.Album{{Id: "album-1", Name: "album 1", Artist: "artist-1"}},
+			songs: map[models.Id][]*models.Song{
+				"album-1": {{Id: "song-1", Album: "album-1"}},
+			},
+		},
+	}
+	items := &Items{browser: server, db: db}
+	s := newLibrarySync(items, server)
+
+	statuses := make(chan models.SyncStatus, 20)
+	s.AddSyncChangedCallback(func(status models.SyncStatus) {
+		statuses <- status
+	})
+
+	err := s.Start()
+	if err != nil {
+		t.Fatalf("start sync: %v", err)
+	}
+	defer s.Stop()
+
+	// wait until sync has completed or failed
+	waitSync := func() []models.SyncStatus {
+		all := []models.SyncStatus{}
+		timeout := time.After(time.Second * 5)
+		for {
+			select {
+			case status := <-statuses:
+				all = append(all, status)
+				if !status.Running {
+					return all
+				}
+			case <-timeout:
+				t.Fatalf("sync did not complete")
+			}
+		}
+	}
+
+	s.SyncNow()
+	all := waitSync()
+	if len(all) != 1 || all[0].Error != "server is offline" {
+		t.Errorf("offline sync: got %v", all)
+	}
+
+	server.online = true
+	s.SyncNow()
+	all = waitSync()
+
+	stages := []string{}
+	for _, v := range all {
+		if v.Stage != "" {
+			stages = append(stages, v.Stage)
+		}
+	}
+	wantStages := []string{"artists", "albums", "songs", "playlists", "genres"}
+	if !reflect.DeepEqual(stages, wantStages) {
+		t.Errorf("sync stages: got %v, want %v", stages, wantStages)
+	}
+
+	status := s.GetSyncStatus()
+	if status.Running || status.Error != "" {
+		t.Errorf("sync not completed: %v", status)
+	}
+	if status.LastSync.IsZero() || status.Summary == nil || !status.Summary.Changed() {
+		t.Fatalf("sync summary not set: %v", status)
+	}
+	if status.Summary.Songs.Added != 1 {
+		t.Errorf("synced songs: %s", status.Summary.Songs)
+	}
+
+	songs, err := db.GetAlbumSongs("album-1")
+	if err != nil {
+		t.Fatalf("get album songs: %v", err)
+	}
+	if len(songs) != 1 {
+		t.Errorf("songs not synced to local cache: %d", len(songs))
+	}
+}
+
+func TestLibrarySync_libraryChanged(t *testing.T) {
+	_, db := testPlayHistory(t)
+	config.AppConfig.Player.EnableLocalCache = true
+	err := db.UpdateSongs([]*models.Song{{Id: "song-1", Album: "album-1"}, {Id: "song-2", Album: "album-1"}})
+	if err != nil {
+		t.Fatalf("update songs: %v", err)
+	}
+	server := &refreshServer{MockServer: api.NewMockServer()}
+	s := newLibrarySync(&Items{browser: server, db: db}, server)
+	notified := 0
+	s.AddLibraryChangedCallback(func() {
+		notified += 1
+	})
+
+	s.libraryChanged(&models.LibraryChanges{
+		Removed:  []models.Id{"song-2"},
+		UserData: []models.UserData{{Item: "song-1", Favorite: true, PlayCount: 2}},
+	})
+	if notified != 1 {
+		t.Errorf("library changed callbacks: %d, want 1", notified)
+	}
+	songs, err := db.GetAlbumSongs("album-1")
+	if err != nil {
+		t.Fatalf("get album songs: %v", err)
+	}
+	if len(songs) != 1 || !songs[0].Favorite || songs[0].PlayCount != 2 {
+		t.Errorf("changes not applied to local cache: %v", songs)
+	}
+
+	// play count alone does not refresh views, added items are synced
+	s.libraryChanged(&models.LibraryChanges{
+		Added:    []models.Id{"song-3"},
+		UserData: []models.UserData{{Item: "song-1", Favorite: true, PlayCount: 3}},
+	})
+	if notified != 1 {
+		t.Errorf("library changed callbacks after play count: %d, want 1", notified)
+	}
+	select {
+	case <-s.syncNow:
+	default:
+		t.Errorf("sync not requested for added items")
+	}
+}
+
+// blockingServer blocks pulling artists until released.
+type blockingServer struct {
+	*offlineServer
+	started chan bool
+	release chan bool
+}
+
+func (b *blockingServer) GetArtists(ctx context.Context, query *interfaces.QueryOpts) ([]*models.Artist, int, error) {
+	b.started <- true
+	<-b.release
+	return b.offlineServer.GetArtists(ctx, query)
+}
+
+func TestLibrarySync_Stop(t *testing.T) {
+	_, db := testPlayHistory(t)
+	config.AppConfig.Player.EnableLocalCache = true
+	server := &blockingServer{
+		offlineServer: &offlineServer{
+			refreshServer: &refreshServer{
+				MockServer: api.NewMockServer(),
+				artists:    []*models.Artist{{Id: "artist-1", Name: "artist 1"}},
+				albums:     []*models.Album{{Id: "album-1", Name: "album 1", Artist: "artist-1"}},
+			},
+			online: true,
+		},
+		started: make(chan bool, 1),
+		release: make(chan bool),
+	}
+	items := &Items{browser: server, db: db}
+	s := newLibrarySync(items, server)
+	err := s.Start()
+	if err != nil {
+		t.Fatalf("start sync: %v", err)
+	}
+	s.SyncNow()
+	<-server.started
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- s.Stop()
+	}()
+	select {
+	case <-stopped:
+		t.Fatalf("stop returned while sync was running")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	close(server.release)
+	select {
+	case err = <-stopped:
+		if err != nil {
+			t.Errorf("stop: %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("sync did not stop")
+	}
+
+	// refresh is cancelled before albums
+	albums, _, err := db.GetAlbums(interfaces.DefaultQueryOpts())
+	if err != nil {
+		t.Fatalf("get albums: %v", err)
+	}
+	if len(albums) != 0 {
+		t.Errorf("refresh continued after stop")
+	}
+}
@@ -0,0 +1,463 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+const (
+	// how often clock offset to server is measured while in group
+	syncPlayTimeSyncInterval = time.Minute
+	// number of clock measurements, the one with shortest round trip is used
+	syncPlayTimeSamples = 5
+	// playback position is corrected once it differs from group more than this
+	syncPlayMaxDrift = time.Millisecond * 500
+	// drift is not corrected right after playback has started or been corrected
+	syncPlaySettleTime = time.Second * 3
+)
+
+var errSyncPlayNotSupported = errors.New("server does not support SyncPlay")
+
+// SyncPlay follows queue and play state of a SyncPlay group. Group commands are scheduled with server time,
+// which is converted to local time with measured clock offset.
+type SyncPlay struct {
+	player *Player
+	server api.SyncPlay
+
+	lock           sync.RWMutex
+	syncPlayStatus models.SyncPlayStatus
+	groupQueue     *models.SyncPlayQueue
+
+	// waitReady is true while current song is being loaded and group is waiting for player to be ready
+	waitReady bool
+	// position to start song from, once it has been loaded
+	startPosition time.Duration
+
+	// groupPlaying is true when group is playing. Song was at anchorPosition at local time anchorTime.
+	groupPlaying   bool
+	anchorTime     time.Time
+	anchorPosition time.Duration
+	lastCorrection time.Time
+
+	lastTimeSync  time.Time
+	commandTimer  *time.Timer
+	syncPlayFuncs []func(status models.SyncPlayStatus)
+}
+
+func newSyncPlay(player *Player, server api.MediaServer) *SyncPlay {
+	s := &SyncPlay{
+		player: player,
+	}
+	if syncPlay, ok := server.(api.SyncPlay); ok {
+		s.server = syncPlay
+		syncPlay.SetSyncPlayHandler(s)
+	}
+	return s
+}
+
+// GetSyncPlayGroups returns groups that user can join.
+func (s *SyncPlay) GetSyncPlayGroups() ([]*models.SyncPlayGroup, error) {
+	if s.server == nil {
+		return nil, errSyncPlayNotSupported
+	}
+	return s.server.GetSyncPlayGroups()
+}
+
+// CreateSyncPlayGroup creates new group and joins it.
+func (s *SyncPlay) CreateSyncPlayGroup(name string) error {
+	if s.server == nil {
+		return errSyncPlayNotSupported
+	}
+	return s.server.CreateSyncPlayGroup(name)
+}
+
+// JoinSyncPlayGroup joins group. Player starts following group once server confirms joining.
+func (s *SyncPlay) JoinSyncPlayGroup(group models.Id) error {
+	if s.server == nil {
+		return errSyncPlayNotSupported
+	}
+	return s.server.JoinSyncPlayGroup(group)
+}
+
+// LeaveSyncPlayGroup leaves current group.
+func (s *SyncPlay) LeaveSyncPlayGroup() error {
+	if s.server == nil {
+		return errSyncPlayNotSupported
+	}
+	return s.server.LeaveSyncPlayGroup()
+}
+
+// GetSyncPlayStatus returns current status.
+func (s *SyncPlay) GetSyncPlayStatus() models.SyncPlayStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.syncPlayStatus
+}
+
+// AddSyncPlayChangedCallback adds a function that is called every time status changes.
+func (s *SyncPlay) AddSyncPlayChangedCallback(cb func(status models.SyncPlayStatus)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.syncPlayFuncs = append(s.syncPlayFuncs, cb)
+}
+
+func (s *SyncPlay) notifySyncPlay() {
+	s.lock.RLock()
+	status := s.syncPlayStatus
+	callbacks := s.syncPlayFuncs
+	s.lock.RUnlock()
+	for _, cb := range callbacks {
+		cb(status)
+	}
+}
+
+func (s *SyncPlay) inGroup() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.syncPlayStatus.Group != nil
+}
+
+// OnSyncPlayGroup updates group.
+func (s *SyncPlay) OnSyncPlayGroup(update *models.SyncPlayGroupUpdate) {
+	s.lock.Lock()
+	joined := false
+	// group is copied, status may be in use elsewhere
+	var group *models.SyncPlayGroup
+	if s.syncPlayStatus.Group != nil {
+		g := *s.syncPlayStatus.Group
+		group = &g
+	}
+
+	switch update.Type {
+	case models.SyncPlayGroupJoined:
+		logrus.Infof("Joined SyncPlay group %s", update.Group.Name)
+		group = update.Group
+		s.syncPlayStatus.Error = ""
+		joined = true
+	case models.SyncPlayGroupLeft:
+		logrus.Info("Left SyncPlay group")
+		group = nil
+		s.groupQueue = nil
+		s.waitReady = false
+		s.groupPlaying = false
+		s.stopCommandTimer()
+	case models.SyncPlayUserJoined:
+		if group != nil {
+			group.Participants = append(append([]string{}, group.Participants...), update.User)
+		}
+	case models.SyncPlayUserLeft:
+		if group != nil {
+			participants := make([]string, 0, len(group.Participants))
+			for _, v := range group.Participants {
+				if v != update.User {
+					participants = append(participants, v)
+				}
+			}
+			group.Participants = participants
+		}
+	case models.SyncPlayStateChanged:
+		if group != nil {
+			group.State = update.State
+		}
+	case models.SyncPlayError:
+		logrus.Warningf("SyncPlay: %s", update.Message)
+		s.syncPlayStatus.Error = update.Message
+	}
+	s.syncPlayStatus.Group = group
+	s.lock.Unlock()
+
+	if joined {
+		// group queue defines order
+		if s.player.Audio.getStatus().Shuffle {
+			s.player.SetShuffle(false)
+		}
+		go s.syncTime()
+	}
+	s.notifySyncPlay()
+}
+
+// OnSyncPlayQueue replaces queue with group queue. If playing item changed, it is loaded and
+// group is notified once player is ready to play it.
+func (s *SyncPlay) OnSyncPlayQueue(queue *models.SyncPlayQueue) {
+	s.lock.Lock()
+	if s.syncPlayStatus.Group == nil {
+		s.lock.Unlock()
+		return
+	}
+	previous := s.groupQueue.Playing()
+	current := queue.Playing()
+	s.groupQueue = queue
+	changed := current == nil || previous == nil || previous.PlaylistItem != current.PlaylistItem
+	if changed {
+		s.waitReady = current != nil
+		s.startPosition = queue.StartPosition
+		s.groupPlaying = false
+		s.stopCommandTimer()
+	}
+	s.lock.Unlock()
+
+	p := s.player
+	if current == nil {
+		p.Audio.StopMedia()
+		p.Queue.ClearQueue(true)
+		return
+	}
+
+	songs := make([]*models.Song, 0, len(queue.Items)-queue.PlayingIndex)
+	for _, v := range queue.Items[queue.PlayingIndex:] {
+		songs = append(songs, v.Song)
+	}
+	if changed {
+		logrus.Debugf("SyncPlay: play %s (%s)", current.Song.Name, queue.Reason)
+		s.syncPlayRequest(&models.SyncPlayRequest{
+			Type:         models.SyncPlayRequestBuffering,
+			Position:     queue.StartPosition,
+			PlaylistItem: current.PlaylistItem,
+		})
+		p.Audio.StopMedia()
+		p.Queue.ClearQueue(true)
+		p.Queue.AddSongs(songs)
+	} else {
+		// only upcoming songs changed
+		p.Queue.ClearQueue(false)
+		p.Queue.AddSongs(songs[1:])
+	}
+}
+
+// OnSyncPlayCommand schedules command to be run at the same time as other group members run it.
+func (s *SyncPlay) OnSyncPlayCommand(command *models.SyncPlayCommand) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.syncPlayStatus.Group == nil {
+		return
+	}
+	at := command.When.Add(-s.syncPlayStatus.TimeOffset)
+	s.stopCommandTimer()
+	s.commandTimer = time.AfterFunc(time.Until(at), func() {
+		s.runCommand(command, at)
+	})
+}
+
+func (s *SyncPlay) stopCommandTimer() {
+	if s.commandTimer != nil {
+		s.commandTimer.Stop()
+		s.commandTimer = nil
+	}
+}
+
+// runCommand runs group command that was scheduled at given local time.
+func (s *SyncPlay) runCommand(command *models.SyncPlayCommand, at time.Time) {
+	s.lock.Lock()
+	s.groupPlaying = command.Command == models.SyncPlayUnpause
+	s.anchorTime = at
+	s.anchorPosition = command.Position
+	s.startPosition = command.Position
+	s.lastCorrection = time.Time{}
+	waiting := s.waitReady
+	s.lock.Unlock()
+
+	if waiting && command.Command != models.SyncPlayStop {
+		// command is applied once song has been loaded
+		return
+	}
+
+	audio := s.player.Audio
+	switch command.Command {
+	case models.SyncPlayUnpause:
+		audio.SeekTo(durationToTick(command.Position + time.Since(at)))
+		audio.Continue()
+	case models.SyncPlayPause:
+		audio.Pause()
+		audio.SeekTo(durationToTick(command.Position))
+	case models.SyncPlaySeek:
+		audio.Pause()
+		audio.SeekTo(durationToTick(command.Position))
+		s.syncPlayRequest(&models.SyncPlayRequest{
+			Type:     models.SyncPlayRequestReady,
+			Position: command.Position,
+		})
+	case models.SyncPlayStop:
+		audio.StopMedia()
+	}
+}
+
+// syncPlaySongLoaded is called when song has been loaded and it started playing. If group is waiting for
+// this song, seek to group position and tell group player is ready.
+func (s *SyncPlay) syncPlaySongLoaded() {
+	s.lock.Lock()
+	if s.syncPlayStatus.Group == nil || !s.waitReady {
+		s.lock.Unlock()
+		return
+	}
+	s.waitReady = false
+	playing := s.groupPlaying
+	position := s.startPosition
+	if playing {
+		position = s.expectedPosition(time.Now())
+	}
+	s.lock.Unlock()
+
+	audio := s.player.Audio
+	if !playing {
+		audio.Pause()
+	}
+	audio.SeekTo(durationToTick(position))
+	s.syncPlayRequest(&models.SyncPlayRequest{
+		Type:      models.SyncPlayRequestReady,
+		Position:  position,
+		IsPlaying: playing,
+	})
+}
+
+// syncPlaySongCompleted requests next song from group. Returns false if not in group.
+func (s *SyncPlay) syncPlaySongCompleted() bool {
+	s.lock.Lock()
+	if s.syncPlayStatus.Group == nil {
+		s.lock.Unlock()
+		return false
+	}
+	s.groupPlaying = false
+	s.lock.Unlock()
+
+	s.player.Audio.StopMedia()
+	s.syncPlayRequest(&models.SyncPlayRequest{Type: models.SyncPlayRequestNext})
+	return true
+}
+
+// syncPlayControl sends playback request to group instead of controlling player directly.
+// Returns false if not in group.
+func (s *SyncPlay) syncPlayControl(request models.SyncPlayRequestType, position time.Duration) bool {
+	if !s.inGroup() {
+		return false
+	}
+	s.syncPlayRequest(&models.SyncPlayRequest{
+		Type:     request,
+		Position: position,
+	})
+	return true
+}
+
+// send request to group in background. Time and current item are filled if not set.
+func (s *SyncPlay) syncPlayRequest(request *models.SyncPlayRequest) {
+	s.lock.RLock()
+	if request.When.IsZero() {
+		request.When = time.Now().Add(s.syncPlayStatus.TimeOffset)
+	}
+	if item := s.groupQueue.Playing(); item != nil && request.PlaylistItem == "" {
+		request.PlaylistItem = item.PlaylistItem
+	}
+	s.lock.RUnlock()
+
+	go func() {
+		err := s.server.SyncPlayRequest(request)
+		if err != nil {
+			logrus.Errorf("SyncPlay request: %v", err)
+		}
+	}()
+}
+
+// position of playing song according to group. Caller must hold lock.
+func (s *SyncPlay) expectedPosition(now time.Time) time.Duration {
+	if !s.groupPlaying {
+		return s.anchorPosition
+	}
+	return s.anchorPosition + now.Sub(s.anchorTime)
+}
+
+// syncPlayTick measures clock offset periodically and corrects playback position if it has
+// drifted from group.
+func (s *SyncPlay) syncPlayTick() {
+	now := time.Now()
+	s.lock.Lock()
+	if s.syncPlayStatus.Group == nil {
+		s.lock.Unlock()
+		return
+	}
+	timeSync := now.Sub(s.lastTimeSync) > syncPlayTimeSyncInterval
+	check := s.groupPlaying && !s.waitReady && now.Sub(s.anchorTime) > syncPlaySettleTime &&
+		now.Sub(s.lastCorrection) > syncPlaySettleTime
+	expected := s.expectedPosition(now)
+	s.lock.Unlock()
+
+	if timeSync {
+		go s.syncTime()
+	}
+	if !check {
+		return
+	}
+
+	actual := time.Duration(s.player.Audio.getPastTicks()) * time.Millisecond
+	if drift := actual - expected; drift > syncPlayMaxDrift || drift < -syncPlayMaxDrift {
+		logrus.Infof("SyncPlay: playback is %v off from group, seek to %v", drift, expected)
+		s.lock.Lock()
+		s.lastCorrection = now
+		s.lock.Unlock()
+		s.player.Audio.SeekTo(durationToTick(expected))
+	}
+}
+
+// syncTime measures offset between server clock and local clock and reports round trip time to server.
+func (s *SyncPlay) syncTime() {
+	s.lock.Lock()
+	s.lastTimeSync = time.Now()
+	s.lock.Unlock()
+
+	var offset time.Duration
+	roundTrip := time.Duration(-1)
+	for i := 0; i < syncPlayTimeSamples; i++ {
+		sent := time.Now()
+		received, transmitted, err := s.server.GetServerTime()
+		done := time.Now()
+		if err != nil {
+			logrus.Errorf("SyncPlay: measure time offset: %v", err)
+			return
+		}
+		o, rtt := timeOffset(sent, received, transmitted, done)
+		if roundTrip < 0 || rtt < roundTrip {
+			offset = o
+			roundTrip = rtt
+		}
+	}
+	logrus.Debugf("SyncPlay: time offset to server %v, round trip %v", offset, roundTrip)
+
+	s.lock.Lock()
+	s.syncPlayStatus.TimeOffset = offset
+	s.lock.Unlock()
+	s.notifySyncPlay()
+	s.syncPlayRequest(&models.SyncPlayRequest{Type: models.SyncPlayRequestPing, Ping: roundTrip})
+}
+
+// timeOffset calculates difference between server clock and local clock, as well as round trip time
+// of request. Sent and done are local times, received and transmitted are server times.
+func timeOffset(sent, received, transmitted, done time.Time) (offset time.Duration, roundTrip time.Duration) {
+	offset = (received.Sub(sent) + transmitted.Sub(done)) / 2
+	roundTrip = done.Sub(sent) - transmitted.Sub(received)
+	return offset, roundTrip
+}
+
+func durationToTick(d time.Duration) interfaces.AudioTick {
+	return interfaces.AudioTick(d.Milliseconds())
+}
@@ -0,0 +1,334 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package player
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/api"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/models"
+)
+
+func Test_timeOffset(t *testing.T) {
+	sent := time.Date(2020, 11, 2, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		received      time.Duration
+		transmitted   time.Duration
+		done          time.Duration
+		wantOffset    time.Duration
+		wantRoundTrip time.Duration
+	}{
+		{
+			name:          "clocks in sync",
+			received:      time.Millisecond * 50,
+			transmitted:   time.Millisecond * 60,
+			done:          time.Millisecond * 110,
+			wantOffset:    0,
+			wantRoundTrip: time.Millisecond * 100,
+		},
+		{
+			name:          "server ahead",
+			received:      time.Second*2 + time.Millisecond*50,
+			transmitted:   time.Second*2 + time.Millisecond*50,
+			done:          time.Millisecond * 100,
+			wantOffset:    time.Second * 2,
+			wantRoundTrip: time.Millisecond * 100,
+		},
+		{
+			name:          "server behind",
+			received:      -time.Second + time.Millisecond*20,
+			transmitted:   -time.Second + time.Millisecond*30,
+			done:          time.Millisecond * 50,
+			wantOffset:    -time.Second,
+			wantRoundTrip: time.Millisecond * 40,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, roundTrip := timeOffset(sent, sent.Add(tt.received), sent.Add(tt.transmitted), sent.Add(tt.done))
+			if offset != tt.wantOffset {
+				t.Errorf("offset: got %v, want %v", offset, tt.wantOffset)
+			}
+			if roundTrip != tt.wantRoundTrip {
+				t.Errorf("round trip: got %v, want %v", roundTrip, tt.wantRoundTrip)
+			}
+		})
+	}
+}
+
+// syncPlayServer records requests sent to group.
+type syncPlayServer struct {
+	requests chan *models.SyncPlayRequest
+}
+
+func (s *syncPlayServer) GetSyncPlayGroups() ([]*models.SyncPlayGroup, error) { return nil, nil }
+func (s *syncPlayServer) CreateSyncPlayGroup(name string) error               { return nil }
+func (s *syncPlayServer) JoinSyncPlayGroup(group models.Id) error             { return nil }
+func (s *syncPlayServer) LeaveSyncPlayGroup() error                           { return nil }
+func (s *syncPlayServer) SetSyncPlayHandler(handler api.SyncPlayHandler)      {}
+
+func (s *syncPlayServer) GetServerTime() (received, sent time.Time, err error) {
+	return time.Now(), time.Now(), nil
+}
+
+func (s *syncPlayServer) SyncPlayRequest(request *models.SyncPlayRequest) error {
+	s.requests <- request
+	return nil
+}
+
+// next returns next request sent to group, or nil if there is none.
+func (s *syncPlayServer) next(wait time.Duration) *models.SyncPlayRequest {
+	select {
+	case request := <-s.requests:
+		return request
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// testStreamer is a silent song that can be seeked.
+type testStreamer struct {
+	lock     sync.Mutex
+	position int
+	length   int
+}
+
+func (s *testStreamer) Stream(samples [][2]float64) (int, bool) { return 0, false }
+func (s *testStreamer) Err() error                              { return nil }
+func (s *testStreamer) Len() int                                { return s.length }
+func (s *testStreamer) Close() error                            { return nil }
+
+func (s *testStreamer) Position() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.position
+}
+
+func (s *testStreamer) Seek(p int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.position = p
+	return nil
+}
+
+func (s *testStreamer) setPosition(position time.Duration) {
+	s.Seek(int(position.Milliseconds() * config.AudioSamplingRate / 1000))
+}
+
+// newTestSyncPlay returns SyncPlay that is in a group and plays a 10 minute song.
+func newTestSyncPlay() (*SyncPlay, *syncPlayServer, *testStreamer) {
+	server := &syncPlayServer{requests: make(chan *models.SyncPlayRequest, 10)}
+	player := &Player{Audio: newAudio(), Queue: newQueue()}
+	streamer := &testStreamer{length: config.AudioSamplingRate * 600}
+	player.Audio.streamer = streamer
+	s := &SyncPlay{player: player, server: server}
+	s.syncPlayStatus.Group = &models.SyncPlayGroup{Id: "group-1", Name: "group"}
+	s.lastTimeSync = time.Now()
+	return s, server, streamer
+}
+
+// playing position of test player.
+func syncPlayPosition(s *SyncPlay) time.Duration {
+	return time.Duration(s.player.Audio.getPastTicks()) * time.Millisecond
+}
+
+func syncPlayQueue(playing int, songs ...models.Id) *models.SyncPlayQueue {
+	queue := &models.SyncPlayQueue{PlayingIndex: playing, StartPosition: time.Second * 5}
+	for _, v := range songs {
+		queue.Items = append(queue.Items, models.SyncPlayItem{Song: &models.Song{Id: v}, PlaylistItem: "item-" + v})
+	}
+	return queue
+}
+
+func TestSyncPlay_OnSyncPlayQueue(t *testing.T) {
+	s, server, _ := newTestSyncPlay()
+
+	queueIds := func() []models.Id {
+		ids := []models.Id{}
+		for _, v := range s.player.Queue.GetQueue() {
+			ids = append(ids, v.Id)
+		}
+		return ids
+	}
+
+	steps := []struct {
+		name  string
+		queue *models.SyncPlayQueue
+		want  []models.Id
+		// buffering request is sent for new playing item
+		buffering models.Id
+	}{
+		{
+			name:      "new queue",
+			queue:     syncPlayQueue(0, "song-1", "song-2", "song-3"),
+			want:      []models.Id{"song-1", "song-2", "song-3"},
+			buffering: "item-song-1",
+		},
+		{
+			name:  "upcoming songs changed",
+			queue: syncPlayQueue(0, "song-1", "song-4"),
+			want:  []models.Id{"song-1", "song-4"},
+		},
+		{
+			name:      "playing song changed",
+			queue:     syncPlayQueue(1, "song-1", "song-4", "song-5"),
+			want:      []models.Id{"song-4", "song-5"},
+			buffering: "item-song-4",
+		},
+	}
+	for _, step := range steps {
+		s.OnSyncPlayQueue(step.queue)
+		if got := queueIds(); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: queue: got %v, want %v", step.name, got, step.want)
+		}
+		request := server.next(time.Millisecond * 100)
+		if step.buffering == "" {
+			if request != nil {
+				t.Errorf("%s: unexpected request: %+v", step.name, request)
+			}
+			continue
+		}
+		if request == nil || request.Type != models.SyncPlayRequestBuffering || request.PlaylistItem != step.buffering ||
+			request.Position != time.Second*5 {
+			t.Errorf("%s: invalid buffering request: %+v", step.name, request)
+		}
+		if !s.waitReady {
+			t.Errorf("%s: not waiting for new song to load", step.name)
+		}
+	}
+}
+
+func TestSyncPlay_OnSyncPlayCommand(t *testing.T) {
+	s, _, _ := newTestSyncPlay()
+	// server clock is 2 seconds behind local clock
+	s.syncPlayStatus.TimeOffset = -time.Second * 2
+
+	start := time.Now()
+	s.OnSyncPlayCommand(&models.SyncPlayCommand{
+		Command:  models.SyncPlayPause,
+		When:     start.Add(-time.Second*2 + time.Millisecond*300),
+		Position: time.Second * 10,
+	})
+	if s.player.Audio.getStatus().Paused {
+		t.Fatalf("command was run before scheduled time")
+	}
+
+	deadline := time.Now().Add(time.Second * 2)
+	for !s.player.Audio.getStatus().Paused && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*250 {
+		t.Errorf("command was run too early: after %v", elapsed)
+	}
+	if !s.player.Audio.getStatus().Paused {
+		t.Fatalf("command was not run")
+	}
+	if position := syncPlayPosition(s); position != time.Second*10 {
+		t.Errorf("position: got %v, want %v", position, time.Second*10)
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if want := start.Add(time.Millisecond * 300); !s.anchorTime.Equal(want) {
+		t.Errorf("command local time: got %v, want %v", s.anchorTime, want)
+	}
+}
+
+func TestSyncPlay_waitReady(t *testing.T) {
+	s, server, streamer := newTestSyncPlay()
+
+	s.OnSyncPlayQueue(syncPlayQueue(0, "song-1"))
+	if request := server.next(time.Second); request == nil || request.Type != models.SyncPlayRequestBuffering {
+		t.Fatalf("expected buffering request, got %+v", request)
+	}
+	// new song has been opened, but it is not ready yet
+	s.player.Audio.streamer = streamer
+
+	// group started playing a second ago, while song is still loading
+	s.runCommand(&models.SyncPlayCommand{Command: models.SyncPlayUnpause, Position: time.Second * 3},
+		time.Now().Add(-time.Second))
+	if position := syncPlayPosition(s); position != 0 {
+		t.Errorf("player was seeked while loading song: %v", position)
+	}
+
+	s.syncPlaySongLoaded()
+	request := server.next(time.Second)
+	if request == nil || request.Type != models.SyncPlayRequestReady || !request.IsPlaying {
+		t.Fatalf("expected ready request, got %+v", request)
+	}
+	if request.Position < time.Second*4 || request.Position > time.Second*4+time.Millisecond*500 {
+		t.Errorf("ready position: got %v, want 4s", request.Position)
+	}
+	if position := syncPlayPosition(s); position != request.Position.Truncate(time.Millisecond) {
+		t.Errorf("position: got %v, want %v", position, request.Position)
+	}
+	if s.player.Audio.getStatus().Paused {
+		t.Errorf("player paused while group is playing")
+	}
+
+	// ready is sent only once
+	s.syncPlaySongLoaded()
+	if request := server.next(time.Millisecond * 100); request != nil {
+		t.Errorf("unexpected request: %+v", request)
+	}
+}
+
+func TestSyncPlay_syncPlayTick(t *testing.T) {
+	tests := []struct {
+		name      string
+		drift     time.Duration
+		corrected bool
+	}{
+		{name: "in sync", drift: 0},
+		{name: "small drift ahead", drift: syncPlayMaxDrift - time.Millisecond*100},
+		{name: "small drift behind", drift: -syncPlayMaxDrift + time.Millisecond*100},
+		{name: "ahead", drift: syncPlayMaxDrift + time.Millisecond*200, corrected: true},
+		{name: "behind", drift: -time.Second * 3, corrected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, streamer := newTestSyncPlay()
+			// group has been playing for 20 seconds
+			s.groupPlaying = true
+			s.anchorTime = time.Now().Add(-time.Second * 20)
+			s.anchorPosition = 0
+
+			position := time.Second*20 + tt.drift
+			streamer.setPosition(position)
+			s.syncPlayTick()
+
+			got := syncPlayPosition(s)
+			if !tt.corrected {
+				if got != position {
+					t.Errorf("position changed: got %v, want %v", got, position)
+				}
+				return
+			}
+			if got < time.Second*20 || got > time.Second*20+time.Millisecond*200 {
+				t.Errorf("position not corrected: got %v, want 20s", got)
+			}
+			if s.lastCorrection.IsZero() {
+				t.Errorf("correction time not set")
+			}
+		})
+	}
+}
@@ -30,15 +30,11 @@ import (
 	"github.com/sirupsen/logrus"
 	"os"
 	"path"
-	"strings"
 	"time"
 	"tryffel.net/go/jellycli/config"
 	"tryffel.net/go/jellycli/models"
-	"tryffel.net/go/jellycli/storage/migrations"
 )
 
-const schemaLevel = 1
-
 // Db implements storing relational data to local database as cache.
 // Schema reflects the data coming from server and tries to store updated content
 // and not enforce relational integrity.
@@ -50,6 +46,8 @@ type Db struct {
 
 	builder squirrel.StatementBuilderType
 	engine  *sqlx.DB
+	// fts is true if full-text search is enabled
+	fts bool
 }
 
 func newDb(file, id string) (*Db, error) {
@@ -67,15 +65,20 @@ func newDb(file, id string) (*Db, error) {
 		return db, err
 	}
 
-	err = db.checkSchema()
+	level, err := db.getSchemaLevel()
 	if err != nil {
-		if strings.Contains(err.Error(), "schema is invalid") {
-			err = db.initDb()
-		} else {
+		return db, err
+	}
+	if level > schemaLevel {
+		return db, fmt.Errorf("database schema is invalid: supported %d, database: %d", schemaLevel, level)
+	}
+	if level < schemaLevel {
+		err = db.migrate(level)
+		if err != nil {
 			return db, err
 		}
 	}
-	return db, err
+	return db, db.initSearch()
 }
 
 func NewDb(id string) (*Db, error) {
@@ -86,65 +89,17 @@ func NewDb(id string) (*Db, error) {
 			return nil, fmt.Errorf("create cache dir: %v", err)
 		}
 	}
-	fileName := path.Join(config.AppConfig.Player.LocalCacheDir, id+".db")
-	return newDb(fileName, id)
+	return newDb(dbFile(id), id)
 }
 
-// create file + schema
-func (db *Db) initDb() error {
-
-	tx, err := db.engine.Begin()
-	if err != nil {
-		return err
-	}
-
-	txOk := false
-
-	commit := func() {
-		if txOk {
-			err = tx.Commit()
-		} else {
-			err = tx.Rollback()
-		}
-
-		if err != nil {
-			logrus.Fatalf("end tx: %v", err)
-		}
-	}
-
-	defer commit()
-
-	_, err = tx.Exec(migrations.SchemaV1)
-	if err != nil {
-		return err
-	}
-
-	_, err = tx.Exec("INSERT INTO schema VALUES (1)")
-	if err == nil {
-		txOk = true
-	}
-	return err
+// DbExists returns true if local database for server id exists.
+func DbExists(id string) bool {
+	_, err := os.Stat(dbFile(id))
+	return err == nil
 }
 
-func (db *Db) checkSchema() error {
-
-	sql := "SELECT * FROM schema;"
-
-	schema := -1
-	err := db.engine.Get(&schema, sql)
-	if err != nil {
-
-		if strings.Contains(err.Error(), "no such table") {
-			return errors.New("schema is invalid: empty schema")
-		}
-		return err
-	}
-
-	if schema != schemaLevel {
-		return fmt.Errorf("database schema is invalid: supported %d, database: %d", schemaLevel, schema)
-	}
-
-	return nil
+func dbFile(id string) string {
+	return path.Join(config.AppConfig.Player.LocalCacheDir, id+".db")
 }
 
 func (db *Db) Close() error {
@@ -0,0 +1,219 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
+
+// types of downloads table rows
+const (
+	// song has been downloaded by user
+	downloadTypeOffline = "offline"
+	// song has been cached automatically when playing it
+	downloadTypeCache = "cache"
+)
+
+// cached song stays in cache this much longer for every time it has been played
+const cachePlayCountBonus = time.Hour * 24
+
+// AddDownload stores a downloaded song. Song and album metadata is stored too,
+// so that downloaded songs can be listed without connection to server. Album can be nil.
+func (db *Db) AddDownload(song *models.Song, album *models.Album, file string, size int64, checksum string) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	err = db.upsertSongs([]*models.Song{song}, tx)
+	if err != nil {
+		return err
+	}
+
+	if album != nil {
+		err = db.upsertAlbums([]*models.Album{album}, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	sql := `INSERT INTO downloads(id, type, path, size, checksum, play_count, last_played)
+	VALUES (?, ?, ?, ?, ?, 0, ?)
+	ON CONFLICT(id) DO UPDATE SET
+	type=excluded.type, path=excluded.path, size=excluded.size, checksum=excluded.checksum;`
+
+	_, err = tx.Exec(sql, song.Id, downloadTypeOffline, file, size, checksum, sqlTime{time.Now()})
+	if err != nil {
+		return err
+	}
+	tx.ok = true
+	return nil
+}
+
+// AddCachedSong stores a song to cache. Song metadata is stored too.
+// If song has already been downloaded, nothing is done.
+func (db *Db) AddCachedSong(song *models.Song, file string, size int64, checksum string) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	err = db.upsertSongs([]*models.Song{song}, tx)
+	if err != nil {
+		return err
+	}
+
+	sql := `INSERT INTO downloads(id, type, path, size, checksum, play_count, last_played)
+	VALUES (?, ?, ?, ?, ?, 1, ?)
+	ON CONFLICT(id) DO UPDATE SET
+	path=excluded.path, size=excluded.size, checksum=excluded.checksum
+	WHERE downloads.type = excluded.type;`
+
+	_, err = tx.Exec(sql, song.Id, downloadTypeCache, file, size, checksum, sqlTime{time.Now()})
+	if err != nil {
+		return err
+	}
+	tx.ok = true
+	return nil
+}
+
+// GetDownloads returns all downloaded songs ordered by album.
+func (db *Db) GetDownloads() ([]*models.Download, error) {
+	sql := `
+	SELECT
+		s.id, s.name, s.duration, s.song_index, s.disc_number, s.album,
+		COALESCE(a.name, ''),
+		d.path, d.size, d.checksum, COALESCE(d.play_count, 0), COALESCE(d.last_played, 0)
+	FROM downloads d
+	JOIN songs s ON d.id = s.id
+	LEFT JOIN albums a ON s.album = a.id
+	WHERE d.type = ?
+	ORDER BY a.name, s.disc_number, s.song_index;`
+
+	return db.queryDownloads(sql, downloadTypeOffline)
+}
+
+// GetCachedSongs returns all cached songs in the order they are going to be removed from cache.
+func (db *Db) GetCachedSongs() ([]*models.Download, error) {
+	sql := `
+	SELECT
+		d.id, COALESCE(s.name, ''), COALESCE(s.duration, 0), COALESCE(s.song_index, 0),
+		COALESCE(s.disc_number, 0), COALESCE(s.album, ''),
+		COALESCE(a.name, ''),
+		d.path, d.size, d.checksum, COALESCE(d.play_count, 0), COALESCE(d.last_played, 0)
+	FROM downloads d
+	LEFT JOIN songs s ON d.id = s.id
+	LEFT JOIN albums a ON s.album = a.id
+	WHERE d.type = ?
+	ORDER BY COALESCE(d.last_played, 0) + COALESCE(d.play_count, 0) * ? ASC;`
+
+	return db.queryDownloads(sql, downloadTypeCache, int64(cachePlayCountBonus))
+}
+
+func (db *Db) queryDownloads(sql string, args ...interface{}) ([]*models.Download, error) {
+	rows, err := db.engine.Query(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	downloads := make([]*models.Download, 0)
+	for rows.Next() {
+		download := &models.Download{
+			Song:  &models.Song{},
+			State: models.DownloadComplete,
+		}
+		song := download.Song
+		lastPlayed := sqlTime{}
+		err = rows.Scan(&song.Id, &song.Name, &song.Duration, &song.Index, &song.DiscNumber, &song.Album,
+			&download.AlbumName, &download.File, &download.Size, &download.Checksum, &download.PlayCount,
+			&lastPlayed)
+		if err != nil {
+			return downloads, err
+		}
+		download.Downloaded = download.Size
+		if lastPlayed.Time.UnixNano() > 0 {
+			download.LastPlayed = lastPlayed.Time
+		}
+		downloads = append(downloads, download)
+	}
+	return downloads, rows.Err()
+}
+
+// GetDownload returns download for song. If song has not been downloaded, nil is returned.
+func (db *Db) GetDownload(id models.Id) (*models.Download, error) {
+	return db.getDownload(id, downloadTypeOffline)
+}
+
+// GetCachedSong returns cached song. If song is not cached, nil is returned.
+func (db *Db) GetCachedSong(id models.Id) (*models.Download, error) {
+	return db.getDownload(id, downloadTypeCache)
+}
+
+func (db *Db) getDownload(id models.Id, downloadType string) (*models.Download, error) {
+	sqlStmt := `SELECT path, size, checksum, COALESCE(play_count, 0), COALESCE(last_played, 0)
+	FROM downloads WHERE id = ? AND type = ?`
+	download := &models.Download{
+		Song:  &models.Song{Id: id},
+		State: models.DownloadComplete,
+	}
+
+	lastPlayed := sqlTime{}
+	row := db.engine.QueryRow(sqlStmt, id, downloadType)
+	err := row.Scan(&download.File, &download.Size, &download.Checksum, &download.PlayCount, &lastPlayed)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	download.Downloaded = download.Size
+	if lastPlayed.Time.UnixNano() > 0 {
+		download.LastPlayed = lastPlayed.Time
+	}
+	return download, nil
+}
+
+// GetCacheSize returns total size of cached songs in bytes.
+func (db *Db) GetCacheSize() (int64, error) {
+	var size int64
+	err := db.engine.Get(&size, "SELECT COALESCE(SUM(size), 0) FROM downloads WHERE type = ?", downloadTypeCache)
+	return size, err
+}
+
+// SongPlayed increases play count for downloaded or cached song.
+func (db *Db) SongPlayed(id models.Id) error {
+	sql := `UPDATE downloads SET
+	play_count = COALESCE(play_count, 0) + 1,
+	last_played = ?
+	WHERE id = ?`
+
+	_, err := db.engine.Exec(sql, sqlTime{time.Now()}, id)
+	return err
+}
+
+// RemoveDownload removes song from downloads or cache. It does not remove the file itself.
+func (db *Db) RemoveDownload(id models.Id) error {
+	_, err := db.engine.Exec("DELETE FROM downloads WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,146 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"testing"
+	"tryffel.net/go/jellycli/api"
+)
+
+func TestDb_Downloads(t *testing.T) {
+	db := testDb(t)
+	if db == nil {
+		return
+	}
+	defer closeDb(t, db)
+
+	song := *api.MockSongs[0]
+	album := *api.MockAlbums[0]
+
+	download, err := db.GetDownload(song.Id)
+	if err != nil {
+		t.Errorf("get download: %v", err)
+	}
+	if download != nil {
+		t.Errorf("song should not be downloaded")
+	}
+
+	err = db.AddDownload(&song, &album, "/tmp/song-1.mp3", 1024, "checksum")
+	if err != nil {
+		t.Errorf("add download: %v", err)
+	}
+
+	download, err = db.GetDownload(song.Id)
+	if err != nil {
+		t.Errorf("get download: %v", err)
+	}
+	if download == nil || download.File != "/tmp/song-1.mp3" || download.Size != 1024 {
+		t.Errorf("invalid download: %v", download)
+	}
+
+	downloads, err := db.GetDownloads()
+	if err != nil {
+		t.Errorf("get downloads: %v", err)
+	}
+	if len(downloads) != 1 {
+		t.Errorf("invalid downloads count: %d, want: 1", len(downloads))
+		return
+	}
+	if downloads[0].Song.Name != song.Name || downloads[0].AlbumName != album.Name {
+		t.Errorf("invalid download metadata: %v", downloads[0])
+	}
+
+	err = db.RemoveDownload(song.Id)
+	if err != nil {
+		t.Errorf("remove download: %v", err)
+	}
+
+	downloads, err = db.GetDownloads()
+	if err != nil {
+		t.Errorf("get downloads: %v", err)
+	}
+	if len(downloads) != 0 {
+		t.Errorf("invalid downloads count: %d, want: 0", len(downloads))
+	}
+}
+
+func TestDb_CachedSongs(t *testing.T) {
+	db := testDb(t)
+	if db == nil {
+		return
+	}
+	defer closeDb(t, db)
+
+	songs := api.MockSongs[:3]
+	for i, v := range songs {
+		err := db.AddCachedSong(v, "/tmp/"+v.Id.String(), int64(100*(i+1)), "")
+		if err != nil {
+			t.Errorf("add cached song: %v", err)
+		}
+	}
+
+	// downloaded song must not be overwritten
+	err := db.AddDownload(api.MockSongs[3], nil, "/tmp/song-4", 1000, "")
+	if err != nil {
+		t.Errorf("add download: %v", err)
+	}
+	err = db.AddCachedSong(api.MockSongs[3], "/tmp/song-4-cache", 10, "")
+	if err != nil {
+		t.Errorf("add cached song: %v", err)
+	}
+
+	size, err := db.GetCacheSize()
+	if err != nil {
+		t.Errorf("get cache size: %v", err)
+	}
+	if size != 600 {
+		t.Errorf("invalid cache size: %d, want: 600", size)
+	}
+
+	// first song has been played many times, so it should be last to remove
+	for i := 0; i < 3; i++ {
+		err = db.SongPlayed(songs[0].Id)
+		if err != nil {
+			t.Errorf("song played: %v", err)
+		}
+	}
+
+	cached, err := db.GetCachedSongs()
+	if err != nil {
+		t.Errorf("get cached songs: %v", err)
+	}
+	if len(cached) != 3 {
+		t.Fatalf("invalid cached songs count: %d, want: 3", len(cached))
+	}
+	if cached[2].Song.Id != songs[0].Id {
+		t.Errorf("most played song should be removed last, got order: %s, %s, %s",
+			cached[0].Song.Id, cached[1].Song.Id, cached[2].Song.Id)
+	}
+	if cached[2].PlayCount != 4 {
+		t.Errorf("invalid play count: %d, want: 4", cached[2].PlayCount)
+	}
+
+	download, err := db.GetDownload(api.MockSongs[3].Id)
+	if err != nil {
+		t.Errorf("get download: %v", err)
+	}
+	if download == nil || download.File != "/tmp/song-4" {
+		t.Errorf("download was overwritten by cache: %v", download)
+	}
+}
@@ -20,6 +20,7 @@ package storage
 
 import (
 	"fmt"
+	"github.com/Masterminds/squirrel"
 	"github.com/sirupsen/logrus"
 	"time"
 	"tryffel.net/go/jellycli/interfaces"
@@ -55,6 +56,7 @@ func (db *Db) UpdateArtists(artists []*models.Artist) error {
 `
 
 	args := make([]interface{}, len(artists)*5)
+	ids := make([]models.Id, len(artists))
 
 	argFmt := ""
 
@@ -69,6 +71,7 @@ func (db *Db) UpdateArtists(artists []*models.Artist) error {
 		args[i*5+2] = v.Favorite
 		args[i*5+3] = v.TotalDuration
 		args[i*5+4] = v.AlbumCount
+		ids[i] = v.Id
 	}
 
 	sql = fmt.Sprintf(sql, argFmt)
@@ -89,6 +92,11 @@ func (db *Db) UpdateArtists(artists []*models.Artist) error {
 		logrus.Infof("Updated/inserted %d artists", affected)
 	}
 
+	err = db.updateSearchIndex("artists", ids, tx)
+	if err != nil {
+		return err
+	}
+
 	err = db.updateKey(keyArtists, tx)
 	if err != nil {
 		return err
@@ -98,15 +106,25 @@ func (db *Db) UpdateArtists(artists []*models.Artist) error {
 }
 
 func (db *Db) GetArtists(query *interfaces.QueryOpts) (artists []*models.Artist, count int, err error) {
+	return db.getArtists(query, false)
+}
 
-	stmt := db.builder.
-		Select("*").From("artists")
+// GetAlbumArtists returns artists that are primary artist of any album.
+func (db *Db) GetAlbumArtists(query *interfaces.QueryOpts) (artists []*models.Artist, count int, err error) {
+	return db.getArtists(query, true)
+}
 
-	if !query.Filter.Empty() {
-		if query.Filter.Favorite {
-			stmt = stmt.Where("favorite = TRUE")
-		}
+func (db *Db) getArtists(query *interfaces.QueryOpts, albumArtists bool) (artists []*models.Artist, count int, err error) {
+	where := squirrel.And{}
+	if query.Filter.Favorite {
+		where = append(where, squirrel.Expr("favorite = TRUE"))
 	}
+	if albumArtists {
+		where = append(where, squirrel.Expr("id IN (SELECT artist FROM albums)"))
+	}
+
+	stmt := db.builder.
+		Select("*").From("artists").Where(where)
 
 	if query.Sort.Field != "" {
 		mode := query.Sort.Mode
@@ -131,24 +149,40 @@ func (db *Db) GetArtists(query *interfaces.QueryOpts) (artists []*models.Artist,
 		return
 	}
 
-	a := &[]models.Artist{}
-
-	err = db.engine.Select(a, sql, args...)
+	artists, err = db.selectArtists(sql, args...)
 	if err != nil {
 		return
 	}
 
-	artists = make([]*models.Artist, len(*a))
-	for i, _ := range *a {
-		artists[i] = &(*a)[i]
+	sql, args, err = db.builder.Select("COUNT(id)").From("artists").Where(where).ToSql()
+	if err != nil {
+		return
 	}
-
-	sql = "SELECT COUNT(id) FROM ARTISTS"
-	err = db.engine.Get(&count, sql)
+	err = db.engine.Get(&count, sql, args...)
 	return
 }
 
 func (db *Db) UpdateAlbums(albums []*models.Album) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	err = db.upsertAlbums(albums, tx)
+	if err != nil {
+		return err
+	}
+
+	err = db.updateKey(keyAlbums, tx)
+	if err != nil {
+		return err
+	}
+	tx.ok = true
+	return nil
+}
+
+func (db *Db) upsertAlbums(albums []*models.Album, tx *tx) error {
 	sql := `INSERT INTO albums(id, name, year, duration, favorite, artist, song_count, image_id, disc_count)
 	VALUES %s
 	ON CONFLICT(id) DO UPDATE SET
@@ -159,6 +193,8 @@ func (db *Db) UpdateAlbums(albums []*models.Album) error {
 `
 
 	args := make([]interface{}, len(albums)*9)
+	ids := make([]models.Id, len(albums))
+	artists := make(map[models.Id][]models.IdName, len(albums))
 
 	argFmt := ""
 
@@ -179,44 +215,50 @@ func (db *Db) UpdateAlbums(albums []*models.Album) error {
 		args[i*9+6] = v.SongCount
 		args[i*9+7] = v.ImageId
 		args[i*9+8] = v.DiscCount
+		ids[i] = v.Id
+		artists[v.Id] = v.AdditionalArtists
 	}
 
 	sql = fmt.Sprintf(sql, argFmt)
-
-	tx, err := db.begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Close()
-
-	_, err = tx.Exec(sql, args...)
+	_, err := tx.Exec(sql, args...)
 	if err != nil {
 		return err
 	}
 
-	err = db.updateKey(keyAlbums, tx)
+	err = db.updateItemArtists("album_artists", "album", artists, tx)
 	if err != nil {
 		return err
 	}
-	tx.ok = true
-	return nil
+	return db.updateSearchIndex("albums", ids, tx)
 }
 
 func (db *Db) GetAlbums(query *interfaces.QueryOpts) (albums []*models.Album, n int, err error) {
-	stmt := db.builder.
-		Select("*").From("albums")
-
-	if !query.Filter.Empty() {
-		if query.Filter.Favorite {
-			stmt = stmt.Where("favorite = TRUE")
+	where := squirrel.And{}
+	if query.Filter.Favorite {
+		where = append(where, squirrel.Expr("favorite = TRUE"))
+	}
+	if len(query.Filter.Genres) > 0 {
+		genres := make([]models.Id, len(query.Filter.Genres))
+		for i, v := range query.Filter.Genres {
+			genres[i] = v.Id
 		}
+		where = append(where, squirrel.Expr("id IN (SELECT album FROM genre_albums WHERE genre IN "+
+			sqlIn(len(genres))+")", idArgs(genres)...))
 	}
+	if query.Filter.YearRange != [2]int{0, 0} && query.Filter.YearRangeValid() {
+		where = append(where, squirrel.Expr("year BETWEEN ? AND ?", query.Filter.YearRange[0], query.Filter.YearRange[1]))
+	}
+
+	stmt := db.builder.
+		Select("*").From("albums").Where(where)
 
 	if query.Sort.Field != "" {
 		mode := query.Sort.Mode
 		switch query.Sort.Field {
 		case interfaces.SortByName:
 			stmt = stmt.OrderBy("name " + mode)
+		case interfaces.SortByDate:
+			stmt = stmt.OrderBy("year "+mode, "name")
 		case interfaces.SortByRandom:
 			stmt = stmt.OrderBy("RANDOM()")
 		default:
@@ -235,33 +277,52 @@ func (db *Db) GetAlbums(query *interfaces.QueryOpts) (albums []*models.Album, n
 		return
 	}
 
-	a := &[]models.Album{}
-
-	err = db.engine.Select(a, sql, args...)
+	albums, err = db.selectAlbums(sql, args...)
 	if err != nil {
 		return
 	}
 
-	albums = make([]*models.Album, len(*a))
-	for i, _ := range *a {
-		albums[i] = &(*a)[i]
+	sql, args, err = db.builder.Select("COUNT(id)").From("albums").Where(where).ToSql()
+	if err != nil {
+		return
 	}
-
-	sql = "SELECT COUNT(id) FROM albums"
-	err = db.engine.Get(&n, sql)
+	err = db.engine.Get(&n, sql, args...)
 	return
 }
 
 func (db *Db) UpdateSongs(songs []*models.Song) error {
-	sql := `INSERT INTO songs(id, name, duration, song_index, disc_number, favorite, album)
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	err = db.upsertSongs(songs, tx)
+	if err != nil {
+		return err
+	}
+
+	err = db.updateKey(keySongs, tx)
+	if err != nil {
+		return err
+	}
+	tx.ok = true
+	return nil
+}
+
+func (db *Db) upsertSongs(songs []*models.Song, tx *tx) error {
+	sql := `INSERT INTO songs(id, name, duration, song_index, disc_number, favorite, album, artist, play_count)
 	VALUES %s
 	ON CONFLICT(id) DO UPDATE SET
     name=excluded.name, duration=excluded.duration,
 	song_index=excluded.song_index, disc_number=excluded.disc_number,
-	favorite=excluded.favorite, album=excluded.album;
+	favorite=excluded.favorite, album=excluded.album, artist=excluded.artist,
+	play_count=excluded.play_count;
 `
 
-	args := make([]interface{}, len(songs)*7)
+	args := make([]interface{}, len(songs)*9)
+	ids := make([]models.Id, len(songs))
+	artists := make(map[models.Id][]models.IdName, len(songs))
 
 	argFmt := ""
 
@@ -269,37 +330,33 @@ func (db *Db) UpdateSongs(songs []*models.Song) error {
 		if i > 0 {
 			argFmt += ", "
 		}
-		argFmt += "(?, ?, ?, ?, ?, ?, ?)"
+		argFmt += "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
 
-		args[i*7] = v.Id
-		args[i*7+1] = v.Name
-		args[i*7+2] = v.Duration
+		args[i*9] = v.Id
+		args[i*9+1] = v.Name
+		args[i*9+2] = v.Duration
 
-		args[i*7+3] = v.Index
-		args[i*7+4] = v.DiscNumber
-		args[i*7+5] = v.Favorite
-		args[i*7+6] = v.Album
+		args[i*9+3] = v.Index
+		args[i*9+4] = v.DiscNumber
+		args[i*9+5] = v.Favorite
+		args[i*9+6] = v.Album
+		args[i*9+7] = v.AlbumArtist
+		args[i*9+8] = v.PlayCount
+		ids[i] = v.Id
+		artists[v.Id] = v.Artists
 	}
 
 	sql = fmt.Sprintf(sql, argFmt)
-
-	tx, err := db.begin()
+	_, err := tx.Exec(sql, args...)
 	if err != nil {
 		return err
 	}
-	defer tx.Close()
 
-	_, err = tx.Exec(sql, args...)
-	if err != nil {
-		return err
-	}
-
-	err = db.updateKey(keySongs, tx)
+	err = db.updateItemArtists("song_artists", "song", artists, tx)
 	if err != nil {
 		return err
 	}
-	tx.ok = true
-	return nil
+	return db.updateSearchIndex("songs", ids, tx)
 }
 
 func (db *Db) GetSongs(page int, pageSize int) ([]*models.Song, int, error) {
@@ -318,18 +375,11 @@ func (db *Db) GetSongs(page int, pageSize int) ([]*models.Song, int, error) {
 		return nil, 0, err
 	}
 
-	s := &[]models.Song{}
-
-	err = db.engine.Select(s, sql, args...)
+	songs, err := db.selectSongs(sql, args...)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	songs := make([]*models.Song, len(*s))
-	for i, _ := range *s {
-		songs[i] = &(*s)[i]
-	}
-
 	count := 0
 
 	sql = "SELECT COUNT(id) FROM songs"
@@ -352,6 +402,7 @@ func (db *Db) UpdatePlaylists(playlists []*models.Playlist) error {
     name=excluded.name;`
 
 	args := make([]interface{}, len(playlists)*2)
+	ids := make([]models.Id, len(playlists))
 	argFmt := ""
 	for i, v := range playlists {
 		if i > 0 {
@@ -361,6 +412,7 @@ func (db *Db) UpdatePlaylists(playlists []*models.Playlist) error {
 
 		args[i*2] = v.Id
 		args[i*2+1] = v.Name
+		ids[i] = v.Id
 	}
 
 	sql = fmt.Sprintf(sql, argFmt)
@@ -370,6 +422,11 @@ func (db *Db) UpdatePlaylists(playlists []*models.Playlist) error {
 		return err
 	}
 
+	err = db.updateSearchIndex("playlists", ids, tx)
+	if err != nil {
+		return err
+	}
+
 	// playlist songs
 	sql = `DELETE FROM playlist_songs WHERE playlist IN %s; 
 	INSERT INTO playlist_songs(playlist_index, playlist, song) VALUES %s;
@@ -443,3 +500,60 @@ func (db *Db) GetPlaylists() ([]*models.Playlist, error) {
 	}
 	return playlists, nil
 }
+
+// GetArtist returns single artist.
+func (db *Db) GetArtist(id models.Id) (*models.Artist, error) {
+	artist := &models.Artist{}
+	err := db.engine.Get(artist, "SELECT * FROM artists WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return artist, nil
+}
+
+// GetAlbum returns single album.
+func (db *Db) GetAlbum(id models.Id) (*models.Album, error) {
+	album := &models.Album{}
+	err := db.engine.Get(album, "SELECT * FROM albums WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return album, db.fillAlbumArtists([]*models.Album{album})
+}
+
+// GetArtistAlbums returns albums where artist is primary or additional artist, ordered by year.
+func (db *Db) GetArtistAlbums(artist models.Id) ([]*models.Album, error) {
+	sql := `SELECT * FROM albums
+	WHERE artist = ? OR id IN (SELECT album FROM album_artists WHERE artist = ?)
+	ORDER BY year, name`
+	return db.selectAlbums(sql, artist, artist)
+}
+
+// GetAlbumSongs returns songs of album in album order.
+func (db *Db) GetAlbumSongs(album models.Id) ([]*models.Song, error) {
+	sql := "SELECT * FROM songs WHERE album = ? ORDER BY disc_number, song_index"
+	return db.selectSongs(sql, album)
+}
+
+// GetPlaylistSongs returns songs of playlist in playlist order.
+func (db *Db) GetPlaylistSongs(playlist models.Id) ([]*models.Song, error) {
+	sql := `SELECT s.* FROM songs s
+	JOIN playlist_songs ps ON ps.song = s.id
+	WHERE ps.playlist = ?
+	ORDER BY ps.playlist_index`
+	return db.selectSongs(sql, playlist)
+}
+
+func (db *Db) selectSongs(sql string, args ...interface{}) ([]*models.Song, error) {
+	s := &[]models.Song{}
+	err := db.engine.Select(s, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	songs := make([]*models.Song, len(*s))
+	for i, _ := range *s {
+		songs[i] = &(*s)[i]
+	}
+	return songs, db.fillSongArtists(songs)
+}
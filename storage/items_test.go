@@ -23,6 +23,7 @@ import (
 	"testing"
 	"tryffel.net/go/jellycli/api"
 	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
 )
 
 func TestDb_UpdateArtists(t *testing.T) {
@@ -204,3 +205,80 @@ func TestDb_UpdatePlaylists(t *testing.T) {
 		}
 	}
 }
+
+func TestDb_GetItems(t *testing.T) {
+	db := testDb(t)
+	if db == nil {
+		return
+	}
+
+	defer closeDb(t, db)
+
+	albums := make([]*models.Album, len(api.MockAlbums))
+	for i, v := range api.MockAlbums {
+		album := *v
+		album.Songs = nil
+		albums[i] = &album
+	}
+
+	songs := make([]*models.Song, len(api.MockSongs))
+	for i, v := range api.MockSongs {
+		song := *v
+		song.AlbumArtist = ""
+		songs[i] = &song
+	}
+
+	err := db.UpdateAlbums(albums)
+	if err != nil {
+		t.Errorf("insert albums: %v", err)
+	}
+	err = db.UpdateSongs(songs)
+	if err != nil {
+		t.Errorf("insert songs: %v", err)
+	}
+	err = db.UpdatePlaylists(api.MockPlaylists)
+	if err != nil {
+		t.Errorf("insert playlists: %v", err)
+	}
+
+	album, err := db.GetAlbum(albums[1].Id)
+	if err != nil {
+		t.Errorf("get album: %v", err)
+	} else if diff := cmp.Diff(albums[1], album); diff != "" {
+		t.Errorf("album differs: %s", diff)
+	}
+
+	artistAlbums, err := db.GetArtistAlbums("artist-1")
+	if err != nil {
+		t.Errorf("get artist albums: %v", err)
+	}
+	// ordered by year
+	if diff := cmp.Diff([]*models.Album{albums[1], albums[0]}, artistAlbums); diff != "" {
+		t.Errorf("artist albums differ: %s", diff)
+	}
+
+	albumSongs, err := db.GetAlbumSongs(albums[0].Id)
+	if err != nil {
+		t.Errorf("get album songs: %v", err)
+	}
+	if len(albumSongs) != 2 || albumSongs[0].Album != albums[0].Id {
+		t.Errorf("invalid album songs: %v", albumSongs)
+	}
+
+	playlistSongs, err := db.GetPlaylistSongs(api.MockPlaylists[0].Id)
+	if err != nil {
+		t.Errorf("get playlist songs: %v", err)
+	}
+	if len(playlistSongs) != len(api.MockPlaylists[0].Songs) {
+		t.Errorf("invalid playlist songs count: %d, want: %d",
+			len(playlistSongs), len(api.MockPlaylists[0].Songs))
+	}
+
+	items, err := db.Search("album-3", models.TypeAlbum, 10)
+	if err != nil {
+		t.Errorf("search: %v", err)
+	}
+	if len(items) != 1 || items[0].GetId() != "album-3" {
+		t.Errorf("invalid search results: %v", items)
+	}
+}
@@ -0,0 +1,422 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+const (
+	keyGenres       = "genres"
+	keyLatestAlbums = "latest_albums"
+)
+
+// sqlMaxArgs is max number of arguments passed to single IN-clause.
+const sqlMaxArgs = 500
+
+// sqlIn returns IN-clause with n placeholders.
+func sqlIn(n int) string {
+	return "(?" + strings.Repeat(", ?", n-1) + ")"
+}
+
+func idArgs(ids []models.Id) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, v := range ids {
+		args[i] = v
+	}
+	return args
+}
+
+// updateItemArtists replaces artists of items in table album_artists or song_artists,
+// where column is either album or song.
+func (db *Db) updateItemArtists(table, column string, artists map[models.Id][]models.IdName, tx *tx) error {
+	if len(artists) == 0 {
+		return nil
+	}
+
+	ids := make([]interface{}, 0, len(artists))
+	args := make([]interface{}, 0, len(artists)*4)
+	argFmt := ""
+	for id, list := range artists {
+		ids = append(ids, id)
+		for i, artist := range list {
+			if artist.Id == "" {
+				continue
+			}
+			if len(args) > 0 {
+				argFmt += ", "
+			}
+			argFmt += "(?, ?, ?, ?)"
+			args = append(args, id, artist.Id, artist.Name, i)
+		}
+	}
+
+	sql := fmt.Sprintf("DELETE FROM %s WHERE %s IN %s", table, column, sqlIn(len(ids)))
+	_, err := tx.Exec(sql, ids...)
+	if err != nil {
+		return fmt.Errorf("remove %s: %v", table, err)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	sql = fmt.Sprintf("INSERT OR IGNORE INTO %s(%s, artist, name, artist_index) VALUES %s", table, column, argFmt)
+	_, err = tx.Exec(sql, args...)
+	if err != nil {
+		return fmt.Errorf("insert %s: %v", table, err)
+	}
+	return nil
+}
+
+// itemArtists returns artists of items from table album_artists or song_artists.
+func (db *Db) itemArtists(table, column string, ids []models.Id) (map[models.Id][]models.IdName, error) {
+	artists := make(map[models.Id][]models.IdName)
+	for start := 0; start < len(ids); start += sqlMaxArgs {
+		end := start + sqlMaxArgs
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		sql := fmt.Sprintf("SELECT %[1]s, artist, name FROM %[2]s WHERE %[1]s IN %[3]s ORDER BY %[1]s, artist_index",
+			column, table, sqlIn(end-start))
+		rows, err := db.engine.Query(sql, idArgs(ids[start:end])...)
+		if err != nil {
+			return artists, err
+		}
+		for rows.Next() {
+			var id models.Id
+			artist := models.IdName{}
+			err = rows.Scan(&id, &artist.Id, &artist.Name)
+			if err != nil {
+				rows.Close()
+				return artists, err
+			}
+			artists[id] = append(artists[id], artist)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return artists, err
+		}
+	}
+	return artists, nil
+}
+
+// fillAlbumArtists sets AdditionalArtists for albums.
+func (db *Db) fillAlbumArtists(albums []*models.Album) error {
+	if len(albums) == 0 {
+		return nil
+	}
+	ids := make([]models.Id, len(albums))
+	for i, v := range albums {
+		ids[i] = v.Id
+	}
+	artists, err := db.itemArtists("album_artists", "album", ids)
+	if err != nil {
+		return fmt.Errorf("get album artists: %v", err)
+	}
+	for _, v := range albums {
+		v.AdditionalArtists = artists[v.Id]
+	}
+	return nil
+}
+
+// fillSongArtists sets Artists for songs.
+func (db *Db) fillSongArtists(songs []*models.Song) error {
+	if len(songs) == 0 {
+		return nil
+	}
+	ids := make([]models.Id, len(songs))
+	for i, v := range songs {
+		ids[i] = v.Id
+	}
+	artists, err := db.itemArtists("song_artists", "song", ids)
+	if err != nil {
+		return fmt.Errorf("get song artists: %v", err)
+	}
+	for _, v := range songs {
+		v.Artists = artists[v.Id]
+	}
+	return nil
+}
+
+func (db *Db) selectAlbums(sql string, args ...interface{}) ([]*models.Album, error) {
+	a := &[]models.Album{}
+	err := db.engine.Select(a, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	albums := make([]*models.Album, len(*a))
+	for i, _ := range *a {
+		albums[i] = &(*a)[i]
+	}
+	return albums, db.fillAlbumArtists(albums)
+}
+
+func (db *Db) selectArtists(sql string, args ...interface{}) ([]*models.Artist, error) {
+	a := &[]models.Artist{}
+	err := db.engine.Select(a, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	artists := make([]*models.Artist, len(*a))
+	for i, _ := range *a {
+		artists[i] = &(*a)[i]
+	}
+	return artists, nil
+}
+
+// UpdateGenres updates/inserts genres.
+func (db *Db) UpdateGenres(genres []*models.IdName) error {
+	if len(genres) == 0 {
+		return nil
+	}
+
+	// genre name is unique too, replace genre that has same name
+	sql := `INSERT OR REPLACE INTO genres(id, name, song_count, album_count) VALUES %s`
+	args := make([]interface{}, 0, len(genres)*3)
+	argFmt := ""
+	for i, v := range genres {
+		if i > 0 {
+			argFmt += ", "
+		}
+		argFmt += "(?, ?, 0, (SELECT COUNT(*) FROM genre_albums WHERE genre = ?))"
+		args = append(args, v.Id, v.Name, v.Id)
+	}
+
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	_, err = tx.Exec(fmt.Sprintf(sql, argFmt), args...)
+	if err != nil {
+		return err
+	}
+
+	err = db.updateKey(keyGenres, tx)
+	if err != nil {
+		return err
+	}
+	tx.ok = true
+	return nil
+}
+
+// UpdateGenreAlbums replaces albums that belong to genre.
+func (db *Db) UpdateGenreAlbums(genre models.Id, albums []models.Id) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	_, err = tx.Exec("DELETE FROM genre_albums WHERE genre = ?", genre)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(albums); start += sqlMaxArgs {
+		end := start + sqlMaxArgs
+		if end > len(albums) {
+			end = len(albums)
+		}
+
+		args := make([]interface{}, 0, (end-start)*2)
+		argFmt := ""
+		for i, v := range albums[start:end] {
+			if i > 0 {
+				argFmt += ", "
+			}
+			argFmt += "(?, ?)"
+			args = append(args, genre, v)
+		}
+		_, err = tx.Exec("INSERT OR IGNORE INTO genre_albums(genre, album) VALUES "+argFmt, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE genres SET album_count = (SELECT COUNT(*) FROM genre_albums WHERE genre = ?) WHERE id = ?",
+		genre, genre)
+	if err != nil {
+		return err
+	}
+	tx.ok = true
+	return nil
+}
+
+// GetGenres returns genres ordered by name and total number of genres.
+func (db *Db) GetGenres(paging interfaces.Paging) ([]*models.IdName, int, error) {
+	sql, args, err := db.builder.Select("id", "name").From("genres").
+		OrderBy("name").
+		Offset(uint64(paging.Offset())).
+		Limit(uint64(paging.PageSize)).ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	g := &[]models.IdName{}
+	err = db.engine.Select(g, sql, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	genres := make([]*models.IdName, len(*g))
+	for i, _ := range *g {
+		genres[i] = &(*g)[i]
+	}
+
+	count := 0
+	err = db.engine.Get(&count, "SELECT COUNT(id) FROM genres")
+	return genres, count, err
+}
+
+// GetGenreAlbums returns albums of genre ordered by name.
+func (db *Db) GetGenreAlbums(genre models.Id) ([]*models.Album, error) {
+	sql := `SELECT a.* FROM albums a
+	JOIN genre_albums g ON g.album = a.id
+	WHERE g.genre = ?
+	ORDER BY a.name`
+	return db.selectAlbums(sql, genre)
+}
+
+// UpdateLatestAlbums replaces latest albums. Albums are expected to be in order, latest first.
+func (db *Db) UpdateLatestAlbums(albums []models.Id) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	_, err = tx.Exec("DELETE FROM latest_albums")
+	if err != nil {
+		return err
+	}
+
+	if len(albums) > 0 {
+		args := make([]interface{}, 0, len(albums)*2)
+		argFmt := ""
+		for i, v := range albums {
+			if i > 0 {
+				argFmt += ", "
+			}
+			argFmt += "(?, ?)"
+			args = append(args, i, v)
+		}
+		_, err = tx.Exec("INSERT INTO latest_albums(position, album) VALUES "+argFmt, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = db.updateKey(keyLatestAlbums, tx)
+	if err != nil {
+		return err
+	}
+	tx.ok = true
+	return nil
+}
+
+// GetLatestAlbums returns latest albums, latest first.
+func (db *Db) GetLatestAlbums() ([]*models.Album, error) {
+	sql := `SELECT a.* FROM albums a
+	JOIN latest_albums l ON l.album = a.id
+	ORDER BY l.position`
+	return db.selectAlbums(sql)
+}
+
+// GetRecentlyPlayed returns songs from local listening history, latest first, and total number of
+// played songs.
+func (db *Db) GetRecentlyPlayed(paging interfaces.Paging) ([]*models.Song, int, error) {
+	sql := `SELECT s.* FROM songs s
+	JOIN (SELECT song, MAX(started_at) AS started FROM plays GROUP BY song) p ON p.song = s.id
+	ORDER BY p.started DESC
+	LIMIT ? OFFSET ?`
+	songs, err := db.selectSongs(sql, paging.PageSize, paging.Offset())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count := 0
+	err = db.engine.Get(&count, "SELECT COUNT(DISTINCT p.song) FROM plays p JOIN songs s ON s.id = p.song")
+	return songs, count, err
+}
+
+// GetSimilarArtists returns artists that share most genres with artist.
+func (db *Db) GetSimilarArtists(artist models.Id, limit int) ([]*models.Artist, error) {
+	sql := `SELECT ar.* FROM artists ar
+	JOIN (
+		SELECT a.artist AS artist, COUNT(DISTINCT g.genre) AS shared
+		FROM genre_albums g
+		JOIN albums a ON a.id = g.album
+		WHERE a.artist != ? AND g.genre IN (
+			SELECT g.genre FROM genre_albums g JOIN albums a ON a.id = g.album WHERE a.artist = ?)
+		GROUP BY a.artist
+	) s ON s.artist = ar.id
+	ORDER BY s.shared DESC, ar.name
+	LIMIT ?`
+	return db.selectArtists(sql, artist, artist, limit)
+}
+
+// GetSimilarAlbums returns albums that share most genres with album.
+func (db *Db) GetSimilarAlbums(album models.Id, limit int) ([]*models.Album, error) {
+	sql := `SELECT a.* FROM albums a
+	JOIN (
+		SELECT album, COUNT(*) AS shared FROM genre_albums
+		WHERE album != ? AND genre IN (SELECT genre FROM genre_albums WHERE album = ?)
+		GROUP BY album
+	) s ON s.album = a.id
+	ORDER BY s.shared DESC, a.name
+	LIMIT ?`
+	return db.selectAlbums(sql, album, album, limit)
+}
+
+// GetInstantMix returns random songs that share genres with item.
+func (db *Db) GetInstantMix(item models.Item, limit int) ([]*models.Song, error) {
+	var genres string
+	switch item.GetType() {
+	case models.TypeArtist:
+		genres = `SELECT g.genre FROM genre_albums g JOIN albums a ON a.id = g.album WHERE a.artist = ?`
+	case models.TypeAlbum:
+		genres = `SELECT genre FROM genre_albums WHERE album = ?`
+	case models.TypeSong:
+		genres = `SELECT g.genre FROM genre_albums g JOIN songs s ON s.album = g.album WHERE s.id = ?`
+	case models.TypePlaylist:
+		genres = `SELECT g.genre FROM genre_albums g
+		JOIN songs s ON s.album = g.album
+		JOIN playlist_songs ps ON ps.song = s.id
+		WHERE ps.playlist = ?`
+	case models.TypeGenre:
+		genres = `SELECT ?`
+	default:
+		return nil, fmt.Errorf("cannot create instant mix for %s", item.GetType())
+	}
+
+	sql := fmt.Sprintf(`SELECT s.* FROM songs s
+	JOIN genre_albums g ON g.album = s.album
+	WHERE g.genre IN (%s)
+	GROUP BY s.id
+	ORDER BY RANDOM()
+	LIMIT ?`, genres)
+	return db.selectSongs(sql, item.GetId(), limit)
+}
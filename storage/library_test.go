@@ -0,0 +1,249 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+func testLibraryDb(t *testing.T) *Db {
+	db := testDb(t)
+	if db == nil {
+		t.FailNow()
+	}
+
+	artists := []*models.Artist{
+		{Id: "artist-1", Name: "artist 1", AlbumCount: 1},
+		{Id: "artist-2", Name: "artist 2", AlbumCount: 1},
+		{Id: "artist-3", Name: "artist 3", AlbumCount: 1},
+		{Id: "artist-4", Name: "artist 4"},
+	}
+	albums := []*models.Album{
+		{Id: "album-1", Name: "album 1", Year: 2001, Artist: "artist-1",
+			AdditionalArtists: []models.IdName{{Id: "artist-1", Name: "artist 1"}, {Id: "artist-4", Name: "artist 4"}}},
+		{Id: "album-2", Name: "album 2", Year: 2002, Artist: "artist-2", Favorite: true},
+		{Id: "album-3", Name: "album 3", Year: 2003, Artist: "artist-3"},
+	}
+	songs := []*models.Song{
+		{Id: "song-1", Name: "song 1", Album: "album-1", AlbumArtist: "artist-1",
+			Artists: []models.IdName{{Id: "artist-1", Name: "artist 1"}, {Id: "artist-4", Name: "artist 4"}}},
+		{Id: "song-2", Name: "song 2", Album: "album-2", AlbumArtist: "artist-2"},
+		{Id: "song-3", Name: "song 3", Album: "album-3", AlbumArtist: "artist-3"},
+	}
+
+	err := db.UpdateArtists(artists)
+	if err != nil {
+		t.Fatalf("update artists: %v", err)
+	}
+	err = db.UpdateAlbums(albums)
+	if err != nil {
+		t.Fatalf("update albums: %v", err)
+	}
+	err = db.UpdateSongs(songs)
+	if err != nil {
+		t.Fatalf("update songs: %v", err)
+	}
+	err = db.UpdateGenres([]*models.IdName{{Id: "genre-1", Name: "rock"}, {Id: "genre-2", Name: "jazz"}})
+	if err != nil {
+		t.Fatalf("update genres: %v", err)
+	}
+	err = db.UpdateGenreAlbums("genre-1", []models.Id{"album-1", "album-2"})
+	if err != nil {
+		t.Fatalf("update genre albums: %v", err)
+	}
+	err = db.UpdateGenreAlbums("genre-2", []models.Id{"album-1", "album-3"})
+	if err != nil {
+		t.Fatalf("update genre albums: %v", err)
+	}
+	return db
+}
+
+func albumIds(albums []*models.Album) []models.Id {
+	ids := make([]models.Id, len(albums))
+	for i, v := range albums {
+		ids[i] = v.Id
+	}
+	return ids
+}
+
+func TestDb_ItemArtists(t *testing.T) {
+	db := testLibraryDb(t)
+	defer closeDb(t, db)
+
+	album, err := db.GetAlbum("album-1")
+	if err != nil {
+		t.Fatalf("get album: %v", err)
+	}
+	want := []models.IdName{{Id: "artist-1", Name: "artist 1"}, {Id: "artist-4", Name: "artist 4"}}
+	if diff := cmp.Diff(album.AdditionalArtists, want); diff != "" {
+		t.Errorf("album artists: %s", diff)
+	}
+
+	songs, err := db.GetAlbumSongs("album-1")
+	if err != nil {
+		t.Fatalf("get album songs: %v", err)
+	}
+	if len(songs) != 1 || songs[0].AlbumArtist != "artist-1" {
+		t.Fatalf("album songs: %v", songs)
+	}
+	if diff := cmp.Diff(songs[0].Artists, want); diff != "" {
+		t.Errorf("song artists: %s", diff)
+	}
+
+	// additional artist has album too
+	albums, err := db.GetArtistAlbums("artist-4")
+	if err != nil {
+		t.Fatalf("get artist albums: %v", err)
+	}
+	if diff := cmp.Diff(albumIds(albums), []models.Id{"album-1"}); diff != "" {
+		t.Errorf("artist albums: %s", diff)
+	}
+
+	// updating album replaces artists
+	err = db.UpdateAlbums([]*models.Album{{Id: "album-1", Name: "album 1", Artist: "artist-1"}})
+	if err != nil {
+		t.Fatalf("update albums: %v", err)
+	}
+	albums, err = db.GetArtistAlbums("artist-4")
+	if err != nil {
+		t.Fatalf("get artist albums: %v", err)
+	}
+	if len(albums) != 0 {
+		t.Errorf("artist albums after update: %v", albumIds(albums))
+	}
+
+	artists, n, err := db.GetAlbumArtists(interfaces.DefaultQueryOpts())
+	if err != nil {
+		t.Fatalf("get album artists: %v", err)
+	}
+	if len(artists) != 3 || n != 3 {
+		t.Errorf("album artists: %d, total %d, want 3", len(artists), n)
+	}
+}
+
+func TestDb_Genres(t *testing.T) {
+	db := testLibraryDb(t)
+	defer closeDb(t, db)
+
+	genres, n, err := db.GetGenres(interfaces.DefaultPaging())
+	if err != nil {
+		t.Fatalf("get genres: %v", err)
+	}
+	want := []*models.IdName{{Id: "genre-2", Name: "jazz"}, {Id: "genre-1", Name: "rock"}}
+	if n != 2 {
+		t.Errorf("genres total: %d, want 2", n)
+	}
+	if diff := cmp.Diff(genres, want); diff != "" {
+		t.Errorf("genres: %s", diff)
+	}
+
+	albums, err := db.GetGenreAlbums("genre-1")
+	if err != nil {
+		t.Fatalf("get genre albums: %v", err)
+	}
+	if diff := cmp.Diff(albumIds(albums), []models.Id{"album-1", "album-2"}); diff != "" {
+		t.Errorf("genre albums: %s", diff)
+	}
+
+	query := interfaces.DefaultQueryOpts()
+	query.Filter.Genres = []models.IdName{{Id: "genre-2"}}
+	query.Filter.Favorite = false
+	albums, n, err = db.GetAlbums(query)
+	if err != nil {
+		t.Fatalf("get albums: %v", err)
+	}
+	if diff := cmp.Diff(albumIds(albums), []models.Id{"album-1", "album-3"}); diff != "" || n != 2 {
+		t.Errorf("filter albums by genre (total %d): %s", n, diff)
+	}
+
+	query = interfaces.DefaultQueryOpts()
+	query.Filter.Favorite = true
+	albums, n, err = db.GetAlbums(query)
+	if err != nil {
+		t.Fatalf("get albums: %v", err)
+	}
+	if diff := cmp.Diff(albumIds(albums), []models.Id{"album-2"}); diff != "" || n != 1 {
+		t.Errorf("favorite albums (total %d): %s", n, diff)
+	}
+
+	similar, err := db.GetSimilarAlbums("album-2", 10)
+	if err != nil {
+		t.Fatalf("get similar albums: %v", err)
+	}
+	if diff := cmp.Diff(albumIds(similar), []models.Id{"album-1"}); diff != "" {
+		t.Errorf("similar albums: %s", diff)
+	}
+
+	artists, err := db.GetSimilarArtists("artist-1", 10)
+	if err != nil {
+		t.Fatalf("get similar artists: %v", err)
+	}
+	if len(artists) != 2 {
+		t.Errorf("similar artists: %d, want 2", len(artists))
+	}
+
+	mix, err := db.GetInstantMix(&models.Album{Id: "album-3"}, 10)
+	if err != nil {
+		t.Fatalf("get instant mix: %v", err)
+	}
+	if len(mix) != 2 {
+		t.Errorf("instant mix: %d songs, want 2", len(mix))
+	}
+}
+
+func TestDb_LatestAndRecentlyPlayed(t *testing.T) {
+	db := testLibraryDb(t)
+	defer closeDb(t, db)
+
+	err := db.UpdateLatestAlbums([]models.Id{"album-3", "album-1"})
+	if err != nil {
+		t.Fatalf("update latest albums: %v", err)
+	}
+	albums, err := db.GetLatestAlbums()
+	if err != nil {
+		t.Fatalf("get latest albums: %v", err)
+	}
+	if diff := cmp.Diff(albumIds(albums), []models.Id{"album-3", "album-1"}); diff != "" {
+		t.Errorf("latest albums: %s", diff)
+	}
+
+	now := time.Now()
+	plays := []*models.Play{
+		{Song: "song-1", Started: now.Add(-time.Hour)},
+		{Song: "song-2", Started: now.Add(-time.Minute * 30)},
+		{Song: "song-1", Started: now.Add(-time.Minute)},
+	}
+	for _, v := range plays {
+		err = db.AddPlay(v)
+		if err != nil {
+			t.Fatalf("add play: %v", err)
+		}
+	}
+
+	songs, n, err := db.GetRecentlyPlayed(interfaces.DefaultPaging())
+	if err != nil {
+		t.Fatalf("get recently played: %v", err)
+	}
+	if n != 2 || len(songs) != 2 || songs[0].Id != "song-1" || songs[1].Id != "song-2" {
+		t.Errorf("recently played (total %d): %v", n, songs)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"tryffel.net/go/jellycli/storage/migrations"
+)
+
+// migration upgrades schema by one level.
+type migration struct {
+	level int
+	name  string
+	sql   string
+}
+
+// schemaMigrations are applied in order. First migration creates schema level 1.
+// Never modify existing migrations, add a new one instead.
+var schemaMigrations = []migration{
+	{level: 1, name: "initial schema", sql: migrations.SchemaV1},
+	{level: 2, name: "download checksums", sql: migrations.SchemaV2},
+	{level: 3, name: "outbox", sql: migrations.SchemaV3},
+	{level: 4, name: "listening history", sql: migrations.SchemaV4},
+	{level: 5, name: "library relations", sql: migrations.SchemaV5},
+	{level: 6, name: "migration history", sql: migrations.SchemaV6},
+	{level: 7, name: "song play counts", sql: migrations.SchemaV7},
+}
+
+// schemaLevel is the latest schema level.
+var schemaLevel = len(schemaMigrations)
+
+// levelMigrationHistory is the first level where schema table has migration name and time.
+const levelMigrationHistory = 6
+
+// migrate schema from given level to latest level. Level 0 means empty database.
+// Existing database is backed up before migrating. Each migration is run in its own transaction
+// and recorded in schema table.
+func (db *Db) migrate(level int) error {
+	if level > 0 {
+		backup, err := db.backup(level)
+		if err != nil {
+			return fmt.Errorf("backup database before migration: %v", err)
+		}
+		logrus.Infof("Database backed up to %s", backup)
+	}
+
+	for _, m := range schemaMigrations[level:] {
+		logrus.Infof("Migrate database schema to level %d (%s)", m.level, m.name)
+		err := db.applyMigration(m)
+		if err != nil {
+			return fmt.Errorf("migrate schema to level %d: %v", m.level, err)
+		}
+	}
+	return nil
+}
+
+func (db *Db) applyMigration(m migration) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	_, err = tx.Exec(m.sql)
+	if err != nil {
+		return err
+	}
+
+	if m.level < levelMigrationHistory {
+		_, err = tx.Exec("INSERT INTO schema(level) VALUES (?)", m.level)
+	} else {
+		_, err = tx.Exec("INSERT INTO schema(level, name, migrated_at) VALUES (?, ?, ?)",
+			m.level, m.name, sqlTime{time.Now()})
+	}
+	if err != nil {
+		return fmt.Errorf("record migration: %v", err)
+	}
+	tx.ok = true
+	return nil
+}
+
+// backup copies database file next to it and returns path to backup.
+func (db *Db) backup(level int) (string, error) {
+	file := fmt.Sprintf("%s.v%d.bak", db.file, level)
+
+	src, err := os.Open(db.file)
+	if err != nil {
+		return file, err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(file)
+	if err != nil {
+		return file, err
+	}
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return file, err
+	}
+	return file, dst.Close()
+}
+
+// get current schema level. Empty database has level 0.
+func (db *Db) getSchemaLevel() (int, error) {
+	schema := 0
+	err := db.engine.Get(&schema, "SELECT COALESCE(MAX(level), 0) FROM schema;")
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return 0, nil
+		}
+		return -1, err
+	}
+	return schema, nil
+}
@@ -0,0 +1,187 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"io/ioutil"
+	"path"
+	"testing"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+)
+
+// fixtureDb creates database file from fixture and returns path to it.
+func fixtureDb(t *testing.T, fixture string) string {
+	file := path.Join(t.TempDir(), "test-123.db")
+	sql, err := ioutil.ReadFile(path.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	engine, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s?_fk=true&_cslike=false", file))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer engine.Close()
+	_, err = engine.Exec(string(sql))
+	if err != nil {
+		t.Fatalf("create fixture database: %v", err)
+	}
+	return file
+}
+
+func TestSchemaMigrations(t *testing.T) {
+	for i, v := range schemaMigrations {
+		if v.level != i+1 {
+			t.Errorf("migration %d has level %d, want %d", i, v.level, i+1)
+		}
+		if v.name == "" || v.sql == "" {
+			t.Errorf("migration %d has no name or sql", v.level)
+		}
+	}
+	if schemaLevel != len(schemaMigrations) {
+		t.Errorf("schema level %d, migrations: %d", schemaLevel, len(schemaMigrations))
+	}
+}
+
+func TestDb_migrate(t *testing.T) {
+	file := fixtureDb(t, "schema_v1.sql")
+
+	db, err := newDb(file, "test-123")
+	if err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	defer closeDb(t, db)
+
+	level, err := db.getSchemaLevel()
+	if err != nil {
+		t.Errorf("get schema level: %v", err)
+	}
+	if level != schemaLevel {
+		t.Errorf("invalid schema level: %d, want: %d", level, schemaLevel)
+	}
+
+	var levels []int
+	err = db.engine.Select(&levels, "SELECT level FROM schema ORDER BY level")
+	if err != nil {
+		t.Fatalf("get migrations: %v", err)
+	}
+	if len(levels) != schemaLevel {
+		t.Errorf("recorded migrations: %v, want %d", levels, schemaLevel)
+	}
+	name := ""
+	err = db.engine.Get(&name, "SELECT name FROM schema WHERE level = ?", schemaLevel)
+	if err != nil || name != schemaMigrations[schemaLevel-1].name {
+		t.Errorf("latest migration name: '%s', err: %v", name, err)
+	}
+
+	// existing data is usable
+	artists, n, err := db.GetArtists(interfaces.DefaultQueryOpts())
+	if err != nil {
+		t.Fatalf("get artists: %v", err)
+	}
+	if len(artists) != 2 || n != 2 {
+		t.Errorf("artists after migration: %d", len(artists))
+	}
+	songs, err := db.GetPlaylistSongs("playlist-1")
+	if err != nil {
+		t.Fatalf("get playlist songs: %v", err)
+	}
+	if len(songs) != 2 || songs[0].Id != "song-4" {
+		t.Errorf("playlist songs after migration: %v", songs)
+	}
+	albums, err := db.GetArtistAlbums("artist-1")
+	if err != nil {
+		t.Fatalf("get artist albums: %v", err)
+	}
+	if len(albums) != 2 {
+		t.Errorf("artist albums after migration: %d", len(albums))
+	}
+	items, err := db.Search("second", models.TypeAlbum, 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(items) != 1 || items[0].GetId() != "album-2" {
+		t.Errorf("search after migration: %v", items)
+	}
+	download, err := db.GetDownload("song-1")
+	if err != nil {
+		t.Fatalf("get download: %v", err)
+	}
+	if download == nil || download.File != "/tmp/song-1.mp3" {
+		t.Errorf("download after migration: %v", download)
+	}
+
+	// backup has original schema
+	backup, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s.v1.bak", file))
+	if err != nil {
+		t.Fatalf("open backup: %v", err)
+	}
+	defer backup.Close()
+	level = 0
+	err = backup.Get(&level, "SELECT MAX(level) FROM schema")
+	if err != nil {
+		t.Fatalf("get backup schema level: %v", err)
+	}
+	if level != 1 {
+		t.Errorf("backup schema level: %d, want 1", level)
+	}
+}
+
+func TestDb_migrateFailure(t *testing.T) {
+	file := fixtureDb(t, "schema_v1.sql")
+
+	oldMigrations, oldLevel := schemaMigrations, schemaLevel
+	defer func() {
+		schemaMigrations, schemaLevel = oldMigrations, oldLevel
+	}()
+	schemaMigrations = append(schemaMigrations[:len(schemaMigrations):len(schemaMigrations)], migration{
+		level: oldLevel + 1,
+		name:  "invalid",
+		sql:   "CREATE TABLE test (id TEXT); INSERT INTO no_such_table VALUES (1);",
+	})
+	schemaLevel = len(schemaMigrations)
+
+	db, err := newDb(file, "test-123")
+	if err == nil {
+		t.Fatalf("migration did not fail")
+	}
+	db.Close()
+
+	// previous migrations were applied, failed migration was rolled back
+	schemaMigrations, schemaLevel = oldMigrations, oldLevel
+	db, err = newDb(file, "test-123")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer closeDb(t, db)
+	level, err := db.getSchemaLevel()
+	if err != nil {
+		t.Fatalf("get schema level: %v", err)
+	}
+	if level != oldLevel {
+		t.Errorf("schema level after failed migration: %d, want %d", level, oldLevel)
+	}
+	exists := 0
+	err = db.engine.Get(&exists, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'test'")
+	if err != nil || exists != 0 {
+		t.Errorf("failed migration was not rolled back: %d, %v", exists, err)
+	}
+}
@@ -0,0 +1,41 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package migrations
+
+// SchemaV4 adds local listening history.
+const SchemaV4 = `
+
+CREATE TABLE plays (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	song TEXT NOT NULL,
+	song_name TEXT NOT NULL DEFAULT '',
+	album TEXT NOT NULL DEFAULT '',
+	album_name TEXT NOT NULL DEFAULT '',
+	artist TEXT NOT NULL DEFAULT '',
+	artist_name TEXT NOT NULL DEFAULT '',
+	-- song duration in seconds
+	duration INTEGER NOT NULL DEFAULT 0,
+	started_at INTEGER NOT NULL,
+	-- played duration in seconds
+	played INTEGER NOT NULL DEFAULT 0,
+	completed BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX plays_started_at ON plays(started_at);
+
+`
@@ -0,0 +1,68 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package migrations
+
+// SchemaV5 adds relations between artists, albums, songs and genres.
+const SchemaV5 = `
+
+-- primary artist of song
+ALTER TABLE songs ADD COLUMN artist TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX songs_album ON songs(album);
+CREATE INDEX albums_artist ON albums(artist);
+
+-- artists of album, including primary artist
+CREATE TABLE album_artists (
+	album TEXT NOT NULL,
+	artist TEXT NOT NULL,
+	name TEXT NOT NULL DEFAULT '',
+	artist_index INTEGER NOT NULL,
+
+	PRIMARY KEY (album, artist)
+);
+
+CREATE INDEX album_artists_artist ON album_artists(artist);
+
+-- all artists taking part in song
+CREATE TABLE song_artists (
+	song TEXT NOT NULL,
+	artist TEXT NOT NULL,
+	name TEXT NOT NULL DEFAULT '',
+	artist_index INTEGER NOT NULL,
+
+	PRIMARY KEY (song, artist)
+);
+
+CREATE INDEX song_artists_artist ON song_artists(artist);
+
+CREATE TABLE genre_albums (
+	genre TEXT NOT NULL,
+	album TEXT NOT NULL,
+
+	PRIMARY KEY (genre, album)
+);
+
+CREATE INDEX genre_albums_album ON genre_albums(album);
+
+-- latest albums in the order server returns them
+CREATE TABLE latest_albums (
+	position INTEGER PRIMARY KEY,
+	album TEXT NOT NULL
+);
+
+`
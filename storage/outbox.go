@@ -0,0 +1,81 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"tryffel.net/go/jellycli/models"
+)
+
+// AddOperation adds operation to outbox. If operation with same key already exists,
+// nothing is done and false is returned.
+func (db *Db) AddOperation(op *models.Operation) (bool, error) {
+	sql := `INSERT INTO outbox(type, key, item, data, played_at, attempts, error)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(key) DO NOTHING;`
+
+	res, err := db.engine.Exec(sql, op.Type, op.Key, op.Item, op.Data, sqlTime{op.PlayedAt}, op.Attempts, op.Error)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	op.Id, err = res.LastInsertId()
+	return true, err
+}
+
+// GetOperations returns all operations in outbox in the order they were added.
+func (db *Db) GetOperations() ([]*models.Operation, error) {
+	sql := `SELECT id, type, key, item, data, played_at, attempts, error FROM outbox ORDER BY id;`
+	rows, err := db.engine.Query(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	operations := make([]*models.Operation, 0)
+	for rows.Next() {
+		op := &models.Operation{}
+		playedAt := sqlTime{}
+		err = rows.Scan(&op.Id, &op.Type, &op.Key, &op.Item, &op.Data, &playedAt, &op.Attempts, &op.Error)
+		if err != nil {
+			return operations, err
+		}
+		op.PlayedAt = playedAt.Time
+		operations = append(operations, op)
+	}
+	return operations, rows.Err()
+}
+
+// OperationFailed increases attempts and stores error for operation.
+func (db *Db) OperationFailed(id int64, opErr error) error {
+	sql := `UPDATE outbox SET attempts = attempts + 1, error = ? WHERE id = ?`
+	_, err := db.engine.Exec(sql, opErr.Error(), id)
+	return err
+}
+
+// RemoveOperation removes operation from outbox.
+func (db *Db) RemoveOperation(id int64) error {
+	_, err := db.engine.Exec("DELETE FROM outbox WHERE id = ?", id)
+	return err
+}
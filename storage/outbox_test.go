@@ -0,0 +1,84 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
+
+func TestDb_Outbox(t *testing.T) {
+	db := testDb(t)
+	if db == nil {
+		return
+	}
+	defer closeDb(t, db)
+
+	playedAt := time.Now()
+	first := models.NewOperation(models.OperationScrobble, "song-1", playedAt, "")
+	second := models.NewOperation(models.OperationPlaybackStopped, "song-1", playedAt, "{}")
+	duplicate := models.NewOperation(models.OperationScrobble, "song-1", playedAt, "")
+
+	for i, v := range []*models.Operation{first, second, duplicate} {
+		added, err := db.AddOperation(v)
+		if err != nil {
+			t.Errorf("add operation: %v", err)
+		}
+		if added != (i < 2) {
+			t.Errorf("operation %d: added: %t, want: %t", i, added, i < 2)
+		}
+	}
+
+	err := db.OperationFailed(first.Id, errors.New("server is offline"))
+	if err != nil {
+		t.Errorf("operation failed: %v", err)
+	}
+
+	operations, err := db.GetOperations()
+	if err != nil {
+		t.Errorf("get operations: %v", err)
+	}
+	if len(operations) != 2 {
+		t.Errorf("invalid operations count: %d, want: 2", len(operations))
+		return
+	}
+	if operations[0].Id != first.Id || operations[1].Id != second.Id {
+		t.Errorf("invalid operations order")
+	}
+	if operations[0].Attempts != 1 || operations[0].Error != "server is offline" {
+		t.Errorf("invalid failed operation: %v", operations[0])
+	}
+	if !operations[1].PlayedAt.Equal(playedAt) || operations[1].Data != "{}" {
+		t.Errorf("invalid operation: %v", operations[1])
+	}
+
+	err = db.RemoveOperation(first.Id)
+	if err != nil {
+		t.Errorf("remove operation: %v", err)
+	}
+	operations, err = db.GetOperations()
+	if err != nil {
+		t.Errorf("get operations: %v", err)
+	}
+	if len(operations) != 1 || operations[0].Id != second.Id {
+		t.Errorf("invalid operations after remove: %v", operations)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	"fmt"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
+
+// AddPlay stores play to listening history.
+func (db *Db) AddPlay(play *models.Play) error {
+	sql := `INSERT INTO plays(song, song_name, album, album_name, artist, artist_name, duration,
+	started_at, played, completed)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+	res, err := db.engine.Exec(sql, play.Song, play.SongName, play.Album, play.AlbumName, play.Artist,
+		play.ArtistName, play.Duration, sqlTime{play.Started}, int(play.Played.Seconds()), play.Completed)
+	if err != nil {
+		return err
+	}
+	play.Id, err = res.LastInsertId()
+	return err
+}
+
+// GetPlays returns plays started after since in the order they were played. Zero since returns all plays.
+func (db *Db) GetPlays(since time.Time) ([]*models.Play, error) {
+	sql := `SELECT id, song, song_name, album, album_name, artist, artist_name, duration,
+	started_at, played, completed
+	FROM plays WHERE started_at >= ? ORDER BY started_at, id;`
+
+	rows, err := db.engine.Query(sql, sinceTime(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plays := make([]*models.Play, 0)
+	for rows.Next() {
+		play := &models.Play{}
+		started := sqlTime{}
+		played := 0
+		err = rows.Scan(&play.Id, &play.Song, &play.SongName, &play.Album, &play.AlbumName, &play.Artist,
+			&play.ArtistName, &play.Duration, &started, &played, &play.Completed)
+		if err != nil {
+			return plays, err
+		}
+		play.Started = started.Time
+		play.Played = time.Duration(played) * time.Second
+		plays = append(plays, play)
+	}
+	return plays, rows.Err()
+}
+
+// GetListeningStats returns statistics of plays started after since. Zero since means all plays.
+// Top items are counted from completed plays and each list contains at most limit items.
+func (db *Db) GetListeningStats(since time.Time, limit int) (*models.ListeningStats, error) {
+	stats := &models.ListeningStats{Since: since}
+	played := 0
+
+	sql := `SELECT COUNT(id), COALESCE(SUM(NOT completed), 0), COALESCE(SUM(played), 0)
+	FROM plays WHERE started_at >= ?;`
+	err := db.engine.QueryRow(sql, sinceTime(since)).Scan(&stats.Plays, &stats.Skips, &played)
+	if err != nil {
+		return stats, err
+	}
+	stats.ListeningTime = time.Duration(played) * time.Second
+
+	stats.TopArtists, err = db.getTopPlays("artist", "artist_name", since, limit)
+	if err != nil {
+		return stats, fmt.Errorf("top artists: %v", err)
+	}
+	stats.TopAlbums, err = db.getTopPlays("album", "album_name", since, limit)
+	if err != nil {
+		return stats, fmt.Errorf("top albums: %v", err)
+	}
+	stats.TopSongs, err = db.getTopPlays("song", "song_name", since, limit)
+	if err != nil {
+		return stats, fmt.Errorf("top songs: %v", err)
+	}
+	return stats, nil
+}
+
+// getTopPlays returns most played items grouped by column idColumn.
+func (db *Db) getTopPlays(idColumn, nameColumn string, since time.Time, limit int) ([]models.PlayCount, error) {
+	sql := fmt.Sprintf(`SELECT %s, MAX(%s), COUNT(id) AS plays, SUM(played) AS played
+	FROM plays WHERE started_at >= ? AND completed AND %s != ''
+	GROUP BY %s ORDER BY plays DESC, played DESC LIMIT ?;`, idColumn, nameColumn, idColumn, idColumn)
+
+	rows, err := db.engine.Query(sql, sinceTime(since), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]models.PlayCount, 0, limit)
+	for rows.Next() {
+		count := models.PlayCount{}
+		played := 0
+		err = rows.Scan(&count.Id, &count.Name, &count.Plays, &played)
+		if err != nil {
+			return counts, err
+		}
+		count.Played = time.Duration(played) * time.Second
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// sinceTime returns since as sql value. Zero time matches all timestamps.
+func sinceTime(since time.Time) interface{} {
+	if since.IsZero() {
+		return 0
+	}
+	return sqlTime{since}
+}
@@ -0,0 +1,91 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
+
+func TestDb_Plays(t *testing.T) {
+	db := testDb(t)
+	if db == nil {
+		return
+	}
+	defer closeDb(t, db)
+
+	now := time.Now()
+	plays := []*models.Play{
+		{Song: "song-1", SongName: "Song 1", Album: "album-1", AlbumName: "Album 1", Artist: "artist-1",
+			ArtistName: "Artist 1", Duration: 200, Started: now.AddDate(0, -2, 0), Played: 200 * time.Second,
+			Completed: true},
+		{Song: "song-1", SongName: "Song 1", Album: "album-1", AlbumName: "Album 1", Artist: "artist-1",
+			ArtistName: "Artist 1", Duration: 200, Started: now.Add(-time.Hour), Played: 198 * time.Second,
+			Completed: true},
+		{Song: "song-2", SongName: "Song 2", Album: "album-2", AlbumName: "Album 2", Artist: "artist-1",
+			ArtistName: "Artist 1", Duration: 100, Started: now.Add(-time.Minute * 30), Played: 100 * time.Second,
+			Completed: true},
+		{Song: "song-3", SongName: "Song 3", Album: "album-2", AlbumName: "Album 2", Artist: "artist-2",
+			ArtistName: "Artist 2", Duration: 300, Started: now.Add(-time.Minute * 20), Played: 10 * time.Second,
+			Completed: false},
+	}
+	for _, v := range plays {
+		err := db.AddPlay(v)
+		if err != nil {
+			t.Fatalf("add play: %v", err)
+		}
+	}
+
+	got, err := db.GetPlays(now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Errorf("get plays: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("invalid plays count: %d, want: 3", len(got))
+	}
+	if got[0].Id != plays[1].Id || got[0].Played != plays[1].Played || !got[0].Started.Equal(plays[1].Started) {
+		t.Errorf("invalid play: %v, want: %v", got[0], plays[1])
+	}
+	if got[2].Completed {
+		t.Errorf("skipped play is completed")
+	}
+
+	stats, err := db.GetListeningStats(time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("get listening stats: %v", err)
+	}
+	if stats.Plays != 4 || stats.Skips != 1 || stats.ListeningTime != 508*time.Second {
+		t.Errorf("invalid stats: plays %d, skips %d, listening time %s", stats.Plays, stats.Skips,
+			stats.ListeningTime)
+	}
+	if len(stats.TopArtists) != 1 || stats.TopArtists[0].Name != "Artist 1" || stats.TopArtists[0].Plays != 3 {
+		t.Errorf("invalid top artists: %v", stats.TopArtists)
+	}
+	if len(stats.TopSongs) != 2 || stats.TopSongs[0].Id != "song-1" || stats.TopSongs[0].Plays != 2 {
+		t.Errorf("invalid top songs: %v", stats.TopSongs)
+	}
+
+	stats, err = db.GetListeningStats(now.AddDate(0, 0, -7), 1)
+	if err != nil {
+		t.Fatalf("get listening stats: %v", err)
+	}
+	if stats.Plays != 3 || len(stats.TopAlbums) != 1 || stats.TopAlbums[0].Id != "album-1" {
+		t.Errorf("invalid stats for period: plays %d, top albums %v", stats.Plays, stats.TopAlbums)
+	}
+}
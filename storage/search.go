@@ -0,0 +1,315 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"tryffel.net/go/jellycli/models"
+	"unicode"
+)
+
+// searchTables have full-text index in table <name>_fts, which uses same rowid as the table.
+var searchTables = []string{"artists", "albums", "songs", "playlists"}
+
+// max number of similar terms to search for each misspelled term
+const searchMaxSimilarTerms = 10
+
+// initSearch creates full-text search indices if sqlite is built with FTS5 (build tag sqlite_fts5).
+// Without FTS5, search falls back to matching names with LIKE.
+func (db *Db) initSearch() error {
+	enabled := false
+	err := db.engine.Get(&enabled, "SELECT sqlite_compileoption_used('ENABLE_FTS5');")
+	if err != nil {
+		return fmt.Errorf("check fts5 support: %v", err)
+	}
+	if !enabled {
+		logrus.Debug("Sqlite has no FTS5 support, full-text search is disabled")
+		return nil
+	}
+
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	for _, table := range searchTables {
+		exists := 0
+		err = tx.Get(&exists, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table+"_fts")
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			sql := fmt.Sprintf(`CREATE VIRTUAL TABLE %[1]s_fts USING fts5(name, tokenize='unicode61 remove_diacritics 2');
+			CREATE VIRTUAL TABLE %[1]s_vocab USING fts5vocab(%[1]s_fts, 'row');`, table)
+			_, err = tx.Exec(sql)
+			if err != nil {
+				return fmt.Errorf("create search index for %s: %v", table, err)
+			}
+		}
+
+		// index is rebuilt if it is new or items were updated without full-text support
+		var items, indexed int
+		err = tx.Get(&items, fmt.Sprintf("SELECT COUNT(*) FROM %s", table))
+		if err != nil {
+			return err
+		}
+		err = tx.Get(&indexed, fmt.Sprintf("SELECT COUNT(*) FROM %s_fts", table))
+		if err != nil {
+			return err
+		}
+		if items != indexed {
+			logrus.Infof("Build search index for %s", table)
+			sql := fmt.Sprintf(`DELETE FROM %[1]s_fts;
+			INSERT INTO %[1]s_fts(rowid, name) SELECT rowid, name FROM %[1]s;`, table)
+			_, err = tx.Exec(sql)
+			if err != nil {
+				return fmt.Errorf("build search index for %s: %v", table, err)
+			}
+		}
+	}
+	tx.ok = true
+	db.fts = true
+	return nil
+}
+
+// updateSearchIndex updates full-text index for items in table that have given ids.
+func (db *Db) updateSearchIndex(table string, ids []models.Id, tx *tx) error {
+	if !db.fts || len(ids) == 0 {
+		return nil
+	}
+	args := idArgs(ids)
+	in := sqlIn(len(ids))
+
+	sql := fmt.Sprintf(`DELETE FROM %[1]s_fts WHERE rowid IN (SELECT rowid FROM %[1]s WHERE id IN %[2]s);`, table, in)
+	_, err := tx.Exec(sql, args...)
+	if err != nil {
+		return fmt.Errorf("remove from search index: %v", err)
+	}
+	sql = fmt.Sprintf(`INSERT INTO %[1]s_fts(rowid, name) SELECT rowid, name FROM %[1]s WHERE id IN %[2]s;`, table, in)
+	_, err = tx.Exec(sql, args...)
+	if err != nil {
+		return fmt.Errorf("add to search index: %v", err)
+	}
+	return nil
+}
+
+// escapeLike escapes wildcards in LIKE pattern. Pattern must use ESCAPE '\'.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Search returns items of itemType that match query. With full-text index, items are matched by words
+// and word prefixes, results are ranked and misspelled words are matched to similar words.
+// Else items whose name contain query are returned.
+func (db *Db) Search(query string, itemType models.ItemType, limit int) ([]models.Item, error) {
+	table, err := itemTable(itemType)
+	if err != nil {
+		return nil, fmt.Errorf("cannot search: %v", err)
+	}
+
+	if !db.fts {
+		sql, args, err := db.builder.Select("*").From(table).
+			Where("name LIKE ? ESCAPE '\\'", "%"+escapeLike(query)+"%").
+			OrderBy("name").
+			Limit(uint64(limit)).ToSql()
+		if err != nil {
+			return nil, err
+		}
+		return db.selectItems(itemType, sql, args...)
+	}
+
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []models.Item{}, nil
+	}
+
+	sql := fmt.Sprintf(`SELECT t.* FROM
+	(SELECT rowid, rank FROM %[1]s_fts WHERE %[1]s_fts MATCH ? ORDER BY rank LIMIT ?) AS f
+	JOIN %[1]s t ON t.rowid = f.rowid ORDER BY f.rank, t.name;`, table)
+
+	match := make([]string, len(terms))
+	for i, v := range terms {
+		match[i] = ftsPrefix(v)
+	}
+	items, err := db.selectItems(itemType, sql, strings.Join(match, " AND "), limit)
+	if err != nil || len(items) > 0 {
+		return items, err
+	}
+
+	// try again with similar terms
+	similar := false
+	for i, v := range terms {
+		alternatives, err := db.similarTerms(table, v)
+		if err != nil {
+			return items, err
+		}
+		if len(alternatives) == 0 {
+			continue
+		}
+		similar = true
+		for j := range alternatives {
+			alternatives[j] = ftsQuote(alternatives[j])
+		}
+		match[i] = "(" + match[i] + " OR " + strings.Join(alternatives, " OR ") + ")"
+	}
+	if !similar {
+		return items, nil
+	}
+	return db.selectItems(itemType, sql, strings.Join(match, " AND "), limit)
+}
+
+// similarTerms returns indexed terms in table that are within edit distance from term.
+// Distance depends on term length, short terms are not corrected.
+func (db *Db) similarTerms(table, term string) ([]string, error) {
+	maxDistance := 0
+	length := len([]rune(term))
+	if length >= 8 {
+		maxDistance = 2
+	} else if length >= 4 {
+		maxDistance = 1
+	}
+	if maxDistance == 0 {
+		return nil, nil
+	}
+
+	rows, err := db.engine.Query(fmt.Sprintf("SELECT term FROM %s_vocab WHERE length(term) BETWEEN ? AND ?", table),
+		length-maxDistance, length+maxDistance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type similarTerm struct {
+		term     string
+		distance int
+	}
+	similar := make([]similarTerm, 0)
+	for rows.Next() {
+		var candidate string
+		err = rows.Scan(&candidate)
+		if err != nil {
+			return nil, err
+		}
+		distance := editDistance(term, candidate)
+		if distance <= maxDistance {
+			similar = append(similar, similarTerm{term: candidate, distance: distance})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(similar, func(i, j int) bool {
+		return similar[i].distance < similar[j].distance
+	})
+	if len(similar) > searchMaxSimilarTerms {
+		similar = similar[:searchMaxSimilarTerms]
+	}
+	terms := make([]string, len(similar))
+	for i, v := range similar {
+		terms[i] = v.term
+	}
+	return terms, nil
+}
+
+func (db *Db) selectItems(itemType models.ItemType, sql string, args ...interface{}) ([]models.Item, error) {
+	var err error
+	items := make([]models.Item, 0)
+	switch itemType {
+	case models.TypeArtist:
+		artists := &[]models.Artist{}
+		err = db.engine.Select(artists, sql, args...)
+		for i, _ := range *artists {
+			items = append(items, &(*artists)[i])
+		}
+	case models.TypeAlbum:
+		var albums []*models.Album
+		albums, err = db.selectAlbums(sql, args...)
+		for _, v := range albums {
+			items = append(items, v)
+		}
+	case models.TypeSong:
+		var songs []*models.Song
+		songs, err = db.selectSongs(sql, args...)
+		for _, v := range songs {
+			items = append(items, v)
+		}
+	case models.TypePlaylist:
+		playlists := &[]models.Playlist{}
+		err = db.engine.Select(playlists, sql, args...)
+		for i, _ := range *playlists {
+			items = append(items, &(*playlists)[i])
+		}
+	}
+	return items, err
+}
+
+// searchTerms splits query to lower-case words the same way full-text index does.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// ftsQuote returns term as fts string.
+func ftsQuote(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
+
+// ftsPrefix returns fts prefix query for term.
+func ftsPrefix(term string) string {
+	return ftsQuote(term) + "*"
+}
+
+// editDistance returns Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,197 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	"testing"
+	"tryffel.net/go/jellycli/models"
+)
+
+func testSearchArtists() []*models.Artist {
+	return []*models.Artist{
+		{Id: "a-1", Name: "The Beatles"},
+		{Id: "a-2", Name: "Beat Happening"},
+		{Id: "a-3", Name: "Sigur Rós"},
+		{Id: "a-4", Name: "Metallica"},
+	}
+}
+
+func searchIds(items []models.Item) []models.Id {
+	ids := make([]models.Id, len(items))
+	for i, v := range items {
+		ids[i] = v.GetId()
+	}
+	return ids
+}
+
+func TestDb_Search(t *testing.T) {
+	db := testDb(t)
+	if db == nil {
+		return
+	}
+	defer closeDb(t, db)
+
+	err := db.UpdateArtists(testSearchArtists())
+	if err != nil {
+		t.Fatalf("update artists: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  []models.Id
+	}{
+		{query: "beatles", want: []models.Id{"a-1"}},
+		{query: "metal", want: []models.Id{"a-4"}},
+		{query: "beat", want: []models.Id{"a-2", "a-1"}},
+		{query: "nothing", want: []models.Id{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			items, err := db.Search(tt.query, models.TypeArtist, 10)
+			if err != nil {
+				t.Fatalf("search: %v", err)
+			}
+			got := searchIds(items)
+			if len(got) != len(tt.want) {
+				t.Fatalf("search results: %v, want: %v", got, tt.want)
+			}
+			// without full-text index results are not ranked
+			if !db.fts {
+				return
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("search results: %v, want: %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	// renamed artist must be found with new name only
+	err = db.UpdateArtists([]*models.Artist{{Id: "a-4", Name: "Megadeth"}})
+	if err != nil {
+		t.Fatalf("update artists: %v", err)
+	}
+	items, err := db.Search("metallica", models.TypeArtist, 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("search renamed artist: %v", searchIds(items))
+	}
+}
+
+func TestDb_SearchLike(t *testing.T) {
+	db := testDb(t)
+	if db == nil {
+		return
+	}
+	defer closeDb(t, db)
+	db.fts = false
+
+	err := db.UpdateArtists([]*models.Artist{
+		{Id: "a-1", Name: "100% Pure"},
+		{Id: "a-2", Name: "1000 Pure"},
+		{Id: "a-3", Name: `AC\DC`},
+	})
+	if err != nil {
+		t.Fatalf("update artists: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  []models.Id
+	}{
+		{query: "0%", want: []models.Id{"a-1"}},
+		{query: "_", want: []models.Id{}},
+		{query: `C\D`, want: []models.Id{"a-3"}},
+		{query: "pure", want: []models.Id{"a-1", "a-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			items, err := db.Search(tt.query, models.TypeArtist, 10)
+			if err != nil {
+				t.Fatalf("search: %v", err)
+			}
+			got := searchIds(items)
+			if len(got) != len(tt.want) {
+				t.Fatalf("search results: %v, want: %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("search results: %v, want: %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDb_SearchTypos(t *testing.T) {
+	db := testDb(t)
+	if db == nil {
+		return
+	}
+	defer closeDb(t, db)
+	if !db.fts {
+		t.Skip("sqlite built without fts5")
+	}
+
+	err := db.UpdateArtists(testSearchArtists())
+	if err != nil {
+		t.Fatalf("update artists: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  models.Id
+	}{
+		{query: "metalica", want: "a-4"},
+		{query: "sigur ros", want: "a-3"},
+		{query: "the beetles", want: "a-1"},
+		{query: "beetles", want: "a-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			items, err := db.Search(tt.query, models.TypeArtist, 10)
+			if err != nil {
+				t.Fatalf("search: %v", err)
+			}
+			if len(items) == 0 || items[0].GetId() != tt.want {
+				t.Errorf("search results: %v, want: %s", searchIds(items), tt.want)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"metallica", "metalica", 1},
+		{"beatles", "beatels", 2},
+		{"rós", "ros", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
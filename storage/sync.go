@@ -0,0 +1,268 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	dbsql "database/sql"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
+
+const (
+	// keyRefreshed is the start time of last successful refresh.
+	keyRefreshed = "refreshed"
+	// keyReconciled is the start time of last successful full refresh.
+	keyReconciled = "reconciled"
+)
+
+// itemTable returns table for itemType.
+func itemTable(itemType models.ItemType) (string, error) {
+	switch itemType {
+	case models.TypeArtist:
+		return "artists", nil
+	case models.TypeAlbum:
+		return "albums", nil
+	case models.TypeSong:
+		return "songs", nil
+	case models.TypePlaylist:
+		return "playlists", nil
+	default:
+		return "", fmt.Errorf("no table for items of type %s", itemType)
+	}
+}
+
+func (db *Db) getStateTime(key string) (time.Time, error) {
+	t := sqlTime{}
+	err := db.engine.Get(&t, "SELECT updated FROM state WHERE key = ?", key)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return t.Time, err
+}
+
+// LastRefresh returns start time of last successful refresh and of last full refresh.
+// Zero time means there has been no such refresh.
+func (db *Db) LastRefresh() (refreshed time.Time, reconciled time.Time, err error) {
+	refreshed, err = db.getStateTime(keyRefreshed)
+	if err != nil {
+		return
+	}
+	reconciled, err = db.getStateTime(keyReconciled)
+	return
+}
+
+// SetRefreshed stores start time of successful refresh.
+func (db *Db) SetRefreshed(start time.Time, full bool) error {
+	sql := `INSERT INTO state (key, updated) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET updated=excluded.updated;`
+	_, err := db.engine.Exec(sql, keyRefreshed, sqlTime{start})
+	if err != nil || !full {
+		return err
+	}
+	_, err = db.engine.Exec(sql, keyReconciled, sqlTime{start})
+	return err
+}
+
+// CountItems returns number of items of itemType.
+func (db *Db) CountItems(itemType models.ItemType) (int, error) {
+	table, err := itemTable(itemType)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = db.engine.Get(&count, fmt.Sprintf("SELECT COUNT(id) FROM %s", table))
+	return count, err
+}
+
+// RemoveMissing removes items of itemType whose id is not in ids, and returns number of removed items.
+// Removed songs are also removed from playlists. Empty ids is ignored to prevent
+// clearing cache when server returns nothing.
+func (db *Db) RemoveMissing(itemType models.ItemType, ids []models.Id) (int, error) {
+	table, err := itemTable(itemType)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		logrus.Warningf("Refusing to remove all %s from local cache", table)
+		return 0, nil
+	}
+
+	tx, err := db.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+
+	err = fillTempIds(ids, tx)
+	if err != nil {
+		return 0, err
+	}
+	n, err := db.removeItems(itemType, "id NOT IN (SELECT id FROM temp_ids)", tx)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("DROP TABLE temp_ids")
+	if err != nil {
+		return 0, err
+	}
+	tx.ok = true
+	return n, nil
+}
+
+// RemoveItems removes items with given ids, regardless of item type, and returns number of removed items.
+func (db *Db) RemoveItems(ids []models.Id) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	tx, err := db.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+
+	err = fillTempIds(ids, tx)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, v := range []models.ItemType{models.TypePlaylist, models.TypeSong, models.TypeAlbum, models.TypeArtist} {
+		n, err := db.removeItems(v, "id IN (SELECT id FROM temp_ids)", tx)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	_, err = tx.Exec("DROP TABLE temp_ids")
+	if err != nil {
+		return 0, err
+	}
+	tx.ok = true
+	return total, nil
+}
+
+// fillTempIds stores ids to temporary table temp_ids.
+func fillTempIds(ids []models.Id, tx *tx) error {
+	_, err := tx.Exec("CREATE TEMP TABLE IF NOT EXISTS temp_ids (id TEXT PRIMARY KEY); DELETE FROM temp_ids;")
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(ids); start += sqlMaxArgs {
+		end := start + sqlMaxArgs
+		if end > len(ids) {
+			end = len(ids)
+		}
+		values := "(?)" + strings.Repeat(", (?)", end-start-1)
+		_, err = tx.Exec("INSERT OR IGNORE INTO temp_ids(id) VALUES "+values, idArgs(ids[start:end])...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeItems removes items of itemType matching condition, along with their relations and search index.
+func (db *Db) removeItems(itemType models.ItemType, condition string, tx *tx) (int, error) {
+	table, err := itemTable(itemType)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := fmt.Sprintf("SELECT id FROM %s WHERE %s", table, condition)
+	sql := ""
+	switch itemType {
+	case models.TypeAlbum:
+		sql = fmt.Sprintf(`DELETE FROM album_artists WHERE album IN (%[1]s);
+		DELETE FROM genre_albums WHERE album IN (%[1]s);
+		DELETE FROM latest_albums WHERE album IN (%[1]s);`, removed)
+	case models.TypeSong:
+		sql = fmt.Sprintf(`DELETE FROM song_artists WHERE song IN (%[1]s);
+		DELETE FROM playlist_songs WHERE song IN (%[1]s);`, removed)
+	case models.TypePlaylist:
+		sql = fmt.Sprintf(`DELETE FROM playlist_songs WHERE playlist IN (%s);`, removed)
+	}
+	if db.fts {
+		sql += fmt.Sprintf(`DELETE FROM %[1]s_fts WHERE rowid IN
+		(SELECT rowid FROM %[1]s WHERE %[2]s);`, table, condition)
+	}
+	if sql != "" {
+		_, err = tx.Exec(sql)
+		if err != nil {
+			return 0, fmt.Errorf("remove %s relations: %v", table, err)
+		}
+	}
+
+	res, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", table, condition))
+	if err != nil {
+		return 0, fmt.Errorf("remove %s: %v", table, err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// SetFavorite sets favorite status of artist, album or song with given id.
+func (db *Db) SetFavorite(item models.Id, favorite bool) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	for _, table := range []string{"artists", "albums", "songs"} {
+		_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET favorite = ? WHERE id = ?", table), favorite, item)
+		if err != nil {
+			return fmt.Errorf("update %s favorite: %v", table, err)
+		}
+	}
+	tx.ok = true
+	return nil
+}
+
+// UpdateUserData updates favorites of artists, albums and songs, and play counts of songs.
+// It returns number of items whose favorite status changed.
+func (db *Db) UpdateUserData(data []models.UserData) (int, error) {
+	tx, err := db.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+
+	changed := 0
+	for _, v := range data {
+		for _, table := range []string{"artists", "albums", "songs"} {
+			res, err := tx.Exec(fmt.Sprintf("UPDATE %s SET favorite = ? WHERE id = ? AND favorite IS NOT ?", table),
+				v.Favorite, v.Item, v.Favorite)
+			if err != nil {
+				return 0, fmt.Errorf("update %s favorite: %v", table, err)
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return 0, err
+			}
+			changed += int(n)
+		}
+		_, err = tx.Exec("UPDATE songs SET play_count = ? WHERE id = ?", v.PlayCount, v.Item)
+		if err != nil {
+			return 0, fmt.Errorf("update play count: %v", err)
+		}
+	}
+	tx.ok = true
+	return changed, nil
+}
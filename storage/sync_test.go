@@ -0,0 +1,221 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package storage
+
+import (
+	"testing"
+	"time"
+	"tryffel.net/go/jellycli/models"
+)
+
+func TestDb_RemoveMissing(t *testing.T) {
+	db := testLibraryDb(t)
+	defer closeDb(t, db)
+
+	err := db.UpdatePlaylists([]*models.Playlist{
+		{Id: "playlist-1", Name: "playlist 1", Songs: []*models.Song{{Id: "song-1"}, {Id: "song-3"}}},
+		{Id: "playlist-2", Name: "playlist 2", Songs: []*models.Song{{Id: "song-2"}}},
+	})
+	if err != nil {
+		t.Fatalf("update playlists: %v", err)
+	}
+
+	removed, err := db.RemoveMissing(models.TypeSong, []models.Id{"song-1", "song-2"})
+	if err != nil {
+		t.Fatalf("remove songs: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("removed songs: %d, want 1", removed)
+	}
+	songs, err := db.GetPlaylistSongs("playlist-1")
+	if err != nil {
+		t.Fatalf("get playlist songs: %v", err)
+	}
+	if len(songs) != 1 || songs[0].Id != "song-1" {
+		t.Errorf("playlist songs after removal: %v", songs)
+	}
+
+	removed, err = db.RemoveMissing(models.TypePlaylist, []models.Id{"playlist-1"})
+	if err != nil {
+		t.Fatalf("remove playlists: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("removed playlists: %d, want 1", removed)
+	}
+
+	removed, err = db.RemoveMissing(models.TypeAlbum, []models.Id{"album-2", "album-3"})
+	if err != nil {
+		t.Fatalf("remove albums: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("removed albums: %d, want 1", removed)
+	}
+	albums, err := db.GetGenreAlbums("genre-1")
+	if err != nil {
+		t.Fatalf("get genre albums: %v", err)
+	}
+	if len(albums) != 1 || albums[0].Id != "album-2" {
+		t.Errorf("genre albums after removal: %v", albumIds(albums))
+	}
+	items, err := db.Search("album 1", models.TypeAlbum, 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("removed album found with search")
+	}
+
+	// empty list does nothing
+	removed, err = db.RemoveMissing(models.TypeArtist, nil)
+	if err != nil {
+		t.Fatalf("remove artists: %v", err)
+	}
+	n, err := db.CountItems(models.TypeArtist)
+	if err != nil {
+		t.Fatalf("count artists: %v", err)
+	}
+	if removed != 0 || n != 4 {
+		t.Errorf("remove with empty list: removed %d, left %d", removed, n)
+	}
+}
+
+func TestDb_RemoveItems(t *testing.T) {
+	db := testLibraryDb(t)
+	defer closeDb(t, db)
+
+	removed, err := db.RemoveItems([]models.Id{"album-1", "song-2", "artist-4", "unknown"})
+	if err != nil {
+		t.Fatalf("remove items: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("removed items: %d, want 3", removed)
+	}
+	want := map[models.ItemType]int{models.TypeArtist: 3, models.TypeAlbum: 2, models.TypeSong: 2}
+	for itemType, count := range want {
+		n, err := db.CountItems(itemType)
+		if err != nil {
+			t.Fatalf("count items: %v", err)
+		}
+		if n != count {
+			t.Errorf("%s left: %d, want %d", itemType, n, count)
+		}
+	}
+	albums, err := db.GetGenreAlbums("genre-1")
+	if err != nil {
+		t.Fatalf("get genre albums: %v", err)
+	}
+	if len(albums) != 1 || albums[0].Id != "album-2" {
+		t.Errorf("genre albums after removal: %v", albumIds(albums))
+	}
+}
+
+func TestDb_UpdateUserData(t *testing.T) {
+	db := testLibraryDb(t)
+	defer closeDb(t, db)
+
+	changed, err := db.UpdateUserData([]models.UserData{
+		{Item: "album-1", Favorite: true},
+		{Item: "album-2", Favorite: false},
+		{Item: "song-1", PlayCount: 5},
+	})
+	if err != nil {
+		t.Fatalf("update user data: %v", err)
+	}
+	if changed != 2 {
+		t.Errorf("changed favorites: %d, want 2", changed)
+	}
+
+	album, err := db.GetAlbum("album-1")
+	if err != nil {
+		t.Fatalf("get album: %v", err)
+	}
+	if !album.Favorite {
+		t.Errorf("album is not favorite")
+	}
+	songs, err := db.GetAlbumSongs("album-1")
+	if err != nil {
+		t.Fatalf("get album songs: %v", err)
+	}
+	if len(songs) != 1 || songs[0].PlayCount != 5 || songs[0].Favorite {
+		t.Errorf("song user data not updated: %v", songs)
+	}
+}
+
+func TestDb_SetFavorite(t *testing.T) {
+	db := testLibraryDb(t)
+	defer closeDb(t, db)
+
+	err := db.SetFavorite("album-1", true)
+	if err != nil {
+		t.Fatalf("set favorite: %v", err)
+	}
+	album, err := db.GetAlbum("album-1")
+	if err != nil {
+		t.Fatalf("get album: %v", err)
+	}
+	if !album.Favorite {
+		t.Errorf("album is not favorite")
+	}
+
+	err = db.SetFavorite("album-1", false)
+	if err != nil {
+		t.Fatalf("remove favorite: %v", err)
+	}
+	album, err = db.GetAlbum("album-1")
+	if err != nil {
+		t.Fatalf("get album: %v", err)
+	}
+	if album.Favorite {
+		t.Errorf("album is still favorite")
+	}
+}
+
+func TestDb_LastRefresh(t *testing.T) {
+	db := testDb(t)
+	if db == nil {
+		return
+	}
+	defer closeDb(t, db)
+
+	refreshed, reconciled, err := db.LastRefresh()
+	if err != nil {
+		t.Fatalf("get last refresh: %v", err)
+	}
+	if !refreshed.IsZero() || !reconciled.IsZero() {
+		t.Errorf("new database has refresh time")
+	}
+
+	full := time.Date(2020, 10, 1, 12, 0, 0, 0, time.Local)
+	incremental := full.Add(time.Hour)
+	err = db.SetRefreshed(full, true)
+	if err != nil {
+		t.Fatalf("set refreshed: %v", err)
+	}
+	err = db.SetRefreshed(incremental, false)
+	if err != nil {
+		t.Fatalf("set refreshed: %v", err)
+	}
+
+	refreshed, reconciled, err = db.LastRefresh()
+	if err != nil {
+		t.Fatalf("get last refresh: %v", err)
+	}
+	if !refreshed.Equal(incremental) || !reconciled.Equal(full) {
+		t.Errorf("last refresh: %s, %s, want %s, %s", refreshed, reconciled, incremental, full)
+	}
+}
@@ -0,0 +1,118 @@
+-- Database with schema level 1 and some data, as created by jellycli before migrations were added.
+
+CREATE TABLE genres (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	song_count INTEGER NOT NULL,
+	album_count INTEGER NOT NULL
+);
+
+
+CREATE TABLE artists (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	favorite BOOL NOT NULL,
+	total_duration INTEGER NOT NULL DEFAULT 0,
+	album_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE albums (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	year INTEGER NOT NULL,
+	duration INTEGER NOT NULL,
+	favorite BOOL NOT NULL,
+	song_count INTEGER NOT NULL,
+	image_id TEXT NOT NULL DEFAULT '',
+	disc_count INTEGER NOT NULL DEFAULT 1,
+
+	-- jellyfin sometimes returns empty artist, so don't require existing artist.
+	artist TEXT NOT NULL DEFAULT ''
+);
+
+
+CREATE TABLE songs (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	duration INTEGER NOT NULL,
+	song_index INTEGER NOT NULL,
+	disc_number INTEGER NOT NULL,
+	favorite bool,
+
+	album TEXT
+);
+
+CREATE TABLE playlists (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL
+);
+
+CREATE TABLE playlist_songs (
+	playlist_index INTEGER NOT NULL,
+	playlist TEXT,
+	song TEXT,
+
+	FOREIGN KEY (playlist) REFERENCES playlists(id),
+	FOREIGN KEY (song) REFERENCES songs(id),
+
+	UNIQUE(playlist_index, playlist)
+);
+
+
+CREATE TABLE state (
+	key TEXT PRIMARY KEY,
+	updated INTEGER NOT NULL
+);
+
+CREATE TABLE schema (
+	level INTEGER PRIMARY KEY
+);
+
+CREATE TABLE settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE downloads (
+	-- song id
+	id TEXT NOT NULL PRIMARY KEY,
+	type TEXT NOT NULL,
+
+	path TEXT NOT NULL,
+	size INTEGER
+
+	-- timestamps
+	added_at INTEGER,
+	play_count INTEGER,
+	last_played INTEGER
+);
+
+-- data
+INSERT INTO schema VALUES (1);
+
+INSERT INTO artists(id, name, favorite, total_duration, album_count) VALUES
+	('artist-1', 'Artist One', TRUE, 3600, 2),
+	('artist-2', 'Artist Two', FALSE, 1800, 1);
+
+INSERT INTO albums(id, name, year, duration, favorite, song_count, image_id, disc_count, artist) VALUES
+	('album-1', 'First Album', 2001, 1200, FALSE, 2, 'image-1', 1, 'artist-1'),
+	('album-2', 'Second Album', 2005, 2400, TRUE, 1, '', 1, 'artist-1'),
+	('album-3', 'Other Album', 2010, 1800, FALSE, 1, '', 2, 'artist-2');
+
+INSERT INTO songs(id, name, duration, song_index, disc_number, favorite, album) VALUES
+	('song-1', 'Song One', 200, 1, 1, FALSE, 'album-1'),
+	('song-2', 'Song Two', 210, 2, 1, TRUE, 'album-1'),
+	('song-3', 'Song Three', 220, 1, 1, FALSE, 'album-2'),
+	('song-4', 'Song Four', 230, 1, 2, FALSE, 'album-3');
+
+INSERT INTO playlists(id, name) VALUES ('playlist-1', 'Playlist');
+
+INSERT INTO playlist_songs(playlist_index, playlist, song) VALUES
+	(1, 'playlist-1', 'song-4'),
+	(2, 'playlist-1', 'song-1');
+
+INSERT INTO genres(id, name, song_count, album_count) VALUES ('genre-1', 'Rock', 3, 2);
+
+INSERT INTO state(key, updated) VALUES ('artists', 1601550000000000000);
+
+INSERT INTO downloads(id, type, path, size) VALUES ('song-1', 'offline', '/tmp/song-1.mp3', 1000);
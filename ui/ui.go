@@ -39,7 +39,8 @@ func NewUi(player *player2.Player) *Gui {
 		player: player,
 	}
 	bindDefaultTheme()
-	u.window = widgets.NewWindow(player, player, player)
+	u.window = widgets.NewWindow(player, player, player, player, player, player, player, player, player,
+		player, player)
 	u.Name = "Gui"
 	u.SetLoop(u.loop)
 	return u
@@ -254,6 +254,18 @@ func NewAlbumview(playSong func(song *models.Song),
 		a.dropDown.AddOption("View similar", func() {
 			a.showSimilar()
 		})
+		a.dropDown.AddOption("Toggle favorite", func() {
+			if a.album != nil && a.context.SetFavorite(a.album, !a.album.Favorite) {
+				a.album.Favorite = !a.album.Favorite
+				a.setDescription()
+			}
+		})
+		a.dropDown.AddOption("Download", func() {
+			a.context.Download(a.album)
+		})
+		a.dropDown.AddOption("Play on device", func() {
+			a.context.PlayOnDevice(a.album)
+		})
 		a.dropDown.AddOption("Open in browser", func() {
 			a.context.OpenInBrowser(a.album)
 		})
@@ -273,7 +285,29 @@ func (a *AlbumView) SetAlbum(album *models.Album, songs []*models.Song) {
 
 	album.SongCount = len(a.songs)
 	a.album = album
+	a.setDescription()
+
+	discs := map[int]bool{}
+	for _, v := range songs {
+		discs[v.DiscNumber] = true
+	}
+	album.DiscCount = len(discs)
+	showDiscNum := album.DiscCount != 1
+	for i, v := range songs {
+		a.songs[i] = newAlbumSong(v, showDiscNum, -1)
+		items[i] = a.songs[i]
+		itemTexts[i] = strings.ToLower(v.Name)
+	}
 
+	a.list.AddItems(items...)
+	a.items = items
+	a.itemsTexts = itemTexts
+	a.searchItemsSet()
+}
+
+// setDescription shows album name, artists and details.
+func (a *AlbumView) setDescription() {
+	album := a.album
 	text := ""
 	if album.Favorite {
 		text += charFavorite + " "
@@ -304,23 +338,6 @@ func (a *AlbumView) SetAlbum(album *models.Album, songs []*models.Song) {
 		album.SongCount, util.SecToStringApproximate(album.Duration), album.Year)
 
 	a.description.SetText(text)
-
-	discs := map[int]bool{}
-	for _, v := range songs {
-		discs[v.DiscNumber] = true
-	}
-	album.DiscCount = len(discs)
-	showDiscNum := album.DiscCount != 1
-	for i, v := range songs {
-		a.songs[i] = newAlbumSong(v, showDiscNum, -1)
-		items[i] = a.songs[i]
-		itemTexts[i] = strings.ToLower(v.Name)
-	}
-
-	a.list.AddItems(items...)
-	a.items = items
-	a.itemsTexts = itemTexts
-	a.searchItemsSet()
 }
 
 func (a *AlbumView) SetArtist(artist *models.Artist) {
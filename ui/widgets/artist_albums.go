@@ -142,6 +142,23 @@ func NewArtistAlbumList(selectAlbum func(album *models.Album), context contextOp
 				a.showSimilar()
 			}
 		})
+		a.list.AddContextItem("Download", 0, func(index int) {
+			if index < len(a.albumCovers) && a.context != nil {
+				album := a.albumCovers[index]
+				a.context.Download(album.album)
+			}
+		})
+		a.list.AddContextItem("Play on device", 0, func(index int) {
+			if index < len(a.albumCovers) && a.context != nil {
+				album := a.albumCovers[index]
+				a.context.PlayOnDevice(album.album)
+			}
+		})
+		a.options.AddOption("Download artist", func() {
+			if a.artist != nil {
+				a.context.Download(a.artist)
+			}
+		})
 		a.options.AddOption("Show in browser", func() {
 			a.context.OpenInBrowser(a.artist)
 		})
@@ -19,6 +19,7 @@
 package widgets
 
 import (
+	"context"
 	"github.com/sirupsen/logrus"
 	"tryffel.net/go/jellycli/models"
 	"tryffel.net/go/jellycli/util"
@@ -33,6 +34,9 @@ type contextOperator interface {
 	ViewArtist(artist *models.Artist)
 	InstantMix(item models.Item)
 	OpenInBrowser(item models.Item)
+	Download(item models.Item)
+	PlayOnDevice(item models.Item)
+	SetFavorite(item models.Item, favorite bool) bool
 }
 
 func (w *Window) AddSongToPlaylist(song *models.Song) error {
@@ -48,21 +52,23 @@ func (w *Window) ViewArtist(artist *models.Artist) {
 }
 
 func (w *Window) ViewSongArtist(song *models.Song) {
-	_, artist, err := w.mediaItems.GetSongArtistAlbum(song)
-	if err != nil {
-		logrus.Errorf("View song album: %v", err)
+	var artist *models.Artist
+	w.loadView("song artist", func(ctx context.Context) (err error) {
+		_, artist, err = w.mediaItems.GetSongArtistAlbum(ctx, song)
 		return
-	}
-	w.selectArtist(artist)
+	}, func() {
+		w.selectArtist(artist)
+	})
 }
 
 func (w *Window) ViewSongAlbum(song *models.Song) {
-	album, _, err := w.mediaItems.GetSongArtistAlbum(song)
-	if err != nil {
-		logrus.Errorf("View song album: %v", err)
+	var album *models.Album
+	w.loadView("song album", func(ctx context.Context) (err error) {
+		album, _, err = w.mediaItems.GetSongArtistAlbum(ctx, song)
 		return
-	}
-	w.selectAlbum(album)
+	}, func() {
+		w.selectAlbum(album)
+	})
 }
 
 func (w *Window) InstantMix(item models.Item) {
@@ -71,7 +77,7 @@ func (w *Window) InstantMix(item models.Item) {
 		return
 	}
 
-	songs, err := w.mediaItems.GetInstantMix(item)
+	songs, err := w.mediaItems.GetInstantMix(context.Background(), item)
 	if err != nil {
 		logrus.Errorf("get instant mix: %v", err)
 		return
@@ -88,3 +94,40 @@ func (w *Window) OpenInBrowser(item models.Item) {
 		util.OpenUrlInBrowser(url)
 	}
 }
+
+func (w *Window) Download(item models.Item) {
+	if item == nil {
+		logrus.Warning("download empty item")
+		return
+	}
+
+	err := w.mediaDownloads.DownloadItem(item)
+	if err != nil {
+		logrus.Errorf("download %s: %v", item.GetType(), err)
+	}
+}
+
+// SetFavorite marks item as favorite or removes it from favorites. Returns true if successful.
+func (w *Window) SetFavorite(item models.Item, favorite bool) bool {
+	if item == nil {
+		logrus.Warning("set favorite on empty item")
+		return false
+	}
+
+	err := w.mediaOutbox.SetFavorite(item, favorite)
+	if err != nil {
+		logrus.Errorf("set %s favorite: %v", item.GetType(), err)
+		return false
+	}
+	return true
+}
+
+// PlayOnDevice opens devices view to select device where item is played.
+func (w *Window) PlayOnDevice(item models.Item) {
+	if item == nil {
+		logrus.Warning("play empty item on device")
+		return
+	}
+	w.sessions.SetPending(item)
+	w.showSessions()
+}
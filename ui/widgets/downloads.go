@@ -0,0 +1,179 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package widgets
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell"
+	"github.com/sirupsen/logrus"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/twidgets"
+)
+
+// Downloads shows songs that are downloaded for offline use as well as ongoing downloads.
+type Downloads struct {
+	*itemList
+	songs     []*albumSong
+	downloads []*models.Download
+
+	playSongsFunc func(songs []*models.Song)
+	controller    interfaces.DownloadController
+
+	playBtn *button
+}
+
+// NewDownloads initializes new downloads view
+func NewDownloads(playSongs func(songs []*models.Song), controller interfaces.DownloadController) *Downloads {
+	d := &Downloads{
+		playSongsFunc: playSongs,
+		controller:    controller,
+		playBtn:       newButton("Play all"),
+	}
+
+	d.itemList = newItemList(d.playSong)
+	d.list.ItemHeight = 2
+	d.list.Padding = 0
+	d.list.SetInputCapture(d.listHandler)
+	d.list.Grid.SetColumns(1, -1)
+
+	d.playBtn.SetSelectedFunc(d.playAll)
+	d.Banner.Grid.SetRows(1, 1, 1, 1, -1)
+	d.Banner.Grid.SetColumns(6, 2, 10, -1, 10, -1, 10, -3)
+	d.Banner.Grid.SetMinSize(1, 6)
+
+	d.Banner.Grid.AddItem(d.prevBtn, 0, 0, 1, 1, 1, 5, false)
+	d.Banner.Grid.AddItem(d.description, 0, 2, 2, 6, 1, 10, false)
+	d.Banner.Grid.AddItem(d.playBtn, 3, 2, 1, 1, 1, 10, true)
+	d.Banner.Grid.AddItem(d.list, 4, 0, 1, 8, 4, 10, false)
+
+	selectables := []twidgets.Selectable{d.prevBtn, d.playBtn, d.list}
+	d.Banner.Selectable = selectables
+
+	d.list.AddContextItem("Remove download", 0, func(index int) {
+		d.removeDownload(index)
+	})
+	d.initContextMenuList()
+	d.printDescription()
+	return d
+}
+
+// SetDownloads clears current downloads and sets new ones
+func (d *Downloads) SetDownloads(downloads []*models.Download) {
+	d.list.Clear()
+	d.downloads = downloads
+	d.songs = make([]*albumSong, len(downloads))
+	items := make([]twidgets.ListItem, len(downloads))
+	for i, v := range downloads {
+		s := newAlbumSong(v.Song, false, i+1)
+		d.songs[i] = s
+		s.updateTextFunc = d.updateSongText
+		s.setText()
+		items[i] = s
+	}
+	d.list.AddItems(items...)
+	d.printDescription()
+}
+
+func (d *Downloads) printDescription() {
+	completed := 0
+	var size int64
+	for _, v := range d.downloads {
+		if v.State == models.DownloadComplete {
+			completed += 1
+			size += v.Size
+		}
+	}
+	text := fmt.Sprintf("Downloads: %d songs, %s", completed, models.ByteSize(size))
+	if pending := len(d.downloads) - completed; pending > 0 {
+		text += fmt.Sprintf("\n%d pending", pending)
+	}
+	d.description.SetText(text)
+}
+
+func (d *Downloads) listHandler(key *tcell.EventKey) *tcell.EventKey {
+	switch key.Key() {
+	case tcell.KeyDEL, tcell.KeyDelete:
+		d.removeDownload(d.list.GetSelectedIndex())
+		return nil
+	}
+	return key
+}
+
+func (d *Downloads) updateSongText(song *albumSong) {
+	if song.index < 1 || song.index > len(d.downloads) {
+		return
+	}
+	download := d.downloads[song.index-1]
+
+	var state string
+	switch download.State {
+	case models.DownloadComplete:
+		state = download.SizeString()
+	case models.DownloadDownloading:
+		state = fmt.Sprintf("%d %%, %s", download.Progress(), download.SizeString())
+	default:
+		state = string(download.State)
+	}
+	if download.State == models.DownloadFailed {
+		song.SetTextColor(config.Color.TextDisabled)
+	}
+
+	text := fmt.Sprintf("%d. %s", song.index, song.song.Name)
+	text += fmt.Sprintf("\n     %s [%s]", download.AlbumName, state)
+	song.SetText(text)
+}
+
+func (d *Downloads) playSong(index int) {
+	if index < 0 || index >= len(d.downloads) || d.playSongsFunc == nil {
+		return
+	}
+	if d.downloads[index].State == models.DownloadComplete {
+		d.playSongsFunc([]*models.Song{d.downloads[index].Song})
+	}
+}
+
+func (d *Downloads) playAll() {
+	if d.playSongsFunc == nil {
+		return
+	}
+	songs := make([]*models.Song, 0, len(d.downloads))
+	for _, v := range d.downloads {
+		if v.State == models.DownloadComplete {
+			songs = append(songs, v.Song)
+		}
+	}
+	if len(songs) > 0 {
+		d.playSongsFunc(songs)
+	}
+}
+
+func (d *Downloads) removeDownload(index int) {
+	if index < 0 || index >= len(d.downloads) || d.controller == nil {
+		return
+	}
+	song := d.downloads[index].Song
+	go func() {
+		err := d.controller.RemoveDownload(song.Id)
+		if err != nil {
+			logrus.Errorf("remove download: %v", err)
+		}
+	}()
+}
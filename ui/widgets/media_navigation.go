@@ -38,6 +38,11 @@ const (
 	MediaFavoriteArtists
 	MediaFavoriteAlbums
 	MediaGenres
+	MediaDownloads
+	MediaOutbox
+	MediaSessions
+	MediaSyncPlay
+	MediaListeningStats
 )
 
 var mediaSelections = map[MediaSelect]string{
@@ -51,6 +56,11 @@ var mediaSelections = map[MediaSelect]string{
 	MediaFavoriteArtists: "Favorite Artists",
 	MediaFavoriteAlbums:  "Favorite Albums",
 	MediaGenres:          "Genres",
+	MediaDownloads:       "Downloads",
+	MediaOutbox:          "Outbox",
+	MediaSessions:        "Devices",
+	MediaSyncPlay:        "SyncPlay",
+	MediaListeningStats:  "Statistics",
 }
 
 //MediaNavigation provides access to artists, albums, playlists
@@ -168,6 +168,11 @@ func (h *Help) shortcutsPage() string {
 * Delete song: Del
 * Move up song: Ctrl-K
 * Move down song: Ctrl-J
+* Select multiple songs: Whitespace ' '
+* Delete selected songs: Del
+* Move selected songs to cursor position: 'm'
+* Undo latest queue edit: 'u'
+* Move selected songs, sort queue and remove duplicates from context menu
 * Clear queue with 'clear'. This does not remove current song
 
 
@@ -178,9 +183,19 @@ You can use mouse (if enabled) to navigate in application.
 
 [yellow]Audio[-]:
 * Shuffle: %s
+* Radio: %s
+* Sleep timer: %s
+* Stop after current song: %s
 * Mute: %s
+
+[yellow]Library[-]:
+* Sync local cache now: %s
 `, util.PackKeyBindingName(config.KeyBinds.Global.Shuffle, 20),
+		util.PackKeyBindingName(config.KeyBinds.Global.Radio, 20),
+		util.PackKeyBindingName(config.KeyBinds.Global.SleepTimer, 20),
+		util.PackKeyBindingName(config.KeyBinds.Global.StopAfterCurrent, 20),
 		util.PackKeyBindingName(config.KeyBinds.Global.MuteUnmute, 20),
+		util.PackKeyBindingName(config.KeyBinds.Global.SyncLibrary, 20),
 	)
 }
 
@@ -227,6 +242,29 @@ func (h *Help) statsPage() string {
 	text += fmt.Sprintf("Memory allocated: %s",
 		h.stats.HeapString())
 
+	text += "\n\n[yellow]Server requests[-]\n"
+	text += fmt.Sprintf("Completed: %d\nFailed: %d\nCoalesced: %d\nRunning: %d / %d\nWaiting: %d\nAverage time: %d ms",
+		h.stats.Requests.Requests,
+		h.stats.Requests.Failed,
+		h.stats.Requests.Coalesced,
+		h.stats.Requests.InFlight,
+		h.stats.Requests.MaxConcurrent,
+		h.stats.Requests.Waiting,
+		h.stats.Requests.AverageTime().Milliseconds())
+
+	text += "\n\n[yellow]Metadata cache[-]\n"
+	text += fmt.Sprintf("Items: %d / %d\nHits: %d (%d stale)\nMisses: %d\nHit rate: %.1f %%\n"+
+		"Evictions: %d\nRevalidated: %d\nInvalidated: %d",
+		h.stats.Cache.Items,
+		h.stats.Cache.MaxItems,
+		h.stats.Cache.Hits,
+		h.stats.Cache.StaleHits,
+		h.stats.Cache.Misses,
+		h.stats.Cache.HitRate(),
+		h.stats.Cache.Evictions,
+		h.stats.Cache.Revalidated,
+		h.stats.Cache.Invalidated)
+
 	text += "\n\n[yellow]Local storage[-]\n"
 	text += fmt.Sprintf("Database file: %s\nDatabase size: %s\nLast updated: %s",
 		h.stats.StorageInfo.DbFile,
@@ -255,15 +293,18 @@ Source code: https://github.com/tryffel/jellycli
 
 [yellow::b]Features [-:-:-]
 * View artists, songs, albums, playlists, favorite artists and albums, genres, similar albums and artists
-* Queue: add songs and albums, reorder & delete songs, clear queue
+* Queue: add songs and albums, reorder, sort & delete songs, multi-select, undo edits, clear queue
 * Control (and view) play state through Dbus integration
 * Remote control over Jellyfin server. Currently implemented:
     * [x] Play / pause / stop
-    * [x] Set volume
+    * [x] Set volume, mute
     * [x] Next/previous track
-    * [x] Control queue
+    * [x] Control queue, instant mix
 	* [x] Shuffle
-    * [ ] Seeking, see (https://github.com/tryffel/jellycli/issues/8
+    * [x] Seeking, rewind / fast forward
+    * [x] Repeat one / all
+* Control other Jellyfin devices from 'Devices' view, play queue, album or playlist on device
+* Listen together with SyncPlay: create, join and leave groups from 'SyncPlay' view
 * Supported formats (server transcodes everything else to mp3): mp3,ogg,flac,wav
 * headless mode (--no-gui)
 
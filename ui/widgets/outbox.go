@@ -0,0 +1,140 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package widgets
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/tslocum/cview"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/twidgets"
+)
+
+// Outbox shows operations that have failed and are waiting to be sent to server.
+type Outbox struct {
+	*itemList
+	operations []*models.Operation
+
+	controller interfaces.OutboxController
+}
+
+// NewOutbox initializes new outbox view
+func NewOutbox(controller interfaces.OutboxController) *Outbox {
+	o := &Outbox{
+		controller: controller,
+	}
+
+	o.itemList = newItemList(nil)
+	o.list.ItemHeight = 2
+	o.list.Padding = 0
+	o.list.SetInputCapture(o.listHandler)
+	o.list.Grid.SetColumns(1, -1)
+
+	o.Banner.Grid.SetRows(1, 1, 1, 1, -1)
+	o.Banner.Grid.SetColumns(6, 2, 10, -1, 10, -1, 10, -3)
+	o.Banner.Grid.SetMinSize(1, 6)
+
+	o.Banner.Grid.AddItem(o.prevBtn, 0, 0, 1, 1, 1, 5, false)
+	o.Banner.Grid.AddItem(o.description, 0, 2, 2, 6, 1, 10, false)
+	o.Banner.Grid.AddItem(o.list, 4, 0, 1, 8, 4, 10, false)
+
+	selectables := []twidgets.Selectable{o.prevBtn, o.list}
+	o.Banner.Selectable = selectables
+
+	o.list.AddContextItem("Discard", 0, func(index int) {
+		o.discard(index)
+	})
+	o.initContextMenuList()
+	o.printDescription()
+	return o
+}
+
+// SetOperations clears current operations and sets new ones
+func (o *Outbox) SetOperations(operations []*models.Operation) {
+	o.list.Clear()
+	o.operations = operations
+	items := make([]twidgets.ListItem, len(operations))
+	for i, v := range operations {
+		items[i] = newOperation(v, i+1)
+	}
+	o.list.AddItems(items...)
+	o.printDescription()
+}
+
+func (o *Outbox) printDescription() {
+	text := fmt.Sprintf("Outbox: %d pending operations", len(o.operations))
+	text += "\nOperations are sent to server once it is reachable"
+	o.description.SetText(text)
+}
+
+func (o *Outbox) listHandler(key *tcell.EventKey) *tcell.EventKey {
+	switch key.Key() {
+	case tcell.KeyDEL, tcell.KeyDelete:
+		o.discard(o.list.GetSelectedIndex())
+		return nil
+	}
+	return key
+}
+
+func (o *Outbox) discard(index int) {
+	if index < 0 || index >= len(o.operations) || o.controller == nil {
+		return
+	}
+	id := o.operations[index].Id
+	go func() {
+		err := o.controller.DiscardOperation(id)
+		if err != nil {
+			logrus.Errorf("discard operation: %v", err)
+		}
+	}()
+}
+
+type operation struct {
+	*cview.TextView
+	operation *models.Operation
+}
+
+func newOperation(op *models.Operation, index int) *operation {
+	o := &operation{
+		TextView:  cview.NewTextView(),
+		operation: op,
+	}
+	o.SetBackgroundColor(config.Color.Background)
+	o.SetTextColor(config.Color.Text)
+
+	text := fmt.Sprintf("%d. %s: %s, %s", index, op.Type.Label(), op.Item, op.PlayedAt.Format("2006-01-02 15:04"))
+	text += fmt.Sprintf("\n     %d attempts: %s", op.Attempts, op.Error)
+	o.SetText(text)
+	return o
+}
+
+func (o *operation) SetSelected(s twidgets.Selection) {
+	if s == twidgets.Selected {
+		o.SetTextColor(config.Color.TextSelected)
+		o.SetBackgroundColor(config.Color.BackgroundSelected)
+	} else if s == twidgets.Deselected {
+		o.SetTextColor(config.Color.Text)
+		o.SetBackgroundColor(config.Color.Background)
+	} else if s == twidgets.Blurred {
+		o.SetBackgroundColor(config.Color.TextDisabled)
+	}
+}
@@ -107,6 +107,14 @@ func NewPlaylistView(playSong func(song *models.Song), playSongs func(songs []*m
 			p.context.InstantMix(p.playlist)
 		})
 
+		p.options.AddOption("Download", func() {
+			p.context.Download(p.playlist)
+		})
+
+		p.options.AddOption("Play on device", func() {
+			p.context.PlayOnDevice(p.playlist)
+		})
+
 		p.options.AddOption("Open in browser", func() {
 			p.context.OpenInBrowser(p.playlist)
 		})
@@ -38,6 +38,10 @@ type Queue struct {
 	playSongsFunc func(songs []*models.Song)
 
 	controller interfaces.QueueController
+	// buffers contains prefetching status for upcoming songs
+	buffers map[models.Id]interfaces.SongBuffer
+	// selected contains indices of selected songs
+	selected map[int]bool
 
 	clearBtn  *button
 	clearFunc func()
@@ -48,6 +52,7 @@ func NewQueue() *Queue {
 	q := &Queue{
 		itemList: newItemList(nil),
 		clearBtn: newButton("Clear"),
+		selected: map[int]bool{},
 	}
 
 	q.list.ItemHeight = 2
@@ -67,6 +72,40 @@ func NewQueue() *Queue {
 
 	selectables := []twidgets.Selectable{q.prevBtn, q.clearBtn, q.list}
 	q.Banner.Selectable = selectables
+
+	q.list.AddContextItem("Play next", 0, func(index int) {
+		q.moveSongs(index, 1)
+	})
+	q.list.AddContextItem("Move to end", 0, func(index int) {
+		q.moveSongs(index, len(q.songs))
+	})
+	q.list.AddContextItem("Move selected here", 0, func(index int) {
+		q.moveSelected(index)
+	})
+	q.list.AddContextItem("Remove", 0, func(index int) {
+		q.removeSongs(index)
+	})
+	q.list.AddContextItem("Clear selection", 0, func(index int) {
+		q.clearSelection()
+	})
+	q.list.AddContextItem("Sort by artist", 0, func(index int) {
+		q.sortQueue(interfaces.SortByArtist)
+	})
+	q.list.AddContextItem("Sort by album", 0, func(index int) {
+		q.sortQueue(interfaces.SortByAlbum)
+	})
+	q.list.AddContextItem("Sort by name", 0, func(index int) {
+		q.sortQueue(interfaces.SortByName)
+	})
+	q.list.AddContextItem("Remove duplicates", 0, func(index int) {
+		if q.controller != nil {
+			q.controller.RemoveDuplicates()
+		}
+	})
+	q.list.AddContextItem("Undo", 0, func(index int) {
+		q.undo()
+	})
+	q.initContextMenuList()
 	q.printDescription()
 	return q
 }
@@ -83,8 +122,10 @@ func (q *Queue) AddSong(song *models.Song, index int) {
 }
 
 // SetSongs clears current songs and sets new ones
+// Selected songs are cleared, since indices are not valid anymore.
 func (q *Queue) SetSongs(songs []*models.Song) {
 	q.Clear()
+	q.selected = map[int]bool{}
 	q.songs = make([]*albumSong, len(songs))
 	items := make([]twidgets.ListItem, len(songs))
 	for i, v := range songs {
@@ -101,6 +142,14 @@ func (q *Queue) SetSongs(songs []*models.Song) {
 	q.printDescription()
 }
 
+// SetBuffers updates prefetching status for songs.
+func (q *Queue) SetBuffers(buffers map[models.Id]interfaces.SongBuffer) {
+	q.buffers = buffers
+	for _, v := range q.songs {
+		q.updateSongText(v)
+	}
+}
+
 // Clear removes all songs
 func (q *Queue) Clear() {
 	q.list.Clear()
@@ -135,14 +184,97 @@ func (q *Queue) listHandler(key *tcell.EventKey) *tcell.EventKey {
 			_ = q.controller.Reorder(index, true)
 		}
 	case tcell.KeyDEL, tcell.KeyDelete:
-		if q.controller != nil {
-			index := q.list.GetSelectedIndex()
-			q.controller.RemoveSong(index)
+		q.removeSongs(q.list.GetSelectedIndex())
+	case tcell.KeyRune:
+		switch key.Rune() {
+		case ' ':
+			q.toggleSelected(q.list.GetSelectedIndex())
+			return nil
+		case 'u':
+			q.undo()
+			return nil
+		case 'm':
+			q.moveSelected(q.list.GetSelectedIndex())
+			return nil
 		}
 	}
 	return key
 }
 
+// toggleSelected selects or unselects song. Current song cannot be selected.
+func (q *Queue) toggleSelected(index int) {
+	if index < 1 || index >= len(q.songs) {
+		return
+	}
+	if q.selected[index] {
+		delete(q.selected, index)
+	} else {
+		q.selected[index] = true
+	}
+	q.updateSongText(q.songs[index])
+}
+
+func (q *Queue) clearSelection() {
+	selected := q.selected
+	q.selected = map[int]bool{}
+	for i := range selected {
+		if i < len(q.songs) {
+			q.updateSongText(q.songs[i])
+		}
+	}
+}
+
+// selectedIndices returns selected songs, or song in index if there's no selection.
+func (q *Queue) selectedIndices(index int) []int {
+	if len(q.selected) == 0 {
+		return []int{index}
+	}
+	indices := make([]int, 0, len(q.selected))
+	for i := range q.songs {
+		if q.selected[i] {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+func (q *Queue) moveSongs(index int, to int) {
+	if q.controller != nil {
+		q.controller.MoveSongs(q.selectedIndices(index), to)
+	}
+}
+
+// moveSelected moves selected songs to index, so that first selected song will be at index.
+func (q *Queue) moveSelected(index int) {
+	if q.controller == nil || len(q.selected) == 0 || index < 1 {
+		return
+	}
+	q.controller.MoveSongs(q.selectedIndices(index), index)
+}
+
+func (q *Queue) removeSongs(index int) {
+	if q.controller == nil {
+		return
+	}
+	if len(q.selected) == 0 {
+		q.controller.RemoveSong(index)
+	} else {
+		q.controller.RemoveSongs(q.selectedIndices(index))
+	}
+}
+
+func (q *Queue) sortQueue(field interfaces.SortField) {
+	if q.controller != nil {
+		q.controller.SortQueue(field)
+	}
+}
+
+func (q *Queue) undo() {
+	if q.controller != nil {
+		q.controller.UndoQueueEdit()
+	}
+}
+
 func (q *Queue) updateSongText(song *albumSong) {
 	var name string
 	if song.playing {
@@ -155,10 +287,17 @@ func (q *Queue) updateSongText(song *albumSong) {
 		name = fmt.Sprintf("%d. %s", song.index, song.song.Name)
 	}
 
+	if q.selected[song.index-1] {
+		name = "+ " + name
+	}
+
 	text := song.getAlignedDuration(name)
+	text += "\n     "
 	if len(song.song.Artists) > 0 {
-		text += "\n     " + song.song.Artists[0].Name
-
+		text += song.song.Artists[0].Name
+	}
+	if buffer := q.buffers[song.song.Id]; buffer.State != interfaces.BufferNone {
+		text += " [" + buffer.String() + "]"
 	}
 	song.SetText(text)
 }
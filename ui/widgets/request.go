@@ -0,0 +1,57 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package widgets
+
+import (
+	"context"
+	"sync"
+)
+
+// request tracks latest background request of one kind, e.g. loading view or searching.
+// Starting new request cancels previous one, so that superseded results are never shown.
+type request struct {
+	lock   sync.Mutex
+	cancel context.CancelFunc
+}
+
+func newRequest() *request {
+	return &request{}
+}
+
+// next cancels previous request and returns context for a new one.
+func (r *request) next() context.Context {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	return ctx
+}
+
+// stop cancels current request, if there's any.
+func (r *request) stop() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+}
@@ -0,0 +1,288 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package widgets
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/tslocum/cview"
+	"strings"
+	"time"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/util"
+	"tryffel.net/go/twidgets"
+)
+
+const (
+	sessionSeekBack    = 10
+	sessionSeekForward = 30
+)
+
+// Sessions shows other devices that can be controlled remotely, and allows sending items to them.
+type Sessions struct {
+	*itemList
+	sessions []*models.Session
+
+	// pending is item that is played on selected session, if set
+	pending models.Item
+
+	controller interfaces.SessionController
+	queue      interfaces.QueueController
+	// refreshFunc reloads sessions
+	refreshFunc func()
+
+	refreshBtn *button
+}
+
+// NewSessions initializes new sessions view
+func NewSessions(controller interfaces.SessionController, queue interfaces.QueueController) *Sessions {
+	s := &Sessions{
+		controller: controller,
+		queue:      queue,
+		refreshBtn: newButton("Refresh"),
+	}
+
+	s.itemList = newItemList(s.selectSession)
+	s.list.ItemHeight = 3
+	s.list.Padding = 0
+	s.list.Grid.SetColumns(1, -1)
+
+	s.refreshBtn.SetSelectedFunc(s.refresh)
+	s.Banner.Grid.SetRows(1, 1, 1, 1, -1)
+	s.Banner.Grid.SetColumns(6, 2, 10, -1, 10, -1, 10, -3)
+	s.Banner.Grid.SetMinSize(1, 6)
+
+	s.Banner.Grid.AddItem(s.prevBtn, 0, 0, 1, 1, 1, 5, false)
+	s.Banner.Grid.AddItem(s.description, 0, 2, 2, 6, 1, 10, false)
+	s.Banner.Grid.AddItem(s.refreshBtn, 3, 2, 1, 1, 1, 10, true)
+	s.Banner.Grid.AddItem(s.list, 4, 0, 1, 8, 4, 10, false)
+
+	selectables := []twidgets.Selectable{s.prevBtn, s.refreshBtn, s.list}
+	s.Banner.Selectable = selectables
+
+	s.list.AddContextItem("Play / pause", 0, func(index int) {
+		s.command(index, models.SessionPlayPause)
+	})
+	s.list.AddContextItem("Next track", 0, func(index int) {
+		s.command(index, models.SessionNext)
+	})
+	s.list.AddContextItem("Previous track", 0, func(index int) {
+		s.command(index, models.SessionPrevious)
+	})
+	s.list.AddContextItem("Stop", 0, func(index int) {
+		s.command(index, models.SessionStop)
+	})
+	s.list.AddContextItem(fmt.Sprintf("Seek back %d s", sessionSeekBack), 0, func(index int) {
+		s.seek(index, -sessionSeekBack)
+	})
+	s.list.AddContextItem(fmt.Sprintf("Seek forward %d s", sessionSeekForward), 0, func(index int) {
+		s.seek(index, sessionSeekForward)
+	})
+	s.list.AddContextItem("Volume up", 0, func(index int) {
+		s.volume(index, config.VolumeStepSize)
+	})
+	s.list.AddContextItem("Volume down", 0, func(index int) {
+		s.volume(index, -config.VolumeStepSize)
+	})
+	s.list.AddContextItem("Play queue on device", 0, func(index int) {
+		s.playQueue(index)
+	})
+	s.initContextMenuList()
+	s.printDescription()
+	return s
+}
+
+// SetSessions clears current sessions and sets new ones
+func (s *Sessions) SetSessions(sessions []*models.Session) {
+	s.list.Clear()
+	s.sessions = sessions
+	items := make([]twidgets.ListItem, len(sessions))
+	for i, v := range sessions {
+		items[i] = newSession(v, i+1)
+	}
+	s.list.AddItems(items...)
+	s.printDescription()
+}
+
+// SetPending sets item to play on session that is selected next. Nil clears item.
+func (s *Sessions) SetPending(item models.Item) {
+	s.pending = item
+	s.printDescription()
+}
+
+func (s *Sessions) printDescription() {
+	text := fmt.Sprintf("Devices: %d", len(s.sessions))
+	if s.pending != nil {
+		text += fmt.Sprintf("\nSelect device to play %s %s", strings.ToLower(string(s.pending.GetType())), s.pending.GetName())
+	} else {
+		text += "\nEnter toggles play / pause, see context menu for more"
+	}
+	s.description.SetText(text)
+}
+
+func (s *Sessions) session(index int) *models.Session {
+	if index < 0 || index >= len(s.sessions) || s.controller == nil {
+		return nil
+	}
+	return s.sessions[index]
+}
+
+func (s *Sessions) selectSession(index int) {
+	if s.pending == nil {
+		s.command(index, models.SessionPlayPause)
+		return
+	}
+	session := s.session(index)
+	if session == nil {
+		return
+	}
+	item := s.pending
+	s.SetPending(nil)
+	s.run("play item on device", func() error {
+		return s.controller.PlayOnSession(session.Id, []models.Id{item.GetId()})
+	})
+}
+
+func (s *Sessions) command(index int, command models.SessionCommand) {
+	session := s.session(index)
+	if session == nil {
+		return
+	}
+	s.run("control device", func() error {
+		return s.controller.ControlSession(session.Id, command)
+	})
+}
+
+func (s *Sessions) seek(index int, seconds int) {
+	session := s.session(index)
+	if session == nil || session.NowPlaying == nil {
+		return
+	}
+	position := session.Position + seconds
+	if position < 0 {
+		position = 0
+	}
+	s.run("seek device", func() error {
+		return s.controller.SeekSession(session.Id, interfaces.AudioTick(position*1000))
+	})
+}
+
+func (s *Sessions) volume(index int, step int) {
+	session := s.session(index)
+	if session == nil {
+		return
+	}
+	volume := interfaces.AudioVolume(session.Volume).Add(step)
+	s.run("set device volume", func() error {
+		return s.controller.SetSessionVolume(session.Id, volume)
+	})
+}
+
+func (s *Sessions) playQueue(index int) {
+	session := s.session(index)
+	if session == nil || s.queue == nil {
+		return
+	}
+	songs := s.queue.GetQueue()
+	if len(songs) == 0 {
+		return
+	}
+	ids := make([]models.Id, len(songs))
+	for i, v := range songs {
+		ids[i] = v.Id
+	}
+	s.run("play queue on device", func() error {
+		return s.controller.PlayOnSession(session.Id, ids)
+	})
+}
+
+// run command in background and refresh sessions once device has had time to update its state.
+func (s *Sessions) run(name string, command func() error) {
+	go func() {
+		err := command()
+		if err != nil {
+			logrus.Errorf("%s: %v", name, err)
+			return
+		}
+		time.Sleep(time.Second)
+		s.refresh()
+	}()
+}
+
+func (s *Sessions) refresh() {
+	if s.refreshFunc != nil {
+		s.refreshFunc()
+	}
+}
+
+type session struct {
+	*cview.TextView
+	session *models.Session
+}
+
+func newSession(s *models.Session, index int) *session {
+	item := &session{
+		TextView: cview.NewTextView(),
+		session:  s,
+	}
+	item.SetBackgroundColor(config.Color.Background)
+	item.SetTextColor(config.Color.Text)
+
+	text := fmt.Sprintf("%d. %s, %s", index, s.Name(), s.UserName)
+	if s.NowPlaying == nil {
+		text += "\n     Not playing"
+	} else {
+		state := "Playing"
+		if s.Paused {
+			state = "Paused"
+		}
+		artists := make([]string, len(s.NowPlaying.Artists))
+		for i, v := range s.NowPlaying.Artists {
+			artists[i] = v.Name
+		}
+		text += fmt.Sprintf("\n     %s: %s - %s (%s)", state, s.NowPlaying.Name, strings.Join(artists, ", "), s.Album)
+		text += fmt.Sprintf("\n     %s / %s",
+			util.SecToString(s.Position), util.SecToString(s.NowPlaying.Duration))
+	}
+	volume := fmt.Sprintf("volume %d %%", s.Volume)
+	if s.Muted {
+		volume = "muted"
+	}
+	if s.NowPlaying == nil {
+		text += "\n     " + volume
+	} else {
+		text += ", " + volume
+	}
+	item.SetText(text)
+	return item
+}
+
+func (s *session) SetSelected(selection twidgets.Selection) {
+	if selection == twidgets.Selected {
+		s.SetTextColor(config.Color.TextSelected)
+		s.SetBackgroundColor(config.Color.BackgroundSelected)
+	} else if selection == twidgets.Deselected {
+		s.SetTextColor(config.Color.Text)
+		s.SetBackgroundColor(config.Color.Background)
+	} else if selection == twidgets.Blurred {
+		s.SetBackgroundColor(config.Color.TextDisabled)
+	}
+}
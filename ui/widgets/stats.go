@@ -0,0 +1,151 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+package widgets
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/tslocum/cview"
+	"time"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/jellycli/util"
+	"tryffel.net/go/twidgets"
+)
+
+// ListeningStats shows statistics from local listening history.
+type ListeningStats struct {
+	*itemList
+	periodBtn *button
+	period    models.ListeningPeriod
+	stats     *models.ListeningStats
+
+	controller interfaces.ListeningHistory
+}
+
+// NewListeningStats initializes new listening statistics view.
+func NewListeningStats(controller interfaces.ListeningHistory) *ListeningStats {
+	s := &ListeningStats{
+		periodBtn:  newButton(""),
+		period:     models.ListeningPeriodMonth,
+		controller: controller,
+	}
+
+	s.itemList = newItemList(nil)
+	s.list.ItemHeight = 1
+	s.list.Padding = 0
+	s.list.Grid.SetColumns(1, -1)
+
+	s.periodBtn.SetSelectedFunc(s.nextPeriod)
+	s.Banner.Grid.SetRows(1, 1, 1, 1, -1)
+	s.Banner.Grid.SetColumns(6, 2, 14, -1, 10, -1, 10, -3)
+	s.Banner.Grid.SetMinSize(1, 6)
+
+	s.Banner.Grid.AddItem(s.prevBtn, 0, 0, 1, 1, 1, 5, false)
+	s.Banner.Grid.AddItem(s.description, 0, 2, 2, 6, 1, 10, false)
+	s.Banner.Grid.AddItem(s.periodBtn, 3, 2, 1, 1, 1, 14, true)
+	s.Banner.Grid.AddItem(s.list, 4, 0, 1, 8, 4, 10, false)
+
+	selectables := []twidgets.Selectable{s.prevBtn, s.periodBtn, s.list}
+	s.Banner.Selectable = selectables
+	s.printDescription()
+	return s
+}
+
+// Refresh loads statistics for current period.
+func (s *ListeningStats) Refresh() {
+	s.periodBtn.SetLabel(s.period.Label())
+	s.list.Clear()
+	s.stats = nil
+
+	stats, err := s.controller.GetListeningStats(s.period.Since(time.Now()))
+	if err != nil {
+		logrus.Errorf("get listening stats: %v", err)
+		s.printDescription()
+		return
+	}
+	s.stats = stats
+
+	items := make([]twidgets.ListItem, 0)
+	items = append(items, playCountItems("Top artists", stats.TopArtists)...)
+	items = append(items, playCountItems("Top albums", stats.TopAlbums)...)
+	items = append(items, playCountItems("Top songs", stats.TopSongs)...)
+	s.list.AddItems(items...)
+	s.printDescription()
+}
+
+func (s *ListeningStats) nextPeriod() {
+	for i, v := range models.ListeningPeriods {
+		if v == s.period {
+			s.period = models.ListeningPeriods[(i+1)%len(models.ListeningPeriods)]
+			break
+		}
+	}
+	s.Refresh()
+}
+
+func (s *ListeningStats) printDescription() {
+	text := "Listening statistics: " + s.period.Label()
+	if s.stats == nil {
+		text += "\nLocal listening history is not available"
+	} else {
+		text += fmt.Sprintf("\n%d plays, %s listened, %.0f%% skipped", s.stats.Plays,
+			util.SecToStringApproximate(int(s.stats.ListeningTime.Seconds())), s.stats.SkipRate()*100)
+	}
+	s.description.SetText(text)
+}
+
+func playCountItems(title string, counts []models.PlayCount) []twidgets.ListItem {
+	if len(counts) == 0 {
+		return nil
+	}
+	items := make([]twidgets.ListItem, 0, len(counts)+1)
+	items = append(items, newStatsRow(fmt.Sprintf("[yellow::b]%s[-::-]", title)))
+	for i, v := range counts {
+		items = append(items, newStatsRow(fmt.Sprintf("%2d. %s, %d plays (%s)", i+1, cview.Escape(v.Name),
+			v.Plays, util.SecToStringApproximate(int(v.Played.Seconds())))))
+	}
+	return items
+}
+
+// single line in listening statistics
+type statsRow struct {
+	*cview.TextView
+}
+
+func newStatsRow(text string) *statsRow {
+	r := &statsRow{TextView: cview.NewTextView()}
+	r.SetDynamicColors(true)
+	r.SetBackgroundColor(config.Color.Background)
+	r.SetTextColor(config.Color.Text)
+	r.SetText(text)
+	return r
+}
+
+func (r *statsRow) SetSelected(s twidgets.Selection) {
+	if s == twidgets.Selected {
+		r.SetTextColor(config.Color.TextSelected)
+		r.SetBackgroundColor(config.Color.BackgroundSelected)
+	} else if s == twidgets.Deselected {
+		r.SetTextColor(config.Color.Text)
+		r.SetBackgroundColor(config.Color.Background)
+	} else if s == twidgets.Blurred {
+		r.SetBackgroundColor(config.Color.TextDisabled)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/gdamore/tcell"
 	"gitlab.com/tslocum/cview"
 	"sync"
+	"time"
 	"tryffel.net/go/jellycli/config"
 	"tryffel.net/go/jellycli/interfaces"
 	"tryffel.net/go/jellycli/models"
@@ -83,6 +84,13 @@ type Status struct {
 
 	song *models.SongInfo
 
+	online bool
+	// remote is state of remote control connection
+	remote models.RemoteControlStatus
+	sync   models.SyncStatus
+	// syncPlay is current SyncPlay group
+	syncPlay models.SyncPlayStatus
+
 	actionCb func(state interfaces.AudioStatus)
 
 	player interfaces.Player
@@ -95,6 +103,7 @@ func (s *Status) MouseHandler() func(action cview.MouseAction, event *tcell.Even
 func newStatus(ctrl interfaces.Player) *Status {
 	s := &Status{frame: cview.NewBox()}
 	s.player = ctrl
+	s.online = true
 
 	colors := config.Color.Status
 	s.detailsMainColor = colors.Text
@@ -246,6 +255,37 @@ func (s *Status) Draw(screen tcell.Screen) {
 		s.btnShuffle.Draw(screen)
 	}
 	s.WriteStatus(screen, x+30, y)
+
+	right := w - 15
+	if sleep := sleepTimerText(s.state); sleep != "" {
+		cview.Print(screen, sleep+"  ", x, y+1, right, cview.AlignRight, colors.ProgressBar)
+		right -= len(sleep) + 2
+	}
+	if text := remoteControlText(s.remote, time.Now()); s.online && text != "" {
+		cview.Print(screen, text+"  ", x, y+1, right, cview.AlignRight, colors.VolumeMuted)
+		right -= len(text) + 2
+	}
+	if text := syncStatusText(s.sync); text != "" {
+		color := colors.ProgressBar
+		if s.sync.Error != "" {
+			color = colors.VolumeMuted
+		}
+		cview.Print(screen, text+"  ", x, y+1, right, cview.AlignRight, color)
+		right -= len(text) + 2
+	}
+	if s.syncPlay.Group != nil {
+		cview.Print(screen, "Group "+s.syncPlay.Group.Name+"  ", x, y+1, right, cview.AlignRight, colors.Shortcuts)
+	}
+	if s.state.Radio {
+		cview.Print(screen, effect("Radio", "b")+"  ", x, y+1, w-8, cview.AlignRight, colors.ProgressBar)
+	} else {
+		cview.Print(screen, "Radio  ", x, y+1, w-8, cview.AlignRight, colors.VolumeMuted)
+	}
+	if s.online {
+		cview.Print(screen, "Online ", x, y+1, w, cview.AlignRight, colors.Shortcuts)
+	} else {
+		cview.Print(screen, "Offline ", x, y+1, w, cview.AlignRight, colors.VolumeMuted)
+	}
 }
 
 func (s *Status) GetRect() (int, int, int, int) {
@@ -308,6 +348,34 @@ func (s *Status) UpdateState(state interfaces.AudioStatus, song *models.SongInfo
 	s.DrawButtons()
 }
 
+// SetOnline sets remote server connection state.
+func (s *Status) SetOnline(online bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.online = online
+}
+
+// SetRemoteControlStatus sets remote control connection state.
+func (s *Status) SetRemoteControlStatus(status models.RemoteControlStatus) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.remote = status
+}
+
+// SetSyncPlayStatus sets SyncPlay group.
+func (s *Status) SetSyncPlayStatus(status models.SyncPlayStatus) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.syncPlay = status
+}
+
+// SetSyncStatus sets library sync state.
+func (s *Status) SetSyncStatus(status models.SyncStatus) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sync = status
+}
+
 func (s *Status) DrawButtons() {
 	if s.state.Paused || s.state.State == interfaces.AudioStateStopped {
 		s.btnPlay.SetLabel(btnPlay)
@@ -324,3 +392,67 @@ func (s *Status) DrawButtons() {
 		s.btnShuffle.SetLabelColor(config.Color.Status.VolumeMuted)
 	}
 }
+
+// sleep timer presets to cycle with keybinding
+var sleepTimerPresets = []interfaces.SleepTimer{
+	{Mode: interfaces.SleepAfterDuration, Duration: time.Minute * 15},
+	{Mode: interfaces.SleepAfterDuration, Duration: time.Minute * 30},
+	{Mode: interfaces.SleepAfterDuration, Duration: time.Minute * 60},
+	{Mode: interfaces.SleepAfterDuration, Duration: time.Minute * 90},
+	{Mode: interfaces.SleepEndOfAlbum},
+	{Mode: interfaces.SleepOff},
+}
+
+// nextSleepTimer returns next sleep timer preset after current timer.
+func nextSleepTimer(current interfaces.SleepTimer) interfaces.SleepTimer {
+	next := sleepTimerPresets[0]
+	for i, v := range sleepTimerPresets[:len(sleepTimerPresets)-1] {
+		if v.Mode == current.Mode && v.Duration == current.Duration {
+			next = sleepTimerPresets[i+1]
+			break
+		}
+	}
+	next.FadeOut = config.AppConfig.Player.SleepFadeOut
+	return next
+}
+
+// sleepTimerText returns sleep timer status, or empty string if there is no sleep timer.
+func sleepTimerText(state interfaces.AudioStatus) string {
+	remaining := util.SecToString(int(state.SleepRemaining.Seconds()))
+	switch state.SleepTimer.Mode {
+	case interfaces.SleepAfterDuration:
+		return "Sleep " + remaining
+	case interfaces.SleepEndOfSong:
+		return "Stop after song"
+	case interfaces.SleepEndOfAlbum:
+		return "Sleep after album " + remaining
+	default:
+		return ""
+	}
+}
+
+// remoteControlText returns remote control connection status, or empty string if it's not reconnecting.
+func remoteControlText(status models.RemoteControlStatus, now time.Time) string {
+	if status.State != models.RemoteControlReconnecting {
+		return ""
+	}
+	retry := status.RetryAt.Sub(now).Round(time.Second)
+	if retry <= 0 {
+		return "Reconnecting"
+	}
+	return fmt.Sprintf("Reconnecting in %s", retry)
+}
+
+// syncStatusText returns library sync status, or empty string if sync is not running and did not fail.
+func syncStatusText(status models.SyncStatus) string {
+	if status.Running {
+		if status.Stage == "" {
+			return "Syncing library"
+		}
+		return "Syncing " + status.Stage
+	}
+	if status.Error != "" {
+		return "Sync failed"
+	}
+	return ""
+}
@@ -0,0 +1,185 @@
+/*
+ * Jellycli is a terminal music player for Jellyfin.
+ * Copyright (C) 2020 Tero Vierimaa
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package widgets
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/tslocum/cview"
+	"strings"
+	"tryffel.net/go/jellycli/config"
+	"tryffel.net/go/jellycli/interfaces"
+	"tryffel.net/go/jellycli/models"
+	"tryffel.net/go/twidgets"
+)
+
+// SyncPlayView lists SyncPlay groups and allows creating, joining and leaving groups.
+type SyncPlayView struct {
+	*itemList
+	groups []*models.SyncPlayGroup
+	status models.SyncPlayStatus
+
+	controller interfaces.SyncPlay
+	// refreshFunc reloads groups
+	refreshFunc func()
+
+	newBtn   *button
+	leaveBtn *button
+}
+
+// NewSyncPlayView initializes new SyncPlay view
+func NewSyncPlayView(controller interfaces.SyncPlay) *SyncPlayView {
+	s := &SyncPlayView{
+		controller: controller,
+		newBtn:     newButton("New group"),
+		leaveBtn:   newButton("Leave group"),
+	}
+
+	s.itemList = newItemList(s.joinGroup)
+	s.list.ItemHeight = 2
+	s.list.Padding = 0
+	s.list.Grid.SetColumns(1, -1)
+
+	s.newBtn.SetSelectedFunc(s.createGroup)
+	s.leaveBtn.SetSelectedFunc(s.leaveGroup)
+	s.Banner.Grid.SetRows(1, 1, 1, 1, -1)
+	s.Banner.Grid.SetColumns(6, 2, 10, -1, 10, -1, 10, -3)
+	s.Banner.Grid.SetMinSize(1, 6)
+
+	s.Banner.Grid.AddItem(s.prevBtn, 0, 0, 1, 1, 1, 5, false)
+	s.Banner.Grid.AddItem(s.description, 0, 2, 2, 6, 1, 10, false)
+	s.Banner.Grid.AddItem(s.newBtn, 3, 2, 1, 1, 1, 10, true)
+	s.Banner.Grid.AddItem(s.leaveBtn, 3, 4, 1, 1, 1, 10, false)
+	s.Banner.Grid.AddItem(s.list, 4, 0, 1, 8, 4, 10, false)
+
+	selectables := []twidgets.Selectable{s.prevBtn, s.newBtn, s.leaveBtn, s.list}
+	s.Banner.Selectable = selectables
+
+	s.list.AddContextItem("Join group", 0, func(index int) {
+		s.joinGroup(index)
+	})
+	s.initContextMenuList()
+	s.printDescription()
+	return s
+}
+
+// SetGroups clears current groups and sets new ones
+func (s *SyncPlayView) SetGroups(groups []*models.SyncPlayGroup) {
+	s.list.Clear()
+	s.groups = groups
+	items := make([]twidgets.ListItem, len(groups))
+	for i, v := range groups {
+		current := s.status.Group != nil && s.status.Group.Id == v.Id
+		items[i] = newSyncPlayGroup(v, i+1, current)
+	}
+	s.list.AddItems(items...)
+	s.printDescription()
+}
+
+// SetStatus sets current group
+func (s *SyncPlayView) SetStatus(status models.SyncPlayStatus) {
+	s.status = status
+	s.printDescription()
+}
+
+func (s *SyncPlayView) printDescription() {
+	var text string
+	if group := s.status.Group; group != nil {
+		text = fmt.Sprintf("SyncPlay group: %s (%s)", group.Name, strings.ToLower(group.State))
+		text += fmt.Sprintf("\nListening with: %s", strings.Join(group.Participants, ", "))
+	} else {
+		text = fmt.Sprintf("SyncPlay: %d groups, select group to join", len(s.groups))
+	}
+	if s.status.Error != "" {
+		text += "\nError: " + s.status.Error
+	}
+	s.description.SetText(text)
+}
+
+func (s *SyncPlayView) joinGroup(index int) {
+	if index < 0 || index >= len(s.groups) || s.controller == nil {
+		return
+	}
+	group := s.groups[index]
+	s.run("join SyncPlay group", func() error {
+		return s.controller.JoinSyncPlayGroup(group.Id)
+	})
+}
+
+func (s *SyncPlayView) createGroup() {
+	s.run("create SyncPlay group", func() error {
+		return s.controller.CreateSyncPlayGroup("")
+	})
+}
+
+func (s *SyncPlayView) leaveGroup() {
+	s.run("leave SyncPlay group", s.controller.LeaveSyncPlayGroup)
+}
+
+// run request in background and reload groups.
+func (s *SyncPlayView) run(name string, request func() error) {
+	if s.controller == nil {
+		return
+	}
+	go func() {
+		err := request()
+		if err != nil {
+			logrus.Errorf("%s: %v", name, err)
+			return
+		}
+		if s.refreshFunc != nil {
+			s.refreshFunc()
+		}
+	}()
+}
+
+type syncPlayGroup struct {
+	*cview.TextView
+	group *models.SyncPlayGroup
+}
+
+func newSyncPlayGroup(group *models.SyncPlayGroup, index int, current bool) *syncPlayGroup {
+	g := &syncPlayGroup{
+		TextView: cview.NewTextView(),
+		group:    group,
+	}
+	g.SetBackgroundColor(config.Color.Background)
+	g.SetTextColor(config.Color.Text)
+
+	text := fmt.Sprintf("%d. %s", index, group.Name)
+	if current {
+		text += " (joined)"
+	}
+	text += fmt.Sprintf("\n     %s, %d listeners: %s", strings.ToLower(group.State), len(group.Participants),
+		strings.Join(group.Participants, ", "))
+	g.SetText(text)
+	return g
+}
+
+func (g *syncPlayGroup) SetSelected(s twidgets.Selection) {
+	if s == twidgets.Selected {
+		g.SetTextColor(config.Color.TextSelected)
+		g.SetBackgroundColor(config.Color.BackgroundSelected)
+	} else if s == twidgets.Deselected {
+		g.SetTextColor(config.Color.Text)
+		g.SetBackgroundColor(config.Color.Background)
+	} else if s == twidgets.Blurred {
+		g.SetBackgroundColor(config.Color.TextDisabled)
+	}
+}
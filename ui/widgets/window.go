@@ -21,6 +21,7 @@
 package widgets
 
 import (
+	"context"
 	"fmt"
 	"github.com/gdamore/tcell"
 	"github.com/sirupsen/logrus"
@@ -39,13 +40,18 @@ type Window struct {
 	layout *twidgets.ModalLayout
 
 	// Widgets
-	navBar   *twidgets.NavBar
-	status   *Status
-	mediaNav *MediaNavigation
-	help     *modal.Help
-	message  *modal.Message
-	queue    *Queue
-	history  *History
+	navBar    *twidgets.NavBar
+	status    *Status
+	mediaNav  *MediaNavigation
+	help      *modal.Help
+	message   *modal.Message
+	queue     *Queue
+	history   *History
+	downloads *Downloads
+	outbox    *Outbox
+	sessions  *Sessions
+	syncPlay  *SyncPlayView
+	stats     *ListeningStats
 
 	artistAlbumList *ArtistAlbumList
 	albumList       *AlbumList
@@ -69,19 +75,43 @@ type Window struct {
 	mediaView         Previous
 	mediaViewSelected bool
 
-	mediaPlayer interfaces.Player
-	mediaItems  interfaces.ItemController
-	mediaQueue  interfaces.QueueController
+	// view opened from media navigation, reloaded after library sync
+	mediaSelected     MediaSelect
+	mediaSelectedView Previous
+
+	// latest view and search requests, superseded requests are cancelled
+	viewRequest   *request
+	searchRequest *request
+
+	mediaPlayer    interfaces.Player
+	mediaItems     interfaces.ItemController
+	mediaQueue     interfaces.QueueController
+	mediaDownloads interfaces.DownloadController
+	mediaOutbox    interfaces.OutboxController
+	mediaStats     interfaces.ListeningHistory
+	mediaBuffers   interfaces.BufferController
+	connection     interfaces.Connection
+	library        interfaces.LibrarySync
+	mediaSessions  interfaces.SessionController
+	mediaSyncPlay  interfaces.SyncPlay
 
 	hasModal  bool
 	lastFocus cview.Primitive
+	// messages counts opened and closed messages, so that timed out server message closes only itself
+	messages int
 }
 
-func NewWindow(p interfaces.Player, i interfaces.ItemController, q interfaces.QueueController) Window {
+func NewWindow(p interfaces.Player, i interfaces.ItemController, q interfaces.QueueController,
+	d interfaces.DownloadController, o interfaces.OutboxController, b interfaces.BufferController,
+	h interfaces.ListeningHistory, c interfaces.Connection, s interfaces.LibrarySync,
+	r interfaces.SessionController, g interfaces.SyncPlay) Window {
 	w := Window{
 		app:    cview.NewApplication(),
 		status: newStatus(p),
 		layout: twidgets.NewModalLayout(),
+
+		viewRequest:   newRequest(),
+		searchRequest: newRequest(),
 	}
 
 	previousWidgets := make([]Previous, 0, 5)
@@ -130,6 +160,14 @@ func NewWindow(p interfaces.Player, i interfaces.ItemController, q interfaces.Qu
 	w.mediaPlayer = p
 	w.mediaItems = i
 	w.mediaQueue = q
+	w.mediaDownloads = d
+	w.mediaOutbox = o
+	w.mediaStats = h
+	w.mediaBuffers = b
+	w.connection = c
+	w.library = s
+	w.mediaSessions = r
+	w.mediaSyncPlay = g
 
 	w.setLayout()
 	w.app.SetRoot(w.layout, true)
@@ -159,6 +197,12 @@ func NewWindow(p interfaces.Player, i interfaces.ItemController, q interfaces.Qu
 			w.queue.list.SetSelected(index)
 		})
 	})
+	w.mediaBuffers.AddBufferChangedCallback(func() {
+		buffers := w.mediaBuffers.GetSongBuffers()
+		w.app.QueueUpdateDraw(func() {
+			w.queue.SetBuffers(buffers)
+		})
+	})
 
 	w.history = NewHistory()
 	previousWidgets = append(previousWidgets, w.history)
@@ -169,6 +213,46 @@ func NewWindow(p interfaces.Player, i interfaces.ItemController, q interfaces.Qu
 		})
 	})
 
+	w.downloads = NewDownloads(w.playSongs, w.mediaDownloads)
+	previousWidgets = append(previousWidgets, w.downloads)
+	w.mediaDownloads.AddDownloadsChangedCallback(w.downloadsChanged)
+
+	w.outbox = NewOutbox(w.mediaOutbox)
+	previousWidgets = append(previousWidgets, w.outbox)
+	w.mediaOutbox.AddOutboxChangedCallback(w.outboxChanged)
+
+	w.sessions = NewSessions(w.mediaSessions, w.mediaQueue)
+	w.sessions.refreshFunc = w.refreshSessions
+	previousWidgets = append(previousWidgets, w.sessions)
+
+	w.syncPlay = NewSyncPlayView(w.mediaSyncPlay)
+	w.syncPlay.refreshFunc = w.refreshSyncPlayGroups
+	previousWidgets = append(previousWidgets, w.syncPlay)
+	w.syncPlay.SetStatus(w.mediaSyncPlay.GetSyncPlayStatus())
+	w.status.SetSyncPlayStatus(w.mediaSyncPlay.GetSyncPlayStatus())
+	w.mediaSyncPlay.AddSyncPlayChangedCallback(w.syncPlayChanged)
+
+	w.stats = NewListeningStats(w.mediaStats)
+	previousWidgets = append(previousWidgets, w.stats)
+
+	w.status.SetOnline(w.connection.IsOnline())
+	w.connection.AddConnectionCallback(func(online bool) {
+		w.app.QueueUpdateDraw(func() {
+			w.status.SetOnline(online)
+		})
+	})
+	w.status.SetRemoteControlStatus(w.connection.GetRemoteControlStatus())
+	w.connection.AddRemoteControlCallback(func(status models.RemoteControlStatus) {
+		w.app.QueueUpdateDraw(func() {
+			w.status.SetRemoteControlStatus(status)
+		})
+	})
+	w.connection.AddMessageCallback(w.serverMessage)
+
+	w.status.SetSyncStatus(w.library.GetSyncStatus())
+	w.library.AddSyncChangedCallback(w.syncChanged)
+	w.library.AddLibraryChangedCallback(w.libraryChanged)
+
 	w.layout.Grid().SetBackgroundColor(config.Color.Background)
 	w.mediaPlayer.AddStatusCallback(w.statusCb)
 	navBarLabels := []string{"Help", "Queue", "History", "Search"}
@@ -293,9 +377,19 @@ func (w *Window) mediaCtrl(event *tcell.EventKey) bool {
 	case ctrls.Shuffle:
 		shuffle := !w.status.state.Shuffle
 		go w.mediaPlayer.SetShuffle(shuffle)
+	case ctrls.Radio:
+		radio := !w.status.state.Radio
+		go w.mediaPlayer.SetRadio(radio)
+	case ctrls.SleepTimer:
+		go w.mediaPlayer.SetSleepTimer(nextSleepTimer(w.status.state.SleepTimer))
+	case ctrls.StopAfterCurrent:
+		stop := w.status.state.SleepTimer.Mode != interfaces.SleepEndOfSong
+		go w.mediaPlayer.SetStopAfterCurrent(stop)
 	case ctrls.MuteUnmute:
 		mute := !w.status.state.Muted
 		go w.mediaPlayer.SetMute(mute)
+	case ctrls.SyncLibrary:
+		w.library.SyncNow()
 
 	default:
 		return false
@@ -365,21 +459,37 @@ func (w *Window) moveCtrl(key tcell.Key) bool {
 	return false
 }
 
+// searchCb searches items in background. New search cancels previous one.
 func (w *Window) searchCb(query string) {
 	logrus.Debug("In search callback")
+	ctx := w.searchRequest.next()
 	w.searchResultsTop.ClearResults()
 
-	for _, itemType := range config.AppConfig.Gui.SearchTypes {
-		items, err := w.mediaItems.Search(itemType, query)
-		if err == nil {
-			if len(items) > 0 {
-				w.searchResultsTop.addItems(itemType, items)
+	go func() {
+		types := config.AppConfig.Gui.SearchTypes
+		results := make([][]models.Item, len(types))
+		for i, itemType := range types {
+			items, err := w.mediaItems.Search(ctx, itemType, query)
+			if ctx.Err() != nil {
+				return
 			}
-		} else {
-			logrus.Errorf("search items of type %s: %v", itemType, err)
+			if err != nil {
+				logrus.Errorf("search items of type %s: %v", itemType, err)
+			}
+			results[i] = items
 		}
-	}
-	w.searchResultsTop.ResultsReady()
+		w.app.QueueUpdateDraw(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			for i, items := range results {
+				if len(items) > 0 {
+					w.searchResultsTop.addItems(types[i], items)
+				}
+			}
+			w.searchResultsTop.ResultsReady()
+		})
+	}()
 }
 
 func (w *Window) showSearchResults(itemType models.ItemType, results []models.Item, query string) {
@@ -507,10 +617,11 @@ func (w *Window) InitBrowser(items []models.Item) {
 func (w *Window) selectMedia(m MediaSelect) {
 	switch m {
 	case MediaLatestMusic:
-		albums, err := w.mediaItems.GetLatestAlbums()
-		if err != nil {
-			logrus.Errorf("get favorite artists: %v", err)
-		} else {
+		var albums []*models.Album
+		w.loadMedia(m, "latest albums", func(ctx context.Context) (err error) {
+			albums, err = w.mediaItems.GetLatestAlbums(ctx)
+			return
+		}, func() {
 			duration := 0
 			for _, v := range albums {
 				duration += v.Duration
@@ -525,47 +636,50 @@ func (w *Window) selectMedia(m MediaSelect) {
 			w.latestAlbums.Clear()
 			w.latestAlbums.SetAlbums(albums)
 			w.setViewWidget(w.latestAlbums, true)
-		}
+		})
+		return
 	case MediaFavoriteArtists:
-		artists, err := w.mediaItems.GetFavoriteArtists()
-		if err != nil {
-			logrus.Errorf("get favorite artists: %v", err)
-		} else {
+		var artists []*models.Artist
+		w.loadMedia(m, "favorite artists", func(ctx context.Context) (err error) {
+			artists, err = w.mediaItems.GetFavoriteArtists(ctx)
+			return
+		}, func() {
 			w.artistList.Clear()
 			w.artistList.SetText("Favorite artists")
 			w.artistList.EnablePaging(false)
 			w.mediaNav.SetCount(MediaFavoriteArtists, len(artists))
 			w.artistList.SetArtists(artists)
 			w.setViewWidget(w.artistList, true)
-		}
+		})
+		return
 	case MediaPlaylists:
-		playlists, err := w.mediaItems.GetPlaylists()
-		if err != nil {
-			logrus.Errorf("get playlists: %v", err)
-		} else {
+		var playlists []*models.Playlist
+		w.loadMedia(m, "playlists", func(ctx context.Context) (err error) {
+			playlists, err = w.mediaItems.GetPlaylists(ctx)
+			return
+		}, func() {
 			w.mediaNav.SetCount(MediaPlaylists, len(playlists))
 			w.playlists.SetPlaylists(playlists)
 			w.setViewWidget(w.playlists, true)
-		}
+		})
+		return
 	case MediaSongs, MediaRecent:
 		page := interfaces.DefaultPaging()
 		var songs []*models.Song
 		var count int
-		var err error
 
-		if m == MediaSongs {
-			songs, count, err = w.mediaItems.GetSongs(0, page.PageSize)
-			if err != nil {
-				logrus.Errorf("get songs: %v", err)
+		w.loadMedia(m, "songs", func(ctx context.Context) (err error) {
+			if m == MediaSongs {
+				songs, count, err = w.mediaItems.GetSongs(ctx, 0, page.PageSize)
 			} else {
+				songs, count, err = w.mediaItems.GetRecentlyPlayed(ctx, page)
+			}
+			return
+		}, func() {
+			if m == MediaSongs {
 				w.songs.showPage = w.selectSongs
 				w.mediaNav.SetCount(m, count)
 				w.songs.setTitle("All songs")
-			}
-		} else {
-			songs, count, err = w.mediaItems.GetRecentlyPlayed(page)
-			if err != nil {
-				logrus.Errorf("get songs: %v", err)
 			} else {
 				w.songs.showPage = w.showRecentSongsPage
 				w.songs.setTitle("Recently played")
@@ -573,90 +687,269 @@ func (w *Window) selectMedia(m MediaSelect) {
 					w.mediaNav.SetCount(m, count)
 				}
 			}
-		}
-		page.SetTotalItems(count)
-		w.songs.SetSongs(songs, page)
+			page.SetTotalItems(count)
+			w.songs.SetSongs(songs, page)
 
-		w.setViewWidget(w.songs, true)
+			w.setViewWidget(w.songs, true)
+		})
+		return
 	case MediaArtists, MediaAlbumArtists:
 		paging := interfaces.DefaultPaging()
 		opts := interfaces.DefaultQueryOpts()
 		var artists []*models.Artist
-		var err error
 		var total int
-		var title string
-		if m == MediaArtists {
-			title = "All artists"
-			artists, total, err = w.mediaItems.GetArtists(opts)
-		} else if m == MediaAlbumArtists {
+		title := "All artists"
+		if m == MediaAlbumArtists {
 			title = "All album artists"
-			artists, total, err = w.mediaItems.GetAlbumArtists(paging)
 		}
-		if err != nil {
-			logrus.Errorf("get all artists: %v", err)
+		w.loadMedia(m, "all artists", func(ctx context.Context) (err error) {
+			if m == MediaArtists {
+				artists, total, err = w.mediaItems.GetArtists(ctx, opts)
+			} else {
+				artists, total, err = w.mediaItems.GetAlbumArtists(ctx, paging)
+			}
 			return
-		}
-		paging.SetTotalItems(total)
-		w.mediaNav.SetCount(m, total)
+		}, func() {
+			paging.SetTotalItems(total)
+			w.mediaNav.SetCount(m, total)
 
-		w.artistList.Clear()
-		w.artistList.EnablePaging(true)
-		w.artistList.SetPage(paging)
+			w.artistList.Clear()
+			w.artistList.EnablePaging(true)
+			w.artistList.SetPage(paging)
 
-		w.artistList.SetArtists(artists)
-		w.setViewWidget(w.artistList, true)
-		w.artistList.SetText(fmt.Sprintf("%s: %d", title, paging.TotalItems))
+			w.artistList.SetArtists(artists)
+			w.setViewWidget(w.artistList, true)
+			w.artistList.SetText(fmt.Sprintf("%s: %d", title, paging.TotalItems))
+		})
+		return
 	case MediaAlbums, MediaFavoriteAlbums:
 		paging := interfaces.DefaultPaging()
 		opts := interfaces.DefaultQueryOpts()
 		var albums []*models.Album
-		var err error
 		var total int
-		var title string
-
+		title := "All Albums"
 		list := w.albumList
-
-		if m == MediaAlbums {
-			albums, total, err = w.mediaItems.GetAlbums(opts)
-			title = "All Albums"
-			w.albumList.EnablePaging(true)
-			w.albumList.EnableFilter(true)
-			w.albumList.EnableSorting(true)
-		} else if m == MediaFavoriteAlbums {
+		if m == MediaFavoriteAlbums {
 			paging.PageSize = 200
-			albums, total, err = w.mediaItems.GetFavoriteAlbums(paging)
 			title = "Favorite albums"
-			w.albumList.EnablePaging(false)
-			w.albumList.EnableFilter(false)
-			w.albumList.EnableSorting(false)
 			list = w.favoriteAlbums
 		}
 
+		w.loadMedia(m, title, func(ctx context.Context) (err error) {
+			if m == MediaAlbums {
+				albums, total, err = w.mediaItems.GetAlbums(ctx, opts)
+			} else {
+				albums, total, err = w.mediaItems.GetFavoriteAlbums(ctx, paging)
+			}
+			return
+		}, func() {
+			enable := m == MediaAlbums
+			w.albumList.EnablePaging(enable)
+			w.albumList.EnableFilter(enable)
+			w.albumList.EnableSorting(enable)
+
+			paging.SetTotalItems(total)
+			w.mediaNav.SetCount(m, total)
+
+			list.SetPage(paging)
+			list.Clear()
+			list.EnableSimilar(false)
+
+			list.SetText(fmt.Sprintf("%s\nTotal %v", title, paging.TotalItems))
+			list.SetAlbums(albums)
+			w.setViewWidget(list, true)
+		})
+		return
+	case MediaGenres:
+		paging := interfaces.DefaultPaging()
+		var genres []*models.IdName
+		var total int
+		w.loadMedia(m, "genres", func(ctx context.Context) (err error) {
+			genres, total, err = w.mediaItems.GetGenres(ctx, paging)
+			return
+		}, func() {
+			paging.SetTotalItems(total)
+			w.setGenres(genres, paging)
+		})
+		return
+	case MediaDownloads:
+		downloads := w.mediaDownloads.GetDownloads()
+		w.mediaNav.SetCount(MediaDownloads, len(downloads))
+		w.downloads.SetDownloads(downloads)
+		w.setViewWidget(w.downloads, true)
+	case MediaOutbox:
+		operations := w.mediaOutbox.GetOperations()
+		w.mediaNav.SetCount(MediaOutbox, len(operations))
+		w.outbox.SetOperations(operations)
+		w.setViewWidget(w.outbox, true)
+	case MediaSessions:
+		w.sessions.SetPending(nil)
+		w.showSessions()
+	case MediaSyncPlay:
+		w.setViewWidget(w.syncPlay, true)
+		w.refreshSyncPlayGroups()
+	case MediaListeningStats:
+		w.stats.Refresh()
+		w.setViewWidget(w.stats, true)
+	}
+	w.viewRequest.stop()
+	w.mediaSelected = m
+	w.mediaSelectedView = w.mediaView
+}
+
+// loadView fetches view contents in background and cancels previous view request.
+// Show is run in ui goroutine once fetch succeeds, unless another view has been requested meanwhile.
+func (w *Window) loadView(name string, fetch func(ctx context.Context) error, show func()) {
+	ctx := w.viewRequest.next()
+	go func() {
+		err := fetch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
-			logrus.Errorf("get %s: %v", title, err)
+			logrus.Errorf("get %s: %v", name, err)
 			return
 		}
-		paging.SetTotalItems(total)
-		w.mediaNav.SetCount(m, total)
+		w.app.QueueUpdateDraw(func() {
+			if ctx.Err() == nil {
+				show()
+			}
+		})
+	}()
+}
 
-		list.SetPage(paging)
-		list.Clear()
-		list.EnableSimilar(false)
+// loadMedia loads view selected from media navigation, see loadView.
+func (w *Window) loadMedia(m MediaSelect, name string, fetch func(ctx context.Context) error, show func()) {
+	w.loadView(name, fetch, func() {
+		show()
+		w.mediaSelected = m
+		w.mediaSelectedView = w.mediaView
+	})
+}
 
-		list.SetText(fmt.Sprintf("%s\nTotal %v", title, paging.TotalItems))
-		list.SetAlbums(albums)
-		w.setViewWidget(list, true)
-	case MediaGenres:
-		paging := interfaces.DefaultPaging()
-		w.showGenrePage(paging)
+// show library sync status
+func (w *Window) syncChanged(status models.SyncStatus) {
+	w.app.QueueUpdateDraw(func() {
+		w.status.SetSyncStatus(status)
+	})
+}
+
+// reload current view once library has changed
+func (w *Window) libraryChanged() {
+	w.app.QueueUpdateDraw(w.reloadView)
+}
+
+// reloadView reloads view opened from media navigation, if it's visible. Paged lists keep current page.
+func (w *Window) reloadView() {
+	if w.mediaView == nil || w.mediaView != w.mediaSelectedView {
+		return
+	}
+	switch w.mediaView {
+	case w.artistList:
+		if w.artistList.pagingEnabled {
+			w.artistList.selectPage(w.artistList.page.CurrentPage)
+			return
+		}
+	case w.albumList:
+		if w.albumList.pagingEnabled {
+			w.albumList.selectPage(w.albumList.page.CurrentPage)
+			return
+		}
+	case w.songs:
+		w.songs.selectPage(w.songs.page.CurrentPage)
+		return
+	case w.genres:
+		w.genres.selectPage(w.genres.page.CurrentPage)
+		return
 	}
+	w.selectMedia(w.mediaSelected)
+}
+
+// refresh downloads view, if it's visible
+func (w *Window) downloadsChanged() {
+	downloads := w.mediaDownloads.GetDownloads()
+	w.app.QueueUpdateDraw(func() {
+		w.mediaNav.SetCount(MediaDownloads, len(downloads))
+		if w.mediaView == w.downloads {
+			index := w.downloads.list.GetSelectedIndex()
+			w.downloads.SetDownloads(downloads)
+			w.downloads.list.SetSelected(index)
+		}
+	})
+}
+
+// show devices view and load sessions
+func (w *Window) showSessions() {
+	w.setViewWidget(w.sessions, true)
+	w.refreshSessions()
+}
+
+// load sessions in background and refresh devices view, if it's visible
+func (w *Window) refreshSessions() {
+	go func() {
+		sessions, err := w.mediaSessions.GetSessions()
+		if err != nil {
+			logrus.Errorf("get sessions: %v", err)
+			return
+		}
+		w.app.QueueUpdateDraw(func() {
+			w.mediaNav.SetCount(MediaSessions, len(sessions))
+			if w.mediaView == w.sessions {
+				index := w.sessions.list.GetSelectedIndex()
+				w.sessions.SetSessions(sessions)
+				w.sessions.list.SetSelected(index)
+			}
+		})
+	}()
+}
+
+// show SyncPlay group and refresh groups, if SyncPlay view is visible
+func (w *Window) syncPlayChanged(status models.SyncPlayStatus) {
+	w.app.QueueUpdateDraw(func() {
+		w.status.SetSyncPlayStatus(status)
+		w.syncPlay.SetStatus(status)
+	})
+	w.refreshSyncPlayGroups()
+}
+
+// load SyncPlay groups in background and refresh SyncPlay view, if it's visible
+func (w *Window) refreshSyncPlayGroups() {
+	go func() {
+		groups, err := w.mediaSyncPlay.GetSyncPlayGroups()
+		if err != nil {
+			logrus.Errorf("get SyncPlay groups: %v", err)
+			return
+		}
+		w.app.QueueUpdateDraw(func() {
+			w.mediaNav.SetCount(MediaSyncPlay, len(groups))
+			if w.mediaView == w.syncPlay {
+				index := w.syncPlay.list.GetSelectedIndex()
+				w.syncPlay.SetGroups(groups)
+				w.syncPlay.list.SetSelected(index)
+			}
+		})
+	}()
+}
+
+// refresh outbox view, if it's visible
+func (w *Window) outboxChanged() {
+	operations := w.mediaOutbox.GetOperations()
+	w.app.QueueUpdateDraw(func() {
+		w.mediaNav.SetCount(MediaOutbox, len(operations))
+		if w.mediaView == w.outbox {
+			index := w.outbox.list.GetSelectedIndex()
+			w.outbox.SetOperations(operations)
+			w.outbox.list.SetSelected(index)
+		}
+	})
 }
 
 func (w *Window) selectArtist(artist *models.Artist) {
-	albums, err := w.mediaItems.GetArtistAlbums(artist.Id)
-	if err != nil {
-		logrus.Errorf("get albumList albums: %v", err)
-	} else {
+	var albums []*models.Album
+	w.loadView("artist albums", func(ctx context.Context) (err error) {
+		albums, err = w.mediaItems.GetArtistAlbums(ctx, artist.Id)
+		return
+	}, func() {
 		artist.AlbumCount = len(albums)
 		w.artistAlbumList.Clear()
 		w.artistAlbumList.EnablePaging(false)
@@ -664,99 +957,98 @@ func (w *Window) selectArtist(artist *models.Artist) {
 		w.artistAlbumList.SetArtist(artist)
 		w.artistAlbumList.SetAlbums(albums)
 		w.setViewWidget(w.artistAlbumList, true)
-	}
+	})
 }
 
 func (w *Window) selectAlbum(album *models.Album) {
-	songs, err := w.mediaItems.GetAlbumSongs(album.Id)
-	if err != nil {
-		logrus.Errorf("get album songs: %v", err)
-	} else {
+	var songs []*models.Song
+	var artist *models.Artist
+	w.loadView("album songs", func(ctx context.Context) error {
+		var err error
+		songs, err = w.mediaItems.GetAlbumSongs(ctx, album.Id)
+		if err != nil {
+			return err
+		}
 		for _, v := range songs {
 			v.AlbumArtist = album.Artist
 		}
 
-		artist, err := w.mediaItems.GetAlbumArtist(album)
-		if err != nil {
+		artist, err = w.mediaItems.GetAlbumArtist(ctx, album)
+		if err != nil && ctx.Err() == nil {
 			logrus.Errorf("get album artist: %v", err)
-		} else {
+		}
+		return nil
+	}, func() {
+		if artist != nil {
 			w.album.SetArtist(artist)
 		}
-
 		w.album.SetAlbum(album, songs)
 		w.setViewWidget(w.album, true)
-	}
+	})
 }
 
 func (w *Window) selectPlaylist(playlist *models.Playlist) {
-	err := w.mediaItems.GetPlaylistSongs(playlist)
-	if err != nil {
-		logrus.Warningf("did not get playlist songs: %v", err)
-		return
-	}
-
-	w.playlist.SetPlaylist(playlist)
-	w.setViewWidget(w.playlist, true)
+	w.loadView("playlist songs", func(ctx context.Context) error {
+		return w.mediaItems.GetPlaylistSongs(ctx, playlist)
+	}, func() {
+		w.playlist.SetPlaylist(playlist)
+		w.setViewWidget(w.playlist, true)
+	})
 }
 
 func (w *Window) selectSongs(page interfaces.Paging) {
-	songs, _, err := w.mediaItems.GetSongs(page.CurrentPage, page.PageSize)
-	if err != nil {
-		logrus.Errorf("get songs: %v", err)
-	}
-
-	w.songs.SetSongs(songs, page)
-	w.setViewWidget(w.songs, true)
+	var songs []*models.Song
+	w.loadView("songs", func(ctx context.Context) (err error) {
+		songs, _, err = w.mediaItems.GetSongs(ctx, page.CurrentPage, page.PageSize)
+		return
+	}, func() {
+		w.songs.SetSongs(songs, page)
+		w.setViewWidget(w.songs, true)
+	})
 }
 
 func (w *Window) showRecentSongsPage(page interfaces.Paging) {
-	songs, _, err := w.mediaItems.GetRecentlyPlayed(page)
-	if err != nil {
-		logrus.Errorf("get songs: %v", err)
-	}
-
-	w.songs.SetSongs(songs, page)
-	w.setViewWidget(w.songs, true)
+	var songs []*models.Song
+	w.loadView("songs", func(ctx context.Context) (err error) {
+		songs, _, err = w.mediaItems.GetRecentlyPlayed(ctx, page)
+		return
+	}, func() {
+		w.songs.SetSongs(songs, page)
+		w.setViewWidget(w.songs, true)
+	})
 }
 
 func (w *Window) showArtistPage(page interfaces.Paging) {
 	opts := interfaces.DefaultQueryOpts()
 	opts.Paging = page
-	artists, _, err := w.mediaItems.GetArtists(opts)
-	if err != nil {
-		logrus.Errorf("get albumList page: %v", err)
-		return
-	}
-
-	w.artistList.Clear()
-	w.artistList.SetArtists(artists)
-	w.artistList.EnablePaging(true)
-	w.setViewWidget(w.artistList, false)
+	w.queryArtists(opts)
 }
 
 func (w *Window) queryArtists(opts *interfaces.QueryOpts) {
-	artists, _, err := w.mediaItems.GetArtists(opts)
-	if err != nil {
-		logrus.Errorf("get albumList page: %v", err)
+	var artists []*models.Artist
+	w.loadView("artists page", func(ctx context.Context) (err error) {
+		artists, _, err = w.mediaItems.GetArtists(ctx, opts)
 		return
-	}
-
-	w.artistList.Clear()
-	w.artistList.SetArtists(artists)
-	w.artistList.EnablePaging(true)
-	w.setViewWidget(w.artistList, false)
+	}, func() {
+		w.artistList.Clear()
+		w.artistList.SetArtists(artists)
+		w.artistList.EnablePaging(true)
+		w.setViewWidget(w.artistList, false)
+	})
 }
 
 func (w *Window) showAlbumPage(opts *interfaces.QueryOpts) {
-	albums, total, err := w.mediaItems.GetAlbums(opts)
-	if err != nil {
-		logrus.Errorf("get all albums: %v", err)
+	var albums []*models.Album
+	var total int
+	w.loadView("all albums", func(ctx context.Context) (err error) {
+		albums, total, err = w.mediaItems.GetAlbums(ctx, opts)
 		return
-	}
-	opts.Paging.SetTotalItems(total)
-	w.mediaNav.SetCount(MediaAlbums, total)
-	w.albumList.SetPage(opts.Paging)
-	w.albumList.SetAlbums(albums)
+	}, func() {
+		opts.Paging.SetTotalItems(total)
+		w.mediaNav.SetCount(MediaAlbums, total)
+		w.albumList.SetPage(opts.Paging)
+		w.albumList.SetAlbums(albums)
+	})
 }
 
 func (w *Window) openFilterModal(m modal.Modal, doneFunc func()) {
@@ -784,24 +1076,33 @@ func (w *Window) clearQueue() {
 }
 
 func (w *Window) showSimilarArtists(artist models.Id) {
-	artists, err := w.mediaItems.GetSimilarArtists(artist)
-	if err != nil {
-		logrus.Errorf("get similar artists: %v", err)
-	} else if len(artists) > 0 {
+	var artists []*models.Artist
+	w.loadView("similar artists", func(ctx context.Context) (err error) {
+		artists, err = w.mediaItems.GetSimilarArtists(ctx, artist)
+		return
+	}, func() {
+		if len(artists) == 0 {
+			w.showMessage("No similar artists", 3, -1, false)
+			return
+		}
+		w.mediaSelectedView = nil
 		w.artistList.Clear()
 		w.artistList.SetArtists(artists)
 		w.artistList.SetText(fmt.Sprintf("Similar artists: %d", len(artists)))
 		w.setViewWidget(w.artistList, true)
-	} else {
-		w.showMessage("No similar artists", 3, -1, false)
-	}
+	})
 }
 
 func (w *Window) showSimilarAlbums(album *models.Album) {
-	albums, err := w.mediaItems.GetSimilarAlbums(album.Id)
-	if err != nil {
-		logrus.Errorf("get similar artists: %v", err)
-	} else if len(albums) > 0 {
+	var albums []*models.Album
+	w.loadView("similar albums", func(ctx context.Context) (err error) {
+		albums, err = w.mediaItems.GetSimilarAlbums(ctx, album.Id)
+		return
+	}, func() {
+		if len(albums) == 0 {
+			w.showMessage("No similar albums", 3, -1, false)
+			return
+		}
 		w.similarAlbums.Clear()
 		w.similarAlbums.EnableSimilar(false)
 		w.similarAlbums.EnablePaging(false)
@@ -809,15 +1110,43 @@ func (w *Window) showSimilarAlbums(album *models.Album) {
 		w.similarAlbums.SetAlbums(albums)
 		w.similarAlbums.SetText(fmt.Sprintf("Similar albums: %d", len(albums)))
 		w.setViewWidget(w.similarAlbums, true)
-	} else {
-		w.showMessage("No similar albums", 3, -1, false)
-	}
+	})
 }
 
 func (w *Window) closeMessage() {
+	w.messages++
 	w.closeModal(w.message)
 }
 
+// serverMessage shows message from server and closes it after timeout, if there is one.
+func (w *Window) serverMessage(msg models.ServerMessage) {
+	w.app.QueueUpdateDraw(func() {
+		if w.hasModal {
+			if w.modal != w.message {
+				logrus.Warningf("cannot show message from server while other dialog is open: %s", msg.Text)
+				return
+			}
+			w.closeMessage()
+		}
+		text := msg.Text
+		if msg.Header != "" {
+			text = msg.Header + "\n\n" + msg.Text
+		}
+		w.showMessage(text, 10, -1, false)
+		if msg.Timeout <= 0 {
+			return
+		}
+		id := w.messages
+		time.AfterFunc(msg.Timeout, func() {
+			w.app.QueueUpdateDraw(func() {
+				if w.messages == id && w.modal == w.message {
+					w.closeMessage()
+				}
+			})
+		})
+	})
+}
+
 func (w *Window) showMessage(msg string, height, width int, lockSize bool) {
 	w.message.SetText(msg)
 	if height == -1 {
@@ -830,33 +1159,39 @@ func (w *Window) showMessage(msg string, height, width int, lockSize bool) {
 }
 
 func (w *Window) selectGenre(id models.IdName) {
-
-	albums, err := w.mediaItems.GetGenreAlbums(id)
-	if err != nil {
-		logrus.Errorf("get genre albums: %v", err)
+	var albums []*models.Album
+	w.loadView("genre albums", func(ctx context.Context) (err error) {
+		albums, err = w.mediaItems.GetGenreAlbums(ctx, id)
 		return
-	}
-
-	w.albumList.Clear()
-	w.albumList.EnablePaging(false)
-	w.albumList.EnableSimilar(false)
-	w.albumList.EnableFilter(false)
-	w.albumList.EnableSorting(false)
-	w.albumList.SetAlbums(albums)
-	w.albumList.SetText("Genre " + id.Name)
-	w.setViewWidget(w.albumList, true)
+	}, func() {
+		w.mediaSelectedView = nil
+		w.albumList.Clear()
+		w.albumList.EnablePaging(false)
+		w.albumList.EnableSimilar(false)
+		w.albumList.EnableFilter(false)
+		w.albumList.EnableSorting(false)
+		w.albumList.SetAlbums(albums)
+		w.albumList.SetText("Genre " + id.Name)
+		w.setViewWidget(w.albumList, true)
+	})
 }
 
 func (w *Window) showGenrePage(paging interfaces.Paging) {
-	genres, n, err := w.mediaItems.GetGenres(paging)
-	if err != nil {
-		logrus.Errorf("get genres: %v", err)
+	var genres []*models.IdName
+	var total int
+	w.loadView("genres", func(ctx context.Context) (err error) {
+		genres, total, err = w.mediaItems.GetGenres(ctx, paging)
 		return
-	}
-	paging.SetTotalItems(n)
+	}, func() {
+		paging.SetTotalItems(total)
+		w.setGenres(genres, paging)
+	})
+}
+
+func (w *Window) setGenres(genres []*models.IdName, paging interfaces.Paging) {
 	w.genres.SetPage(paging)
 	w.genres.setGenres(genres)
-	w.genres.description.SetText(fmt.Sprintf("Genres: total %d", n))
+	w.genres.description.SetText(fmt.Sprintf("Genres: total %d", paging.TotalItems))
 	w.setViewWidget(w.genres, true)
 }
 